@@ -1,372 +1,655 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"image"
-	"image/color"
-	"math"
 	"os"
 	"path/filepath"
-	"runtime"
-	"sync"
+	"strconv"
+	"strings"
 
-	"github.com/disintegration/imaging"
-	"github.com/gen2brain/go-fitz"
-	"github.com/phpdave11/gofpdf"
+	"PdfDiff/pdfdiff"
+	"PdfDiff/pdfdiff/remote"
 )
 
-// Mutex to avoid race conditions when multiple goroutines access the same memory
-var mutex = &sync.Mutex{}
-
-// Brightness calculates the perceived brightness of a color. It uses an algorithm that approximates human perception
-func brightness(c color.Color) uint8 {
-	r, g, b, _ := c.RGBA()
-	return uint8((r*19595 + g*38470 + b*7471) >> 16) // Perform the brightness calculation using integer arithmetic to maintain precision and avoid floating point calculations, which are slower in Go compared with bitwise operations. The coefficients used here (19595 for red, 38470 for green, and 7471 for blue) were chosen based on a study of human color perception that approximates the luma or luminance value more accurately than simple calculations would suggest.
-}
-
-// worker is a function that will be run in a separate goroutine. It processes jobs from the jobs channel and sends a signal to the done channel when it finishes a job.
-// It takes images from two PDF documents and compares them, creating a new image that highlights the differences.
-func worker(id int, jobs <-chan int, done chan<- bool, doc1 *fitz.Document, doc2 *fitz.Document, mergeFlag *bool, offset int, startOffset int, totalOps int, sideBySideFlag *bool, verticalAlignFlag *bool) {
-	for j := range jobs {
-		var img1, img2 image.Image
-		var err error
-
-		// If we've reached the startOffset, create images for the pages from startOffset to startOffset+offset in file2
-		if j == startOffset {
-			for i := startOffset; i < startOffset+offset; i++ {
-				if i < doc2.NumPage() {
-					mutex.Lock()
-					img, err := doc2.Image(i - 1)
-					mutex.Unlock()
-					if checkError(err) != nil {
-						continue
-					}
-					imgPath := fmt.Sprintf("differences_%d.png", i)
-					err = imaging.Save(img, imgPath)
-					if checkError(err) != nil {
-						continue
-					}
-				}
-			}
-		}
-
-		// Extract the images from the PDFs or create a white image if the page does not exist
-		if j < doc1.NumPage() {
-			mutex.Lock()
-			img1, err = doc1.Image(j)
-			mutex.Unlock()
-			if checkError(err) != nil {
-				continue
-			}
-		} else {
-			img1 = image.NewRGBA(image.Rect(0, 0, 595, 842)) // dimensions of an A4 page in points
-		}
-
-		pagToCompare := j
-		if j >= startOffset {
-			pagToCompare = j + offset
-		}
-
-		if pagToCompare < doc2.NumPage() {
-			mutex.Lock()
-			img2, err = doc2.Image(pagToCompare)
-			mutex.Unlock()
-			if checkError(err) != nil {
-				continue
-			}
-		} else {
-			img2 = image.NewRGBA(image.Rect(0, 0, 595, 842)) // dimensions of an A4 page in points
-		}
-
-		// Create an image to show the differences
-		bounds := img1.Bounds()
-		diffImg := image.NewRGBA(bounds)
-		parallelism := 2 // Number of Goroutines to use
-		var wg sync.WaitGroup
-
-		for p := 0; p < parallelism; p++ {
-			wg.Add(1)
-			go func(p int) {
-				defer wg.Done()
-				for y := bounds.Min.Y + p; y < bounds.Max.Y; y += parallelism {
-					for x := bounds.Min.X; x < bounds.Max.X; x++ {
-						c1 := img1.At(x, y)
-						c2 := img2.At(x, y)
-						// Check if the pixels at the same position in both images are different
-						if c1 != c2 {
-							// If the pixels are different, color the pixel depending on which image has the brighter pixel
-							// The brightness is calculated as the sum of the squares of the RGB components
-							b1 := brightness(c1)
-							b2 := brightness(c2)
-							if b1 > b2 {
-								// If the pixel in the first image is brighter, color the pixel in the difference image red
-								diffImg.Set(x, y, color.RGBA{255, 0, 0, 255}) // red for image 1
-							} else {
-								// If the pixel in the second image is brighter, color the pixel in the difference image blue
-								diffImg.Set(x, y, color.RGBA{0, 0, 255, 255}) // blue for image 2
-							}
-						} else {
-							// If the pixels are the same, use the original pixel in the difference image
-							diffImg.Set(x, y, c1)
-						}
-					}
-				}
-			}(p)
-		}
-		wg.Wait()
+// Exit codes of the tool, so scripts and CI jobs can tell differences apart from failures
+const (
+	exitIdentical  = 0 // the documents have no differences
+	exitDifferent  = 1 // the documents have differences
+	exitUsage      = 2 // the command line or the options are invalid (also used by the flag package)
+	exitInput      = 3 // an input is missing, unsupported or cannot be opened
+	exitRender     = 4 // a page cannot be rendered or compared
+	exitOutput     = 5 // an output file cannot be written
+	exitIncomplete = 6 // the deadline passed before every page was compared
+)
 
-		// Save the difference image
-		diffImgPath := fmt.Sprintf("differences_%d.png", j)
-		if j >= startOffset {
-			diffImgPath = fmt.Sprintf("differences_%d.png", j+offset)
+func main() {
+	// Dispatch to the requested subcommand. Invoking the tool without a subcommand runs a comparison,
+	// which keeps the original command line working.
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "compare", "verify":
+			runCompare(args[1:])
+			return
+		case "render":
+			runRender(args[1:])
+			return
+		case "report":
+			runReport(args[1:])
+			return
+		case "merge":
+			runMerge(args[1:])
+			return
+		case "batch":
+			runBatch(args[1:])
+			return
+		case "align":
+			runAlign(args[1:])
+			return
+		case "import-annotations":
+			runImportAnnotations(args[1:])
+			return
+		case "serve":
+			runServe(args[1:])
+			return
+		case "approve":
+			runApprove(args[1:])
+			return
+		case "config-diff":
+			runConfigDiff(args[1:])
+			return
+		case "gui":
+			runGUI(args[1:])
+			return
+		case "schema":
+			// Print the JSON schema of the machine-readable outputs
+			os.Stdout.Write(pdfdiff.Schema)
+			return
+		case "proto":
+			// Print the protobuf definition of the public gRPC service
+			os.Stdout.Write(remote.Proto)
+			return
 		}
-		err = imaging.Save(diffImg, diffImgPath)
-		if checkError(err) != nil {
-			continue
-		}
-		// Save the combined image in the same page if sidebyside enabled
-		if *sideBySideFlag {
-			var combinedWidth, combinedHeight int
-			//Combine imege verticaly or horizontally
-			if *verticalAlignFlag {
-				// For vertical alignment
-				combinedWidth = max(img1.Bounds().Dx(), img2.Bounds().Dx())
-				combinedHeight = img1.Bounds().Dy() + img2.Bounds().Dy()
-			} else {
-				// For horizontal alignment
-				combinedWidth = img1.Bounds().Dx() + img2.Bounds().Dx()
-				combinedHeight = max(img1.Bounds().Dy(), img2.Bounds().Dy())
-			}
-
-			combinedImg := image.NewRGBA(image.Rect(0, 0, combinedWidth, combinedHeight))
-
-			// Copy img1 to combinedImg
-			for y := 0; y < img1.Bounds().Dy(); y++ {
-				for x := 0; x < img1.Bounds().Dx(); x++ {
-					combinedImg.Set(x, y, img1.At(x, y))
-				}
-			}
-
-			if *verticalAlignFlag {
-				// Copy img2 to combinedImg for vertical alignment
-				for y := 0; y < img2.Bounds().Dy(); y++ {
-					for x := 0; x < img2.Bounds().Dx(); x++ {
-						combinedImg.Set(x, y+img1.Bounds().Dy(), img2.At(x, y))
-					}
-				}
-			} else {
-				// Copy img2 to combinedImg for horizontal alignment
-				for y := 0; y < img2.Bounds().Dy(); y++ {
-					for x := 0; x < img2.Bounds().Dx(); x++ {
-						combinedImg.Set(x+img1.Bounds().Dx(), y, img2.At(x, y))
-					}
-				}
-			}
+	}
+	runCompare(args)
+}
 
-			// Save the combined image
-			combinedImgPath := fmt.Sprintf("combined_%d.png", j)
-			err = imaging.Save(combinedImg, combinedImgPath)
-			if checkError(err) != nil {
-				continue
-			}
+// parseArgs parses the flags of a subcommand and returns its positional arguments.
+// Unlike flag.Parse it also accepts flags after the positional arguments (e.g. "render file.pdf -dpi 150").
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
 		}
-
-		// Signal that the job is done
-		done <- true
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 }
 
-func main() {
+// runCompare parses the compare flags and compares the two documents page by page
+func runCompare(args []string) {
 	// Define the flags
-	mergeFlag := flag.Bool("merge", false, "merge the difference images into a single PDF")
-	cleanFlag := flag.Bool("clean", false, "remove the difference images after processing")
-	offsetFlag := flag.Int("offset", 0, "the number of pages to skip in the second PDF")
-	startOffsetFlag := flag.Int("startoffset", 0, "the page of the first PDF to start the offset")
-	orientationFlag := flag.String("orientation", "", "the orientation of the PDF (P for portrait, L for landscape)")
-	printSizeFlag := flag.String("printsize", "A3", "Size of printed PDF A4,A3,A2...")
-	outputFlag := flag.String("output", "differences.pdf", "the name of the output PDF file")
-	workersFlag := flag.Int("workers", 0, "the number of workers to use. (Default: CPU Count)")
-	sideBySideFlag := flag.Bool("sidebyside", false, "create a side-by-side comparison of the two PDFs")
-	verticalAlignFlag := flag.Bool("verticalalign", false, "align the documents vertically in the combined image")
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	mergeFlag := flags.Bool("merge", false, "merge the difference images into a single PDF")
+	cleanFlag := flags.Bool("clean", false, "remove the difference images after processing")
+	inMemoryFlag := flags.Bool("in-memory", false, "keep the page images in memory and only write the requested outputs, leaving no image files behind")
+	offsetFlag := flags.Int("offset", 0, "the number of pages to skip in the second document")
+	startOffsetFlag := flags.Int("startoffset", 0, "the page of the first document to start the offset")
+	alignFlag := flags.Bool("align", false, "pair the pages automatically, finding the inserted and deleted pages instead of using -offset")
+	page1Flag := flags.Int("page1", 0, "only compare this page of the first document, e.g. against an image of it (Default: 1 with -page2)")
+	page2Flag := flags.Int("page2", 0, "only compare this page of the second document, e.g. proof.png book.pdf -page2 7 (Default: 1 with -page1)")
+	orientationFlag := flags.String("orientation", pdfdiff.Auto, "the orientation of the PDF (P for portrait, L for landscape, first for the orientation of the first page, auto to turn every page like its image)")
+	printSizeFlag := flags.String("printsize", pdfdiff.Auto, "Size of printed PDF A4,A3,A2..., or auto for the A size closest to most pages")
+	tilePrintFlag := flags.String("tile-print", "", "split the difference pages larger than this A format, e.g. A4, into overlapping tiles at their actual size with assembly marks, to print drawings without a plotter")
+	outputFlag := flags.String("output", "differences.pdf", "the name of the output PDF file")
+	workersFlag := flags.Int("workers", 0, "the number of workers to use. (Default: CPU Count)")
+	workdirFlag := flags.String("workdir", "", "the directory where the page images and the manifest are written (Default: a new temporary directory)")
+	flags.StringVar(workdirFlag, "outdir", "", "the same as -workdir, named like in the batch and render subcommands")
+	prefixFlag := flags.String("prefix", "", "prepended to the names of the page images, e.g. invoiceA_vs_invoiceB_, so several comparisons can share a directory")
+	dpiFlag := flags.Float64("dpi", pdfdiff.DefaultDPI, "the resolution used to rasterize the pages, higher to catch hairline changes, lower for speed and smaller images")
+	multiDPIFlag := flags.String("multi-dpi", "", "compare at several resolutions, e.g. 72,150,300, and only report the differences found at all of them; the images are written at the highest")
+	sideBySideFlag := flags.Bool("sidebyside", false, "create a side-by-side comparison of the two documents")
+	verticalAlignFlag := flags.Bool("verticalalign", false, "align the documents vertically in the combined image")
+	htmlFlag := flags.String("html", "", "also write a self-contained HTML report with a viewer for every page, e.g. report.html")
+	jsonFlag := flags.String("json", "", "also write the per-page statistics as a JSON report, e.g. report.json")
+	csvFlag := flags.String("csv", "", "also write the metrics of every page to a CSV file, e.g. metrics.csv: the pixels compared and changed, the percentage, the SSIM and the paths of the images")
+	sarifFlag := flags.String("sarif", "", "also write the changed regions as the results of a SARIF log, e.g. pdfdiff.sarif, for the platforms that ingest code scanning results")
+	junitFlag := flags.String("junit", "", "also write a JUnit XML report, e.g. junit.xml, with a test case per page failing when it changed (or is below -min-ssim), for the test result views of CI systems")
+	regionThumbnailsFlag := flags.Int("region-thumbnails", 0, "embed a thumbnail of every changed region in the JSON report, at most this many pixels wide and high, e.g. 128")
+	priorityFlag := flags.Bool("priority", false, "compare the pages most likely to have changed first and print each changed page as soon as it is found")
+	remoteFlag := flags.String("remote", "", "compare on remote workers started with the serve subcommand, e.g. host1:50051,host2:50051")
+	chunkFlag := flags.Int("chunk", remote.DefaultChunkSize, "the number of pages sent to a remote worker at a time")
+	remoteTLSFlag := flags.Bool("remote-tls", false, "connect to the remote workers over TLS, verifying their certificates with the system roots")
+	remoteCAFlag := flags.String("remote-ca", "", "connect to the remote workers over TLS, verifying their certificates with the CA certificates of this PEM file")
+	remoteTokenFlag := flags.String("remote-token-file", "", "send the bearer token of this file to the remote workers, the -token-file of serve")
+	thresholdFlag := flags.Int("threshold", defaultThreshold(), "the largest difference of a color channel (0-255) still considered equal (Default: $PDFDIFF_THRESHOLD or 0)")
+	adaptiveFlag := flags.Bool("adaptive-threshold", false, "raise the threshold of every page to the noise measured in its margins, for scans and rasterizer noise")
+	ignoreAAFlag := flags.Bool("ignore-antialiasing", false, "ignore differences along glyph and line edges that moved by at most one pixel")
+	minRegionSizeFlag := flags.String("min-region-size", "", "discard the changed regions of fewer changed pixels, in pixels (e.g. 20) or square millimeters (e.g. 0.5mm2), so dust specks and rasterization jitter do not mark a page as changed")
+	minSSIMFlag := flags.Float64("min-ssim", 0, "fail only if the structural similarity of a page is below this value, e.g. 0.98, instead of on any changed pixel")
+	tuiFlag := flags.Bool("tui", false, "browse the pages and preview their difference images in the terminal once the comparison is done, e.g. over SSH")
+	failFastFlag := flags.Bool("fail-fast", false, "stop comparing at the first page that fails the run, cancelling the pages in flight, and exit with 1, for the jobs that only need to know whether the documents differ")
+	deadlineFlag := flags.Duration("deadline", 0, "stop comparing at this deadline, e.g. 10m, report the pages completed by then and exit with 6 (incomplete)")
+	maxDiffPercentFlag, maxPageDiffPercentFlag := budgetFlags(flags)
+	allowChangedPagesFlag := flags.Int("allow-changed-pages", 0, "pass if at most this many pages have differences (or are below -min-ssim), e.g. 1 for a generated index that changes on every run")
+	summaryFlag, summaryTopFlag := summaryFlags(flags)
+	changedOnlyFlag := flags.Bool("changed-only", false, "only write the images of the pages with differences and merge those pages, each stamped with its page number")
+	summaryPageFlag := flags.Bool("summary-page", false, "begin the merged PDFs with a page describing the comparison: the documents, their page counts and the changes of every page")
+	skipIdenticalFlag := flags.Bool("skip-identical", false, "do not write the difference images of identical pages, so -merge only contains the changed pages")
+	archiveFlag := flags.String("archive", "", "also package the merged PDFs, the page images and the reports in a ZIP archive, e.g. out.zip, to attach to a ticket or an email")
+	overviewFlag := flags.String("overview", "", "also write all the pages on a single A0 sheet, tinted by how much they changed, to print for a wall, as a PDF (.pdf) or an image, e.g. overview.png")
+	annotationsFlag := flags.String("annotations", "", "also export the changed regions as annotations to import into the second document, as XFDF (.xfdf) or FDF (.fdf)")
+	colorOldFlag := flags.String("color-old", pdfdiff.DefaultColorOld, "the color of the changed pixels where the first document is brighter, RRGGBB or RRGGBBAA for a translucent overlay")
+	colorNewFlag := flags.String("color-new", pdfdiff.DefaultColorNew, "the color of the changed pixels where the second document is brighter, RRGGBB or RRGGBBAA for a translucent overlay")
+	watermarkFlag := flags.Bool("remove-watermarks", false, "remove a light watermark repeated on every page of one document, such as DRAFT, before comparing")
+	descreenFlag := flags.Bool("descreen", false, "blur the halftones of scanned print material on both pages before comparing, so rescreened pictures are not reported as changed")
+	despeckleFlag := flags.Bool("despeckle", false, "remove specks of scanner dust smaller than a period from both pages before comparing")
+	typographyFlag := flags.Bool("typography", false, "find the widows, the orphans and the lines of text overflowing the page box of both documents, and fail the run on the ones the second document introduced")
+	glyphsFlag := flags.Bool("glyphs", false, "pair the glyphs of the text of both documents and report the changes of their shape, baseline and spacing, for font and typesetting regression testing")
+	vectorOverlayFlag := flags.String("vector-overlay", "", "compare the vector paths of the drawings of both pages and write the paths added and removed as an overlay for CAD tools, svg or dxf, e.g. vectors_0.dxf")
+	dimensionsFlag := flags.Bool("dimensions", false, "read the dimensions of technical drawings, e.g. 120 mm, and report the changed values, e.g. dimension 120 mm → 125 mm")
+	equationsFlag := flags.Bool("equations", false, "compare the equations at a higher resolution with a relaxed tolerance on their position, so math typeset again is not reported as changed")
+	chartsFlag := flags.Bool("charts", false, "compare the bars, lines and axes of vector charts and report their changes, e.g. bar 3 of chart 1 grew 12.0%")
+	segmentFlag := flags.Bool("segment", false, "split the pages into text, image and graphics zones and report how much of each type of content changed")
+	backgroundFlag := flags.Bool("normalize-background", false, "whiten the paper of pages whose background colors differ, e.g. a gray scan, and report the change once per page")
+	highlightStyleFlag := flags.String("highlight-style", pdfdiff.HighlightPixels, "pixels to color the changed pixels, or boxes to draw a rectangle around every changed region over the second page, which stays legible")
+	boxWidthFlag := flags.Int("box-width", 0, "the width in pixels of the rectangles of -highlight-style boxes (Default: a quarter of a millimeter)")
+	heatmapFlag := flags.Bool("heatmap", false, "color the changed pixels by how much they changed, from blue for subtle shade shifts to red for content changes")
+	ignoreRegionsFlag := ignoreRegionsFlag(flags)
+	criticalRegionsFlag := flags.String("critical-regions", "", "regions of mask files, separated by commas, that must match exactly, e.g. critical.yaml: any changed pixel inside them fails the run, whatever the threshold")
+	templateFlag := flags.String("template", "", "a file of named fields, such as the invoice number, the total or the dates, e.g. invoice.yaml: their values are read from the text of both documents (or with -ocr) and compared by type")
+	quarantineFlag, reportQuarantineFlag := quarantineFlags(flags)
+	ignoreTextFlag := ignoreTextFlag(flags)
+	cropFlag := cropFlags(flags)
+	reflowFlag := reflowFlags(flags)
+	textFlag := flags.Bool("text", false, "also compare the extracted text of the pages and report the words inserted and deleted, next to the difference images in the HTML report")
+	ocrFlag := flags.Bool("ocr", false, "read the text of scanned pages with Tesseract, which must be installed, report the words inserted and deleted and do not compare the pixels of the unchanged words")
+	ocrLangFlag := flags.String("ocr-lang", "", "the Tesseract language of the documents, e.g. deu or eng+fra (Default: eng)")
+	languageFlag := flags.String("language", "", "the language of the text of the documents: auto to detect it on every page, a code such as deu, or one for each document such as eng,deu; it selects the normalization of the text of -text and -ocr and the Tesseract pack of -ocr")
+	embeddedFontsFlag := flags.Bool("embedded-fonts-only", false, "refuse to compare PDFs with pages whose fonts are not embedded, which render differently on machines with other fonts")
+	preflightFlag := flags.Bool("preflight", false, "render the first page of each document twice before comparing and warn if the renderer does not draw it the same way twice")
+	accessibilityFlag := flags.Bool("accessibility", false, "also compare the accessibility signals of the PDFs (tagging, language, alternate texts of the figures, reading order) and fail on the regressions of the second one")
+	thumbnailsFlag := flags.Bool("thumbnails", false, "also check that the thumbnails embedded in the PDFs still show their pages")
+	password1Flag, password2Flag := passwordFlags(flags)
+	niceFlag := flags.Int("nice", 0, "lower the CPU priority of the comparison, from 1 to 19 like nice(1)")
+	heartbeatFlag, heartbeatTextFlag := heartbeatFlags(flags)
+	interactiveFlag := flags.Bool("interactive", false, "ask for the documents, the resolution and whether to merge when they are not given")
+	watchFlag := flags.Bool("watch", false, "compare the documents again every time one of them changes, until interrupted")
+	storeFlag := storeFlag(flags)
+	uploadFlag := flags.String("upload", "", "upload the outputs, the page images and the manifest below a URL, e.g. s3://bucket/run-42/, gs://bucket/run-42/ or an https URL they are PUT to")
+	updateOnApproveFlag := flags.Bool("update-on-approve", false, "when the document differs from its baseline, propose its renders as the new baseline for review instead of failing")
 
 	// Parse the flags
-	flag.Parse()
+	flags.Parse(args)
+	files := flags.Args()
+	if *interactiveFlag {
+		files = askOptions(flags, files)
+	}
 
-	// Check if the workers flag has been set
-	if *workersFlag == 0 {
-		*workersFlag = runtime.NumCPU()
+	// Check that at least two arguments have been passed
+	if len(files) < 2 {
+		fmt.Println("Usage: [compare] [-merge] [-clean] [-in-memory] [-printsize auto|A4|A3|A2|A1|A0] [-tile-print A4] [-offset n] [-startoffset n] [-align] [-page1 n] [-page2 n] [-orientation auto|first|P|L] [-output output.pdf] [-workdir dir] [-prefix name_] [-workers n] [-dpi 300] [-multi-dpi 72,150,300] [-html report.html] [-json report.json] [-junit junit.xml] [-sarif pdfdiff.sarif] [-csv metrics.csv] [-region-thumbnails n] [-threshold n] [-adaptive-threshold] [-ignore-antialiasing] [-min-region-size px|mm2] [-min-ssim n] [-max-diff-percent n] [-max-page-diff-percent n] [-allow-changed-pages n] [-fail-fast] [-deadline 10m] [-skip-identical] [-changed-only] [-summary-page] [-color-old RRGGBB] [-color-new RRGGBB] [-heatmap] [-highlight-style pixels|boxes] [-box-width n] [-remove-watermarks] [-normalize-background] [-despeckle] [-descreen] [-segment] [-charts] [-vector-overlay svg|dxf] [-dimensions] [-glyphs] [-typography] [-annotations diff.xfdf] [-archive out.zip] [-ignore-regions pdfdiff_ignore.json] [-critical-regions critical.yaml] [-template fields.yaml] [-quarantine quarantine.txt] [-report-quarantine] [-ignore-text pattern]... [-crop-top mm|%] [-crop-bottom mm|%] [-crop-left mm|%] [-crop-right mm|%] [-reflow-size 450x600] [-reflow-font-size 12] [-thumbnails] [-accessibility] [-preflight] [-embedded-fonts-only] [-text] [-ocr] [-ocr-lang eng] [-language auto|code] [-password1 password|-] [-password2 password|-] [-summary summary.md|.txt|.pdf] [-summary-top n] [-overview overview.pdf|.png] [-priority] [-nice n] [-remote host:port,...] [-chunk n] [-remote-tls] [-remote-ca ca.pem] [-remote-token-file token.txt] [-interactive] [-tui] [-watch] [-store dir|lfs:path|URL] [-upload s3://bucket/prefix/] [-update-on-approve] <file1> <file2> [file3...]")
+		fmt.Println("Supported input formats: PDF, EPUB, XPS, OXPS, FB2, images (PNG, JPEG, GIF, BMP, TIFF, JPEG 2000, JPEG XR, PNM, JBIG2), CBZ/CBR/ZIP archives and directories of page images")
+		os.Exit(exitUsage)
 	}
-	// Check that two arguments have been passed
-	if flag.NArg() != 2 {
-		fmt.Println("Usage: [-merge] [-clean] [-printsize A4|A3|A2|A1|A0] [-offset n] [-startoffset n] [-orientation P|L] [-output output.pdf] [-workers n] <file1.pdf> <file2.pdf>")
-		os.Exit(1)
+
+	// Report every problem of the command line at once, before anything runs
+	problems := flagProblems(flags)
+	if len(files) > 2 {
+		problems = append(problems, roundsProblems(flags)...)
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		}
+		os.Exit(exitUsage)
 	}
 
-	// Get the paths of the PDF files from the command line arguments
-	file1 := flag.Arg(0)
-	file2 := flag.Arg(1)
+	// The second input names a baseline approved with the approve subcommand
+	inputs := append([]string(nil), files...)
+	if *storeFlag != "" {
+		files[1] = fetchBaseline(*storeFlag, files[1])
+	}
+	// The documents can also be read from https://, s3:// and gs:// URLs
+	downloadInputs(files)
+
+	// Get the paths of the documents from the command line arguments
+	file1 := files[0]
+	file2 := files[1]
+
+	// Check if the files exist and can be opened by the fitz backend
+	for _, file := range files {
+		if err := pdfdiff.CheckInput(file); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
+	}
 
-	// Check if the files exist
-	if _, err := os.Stat(file1); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: file %s does not exist\n", file1)
-		os.Exit(1)
+	// Every comparison of the watch runs the tool again with the same flags
+	if *watchFlag {
+		watch(flags, inputs, files)
 	}
 
-	if _, err := os.Stat(file2); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: file %s does not exist\n", file2)
-		os.Exit(1)
+	// Get the passwords of the encrypted documents before anything else reads the standard input. More than two
+	// documents cannot be encrypted.
+	if len(files) == 2 {
+		if err := readPasswords(files, []*string{password1Flag, password2Flag}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
 	}
 
-	// Open the first PDF file
-	doc1, err := fitz.New(file1)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	// A single page of each document is compared by pairing them, e.g. a rendered proof against its page of the PDF
+	var alignment []pdfdiff.PagePair
+	if *page1Flag != 0 || *page2Flag != 0 {
+		pair := pdfdiff.PagePair{Page1: *page1Flag - 1, Page2: *page2Flag - 1}
+		if pair.Page1 < 0 {
+			pair.Page1 = 0
+		}
+		if pair.Page2 < 0 {
+			pair.Page2 = 0
+		}
+		alignment = []pdfdiff.PagePair{pair}
 	}
-	// Ensure the document is closed after use
-	defer doc1.Close()
 
-	// Open the second PDF file
-	doc2, err := fitz.New(file2)
+	// The images are written at the highest resolution, where the differences are the most precise
+	multiDPI, err := parseDPIs(*multiDPIFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-	// Ensure the document is closed after use
-	defer doc2.Close()
-
-	// Check that the offset and startoffset are valid
-	if *offsetFlag < 0 || *offsetFlag >= doc2.NumPage() {
-		fmt.Fprintf(os.Stderr, "Error: The offset is invalid. It should be between 0 and %d.\n", doc2.NumPage()-1)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
-	if *startOffsetFlag < 0 || *startOffsetFlag >= doc1.NumPage() {
-		fmt.Fprintf(os.Stderr, "Error: The startOffset is invalid. It should be between 0 and %d.\n", doc1.NumPage()-1)
-		os.Exit(1)
+	if len(multiDPI) > 0 {
+		*dpiFlag = multiDPI[0]
+		for _, dpi := range multiDPI[1:] {
+			if dpi > *dpiFlag {
+				*dpiFlag = dpi
+			}
+		}
 	}
-	// Check that the orientation is valid
-	if *orientationFlag != "" && *orientationFlag != "P" && *orientationFlag != "L" {
-		fmt.Fprintf(os.Stderr, "Error: The orientation is invalid. It should be either 'P' or 'L'.\n")
-		os.Exit(1)
+
+	// Fonts that are not embedded are replaced by whatever the machine has, so the comparison would not be reproducible
+	if *embeddedFontsFlag {
+		missing, err := pdfdiff.CheckFonts(file1, file2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		for _, page := range missing {
+			fmt.Fprintf(os.Stderr, "Error: page %d of document %d uses fonts that are not embedded: %s\n", page.Page+1, page.Document, strings.Join(page.Fonts, ", "))
+		}
+		if len(missing) > 0 {
+			os.Exit(exitInput)
+		}
 	}
 
-	// Check that the print size is valid
-	if *printSizeFlag != "A4" && *printSizeFlag != "A3" && *printSizeFlag != "A2" && *printSizeFlag != "A1" && *printSizeFlag != "A0" {
-		fmt.Fprintf(os.Stderr, "Error: Invalid print size. It should be one of 'A4', 'A3', 'A2', 'A1', or 'A0'.\n")
-		os.Exit(1)
+	// Lower the priority before the workers start, so every thread they use inherits it
+	if *niceFlag > 0 {
+		if err := setNice(*niceFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
 	}
 
-	// Calculate the total number of operations
-	totalOps := max(doc1.NumPage(), doc2.NumPage())
+	// Calculate the number of operations that follow the page comparisons
+	extraOps := 0
 	if *mergeFlag {
-		totalOps++ // for merging the images into a PDF
+		extraOps++ // for merging the images into a PDF
 	}
 	if *cleanFlag {
-		totalOps++ // for removing the images
+		extraOps++ // for removing the images
+	}
+	ignoreRegions := loadIgnoreRegions(*ignoreRegionsFlag)
+	criticalRegions := loadIgnoreRegions(*criticalRegionsFlag)
+	template := loadTemplate(*templateFlag)
+	quarantine := loadQuarantine(*quarantineFlag, files)
+
+	// Every run gets its own directory by default, so two runs started in the same directory do not overwrite each other's images
+	workdir := *workdirFlag
+	if workdir == "" && !*inMemoryFlag {
+		dir, err := os.MkdirTemp("", "pdfdiff-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitOutput)
+		}
+		workdir = dir
 	}
 
-	// If the orientation has not been specified, set the orientation based on the dimensions of the first page
-	if *orientationFlag == "" {
-		img1, err := doc1.Image(0)
-		if checkError(err) != nil {
-			return
+	// Initialize the count of completed operations
+	completedOps, totalOps := 0, 0
+
+	// Keep printing while a huge page or the merge runs silently
+	hb := startHeartbeat(*heartbeatFlag, *heartbeatTextFlag)
+	defer hb.Stop()
+
+	opts := pdfdiff.Options{
+		Offset:              *offsetFlag,
+		StartOffset:         *startOffsetFlag,
+		Align:               *alignFlag,
+		Alignment:           alignment,
+		Workers:             *workersFlag,
+		DPI:                 *dpiFlag,
+		MultiDPI:            multiDPI,
+		SideBySide:          *sideBySideFlag,
+		VerticalAlign:       *verticalAlignFlag,
+		PageImages:          *htmlFlag != "",
+		RegionThumbnails:    *regionThumbnailsFlag,
+		Prioritize:          *priorityFlag,
+		Threshold:           *thresholdFlag,
+		AdaptiveThreshold:   *adaptiveFlag,
+		SkipIdentical:       *skipIdenticalFlag || *changedOnlyFlag,
+		ColorOld:            *colorOldFlag,
+		ColorNew:            *colorNewFlag,
+		Heatmap:             *heatmapFlag,
+		HighlightStyle:      strings.ToLower(*highlightStyleFlag),
+		BoxWidth:            *boxWidthFlag,
+		RemoveWatermarks:    *watermarkFlag,
+		NormalizeBackground: *backgroundFlag,
+		Despeckle:           *despeckleFlag,
+		Descreen:            *descreenFlag,
+		Segment:             *segmentFlag,
+		Charts:              *chartsFlag,
+		Preflight:           *preflightFlag,
+		TextDiff:            *textFlag,
+		OCR:                 *ocrFlag,
+		OCRLanguage:         *ocrLangFlag,
+		Language:            *languageFlag,
+		Equations:           *equationsFlag,
+		Glyphs:              *glyphsFlag,
+		VectorOverlay:       strings.ToLower(*vectorOverlayFlag),
+		Dimensions:          *dimensionsFlag,
+		Typography:          *typographyFlag,
+		IgnoreRegions:       ignoreRegions,
+		CriticalRegions:     criticalRegions,
+		Fields:              template.Fields,
+		Checks:              template.Checks,
+		Quarantine:          quarantine,
+		IgnoreText:          *ignoreTextFlag,
+		Crop:                *cropFlag,
+		Reflow:              *reflowFlag,
+		IgnoreAntialiasing:  *ignoreAAFlag,
+		MinRegionSize:       *minRegionSizeFlag,
+		Password1:           *password1Flag,
+		Password2:           *password2Flag,
+		Pauser:              &pdfdiff.Pauser{},
+		OutputDir:           workdir,
+		Prefix:              *prefixFlag,
+		InMemory:            *inMemoryFlag,
+		Progress: hb.wrap(func(completed, total int) {
+			// Update the count of completed operations and print the progress percentage
+			completedOps, totalOps = completed, total+extraOps
+			fmt.Printf("%.2f%% completed\n", float64(completedOps)/float64(totalOps)*100)
+		}),
+	}
+	// SIGUSR1 and SIGUSR2 pause and resume the comparison on shared machines
+	handlePauseSignals(opts.Pauser)
+	if len(files) > 2 {
+		layout := pdfdiff.Layout{Orientation: *orientationFlag, PrintSize: *printSizeFlag, TilePrint: *tilePrintFlag, DPI: opts.DPI, Progress: hb.wrap(printMergeProgress), PageNumbers: *changedOnlyFlag}
+		runRounds(files, opts, layout, *mergeFlag, *outputFlag, *jsonFlag)
+		return
+	}
+	if *priorityFlag {
+		opts.PageDone = func(page pdfdiff.PageResult) {
+			if page.Changed {
+				fmt.Printf("Page %d has differences\n", page.Page+1)
+			}
 		}
-		if img1.Bounds().Dx() > img1.Bounds().Dy() {
-			*orientationFlag = "L"
-		} else {
-			*orientationFlag = "P"
+	}
+	// At the deadline the comparison stops, and the pages completed by then are still reported
+	ctx := context.Background()
+	if *deadlineFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *deadlineFlag)
+		defer cancel()
+	}
+	// With -fail-fast the first page failing the run decides it, so the pages left are not rendered
+	stoppedAt := 0
+	if *failFastFlag {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		failing := 0
+		opts.OnPageDone = func(page pdfdiff.PageResult) error {
+			if containsPage(opts.Quarantine, page.Page) || !pageFails(page, *minSSIMFlag, *maxPageDiffPercentFlag) {
+				return nil
+			}
+			if failing++; (failing > *allowChangedPagesFlag || len(page.Critical) > 0) && stoppedAt == 0 {
+				stoppedAt = page.Page + 1
+				cancel()
+			}
+			return nil
 		}
 	}
-
-	// Create a new PDF for the difference images
-	pdf := gofpdf.New(*orientationFlag, "mm", *printSizeFlag, "")
-
-	// Create a channel for the jobs
-	jobs := make(chan int, max(doc1.NumPage(), doc2.NumPage()))
-
-	// Create a channel to signal job completion
-	done := make(chan bool)
-
-	// Create the workers
-	for w := 1; w <= *workersFlag; w++ {
-		go func(id int) {
-			worker(id, jobs, done, doc1, doc2, mergeFlag, *offsetFlag, *startOffsetFlag, totalOps, sideBySideFlag, verticalAlignFlag)
-		}(w)
+	var report pdfdiff.Report
+	if *remoteFlag != "" {
+		// Farm the pages out to the remote workers and merge their results here
+		coordinator := &remote.Coordinator{Endpoints: strings.Split(*remoteFlag, ","), ChunkSize: *chunkFlag}
+		if coordinator.TLS, err = clientTLS(*remoteTLSFlag, *remoteCAFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		if *remoteTokenFlag != "" {
+			if coordinator.Token, err = readToken(*remoteTokenFlag); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitUsage)
+			}
+		}
+		report, err = coordinator.Compare(ctx, file1, file2, opts)
+	} else {
+		report, err = pdfdiff.Compare(ctx, file1, file2, opts)
 	}
-
-	// Initialize the count of completed operations
-	completedOps := 0
-
-	// Iterate over all the pages of the PDFs
-	for i := 0; i < max(doc1.NumPage(), doc2.NumPage()); i++ {
-		// Send the job to the workers
-		jobs <- i
+	incomplete := err != nil && report.Partial && errors.Is(err, context.DeadlineExceeded)
+	stopped := err != nil && report.Partial && stoppedAt > 0 && errors.Is(err, context.Canceled)
+	if incomplete {
+		fmt.Fprintf(os.Stderr, "Warning: the deadline of %s passed, only the %d pages compared by then are reported\n", *deadlineFlag, len(report.Pages))
+	} else if stopped {
+		fmt.Printf("Page %d fails the comparison, stopped by -fail-fast: only the %d pages compared by then are reported\n", stoppedAt, len(report.Pages))
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	// The reports name the objects the documents were downloaded from
+	for i, name := range []*string{&report.File1, &report.File2} {
+		if pdfdiff.IsObjectURL(inputs[i]) {
+			*name = inputs[i]
+		}
+	}
+	// The settings of the command line that decide the exit code are recorded along with the options
+	report.Config["min_ssim"] = *minSSIMFlag
+	report.Config["max_diff_percent"] = *maxDiffPercentFlag
+	report.Config["max_page_diff_percent"] = *maxPageDiffPercentFlag
+	report.Config["allow_changed_pages"] = *allowChangedPagesFlag
+	report.Config["fail_fast"] = *failFastFlag
+	report.Config["deadline"] = deadlineFlag.String()
+	report.Config["thumbnails"] = *thumbnailsFlag
+	report.Config["embedded_fonts_only"] = *embeddedFontsFlag
+
+	for _, doc := range report.NondeterministicIn {
+		fmt.Fprintf(os.Stderr, "Warning: the first page of document %d was rendered differently twice, so some differences may come and go between runs. Embed the fonts of the document, or install the ones it uses.\n", doc)
+	}
+	for _, doc := range report.WatermarkIn {
+		fmt.Printf("A watermark was found on every page of document %d and removed before comparing\n", doc)
 	}
 
-	// Close the jobs channel to signal that there are no more jobs to do
-	close(jobs)
+	for _, page := range report.Pages {
+		if page.Background != nil {
+			fmt.Printf("Page %d: the background changed from %s to %s\n", page.Page+1, page.Background.Color1, page.Background.Color2)
+		}
+		if page.Content != nil && page.Changed {
+			fmt.Printf("Page %d: %s changed\n", page.Page+1, describeContent(page.Content))
+		}
+		for _, change := range page.Charts {
+			fmt.Printf("Page %d: %s\n", page.Page+1, change.Description)
+		}
+		if len(page.TextChanges) > 0 {
+			fmt.Printf("Page %d: %s\n", page.Page+1, describeTextChanges(page.TextChanges))
+		}
+		if len(page.MaskedText) > 0 {
+			fmt.Printf("Page %d: ignored %q\n", page.Page+1, page.MaskedText)
+		}
+		if len(page.Equations) > 0 {
+			fmt.Printf("Page %d: %s\n", page.Page+1, describeEquations(page.Equations))
+		}
+		if page.Glyphs != nil {
+			fmt.Printf("Page %d: %s\n", page.Page+1, describeGlyphs(page.Glyphs.GlyphStats))
+		}
+		for _, change := range page.Dimensions {
+			fmt.Printf("Page %d: %s\n", page.Page+1, change.Description)
+		}
+		if v := page.Vectors; v != nil && v.Overlay != "" {
+			fmt.Printf("Page %d: %d vector paths added, %d removed (%s)\n", page.Page+1, v.Added, v.Removed, v.Overlay)
+		}
+		if page.Threshold > opts.Threshold {
+			fmt.Printf("Page %d: the noise of the page raised the threshold to %d\n", page.Page+1, page.Threshold)
+		}
+	}
 
-	// Wait for all jobs to be completed
-	for i := 0; i < max(doc1.NumPage(), doc2.NumPage()); i++ {
-		<-done
-		// Update the count of completed operations and print the progress percentage
-		completedOps++
-		fmt.Printf("%.2f%% completed\n", float64(completedOps)/float64(totalOps)*100)
+	// Changes to the critical regions matter whatever their size, so they stand out
+	for _, page := range report.CriticalPages() {
+		for _, c := range page.Critical {
+			region := fmt.Sprintf("at %d,%d", c.X, c.Y)
+			if c.Reason != "" {
+				region = fmt.Sprintf("%q", c.Reason)
+			}
+			fmt.Printf("CRITICAL: page %d: %d pixels changed in the critical region %s\n", page.Page+1, c.ChangedPixels, region)
+		}
 	}
 
-	fmt.Printf("Merging difference images...")
+	// The breaks that moved are listed apart, for the reviews of the layout only
+	for _, b := range report.Breaks {
+		fmt.Printf("Page %d: %s\n", b.Page+1, b.Description)
+	}
+	if report.Glyphs != nil {
+		printGlyphs(*report.Glyphs)
+	}
+	if *typographyFlag {
+		printTypography(report)
+	}
+	if len(template.Fields) > 0 {
+		printFields(report)
+	}
+	if *languageFlag != "" {
+		printLanguages(report)
+	}
 
-	// Add the images to the PDF in the correct order
-	if *mergeFlag {
-		imgOptions := gofpdf.ImageOptions{
-			ImageType:             "",
-			ReadDpi:               true,
-			AllowNegativePosition: true,
+	// Flaky pages are reported apart, so their differences are seen without failing the run
+	for _, page := range report.QuarantinedPages() {
+		if page.Changed {
+			fmt.Printf("Page %d is quarantined: it has differences, which do not fail the run\n", page.Page+1)
 		}
-		doc1Pages := doc1.NumPage()
-		doc2Pages := doc2.NumPage()
-		maxPages := max(doc1Pages+*offsetFlag, doc2Pages+*offsetFlag)
-
-		pdfW, pdfH := pdf.GetPageSize()
+	}
 
-		progressInterval := maxPages / 10
-		if progressInterval == 0 {
-			progressInterval = 1
+	// Inserted and deleted pages are what the alignment is for
+	for _, page := range report.Pages {
+		switch {
+		case len(page.SourcePages) != 2:
+		case page.SourcePages[0] < 0:
+			fmt.Printf("Page %d of the second document was inserted (page %d of the output)\n", page.SourcePages[1]+1, page.Page+1)
+		case page.SourcePages[1] < 0:
+			fmt.Printf("Page %d of the first document was deleted (page %d of the output)\n", page.SourcePages[0]+1, page.Page+1)
 		}
+	}
 
-		for i := 0; i < maxPages; i++ {
-			pdf.AddPage()
+	// Approval workflows care about signatures and stamps more than about any other change
+	for _, page := range report.Pages {
+		for _, r := range page.Regions {
+			if mark := r.Mark(); mark != "" {
+				fmt.Printf("%s on page %d\n", capitalize(mark), page.Page+1)
+			}
+		}
+	}
 
-			// Assuming each image has a unique path with index i
-			diffImgPath := fmt.Sprintf("differences_%d.png", i)
+	// Catch the thumbnails a tool did not update along with the pages, which confuse viewers
+	staleThumbnails := 0
+	if *thumbnailsFlag {
+		report.Thumbnails, err = pdfdiff.CheckThumbnails(file1, file2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		for _, thumb := range report.Thumbnails {
+			if thumb.Stale || thumb.Copied {
+				staleThumbnails++
+				copied := ""
+				if thumb.Copied {
+					copied = ", it is still the thumbnail of the first document"
+				}
+				fmt.Printf("The thumbnail of page %d of document %d is stale (SSIM %.4f%s)\n", thumb.Page+1, thumb.Document, thumb.SSIM, copied)
+			}
+		}
+		fmt.Printf("%d embedded thumbnails checked, %d stale\n", len(report.Thumbnails), staleThumbnails)
+	}
 
-			// Register each image inside the loop if they are not the same
-			imgInfo := pdf.RegisterImageOptions(diffImgPath, imgOptions)
-			imgW, imgH := imgInfo.Extent()
-			scale := min(pdfW/imgW, pdfH/imgH)
-			scaledImgW := imgW * scale
-			scaledImgH := imgH * scale
+	// The tagging gets lost when a tool rewrites a document, which no pixel shows
+	a11yRegressions := 0
+	if *accessibilityFlag {
+		report.Accessibility, err = pdfdiff.CheckAccessibility(file1, file2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if a := report.Accessibility; a != nil {
+			for n, doc := range []pdfdiff.Accessibility{a.Document1, a.Document2} {
+				fmt.Printf("Accessibility of document %d: %s\n", n+1, describeAccessibility(doc))
+			}
+			for _, r := range a.Regressions {
+				fmt.Printf("ACCESSIBILITY REGRESSION: %s\n", r)
+			}
+			a11yRegressions = len(a.Regressions)
+		}
+	}
 
-			// Calculate the position of the image so that it is centered on the page
-			x := (pdfW - scaledImgW) / 2
-			y := (pdfH - scaledImgH) / 2
+	*orientationFlag = resolveOrientation(*orientationFlag, report.Pages)
+
+	// Describe the produced artifacts so the reports can be regenerated without comparing again
+	manifest := &runManifest{
+		Report:             report,
+		Orientation:        *orientationFlag,
+		PrintSize:          *printSizeFlag,
+		TilePrint:          *tilePrintFlag,
+		Output:             *outputFlag,
+		Merge:              *mergeFlag,
+		SideBySide:         *sideBySideFlag,
+		HTML:               *htmlFlag,
+		ChangedOnly:        *changedOnlyFlag,
+		SummaryPage:        *summaryPageFlag,
+		JSON:               *jsonFlag,
+		CSV:                *csvFlag,
+		SARIF:              *sarifFlag,
+		JUnit:              *junitFlag,
+		Summary:            *summaryFlag,
+		SummaryTop:         *summaryTopFlag,
+		Overview:           *overviewFlag,
+		Annotations:        *annotationsFlag,
+		MinSSIM:            *minSSIMFlag,
+		MaxPageDiffPercent: *maxPageDiffPercentFlag,
+	}
+	layout := pdfdiff.Layout{Orientation: *orientationFlag, PrintSize: *printSizeFlag, TilePrint: *tilePrintFlag, DPI: report.DPI, Progress: hb.wrap(printMergeProgress), Images: report.Images, PageNumbers: *changedOnlyFlag}
+	if *summaryPageFlag {
+		layout.Summary = &report
+	}
 
-			// Add the image to the PDF
-			pdf.ImageOptions(diffImgPath, x, y, scaledImgW, scaledImgH, false, imgOptions, 0, "")
+	// Reviewers of long documents only get the changed pages, stamped with their number
+	mergePages := report.Pages
+	if *changedOnlyFlag {
+		mergePages = report.ChangedPages()
+	}
 
-			// Update and print the progress percentage less frequently to improve performance
-			if i%progressInterval == 0 || i == maxPages-1 {
-				progress := float64(i+1) / float64(maxPages) * 100.0
-				fmt.Printf("\rProgress: %.2f%%", progress)
-			}
-		}
+	// Add the images to the PDF in the correct order
+	if *mergeFlag && len(mergePages) == 0 {
+		fmt.Println("There are no changed pages to merge")
+		completedOps++
+	} else if *mergeFlag {
+		fmt.Printf("Merging difference images...")
+		err := pdfdiff.WriteDiffPDF(report.Dir, mergePages, *outputFlag, layout)
 		fmt.Println()
-
-		// Save the PDF
-		err := pdf.OutputFileAndClose(*outputFlag)
 		if checkError(err) != nil {
-			return
+			os.Exit(exitOutput)
 		}
 		fmt.Printf("The difference images have been merged into %s\n", *outputFlag)
 
@@ -375,54 +658,51 @@ func main() {
 		fmt.Printf("The difference images have been merged into a PDF (100.00%% completed)\n")
 	}
 
-	if *sideBySideFlag {
-		// Create a new PDF for the combined images
-		pdf := gofpdf.New(*orientationFlag, "mm", *printSizeFlag, "")
-
-		// Number of combined images to process
-		numCombinedImages := max(doc1.NumPage()+*offsetFlag, doc2.NumPage()+*offsetFlag)
-
-		// Loop through all combined images and add them to the PDF
-		for i := 0; i < numCombinedImages; i++ {
-			combinedImgPath := fmt.Sprintf("combined_%d.png", i)
-
-			// Check if the image exists before trying to add it to the PDF
-			if _, err := os.Stat(combinedImgPath); !os.IsNotExist(err) {
-				imgOptions := gofpdf.ImageOptions{
-					ImageType:             "",
-					ReadDpi:               true,
-					AllowNegativePosition: true,
-				}
-				imgInfo := pdf.RegisterImageOptions(combinedImgPath, imgOptions)
-
-				// Convert the image dimensions from points to millimeters (assuming 72 dpi)
-				imgWidthMM := imgInfo.Width() / 2.83465
-				imgHeightMM := imgInfo.Height() / 2.83465
+	if *sideBySideFlag && len(mergePages) > 0 {
+		outputCombinedPDF := combinedOutputPath(*outputFlag)
+		err := pdfdiff.WriteCombinedPDF(report.Dir, mergePages, outputCombinedPDF, layout)
+		if checkError(err) != nil {
+			os.Exit(exitOutput)
+		}
+		fmt.Printf("The combined images have been merged into %s\n", outputCombinedPDF)
+	}
 
-				// Add a new page with the exact size of the image
-				pdf.AddPageFormat("P", gofpdf.SizeType{Wd: imgWidthMM, Ht: imgHeightMM})
+	if checkError(writeReports(manifest)) != nil {
+		os.Exit(exitOutput)
+	}
 
-				// Add the image to the PDF
-				pdf.ImageOptions(combinedImgPath, 0, 0, imgWidthMM, imgHeightMM, false, imgOptions, 0, "")
-			}
+	if *archiveFlag != "" {
+		files := outputFiles(report, *mergeFlag && len(mergePages) > 0, *sideBySideFlag && len(mergePages) > 0,
+			*outputFlag, manifest.reports(), false)
+		if checkError(pdfdiff.WriteArchive(report, files, *archiveFlag)) != nil {
+			os.Exit(exitOutput)
 		}
+		fmt.Printf("The archive has been written to %s\n", *archiveFlag)
+	}
 
-		// Save the PDF
-		outputCombinedPDF := filepath.Join(filepath.Dir(*outputFlag), "combined_"+filepath.Base(*outputFlag))
-		err := pdf.OutputFileAndClose(outputCombinedPDF)
-		if checkError(err) != nil {
-			return
+	if *tuiFlag {
+		if err := runTUI(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
-		fmt.Printf("The combined images have been merged into %s\n", outputCombinedPDF)
 	}
 
-	if *cleanFlag {
+	if *inMemoryFlag {
+		// Nothing was written but the requested outputs, so there is no image to remove nor any to describe in a manifest
+	} else if *cleanFlag {
 		// Get the paths of the difference images.
 		var differenceImagePaths []string
-		for i := 0; i < max(doc1.NumPage()+*offsetFlag, doc2.NumPage()+*offsetFlag); i++ {
-			differenceImagePaths = append(differenceImagePaths, fmt.Sprintf("differences_%d.png", i))
-			if *sideBySideFlag {
-				differenceImagePaths = append(differenceImagePaths, fmt.Sprintf("combined_%d.png", i))
+		for _, page := range report.Pages {
+			if page.DiffImage != "" {
+				differenceImagePaths = append(differenceImagePaths, filepath.Join(report.Dir, page.DiffImage))
+			}
+			if page.CombinedImage != "" {
+				differenceImagePaths = append(differenceImagePaths, filepath.Join(report.Dir, page.CombinedImage))
+			}
+			if page.Image1 != "" {
+				differenceImagePaths = append(differenceImagePaths, filepath.Join(report.Dir, page.Image1))
+			}
+			if page.Image2 != "" {
+				differenceImagePaths = append(differenceImagePaths, filepath.Join(report.Dir, page.Image2))
 			}
 		}
 
@@ -434,25 +714,208 @@ func main() {
 			}
 		}
 
+		// A manifest left by a previous run in the same directory would describe images that no longer exist
+		if err := os.Remove(filepath.Join(report.Dir, manifestName)); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error removing manifest: %v\n", err)
+		}
+
+		// The temporary directory is only removed once empty, in case other files were put there meanwhile
+		if *workdirFlag == "" {
+			if err := os.Remove(report.Dir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error removing directory: %v\n", err)
+			}
+		}
+
 		fmt.Println("The images have been removed")
 
 		// Update the count of completed operations and print the progress percentage
 		completedOps++
 		fmt.Printf("The images have been removed (%.2f%% completed)\n", float64(completedOps)/float64(totalOps)*100)
+	} else if checkError(writeManifest(report.Dir, manifest)) != nil {
+		// The manifest is only kept while the images it describes exist
+		os.Exit(exitOutput)
+	} else {
+		fmt.Printf("The page images and the manifest have been written to %s\n", report.Dir)
+	}
+
+	if *uploadFlag != "" {
+		if checkError(uploadOutputs(*uploadFlag, outputFiles(report, *mergeFlag && len(mergePages) > 0, *sideBySideFlag && len(mergePages) > 0,
+			*outputFlag, manifest.reports(), !*inMemoryFlag && !*cleanFlag))) != nil {
+			os.Exit(exitOutput)
+		}
+	}
+
+	fmt.Printf("Structural similarity (SSIM): %.4f\n", report.SSIM)
+	if *reportQuarantineFlag {
+		printQuarantine(report, *quarantineFlag)
+	}
+	code := verdict(verdictInput{report: report, stopped: stopped, incomplete: incomplete, failures: staleThumbnails + a11yRegressions,
+		minSSIM: *minSSIMFlag, maxDiffPercent: *maxDiffPercentFlag, maxPageDiffPercent: *maxPageDiffPercentFlag, allowChangedPages: *allowChangedPagesFlag})
+	if code == exitDifferent && *updateOnApproveFlag {
+		// Intentional changes are reviewed and approved instead of failing the job, whatever failed it
+		proposeBaseline(file1, *password1Flag, inputs[1], *storeFlag, *dpiFlag, report, *summaryTopFlag)
+		code = exitIdentical
+	}
+	os.Exit(code)
+}
+
+// exitCode returns the exit code that describes an error returned by a comparison
+func exitCode(err error) int {
+	var inputErr *pdfdiff.InputError
+	switch {
+	case errors.As(err, &inputErr):
+		return exitInput
+	case errors.Is(err, pdfdiff.ErrInvalidOptions):
+		return exitUsage
+	default:
+		return exitRender
+	}
+}
+
+// summaryFlags registers the executive summary flags on a subcommand
+func summaryFlags(flags *flag.FlagSet) (*string, *int) {
+	output := flags.String("summary", "", "also write an executive summary of the most changed pages, as Markdown (.md), a one-page PDF (.pdf) or text")
+	top := flags.Int("summary-top", pdfdiff.DefaultSummaryTop, "the number of pages listed in the executive summary")
+	return output, top
+}
+
+// reflowFlags adds the -reflow-size and -reflow-font-size flags of the subcommands that render EPUB and FB2 documents
+func reflowFlags(flags *flag.FlagSet) *pdfdiff.ReflowLayout {
+	l := new(pdfdiff.ReflowLayout)
+	flags.Func("reflow-size", "paginate the EPUB and FB2 documents with pages of this size in points, e.g. 360x540 (Default: 450x600)", func(s string) error {
+		w, h, ok := strings.Cut(s, "x")
+		width, err1 := strconv.ParseFloat(w, 64)
+		height, err2 := strconv.ParseFloat(h, 64)
+		if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+			return fmt.Errorf("give the width and the height of the pages in points, e.g. 360x540")
+		}
+		l.Width, l.Height = width, height
+		return nil
+	})
+	flags.Float64Var(&l.FontSize, "reflow-font-size", 0, "paginate the EPUB and FB2 documents with this default font size in points, e.g. 16 (Default: 12)")
+	return l
+}
+
+// cropFlags adds the -crop-top, -crop-bottom, -crop-left and -crop-right flags of the comparison subcommands
+func cropFlags(flags *flag.FlagSet) *pdfdiff.Margins {
+	m := new(pdfdiff.Margins)
+	flags.StringVar(&m.Top, "crop-top", "", "exclude the top margin of every page from the comparison, in mm (e.g. 15mm) or percent of the page height (e.g. 5%), for running headers")
+	flags.StringVar(&m.Bottom, "crop-bottom", "", "exclude the bottom margin of every page from the comparison, in mm or percent of the page height, for footers with page numbers and dates")
+	flags.StringVar(&m.Left, "crop-left", "", "exclude the left margin of every page from the comparison, in mm or percent of the page width")
+	flags.StringVar(&m.Right, "crop-right", "", "exclude the right margin of every page from the comparison, in mm or percent of the page width")
+	return m
+}
+
+// describeContent lists how much of every type of content of a page changed, e.g. "2.10% of the text and 0.01% of the images"
+func describeContent(c *pdfdiff.ContentDiffs) string {
+	var parts []string
+	for _, t := range []struct {
+		name string
+		diff pdfdiff.ContentDiff
+	}{{"text", c.Text}, {"images", c.Image}, {"graphics", c.Graphics}} {
+		if t.diff.Pixels > 0 {
+			parts = append(parts, fmt.Sprintf("%.2f%% of the %s", t.diff.PercentChanged, t.name))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing drawn on the page"
+	}
+	if len(parts) == 1 {
+		return parts[0]
 	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
 }
 
-// min returns the smaller of two float64 numbers.
-func min(a, b float64) float64 {
-	return math.Min(a, b)
+// describeTextChanges counts the words inserted and deleted on a page, e.g. "12 words inserted, 3 deleted"
+func describeTextChanges(changes []pdfdiff.TextChange) string {
+	inserted, deleted := 0, 0
+	for _, c := range changes {
+		if c.Op == "insert" {
+			inserted += len(strings.Fields(c.Text))
+		} else {
+			deleted += len(strings.Fields(c.Text))
+		}
+	}
+	noun := "words"
+	if inserted == 1 {
+		noun = "word"
+	}
+	return fmt.Sprintf("%d %s inserted, %d deleted", inserted, noun, deleted)
+}
+
+// describeEquations describes the equations of a page, e.g. "3 equations, 1 changed"
+func describeEquations(equations []pdfdiff.Equation) string {
+	changed := 0
+	for _, eq := range equations {
+		if eq.Changed {
+			changed++
+		}
+	}
+	if len(equations) == 1 {
+		return fmt.Sprintf("1 equation, %d changed", changed)
+	}
+	return fmt.Sprintf("%d equations, %d changed", len(equations), changed)
 }
 
-// max returns the larger of two int numbers.
-func max(a, b int) int {
-	if a > b {
-		return a
+// parseDPIs parses the comma-separated resolutions of -multi-dpi
+func parseDPIs(list string) ([]float64, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var dpis []float64
+	for _, field := range strings.Split(list, ",") {
+		dpi, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || dpi <= 0 {
+			return nil, fmt.Errorf("invalid resolution %q in -multi-dpi, it should be a number greater than 0", field)
+		}
+		dpis = append(dpis, dpi)
 	}
-	return b
+	return dpis, nil
+}
+
+// defaultThreshold returns the default of -threshold, set with the PDFDIFF_THRESHOLD environment variable
+func defaultThreshold() int {
+	threshold, err := strconv.Atoi(os.Getenv("PDFDIFF_THRESHOLD"))
+	if err != nil {
+		return 0
+	}
+	return threshold
+}
+
+// validTileSize reports whether the size of the tiles of -tile-print is one of the supported page formats
+func validTileSize(tileSize string) bool {
+	return tileSize != pdfdiff.Auto && validPrintSize(tileSize)
+}
+
+// validPrintSize reports whether the print size is one of the supported page formats, or auto
+func validPrintSize(printSize string) bool {
+	return printSize == pdfdiff.Auto || printSize == "A4" || printSize == "A3" || printSize == "A2" || printSize == "A1" || printSize == "A0"
+}
+
+// validOrientation reports whether the orientation is P, L, auto or first
+func validOrientation(orientation string) bool {
+	return orientation == "P" || orientation == "L" || orientation == pdfdiff.Auto || orientation == "first"
+}
+
+// resolveOrientation returns the orientation of the merged PDFs: first is the orientation of the first page for all
+// of them, as the PDFs were once laid out, and no orientation turns every page
+func resolveOrientation(orientation string, pages []pdfdiff.PageResult) string {
+	switch orientation {
+	case "":
+		return pdfdiff.Auto
+	case "first":
+		if len(pages) > 0 && pages[0].Width > pages[0].Height {
+			return "L"
+		}
+		return "P"
+	}
+	return orientation
+}
+
+// printMergeProgress prints the progress of the merge stage on a single line
+func printMergeProgress(completed, total int) {
+	progress := float64(completed) / float64(total) * 100.0
+	fmt.Printf("\rProgress: %.2f%%", progress)
 }
 
 // checkError prints an error message and returns the error if it is not nil.
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"PdfDiff/pdfdiff"
+)
+
+// describeAccessibility describes the accessibility signals of a document, e.g. "tagged, language en-US, 3 of 4
+// figures with an alternate text, 120 marked contents in the reading order"
+func describeAccessibility(a pdfdiff.Accessibility) string {
+	tagged, language := "tagged", "no language"
+	switch {
+	case a.Encrypted:
+		language = "language not read from the encrypted document"
+	case a.Language != "":
+		language = "language " + a.Language
+	}
+	if !a.Tagged {
+		if a.Figures == 0 && a.ReadingOrder == 0 {
+			return "not tagged, " + language
+		}
+		// A structure tree without the mark of a tagged document
+		tagged = "not marked as tagged"
+	}
+	return fmt.Sprintf("%s, %s, %d of %d figures with an alternate text, %d marked contents in the reading order", tagged, language, a.FiguresWithAlt, a.Figures, a.ReadingOrder)
+}
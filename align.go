@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// alignRun is a run of consecutive output pages of an alignment with the same offset, or of inserted or deleted pages
+type alignRun struct {
+	pairs  []pdfdiff.PagePair
+	offset int
+}
+
+// runAlign pairs the pages of two documents and prints the offsets found between them with their confidence,
+// without comparing the pages, so the alignment can be checked before a long comparison
+func runAlign(args []string) {
+	flags := flag.NewFlagSet("align", flag.ExitOnError)
+
+	files := parseArgs(flags, args)
+	if len(files) != 2 {
+		fmt.Println("Usage: align <file1> <file2>")
+		os.Exit(exitUsage)
+	}
+
+	docs := make([]pdfdiff.Document, 2)
+	for n, file := range files {
+		doc, err := pdfdiff.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", &pdfdiff.InputError{File: file, Err: err})
+			os.Exit(exitInput)
+		}
+		defer doc.Close()
+		docs[n] = doc
+	}
+	pdfdiff.MatchPages(docs[0], docs[1])
+	pairs, err := pdfdiff.AlignPages(docs[0], docs[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	// Group the pairs into runs of the same offset
+	var runs []alignRun
+	for _, pair := range pairs {
+		offset := pair.Page2 - pair.Page1
+		if pair.Page1 < 0 || pair.Page2 < 0 {
+			offset = 0
+		}
+		if n := len(runs) - 1; n >= 0 && runs[n].offset == offset && missing(runs[n].pairs[0]) == missing(pair) {
+			runs[n].pairs = append(runs[n].pairs, pair)
+			continue
+		}
+		runs = append(runs, alignRun{pairs: []pdfdiff.PagePair{pair}, offset: offset})
+	}
+
+	matched, similarity := 0, 0.0
+	for _, run := range runs {
+		first, last := run.pairs[0], run.pairs[len(run.pairs)-1]
+		verb := "were"
+		if len(run.pairs) == 1 {
+			verb = "was"
+		}
+		switch missing(first) {
+		case 1:
+			fmt.Printf("%s of the second document %s inserted\n", capitalize(pageSpan(first.Page2, last.Page2)), verb)
+		case 2:
+			fmt.Printf("%s of the first document %s deleted\n", capitalize(pageSpan(first.Page1, last.Page1)), verb)
+		default:
+			runSimilarity := 0.0
+			for _, pair := range run.pairs {
+				runSimilarity += pair.Similarity
+			}
+			matched += len(run.pairs)
+			similarity += runSimilarity
+			fmt.Printf("%s of the first document: %s of the second document (offset %d, confidence %.2f)\n",
+				capitalize(pageSpan(first.Page1, last.Page1)), pageSpan(first.Page2, last.Page2), run.offset, runSimilarity/float64(len(run.pairs)))
+		}
+	}
+	if matched > 0 {
+		fmt.Printf("Overall confidence: %.2f\n", similarity/float64(matched))
+	}
+	fmt.Println(suggestOptions(pairs))
+}
+
+// missing returns the document (1 or 2) that does not have the page of a pair, or 0 if both have it
+func missing(pair pdfdiff.PagePair) int {
+	switch {
+	case pair.Page1 < 0:
+		return 1
+	case pair.Page2 < 0:
+		return 2
+	}
+	return 0
+}
+
+// pageSpan formats a range of pages counted from 0 as the pages counted from 1 that users see, e.g. "pages 3-5"
+func pageSpan(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("page %d", first+1)
+	}
+	return fmt.Sprintf("pages %d-%d", first+1, last+1)
+}
+
+// capitalize returns the text with its first letter in upper case, to start a sentence
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// suggestOptions returns the -offset and -startoffset that reproduce the alignment, or suggests -align when
+// a single offset cannot, e.g. because pages were deleted or inserted in several places
+func suggestOptions(pairs []pdfdiff.PagePair) string {
+	offset, startOffset := 0, 0
+	for _, pair := range pairs {
+		if missing(pair) == 2 {
+			return "Suggested options: -align (pages were deleted, which -offset cannot skip)"
+		}
+		if missing(pair) != 0 {
+			continue
+		}
+		switch o := pair.Page2 - pair.Page1; {
+		case o == offset:
+		case offset == 0 && o > 0:
+			offset, startOffset = o, pair.Page1
+		default:
+			return "Suggested options: -align (the offset changes more than once)"
+		}
+	}
+	if offset == 0 {
+		return "Suggested options: none, the pages are already aligned"
+	}
+	return fmt.Sprintf("Suggested options: -offset %d -startoffset %d", offset, startOffset)
+}
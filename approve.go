@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"PdfDiff/pdfdiff"
+)
+
+// storeFlag adds the flag giving the store of the baselines, see pdfdiff.OpenBaselineStore
+func storeFlag(flags *flag.FlagSet) *string {
+	return flags.String("store", "", "the store of the baselines: a directory, lfs:path for a directory of the Git repository tracked by Git LFS, or an http(s) URL")
+}
+
+// openStore opens the store of the baselines, authenticating with $PDFDIFF_STORE_TOKEN with an HTTP store
+func openStore(spec string) pdfdiff.BaselineStore {
+	store, err := pdfdiff.OpenBaselineStore(spec, os.Getenv("PDFDIFF_STORE_TOKEN"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	return store
+}
+
+// fetchBaseline returns the directory of the page images of a baseline, downloaded to the temporary directory
+// if the store is remote
+func fetchBaseline(spec, name string) string {
+	dir, err := openStore(spec).Fetch(context.Background(), name, filepath.Join(os.TempDir(), "pdfdiff-baselines"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	return dir
+}
+
+// runApprove renders the pages of a document and stores them as a baseline, which later comparisons verify renders
+// against with compare -store
+func runApprove(args []string) {
+	flags := flag.NewFlagSet("approve", flag.ExitOnError)
+	storeFlag := storeFlag(flags)
+	pagesFlag := flags.String("pages", "", "the pages to approve, e.g. 1-10 or 1,3,5-7 (Default: all pages)")
+	dpiFlag := flags.Float64("dpi", pdfdiff.DefaultDPI, "the resolution used to rasterize the pages, the same as the one of the comparisons")
+
+	files := parseArgs(flags, args)
+	if len(files) != 2 || *storeFlag == "" {
+		fmt.Println("Usage: approve -store dir|lfs:path|URL [-pages 1-10] [-dpi 300] <file> <name>")
+		os.Exit(exitUsage)
+	}
+	file, name := files[0], files[1]
+	store := openStore(*storeFlag)
+
+	if *dpiFlag <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: The dpi is invalid. It should be greater than 0.\n")
+		os.Exit(exitUsage)
+	}
+	if err := pdfdiff.CheckInput(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	doc, err := pdfdiff.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	pages, err := parsePageRange(*pagesFlag, doc.NumPage())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	dir, err := os.MkdirTemp("", "pdfdiff-approve-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+	renderPages(doc, pages, *dpiFlag, dir)
+	doc.Close()
+	err = store.Save(context.Background(), name, dir)
+	os.RemoveAll(dir)
+	if checkError(err) != nil {
+		os.Exit(exitOutput)
+	}
+	fmt.Printf("%d pages of %s have been approved as the baseline %s\n", len(pages), file, name)
+}
+
+// proposeBaseline renders the pages of a document that differs from its baseline, with a summary of the changes, to
+// the directory proposed/<name> of the comparison, where they can be reviewed and then approved as the new baseline
+func proposeBaseline(file, password, name, spec string, dpi float64, report pdfdiff.Report, top int) {
+	doc, err := pdfdiff.OpenWithPassword(file, password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	defer doc.Close()
+	pages, _ := parsePageRange("", doc.NumPage())
+
+	dir := filepath.Join(report.Dir, "proposed", name)
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+	renderPages(doc, pages, dpi, dir)
+	summary := filepath.Join(report.Dir, "proposed", name+".md")
+	if checkError(pdfdiff.WriteSummary([]pdfdiff.Report{report}, summary, top)) != nil {
+		os.Exit(exitOutput)
+	}
+	fmt.Printf("%d pages differ from the baseline %s. The update is proposed in %s and described in %s; approve it with:\n", len(report.ChangedPages()), name, dir, summary)
+	fmt.Printf("    PdfDiffGo approve -store %s -dpi %g %s %s\n", spec, dpi, dir, name)
+}
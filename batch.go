@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// batchResult is the outcome of one pair of documents of a batch
+type batchResult struct {
+	name      string // path relative to the compared directories
+	report    pdfdiff.Report
+	signature string // identifies the differences, empty if the documents are identical
+}
+
+// runBatch compares every document of a directory with the document of the same name in another directory,
+// and groups the pairs that have the same differences so a template regression is reported once
+func runBatch(args []string) {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	outdirFlag := flags.String("outdir", "batch", "the directory where the artifacts of every pair are written, one subdirectory per pair")
+	workersFlag := flags.Int("workers", 0, "the number of workers to use. (Default: CPU Count)")
+	dpiFlag := flags.Float64("dpi", pdfdiff.DefaultDPI, "the resolution used to rasterize the pages")
+	thresholdFlag := flags.Int("threshold", defaultThreshold(), "the largest difference of a color channel (0-255) still considered equal (Default: $PDFDIFF_THRESHOLD or 0)")
+	adaptiveFlag := flags.Bool("adaptive-threshold", false, "raise the threshold of every page to the noise measured in its margins, for scans and rasterizer noise")
+	ignoreAAFlag := flags.Bool("ignore-antialiasing", false, "ignore differences along glyph and line edges that moved by at most one pixel")
+	minRegionSizeFlag := flags.String("min-region-size", "", "discard the changed regions of fewer changed pixels, in pixels (e.g. 20) or square millimeters (e.g. 0.5mm2), so dust specks and rasterization jitter do not mark a page as changed")
+	alignFlag := flags.Bool("align", false, "pair the pages automatically, finding the inserted and deleted pages")
+	summaryFlag, summaryTopFlag := summaryFlags(flags)
+	ignoreRegionsFlag := ignoreRegionsFlag(flags)
+	ignoreTextFlag := ignoreTextFlag(flags)
+	cropFlag := cropFlags(flags)
+	reflowFlag := reflowFlags(flags)
+
+	dirs := parseArgs(flags, args)
+	if len(dirs) != 2 {
+		fmt.Println("Usage: batch [-outdir batch/] [-workers n] [-dpi 300] [-threshold n] [-adaptive-threshold] [-ignore-antialiasing] [-min-region-size px|mm2] [-align] [-ignore-regions pdfdiff_ignore.json] [-ignore-text pattern]... [-crop-top mm|%] [-crop-bottom mm|%] [-crop-left mm|%] [-crop-right mm|%] [-reflow-size 450x600] [-reflow-font-size 12] [-summary summary.md] [-summary-top n] <dir1> <dir2>")
+		os.Exit(exitUsage)
+	}
+	if problems := flagProblems(flags); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		}
+		os.Exit(exitUsage)
+	}
+
+	names, err := batchPairs(dirs[0], dirs[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s and %s have no documents in common\n", dirs[0], dirs[1])
+		os.Exit(exitInput)
+	}
+	ignoreRegions := loadIgnoreRegions(*ignoreRegionsFlag)
+
+	var results []batchResult
+	failure := 0
+	for n, name := range names {
+		fmt.Printf("Comparing %s (%d/%d)\n", name, n+1, len(names))
+		// The directory keeps the extension, so a.pdf and a.epub do not write to the same one
+		dir := filepath.Join(*outdirFlag, name)
+		report, err := pdfdiff.Compare(context.Background(), filepath.Join(dirs[0], name), filepath.Join(dirs[1], name), pdfdiff.Options{
+			Workers:            *workersFlag,
+			DPI:                *dpiFlag,
+			Threshold:          *thresholdFlag,
+			AdaptiveThreshold:  *adaptiveFlag,
+			IgnoreAntialiasing: *ignoreAAFlag,
+			MinRegionSize:      *minRegionSizeFlag,
+			Align:              *alignFlag,
+			IgnoreRegions:      ignoreRegions,
+			IgnoreText:         *ignoreTextFlag,
+			Crop:               *cropFlag,
+			Reflow:             *reflowFlag,
+			OutputDir:          dir,
+		})
+		if err != nil {
+			// Keep comparing the other pairs, and exit with the first failure at the end
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if failure == 0 {
+				failure = exitCode(err)
+			}
+			continue
+		}
+
+		// Keep the artifacts of every pair usable by the report and merge subcommands
+		manifest := &runManifest{Report: report, Orientation: pdfdiff.Auto, PrintSize: pdfdiff.Auto, Output: filepath.Join(dir, "differences.pdf")}
+		if checkError(writeManifest(dir, manifest)) != nil && failure == 0 {
+			failure = exitOutput
+		}
+		results = append(results, batchResult{name: name, report: report, signature: diffSignature(report)})
+	}
+
+	changed := printBatchSummary(results)
+	if *summaryFlag != "" {
+		var reports []pdfdiff.Report
+		for _, result := range results {
+			reports = append(reports, result.report)
+		}
+		if checkError(pdfdiff.WriteSummary(reports, *summaryFlag, *summaryTopFlag)) != nil && failure == 0 {
+			failure = exitOutput
+		}
+	}
+	if failure != 0 {
+		os.Exit(failure)
+	}
+	if changed {
+		os.Exit(exitDifferent)
+	}
+}
+
+// batchPairs lists the documents of dir1 that also exist in dir2, by path relative to the directories
+func batchPairs(dir1, dir2 string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir1, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || pdfdiff.CheckInput(path) != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir1, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(dir2, name)); err != nil {
+			fmt.Printf("Skipping %s: it does not exist in %s\n", name, dir2)
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	return names, err
+}
+
+// diffSignature identifies the differences of a report by the changed regions of every page, so pairs of documents
+// broken by the same regression get the same signature even though their content differs elsewhere
+func diffSignature(report pdfdiff.Report) string {
+	if !report.Changed() {
+		return ""
+	}
+	h := sha256.New()
+	for _, page := range report.ChangedPages() {
+		// The quarantined pages are known to change on their own, whatever the regression
+		if page.Quarantined {
+			continue
+		}
+		fmt.Fprintf(h, "page %d missing %d:", page.Page, page.MissingIn)
+		for _, r := range page.Regions {
+			fmt.Fprintf(h, " %d,%d,%d,%d", r.X, r.Y, r.Width, r.Height)
+		}
+		fmt.Fprintln(h)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// printBatchSummary prints the pairs grouped by their differences, largest group first, and reports whether any pair has differences
+func printBatchSummary(results []batchResult) bool {
+	groups := make(map[string][]batchResult)
+	identical := 0
+	for _, result := range results {
+		if result.signature == "" {
+			identical++
+			continue
+		}
+		groups[result.signature] = append(groups[result.signature], result)
+	}
+	var signatures []string
+	for signature := range groups {
+		signatures = append(signatures, signature)
+	}
+	sort.Slice(signatures, func(i, j int) bool {
+		a, b := groups[signatures[i]], groups[signatures[j]]
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a[0].name < b[0].name
+	})
+
+	fmt.Printf("\n%d of %d pairs have differences, in %d distinct groups (%d pairs are identical)\n", len(results)-identical, len(results), len(signatures), identical)
+	for n, signature := range signatures {
+		group := groups[signature]
+		var pages []string
+		for _, page := range group[0].report.ChangedPages() {
+			pages = append(pages, fmt.Sprint(page.Page+1))
+		}
+		fmt.Printf("\nGroup %d: %d pairs, changed pages %s\n", n+1, len(group), strings.Join(pages, ", "))
+		for _, result := range group {
+			fmt.Printf("    %s\n", result.name)
+		}
+	}
+	return len(signatures) > 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestBatchSameStem(t *testing.T) {
+	dir := t.TempDir()
+	// a.png changed, a.gif did not: both write their own artifacts
+	writePage(t, filepath.Join(dir, "old", "a.png"), false)
+	writePage(t, filepath.Join(dir, "new", "a.png"), true)
+	for _, side := range []string{"old", "new"} {
+		// The GIF copy of the page without changes
+		in, err := os.Open(filepath.Join(dir, "old", "a.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(in)
+		in.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.Create(filepath.Join(dir, side, "a.gif"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gif.Encode(out, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		out.Close()
+	}
+
+	if got := runTool(t, dir, "batch", "-outdir", "out", "old", "new"); got != exitDifferent {
+		t.Fatalf("exit code = %d, want %d", got, exitDifferent)
+	}
+	for name, want := range map[string]int{"a.png": 1, "a.gif": 0} {
+		m, err := readManifest(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(m.ChangedPages()); got != want {
+			t.Errorf("%s: %d changed pages, want %d", name, got, want)
+		}
+	}
+}
+
+func TestDiffSignature(t *testing.T) {
+	region := pdfdiff.Region{X: 10, Y: 20, Width: 30, Height: 40}
+	changed := pdfdiff.PageResult{Page: 0, Changed: true, Regions: []pdfdiff.Region{region}}
+	flaky := pdfdiff.PageResult{Page: 1, Changed: true, Quarantined: true, Regions: []pdfdiff.Region{{X: 1, Y: 2, Width: 3, Height: 4}}}
+	report := func(pages ...pdfdiff.PageResult) pdfdiff.Report {
+		return pdfdiff.Report{Pages: pages}
+	}
+
+	if got := diffSignature(report(pdfdiff.PageResult{Page: 0})); got != "" {
+		t.Errorf("signature of identical documents = %q, want none", got)
+	}
+	if got := diffSignature(report(pdfdiff.PageResult{Page: 0}, flaky)); got != "" {
+		t.Errorf("signature of a quarantined change only = %q, want none", got)
+	}
+	if diffSignature(report(changed)) != diffSignature(report(changed, flaky)) {
+		t.Error("a quarantined page changes the signature")
+	}
+	moved := changed
+	moved.Regions = []pdfdiff.Region{{X: 11, Y: 20, Width: 30, Height: 40}}
+	if diffSignature(report(changed)) == diffSignature(report(moved)) {
+		t.Error("a region elsewhere has the same signature")
+	}
+}
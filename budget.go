@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"PdfDiff/pdfdiff"
+)
+
+// budgetFlags adds the flags of the share of the pixels allowed to change, for the whole document and for each page
+func budgetFlags(flags *flag.FlagSet) (*float64, *float64) {
+	document := flags.Float64("max-diff-percent", 0, "fail only if more than this percentage of the document changed, the mean over its pages, e.g. 0.5, instead of on any changed pixel")
+	page := flags.Float64("max-page-diff-percent", 0, "fail only if more than this percentage of the pixels of a page changed, e.g. 2, instead of on any changed pixel")
+	return document, page
+}
+
+// overBudget prints the pages, and the document, with more changes than allowed by -max-page-diff-percent and
+// -max-diff-percent, and returns the number of pages over their budget and whether the document is over its own.
+// The quarantined pages are left out.
+func overBudget(report pdfdiff.Report, maxDiff, maxPageDiff float64) (int, bool) {
+	pages := 0
+	if maxPageDiff > 0 {
+		for _, page := range report.Pages {
+			if page.PercentChanged > maxPageDiff && !page.Quarantined {
+				fmt.Printf("Page %d: %.2f%% of the page changed, above the %.2f%% allowed by -max-page-diff-percent\n", page.Page+1, page.PercentChanged, maxPageDiff)
+				pages++
+			}
+		}
+	}
+	document := false
+	if percent := report.PercentChanged(); maxDiff > 0 {
+		if document = percent > maxDiff; document {
+			fmt.Printf("%.2f%% of the document changed, above the %.2f%% allowed by -max-diff-percent\n", percent, maxDiff)
+		} else if report.Changed() {
+			fmt.Printf("%.2f%% of the document changed, within the %.2f%% allowed by -max-diff-percent\n", percent, maxDiff)
+		}
+	}
+	return pages, document
+}
+
+// pageFails reports whether a page fails the run by itself, before -allow-changed-pages: a critical region changed,
+// or it is below -min-ssim, above -max-page-diff-percent or, without them, has differences
+func pageFails(page pdfdiff.PageResult, minSSIM, maxPageDiff float64) bool {
+	switch {
+	case len(page.Critical) > 0:
+		return true
+	case minSSIM > 0:
+		return page.SSIM < minSSIM
+	case maxPageDiff > 0:
+		return page.PercentChanged > maxPageDiff
+	}
+	return page.Changed
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestOverBudget(t *testing.T) {
+	identical := pdfdiff.PageResult{Page: 0}
+	small := pdfdiff.PageResult{Page: 1, Changed: true, PercentChanged: 0.5}
+	large := pdfdiff.PageResult{Page: 2, Changed: true, PercentChanged: 4}
+	quarantined := pdfdiff.PageResult{Page: 3, Changed: true, PercentChanged: 90, Quarantined: true}
+
+	tests := []struct {
+		name                 string
+		pages                []pdfdiff.PageResult
+		maxDiff, maxPageDiff float64
+		wantPages            int
+		wantDocument         bool
+	}{
+		{"no budget", []pdfdiff.PageResult{small, large}, 0, 0, 0, false},
+		{"pages within their budget", []pdfdiff.PageResult{identical, small}, 0, 1, 0, false},
+		{"page over its budget", []pdfdiff.PageResult{small, large}, 0, 1, 1, false},
+		{"pages over their budget", []pdfdiff.PageResult{small, large}, 0, 0.1, 2, false},
+		{"at the page budget", []pdfdiff.PageResult{large}, 0, 4, 0, false},
+		// The document budget is the mean over its pages, 1.5% here
+		{"document within its budget", []pdfdiff.PageResult{identical, small, large}, 2, 0, 0, false},
+		{"document over its budget", []pdfdiff.PageResult{identical, small, large}, 1, 0, 0, true},
+		{"both budgets", []pdfdiff.PageResult{identical, small, large}, 1, 1, 1, true},
+		{"quarantined page left out", []pdfdiff.PageResult{small, quarantined}, 1, 1, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pages, document := overBudget(pdfdiff.Report{Pages: test.pages}, test.maxDiff, test.maxPageDiff)
+			if pages != test.wantPages || document != test.wantDocument {
+				t.Errorf("overBudget = %d, %v, want %d, %v", pages, document, test.wantPages, test.wantDocument)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"PdfDiff/pdfdiff"
+)
+
+// runConfigDiff prints the settings that differ between the runs of two JSON reports or manifests
+func runConfigDiff(args []string) {
+	flags := flag.NewFlagSet("config-diff", flag.ExitOnError)
+	files := parseArgs(flags, args)
+	if len(files) != 2 {
+		fmt.Println("Usage: config-diff <runA.json> <runB.json>")
+		fmt.Println("The runs are JSON reports written with -json, or the pdfdiff_manifest.json of their working directory")
+		os.Exit(exitUsage)
+	}
+
+	var configs [2]map[string]interface{}
+	for n, file := range files {
+		config, err := readConfig(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
+		configs[n] = config
+	}
+
+	changes := pdfdiff.CompareConfigs(configs[0], configs[1])
+	if len(changes) == 0 {
+		fmt.Println("Both runs have the same configuration")
+		os.Exit(exitIdentical)
+	}
+	notSet := func(v string) string {
+		if v == "" {
+			return "(not set)"
+		}
+		return v
+	}
+	for _, c := range changes {
+		fmt.Printf("%s: %s -> %s\n", c.Key, notSet(c.Value1), notSet(c.Value2))
+	}
+	os.Exit(exitDifferent)
+}
+
+// readConfig reads the configuration recorded in a JSON report or manifest
+func readConfig(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		SchemaVersion string                 `json:"schema_version"`
+		Config        map[string]interface{} `json:"config"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := pdfdiff.CheckSchemaVersion(report.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if report.Config == nil {
+		return nil, fmt.Errorf("%s does not record the configuration of its run, it was written before version 1.16 of the schema", path)
+	}
+	return report.Config, nil
+}
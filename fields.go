@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"PdfDiff/pdfdiff"
+)
+
+// loadTemplate reads the template given with -template, if any, and exits if it cannot be read
+func loadTemplate(path string) pdfdiff.Template {
+	if path == "" {
+		return pdfdiff.Template{}
+	}
+	template, err := pdfdiff.ReadTemplate(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	return template
+}
+
+// printFields prints the values of the template fields that changed, or could not be read as their type, how many
+// fields were compared, and the checks that failed in either document
+func printFields(report pdfdiff.Report) {
+	compared, changed := 0, 0
+	for _, page := range report.Pages {
+		for _, f := range page.Fields {
+			compared++
+			if f.Changed {
+				changed++
+				fmt.Printf("Page %d: field %s changed from %q to %q\n", page.Page+1, f.Name, f.Value1, f.Value2)
+			}
+			if f.Error != "" {
+				fmt.Printf("Page %d: field %s compared as text, %s\n", page.Page+1, f.Name, f.Error)
+			}
+		}
+	}
+	fmt.Printf("%d of %d template fields changed\n", changed, compared)
+
+	// A check failing in one document only is a discrepancy the revision brought or fixed, so it is told apart
+	for _, c := range report.Checks {
+		switch {
+		case !c.Passed1 && !c.Passed2:
+			fmt.Printf("CHECK FAILED: %s, in both documents: %s (document 1), %s (document 2)\n", c.Name, c.Failure1, c.Failure2)
+		case !c.Passed2:
+			fmt.Printf("CHECK FAILED: %s, in document 2 only: %s\n", c.Name, c.Failure2)
+		case !c.Passed1:
+			fmt.Printf("Check %s failed in document 1 only, and passes in document 2: %s\n", c.Name, c.Failure1)
+		}
+	}
+	if len(report.Checks) > 0 {
+		fmt.Printf("%d of %d checks passed in document 2\n", len(report.Checks)-len(report.FailedChecks()), len(report.Checks))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// maxGlyphCharacters is the number of the most changed characters printed with -glyphs
+const maxGlyphCharacters = 10
+
+// describeGlyphs describes the glyph changes of a page or a document, e.g. "1250 glyphs matched, 3 inserted, 0
+// deleted; 12 shapes changed (max 18% of their pixels), 40 respaced (max 0.420 pt), 0 moved"
+func describeGlyphs(s pdfdiff.GlyphStats) string {
+	return fmt.Sprintf("%d glyphs matched, %d inserted, %d deleted; %d shapes changed (max %.0f%% of their pixels), %d respaced (max %.3f pt), %d moved off their baseline (max %.3f pt)",
+		s.Matched, s.Inserted, s.Deleted, s.Shape, s.MaxRaster*100, s.Spacing, s.MaxSpacing, s.Position, s.MaxShift)
+}
+
+// printGlyphs prints the glyph statistics of the documents, and the characters with the most changed glyphs
+func printGlyphs(s pdfdiff.GlyphStats) {
+	fmt.Printf("Glyphs: %s\n", describeGlyphs(s))
+	if len(s.Characters) == 0 {
+		return
+	}
+	chars := make([]string, 0, len(s.Characters))
+	for c := range s.Characters {
+		chars = append(chars, c)
+	}
+	sort.Slice(chars, func(i, j int) bool {
+		if s.Characters[chars[i]] != s.Characters[chars[j]] {
+			return s.Characters[chars[i]] > s.Characters[chars[j]]
+		}
+		return chars[i] < chars[j]
+	})
+	if len(chars) > maxGlyphCharacters {
+		chars = chars[:maxGlyphCharacters]
+	}
+	list := make([]string, len(chars))
+	for i, c := range chars {
+		list[i] = fmt.Sprintf("%q (%d)", c, s.Characters[c])
+	}
+	fmt.Printf("Most changed glyphs: %s\n", strings.Join(list, ", "))
+}
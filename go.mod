@@ -5,7 +5,18 @@ go 1.20
 require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/gen2brain/go-fitz v1.22.2
-	github.com/jung-kurt/gofpdf v1.16.2
+	github.com/nwaples/rardecode v1.1.3
+	github.com/phpdave11/gofpdf v1.4.3
+	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8
+	golang.org/x/text v0.11.0
+	google.golang.org/grpc v1.58.3
+	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	golang.org/x/net v0.12.0 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230711160842-782d3b101e98 // indirect
+)
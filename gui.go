@@ -0,0 +1,243 @@
+//go:build gui
+
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"PdfDiff/pdfdiff"
+)
+
+//go:embed gui.html.tmpl
+var guiTemplate string
+
+var guiPage = template.Must(template.New("gui").Parse(guiTemplate))
+
+// guiJob is a comparison started from the GUI
+type guiJob struct {
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Done      bool   `json:"done"`
+	Error     string `json:"error,omitempty"`
+	Changed   int    `json:"changed"`
+}
+
+// gui is the desktop front-end of the tool: a page served on the local machine and opened in the browser, with file
+// pickers for the documents, the main options, a progress bar, and the viewer of the web UI for the results
+type gui struct {
+	results string
+	ui      *resultsUI
+
+	mu   sync.Mutex
+	jobs map[string]*guiJob
+}
+
+// runGUI serves the GUI on a local address and opens it in the default browser
+func runGUI(args []string) {
+	flags := flag.NewFlagSet("gui", flag.ExitOnError)
+	listenFlag := flags.String("listen", "127.0.0.1:0", "the address to serve the GUI on, only reachable from this machine by default")
+	resultsFlag := flags.String("results", filepath.Join(os.TempDir(), "pdfdiff-gui"), "the directory where the comparisons are kept")
+	noBrowserFlag := flags.Bool("no-browser", false, "do not open the GUI in the browser, only print its address")
+	if rest := parseArgs(flags, args); len(rest) != 0 {
+		fmt.Println("Usage: gui [-listen 127.0.0.1:0] [-results dir] [-no-browser]")
+		os.Exit(exitUsage)
+	}
+	if err := os.MkdirAll(*resultsFlag, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+	lis, err := net.Listen("tcp", *listenFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	g := &gui{results: *resultsFlag, ui: &resultsUI{root: *resultsFlag}, jobs: make(map[string]*guiJob)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", g.index)
+	mux.HandleFunc("/compare", g.compare)
+	mux.HandleFunc("/status/", g.status)
+	mux.HandleFunc("/download/", g.download)
+	mux.HandleFunc("/view/", g.ui.view)
+	mux.HandleFunc("/image/", g.ui.image)
+
+	url := fmt.Sprintf("http://%s/", lis.Addr())
+	fmt.Printf("The GUI is served on %s, press Ctrl+C to stop\n", url)
+	if !*noBrowserFlag {
+		if err := openBrowser(url); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: the browser could not be opened (%v), open %s instead\n", err, url)
+		}
+	}
+	checkError(http.Serve(lis, mux))
+	os.Exit(exitOutput)
+}
+
+// openBrowser opens a URL in the default browser of the desktop
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		return exec.Command("open", url).Start()
+	}
+	return exec.Command("xdg-open", url).Start()
+}
+
+func (g *gui) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	list, _ := g.ui.refresh()
+	if err := guiPage.Execute(w, list); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// compare starts the comparison of the two uploaded documents, and returns its ID
+func (g *gui) compare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "the comparison is started with POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseMultipartForm(64 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every comparison has its own directory, with its documents, whose name is the ID of the comparison in the
+	// viewer too
+	dir, err := os.MkdirTemp(g.results, "comparison-")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id := filepath.Base(dir)
+	var files []string
+	for _, field := range []string{"file1", "file2"} {
+		file, err := saveUpload(r, field, filepath.Join(dir, field))
+		if err != nil {
+			os.RemoveAll(dir)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		files = append(files, file)
+	}
+
+	opts := pdfdiff.Options{
+		OutputDir:          dir,
+		PageImages:         true,
+		Align:              r.FormValue("align") != "",
+		IgnoreAntialiasing: r.FormValue("ignore-antialiasing") != "",
+		Heatmap:            r.FormValue("heatmap") != "",
+		SideBySide:         r.FormValue("sidebyside") != "",
+		TextDiff:           r.FormValue("text") != "",
+	}
+	opts.DPI, _ = strconv.ParseFloat(r.FormValue("dpi"), 64)
+	opts.Threshold, _ = strconv.Atoi(r.FormValue("threshold"))
+
+	job := &guiJob{}
+	g.mu.Lock()
+	g.jobs[id] = job
+	g.mu.Unlock()
+	opts.Progress = func(completed, total int) {
+		g.mu.Lock()
+		job.Completed, job.Total = completed, total
+		g.mu.Unlock()
+	}
+	go g.run(job, dir, files, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// saveUpload saves an uploaded document in its own directory, under its own name, which tells its format
+func saveUpload(r *http.Request, field, dir string) (string, error) {
+	in, header, err := r.FormFile(field)
+	if err != nil {
+		return "", fmt.Errorf("choose both documents to compare")
+	}
+	defer in.Close()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, filepath.Base(header.Filename))
+	out, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return file, pdfdiff.CheckInput(file)
+}
+
+// run compares the documents of a job, then merges the difference images into a PDF and writes the manifest the
+// viewer reads
+func (g *gui) run(job *guiJob, dir string, files []string, opts pdfdiff.Options) {
+	report, err := pdfdiff.Compare(context.Background(), files[0], files[1], opts)
+	if err == nil {
+		err = pdfdiff.WriteDiffPDF(dir, report.Pages, filepath.Join(dir, "differences.pdf"), pdfdiff.Layout{Orientation: "auto", PrintSize: "auto", DPI: report.DPI})
+	}
+	if err == nil {
+		err = writeManifest(dir, &runManifest{Report: report, Orientation: "auto", PrintSize: "auto", Output: "differences.pdf", Merge: true, SideBySide: opts.SideBySide})
+	}
+	// The comparison is listed and downloaded as soon as it is done, not after the next scan
+	g.ui.invalidate()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	job.Done = true
+	if err != nil {
+		job.Error = err.Error()
+		return
+	}
+	job.Changed = len(report.ChangedPages())
+}
+
+// status returns the progress of a comparison, /status/<id>
+func (g *gui) status(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	job, ok := g.jobs[strings.TrimPrefix(r.URL.Path, "/status/")]
+	var state guiJob
+	if ok {
+		state = *job
+	}
+	g.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// download serves the merged PDF of a comparison, /download/<id>
+func (g *gui) download(w http.ResponseWriter, r *http.Request) {
+	m := g.ui.comparison(strings.TrimPrefix(r.URL.Path, "/download/"))
+	if m == nil || m.Output == "" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="differences.pdf"`)
+	http.ServeFile(w, r, filepath.Join(m.Dir, filepath.Base(m.Output)))
+}
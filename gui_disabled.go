@@ -0,0 +1,14 @@
+//go:build !gui
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runGUI reports that this build has no GUI, which is only built with the gui tag
+func runGUI(args []string) {
+	fmt.Fprintf(os.Stderr, "Error: this build has no GUI, build it with go build -tags gui\n")
+	os.Exit(exitUsage)
+}
@@ -0,0 +1,23 @@
+//go:build !gui
+
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestGUIBuild builds the package with the gui tag, which the other tests are built without, and runs the tests of the
+// GUI, so a change that breaks the GUI fails the tests too
+func TestGUIBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the package again")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not installed")
+	}
+	if out, err := exec.Command(goTool, "test", "-tags", "gui", "-run", "^TestGUI", ".").CombinedOutput(); err != nil {
+		t.Fatalf("go test -tags gui: %v\n%s", err, out)
+	}
+}
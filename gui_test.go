@@ -0,0 +1,49 @@
+//go:build gui
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestGUIRun(t *testing.T) {
+	results := t.TempDir()
+	g := &gui{results: results, ui: &resultsUI{root: results}, jobs: make(map[string]*guiJob)}
+	// The index is viewed before the comparison, so the UI has scanned the results already
+	g.index(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	dir, err := os.MkdirTemp(results, "comparison-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := filepath.Base(dir)
+	files := []string{filepath.Join(dir, "file1", "a.png"), filepath.Join(dir, "file2", "b.png")}
+	writePage(t, files[0], false)
+	writePage(t, files[1], true)
+	job := &guiJob{}
+	g.run(job, dir, files, pdfdiff.Options{OutputDir: dir, PageImages: true})
+	if job.Error != "" || job.Changed != 1 {
+		t.Fatalf("job = %+v, want 1 changed page", job)
+	}
+
+	// The comparison is there without waiting for the next scan
+	index := httptest.NewRecorder()
+	g.index(index, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(index.Body.String(), "/view/"+id) {
+		t.Errorf("the index does not list %s", id)
+	}
+	for path, handler := range map[string]http.HandlerFunc{"/view/" + id: g.ui.view, "/download/" + id: g.download} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minHeartbeat is the shortest -heartbeat interval
+const minHeartbeat = time.Second
+
+// heartbeat prints a line whenever the tool has been silent for longer than the interval,
+// so CI systems with inactivity timeouts do not kill long comparisons or merges
+type heartbeat struct {
+	mu       sync.Mutex
+	last     time.Time
+	start    time.Time
+	interval time.Duration
+	text     string
+	stop     chan struct{}
+}
+
+// heartbeatFlags registers the heartbeat flags on a subcommand
+func heartbeatFlags(flags *flag.FlagSet) (*time.Duration, *string) {
+	interval := flags.Duration("heartbeat", 0, "print a line after this much time without output, e.g. 30s (Default: disabled)")
+	text := flags.String("heartbeat-text", "Still working...", "the line printed by -heartbeat")
+	return interval, text
+}
+
+// startHeartbeat starts printing the heartbeat text after every interval without output, at least minHeartbeat. A zero
+// interval disables it.
+func startHeartbeat(interval time.Duration, text string) *heartbeat {
+	now := time.Now()
+	h := &heartbeat{last: now, start: now, interval: interval, text: text, stop: make(chan struct{})}
+	if interval <= 0 {
+		return h
+	}
+	if interval < minHeartbeat {
+		h.interval = minHeartbeat
+	}
+	go func() {
+		// Check several times per interval so the heartbeat is printed close to the deadline
+		ticker := time.NewTicker(h.interval / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case now := <-ticker.C:
+				h.mu.Lock()
+				if now.Sub(h.last) >= h.interval {
+					fmt.Printf("%s (%s elapsed)\n", h.text, now.Sub(h.start).Round(time.Second))
+					h.last = now
+				}
+				h.mu.Unlock()
+			}
+		}
+	}()
+	return h
+}
+
+// touch records that the tool just printed something
+func (h *heartbeat) touch() {
+	h.mu.Lock()
+	h.last = time.Now()
+	h.mu.Unlock()
+}
+
+// Stop stops printing the heartbeat
+func (h *heartbeat) Stop() {
+	select {
+	case <-h.stop:
+	default:
+		close(h.stop)
+	}
+}
+
+// wrap returns a progress callback that calls fn and records that it printed something
+func (h *heartbeat) wrap(fn func(completed, total int)) func(completed, total int) {
+	return func(completed, total int) {
+		fn(completed, total)
+		h.touch()
+	}
+}
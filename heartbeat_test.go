@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartHeartbeatShortInterval(t *testing.T) {
+	for _, interval := range []time.Duration{time.Nanosecond, 3 * time.Nanosecond, time.Millisecond} {
+		h := startHeartbeat(interval, "Still working...")
+		if h.interval != minHeartbeat {
+			t.Errorf("interval of -heartbeat %s = %s, want %s", interval, h.interval, minHeartbeat)
+		}
+		h.Stop()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// runImportAnnotations reads the annotations reviewers added to the exported differences and adds the ones marked as
+// expected or to ignore to an ignore file, which later comparisons load with -ignore-regions
+func runImportAnnotations(args []string) {
+	flags := flag.NewFlagSet("import-annotations", flag.ExitOnError)
+	outputFlag := flags.String("output", "pdfdiff_ignore.json", "the ignore file to add the regions to, created if it does not exist")
+
+	files := parseArgs(flags, args)
+	if len(files) == 0 {
+		fmt.Println("Usage: import-annotations [-output pdfdiff_ignore.json] <reviewed.xfdf|.fdf|.pdf>...")
+		os.Exit(exitUsage)
+	}
+
+	regions, err := pdfdiff.ReadIgnoreRegions(*outputFlag)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	known := len(regions)
+	for _, file := range files {
+		imported, err := pdfdiff.ImportAnnotations(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
+		fmt.Printf("%s: %d regions marked as expected or to ignore\n", file, len(imported))
+		regions = pdfdiff.MergeIgnoreRegions(regions, imported)
+	}
+	if err := pdfdiff.WriteIgnoreRegions(*outputFlag, regions); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+	fmt.Printf("Added %d regions to %s (%d in total)\n", len(regions)-known, *outputFlag, len(regions))
+}
+
+// ignoreRegionsFlag adds the -ignore-regions flag of the comparison subcommands
+func ignoreRegionsFlag(flags *flag.FlagSet) *string {
+	return flags.String("ignore-regions", "", "exclude the regions of ignore or mask files, separated by commas, from the comparison, e.g. pdfdiff_ignore.json,mask.yaml")
+}
+
+// loadIgnoreRegions reads the ignore and mask files given with -ignore-regions, if any, and exits if one cannot be read
+func loadIgnoreRegions(paths string) []pdfdiff.IgnoreRegion {
+	var regions []pdfdiff.IgnoreRegion
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		more, err := pdfdiff.ReadIgnoreRegions(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
+		regions = pdfdiff.MergeIgnoreRegions(regions, more)
+	}
+	return regions
+}
+
+// patternList is the value of the -ignore-text flag, which is repeated for every pattern since a pattern may
+// contain commas
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *patternList) Set(pattern string) error {
+	*l = append(*l, pattern)
+	return nil
+}
+
+// ignoreTextFlag adds the -ignore-text flag of the comparison subcommands
+func ignoreTextFlag(flags *flag.FlagSet) *patternList {
+	patterns := new(patternList)
+	flags.Var(patterns, "ignore-text", "exclude the text matched by a regular expression from the comparison, e.g. 'Printed on \\S+', repeated for every pattern")
+	return patterns
+}
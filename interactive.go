@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// askOptions asks on the terminal for the documents and the key options of a comparison that the command line does
+// not give, offering their defaults, and sets the flags to the answers. An empty answer, or the end of the input,
+// keeps the default.
+func askOptions(flags *flag.FlagSet, files []string) []string {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for len(files) < 2 {
+		name := ask(fmt.Sprintf("Document %d to compare: ", len(files)+1), "")
+		if name == "" {
+			break
+		}
+		files = append(files, name)
+	}
+	if !set["dpi"] && !set["multi-dpi"] {
+		askFlag(flags, "dpi", "Resolution in dpi, higher to catch hairline changes, lower for speed")
+	}
+	if !set["merge"] {
+		if answer := ask("Merge the difference images into a PDF? [y/N]: ", "n"); strings.HasPrefix(strings.ToLower(answer), "y") {
+			flags.Set("merge", "true")
+		}
+	}
+	if flags.Lookup("merge").Value.String() == "true" && !set["output"] {
+		askFlag(flags, "output", "Name of the merged PDF")
+	}
+	return files
+}
+
+// askFlag asks for the value of a flag until it is valid, offering its default
+func askFlag(flags *flag.FlagSet, name, question string) {
+	f := flags.Lookup(name)
+	for {
+		answer := ask(fmt.Sprintf("%s [%s]: ", question, f.DefValue), f.DefValue)
+		err := flags.Set(name, answer)
+		if err == nil {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Error: %q is not a valid value for -%s\n", answer, name)
+	}
+}
+
+// ask prints a question and reads the answer from a line of the standard input
+func ask(question, def string) string {
+	fmt.Fprint(os.Stderr, question)
+	line, err := stdin.ReadString('\n')
+	answer := strings.TrimSpace(line)
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr)
+	}
+	if answer == "" {
+		return def
+	}
+	return answer
+}
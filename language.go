@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"PdfDiff/pdfdiff"
+)
+
+// printLanguages prints the dominant languages of the documents found with -language, and the pages whose
+// language differs from the one of their document
+func printLanguages(report pdfdiff.Report) {
+	if len(report.Languages) != 2 {
+		fmt.Println("The language of the documents could not be detected")
+		return
+	}
+	fmt.Printf("Languages: %s (document 1), %s (document 2)\n", languageName(report.Languages[0]), languageName(report.Languages[1]))
+	for _, page := range report.Pages {
+		if len(page.Languages) != 2 {
+			continue
+		}
+		for doc, lang := range page.Languages {
+			if lang != "" && lang != report.Languages[doc] {
+				fmt.Printf("Page %d is in %s in document %d\n", page.Page+1, lang, doc+1)
+			}
+		}
+	}
+}
+
+// languageName returns the code of a language, or unknown
+func languageName(lang string) string {
+	if lang == "" {
+		return "unknown"
+	}
+	return lang
+}
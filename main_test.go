@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+// TestMain runs the tool instead of the tests when PDFDIFF_TEST_MAIN is set, so runTool can check the exit code
+// of a whole command line
+func TestMain(m *testing.M) {
+	if os.Getenv("PDFDIFF_TEST_MAIN") != "" {
+		main()
+		os.Exit(exitIdentical)
+	}
+	os.Exit(m.Run())
+}
+
+// runTool runs the tool with the given arguments in dir and returns its exit code
+func runTool(t *testing.T, dir string, args ...string) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PDFDIFF_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		t.Logf("%s", out)
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exitIdentical
+}
+
+// writePage writes a white page image of 32x32 pixels, with a black square of 16 pixels on it when changed, so
+// 1.5625% of its pixels differ from a page without it
+func writePage(t *testing.T, path string, changed bool) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	if changed {
+		draw.Draw(img, image.Rect(10, 10, 14, 14), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"missing input", &pdfdiff.InputError{File: "a.pdf", Err: os.ErrNotExist}, exitInput},
+		{"wrapped input error", fmt.Errorf("page 3: %w", &pdfdiff.InputError{File: "b.pdf", Err: pdfdiff.ErrPassword}), exitInput},
+		{"invalid options", fmt.Errorf("%w: the dpi should be greater than 0", pdfdiff.ErrInvalidOptions), exitUsage},
+		{"render failure", errors.New("cannot render page 2"), exitRender},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	// a.png and b.png are single pages, old and new hold two pages each, both changed in new
+	writePage(t, filepath.Join(dir, "a.png"), false)
+	writePage(t, filepath.Join(dir, "b.png"), true)
+	for _, name := range []string{"page_001.png", "page_002.png"} {
+		writePage(t, filepath.Join(dir, "old", name), false)
+		writePage(t, filepath.Join(dir, "new", name), true)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"identical", []string{"a.png", "a.png"}, exitIdentical},
+		{"different", []string{"a.png", "b.png"}, exitDifferent},
+		{"different pages", []string{"old", "new"}, exitDifferent},
+		{"missing input", []string{"a.png", "missing.png"}, exitInput},
+		{"conflicting flags", []string{"-align", "-offset", "1", "a.png", "b.png"}, exitUsage},
+		{"unknown flag", []string{"-no-such-flag", "a.png", "b.png"}, exitUsage},
+		{"one input", []string{"a.png"}, exitUsage},
+		{"unwritable output", []string{"-json", filepath.Join(dir, "missing", "report.json"), "a.png", "b.png"}, exitOutput},
+		{"deadline passed", []string{"-deadline", "1ns", "old", "new"}, exitIncomplete},
+		// The square changes a single window of the 16 the structural similarity is computed on
+		{"above -min-ssim", []string{"-min-ssim", "0.9", "a.png", "b.png"}, exitIdentical},
+		{"pages above -min-ssim", []string{"-min-ssim", "0.9", "old", "new"}, exitIdentical},
+		{"below -min-ssim", []string{"-min-ssim", "0.9999", "a.png", "b.png"}, exitDifferent},
+		{"identical with -min-ssim", []string{"-min-ssim", "1", "a.png", "a.png"}, exitIdentical},
+		{"invalid -min-ssim", []string{"-min-ssim", "high", "a.png", "b.png"}, exitUsage},
+		{"within -allow-changed-pages", []string{"-allow-changed-pages", "1", "a.png", "b.png"}, exitIdentical},
+		{"at -allow-changed-pages", []string{"-allow-changed-pages", "2", "old", "new"}, exitIdentical},
+		{"above -allow-changed-pages", []string{"-allow-changed-pages", "1", "old", "new"}, exitDifferent},
+		{"below -min-ssim, allowed", []string{"-min-ssim", "0.9999", "-allow-changed-pages", "2", "old", "new"}, exitIdentical},
+		{"below -min-ssim, not all allowed", []string{"-min-ssim", "0.9999", "-allow-changed-pages", "1", "old", "new"}, exitDifferent},
+		{"negative -allow-changed-pages", []string{"-allow-changed-pages", "-1", "a.png", "b.png"}, exitUsage},
+		{"within -max-page-diff-percent", []string{"-max-page-diff-percent", "2", "a.png", "b.png"}, exitIdentical},
+		{"above -max-page-diff-percent", []string{"-max-page-diff-percent", "1", "a.png", "b.png"}, exitDifferent},
+		{"above -max-page-diff-percent, allowed", []string{"-max-page-diff-percent", "1", "-allow-changed-pages", "2", "old", "new"}, exitIdentical},
+		{"within -max-diff-percent", []string{"-max-diff-percent", "2", "old", "new"}, exitIdentical},
+		{"above -max-diff-percent", []string{"-max-diff-percent", "1", "old", "new"}, exitDifferent},
+		{"above -max-diff-percent, pages allowed", []string{"-max-diff-percent", "1", "-allow-changed-pages", "2", "old", "new"}, exitDifferent},
+		{"-max-diff-percent over 100", []string{"-max-diff-percent", "101", "a.png", "b.png"}, exitUsage},
+		{"-fail-fast with -max-diff-percent", []string{"-fail-fast", "-max-diff-percent", "1", "a.png", "b.png"}, exitUsage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args := append([]string{"-workdir", t.TempDir()}, test.args...)
+			if got := runTool(t, dir, args...); got != test.want {
+				t.Errorf("exit code of %q = %d, want %d", test.args, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// setNice lowers the scheduling priority of the process. On Linux the priority belongs to each thread,
+// so every thread that already exists is changed; the threads started later inherit it.
+func setNice(n int) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, 0, n)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
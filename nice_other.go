@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// setNice is not supported on this platform
+func setNice(n int) error {
+	return errors.New("-nice is not supported on this platform")
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package main
+
+import "syscall"
+
+// setNice lowers the scheduling priority of the process
+func setNice(n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, n)
+}
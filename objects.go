@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// downloadInputs downloads the inputs given as URLs of objects, each to its own directory so two objects of the
+// same name do not overwrite each other, and exits if one cannot be downloaded
+func downloadInputs(files []string) {
+	dir := ""
+	for i, file := range files {
+		if !pdfdiff.IsObjectURL(file) {
+			continue
+		}
+		if dir == "" {
+			var err error
+			if dir, err = os.MkdirTemp("", "pdfdiff-inputs-"); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitOutput)
+			}
+		}
+		fmt.Printf("Downloading %s\n", file)
+		local, err := pdfdiff.DownloadObject(context.Background(), file, filepath.Join(dir, strconv.Itoa(i+1)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitInput)
+		}
+		files[i] = local
+	}
+}
+
+// uploadOutputs uploads the files below a URL prefix, e.g. s3://bucket/run-42/, under their base names. Two files of
+// the same base name are refused before anything is uploaded, instead of the second overwriting the first
+func uploadOutputs(prefix string, files []string) error {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	paths := map[string]string{}
+	var unique []string
+	for _, file := range files {
+		file = filepath.Clean(file)
+		if other, ok := paths[filepath.Base(file)]; ok {
+			if other == file {
+				continue
+			}
+			return fmt.Errorf("%s and %s would both be uploaded as %s%s: give them different names", other, file, prefix, filepath.Base(file))
+		}
+		paths[filepath.Base(file)] = file
+		unique = append(unique, file)
+	}
+	files = unique
+	for _, file := range files {
+		if err := pdfdiff.UploadObject(context.Background(), file, prefix+filepath.Base(file)); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d files have been uploaded to %s\n", len(files), prefix)
+	return nil
+}
+
+// outputFiles lists the files a comparison wrote: the merged PDFs, the reports, and the page images and the manifest
+// while they are kept
+func outputFiles(report pdfdiff.Report, merged, combined bool, output string, reports []string, images bool) []string {
+	var files []string
+	if merged {
+		files = append(files, output)
+	}
+	if combined {
+		files = append(files, combinedOutputPath(output))
+	}
+	for _, file := range reports {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if images {
+		for _, page := range report.Pages {
+			for _, name := range []string{page.DiffImage, page.CombinedImage, page.Image1, page.Image2} {
+				if name != "" {
+					files = append(files, filepath.Join(report.Dir, name))
+				}
+			}
+		}
+		files = append(files, filepath.Join(report.Dir, manifestName))
+	}
+	return files
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUploadOutputs(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"diff.pdf", "report.json", filepath.Join("html", "report.json"), filepath.Join("html", "index.html")} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		files   []string
+		want    []string
+		wantErr bool
+	}{
+		{"different names", []string{"diff.pdf", "report.json", filepath.Join("html", "index.html")},
+			[]string{"PUT /run-42/diff.pdf", "PUT /run-42/index.html", "PUT /run-42/report.json"}, false},
+		{"same file twice", []string{"report.json", "./report.json"}, []string{"PUT /run-42/report.json"}, false},
+		{"same name in two directories", []string{"diff.pdf", "report.json", filepath.Join("html", "report.json")}, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys = nil
+			var files []string
+			for _, file := range test.files {
+				files = append(files, filepath.Join(dir, file))
+			}
+			err := uploadOutputs(server.URL+"/run-42", files)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "report.json") {
+				t.Errorf("err = %v, want the name of report.json", err)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ", ") != strings.Join(test.want, ", ") {
+				t.Errorf("uploads = %q, want %q", keys, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flagProblems lists the invalid values of a command line and the flags that conflict with each other or have no
+// effect without another one, each with the way to fix it, so all of them are reported before anything runs.
+// Flags that the subcommand does not have are never set.
+func flagProblems(flags *flag.FlagSet) []string {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	value := func(name string) string {
+		if f := flags.Lookup(name); f != nil {
+			return f.Value.String()
+		}
+		return ""
+	}
+	on := func(name string) bool {
+		return value(name) == "true"
+	}
+
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if set["orientation"] && !validOrientation(value("orientation")) {
+		add("The orientation %q is invalid. It should be 'P', 'L', 'first' or 'auto'.", value("orientation"))
+	}
+	if set["printsize"] && !validPrintSize(value("printsize")) {
+		add("Invalid print size %q. It should be one of 'auto', 'A4', 'A3', 'A2', 'A1', or 'A0'.", value("printsize"))
+	}
+	if set["tile-print"] && !validTileSize(value("tile-print")) {
+		add("Invalid tile size %q for -tile-print. It should be one of 'A4', 'A3', 'A2', 'A1', or 'A0'.", value("tile-print"))
+	}
+	if _, err := parseDPIs(value("multi-dpi")); err != nil {
+		add("%v", err)
+	}
+	if nice, _ := strconv.Atoi(value("nice")); nice < 0 || nice > 19 {
+		add("The nice value %d is invalid. It should be between 0 and 19.", nice)
+	}
+	if size, _ := strconv.Atoi(value("region-thumbnails")); size < 0 {
+		add("-region-thumbnails %d is invalid: give the largest side of the thumbnails in pixels, e.g. 128.", size)
+	}
+	if n, _ := strconv.Atoi(value("allow-changed-pages")); n < 0 {
+		add("-allow-changed-pages %d is invalid: give the number of pages allowed to differ, e.g. 1.", n)
+	}
+	if size, _ := strconv.ParseFloat(value("reflow-font-size"), 64); size < 0 {
+		add("-reflow-font-size %g is invalid: give the default font size of the EPUB and FB2 documents in points, e.g. 16.", size)
+	}
+	for _, name := range []string{"max-diff-percent", "max-page-diff-percent"} {
+		if percent, _ := strconv.ParseFloat(value(name), 64); percent < 0 || percent > 100 {
+			add("-%s %g is invalid: give a percentage of the pixels between 0 and 100, e.g. 0.5.", name, percent)
+		}
+	}
+	if interval, err := time.ParseDuration(value("heartbeat")); err == nil && interval != 0 && interval < minHeartbeat {
+		add("-heartbeat %s is invalid: give at least %s without output, e.g. 30s, or 0 to disable it.", interval, minHeartbeat)
+	}
+	for _, name := range []string{"page1", "page2"} {
+		if page, _ := strconv.Atoi(value(name)); set[name] && page <= 0 {
+			add("-%s %d is invalid: the pages are numbered from 1.", name, page)
+		}
+	}
+
+	// Flags that conflict with each other
+	if (set["page1"] || set["page2"]) && on("align") {
+		add("-page1 and -page2 cannot be used with -align, which pairs the pages itself: remove one of them.")
+	}
+	if (set["page1"] || set["page2"]) && (set["offset"] || set["startoffset"]) {
+		add("-page1 and -page2 cannot be used with -offset or -startoffset: give the pages to compare with -page1 and -page2 only.")
+	}
+	if on("align") && (set["offset"] || set["startoffset"]) {
+		add("-offset and -startoffset cannot be used with -align, which finds the inserted and deleted pages itself: remove one of them.")
+	}
+	if on("fail-fast") && set["max-diff-percent"] {
+		add("-fail-fast cannot be used with -max-diff-percent, whose budget is only known once every page is compared: give -max-page-diff-percent instead.")
+	}
+	if set["min-ssim"] && (set["max-diff-percent"] || set["max-page-diff-percent"]) {
+		add("-min-ssim cannot be used with -max-diff-percent or -max-page-diff-percent: decide on either the structural similarity or the changed pixels.")
+	}
+	if set["dpi"] && value("multi-dpi") != "" {
+		add("-dpi cannot be used with -multi-dpi, whose highest resolution the images are written at: remove -dpi.")
+	}
+	if on("heatmap") && (set["color-old"] || set["color-new"]) {
+		add("-color-old and -color-new are not used by -heatmap, which colors the pixels by how much they changed: remove one of them.")
+	}
+	if on("watch") && (value("password1") == "-" || value("password2") == "-") {
+		add("-watch cannot read the passwords from the standard input again for every comparison: give them on the command line.")
+	}
+	if on("update-on-approve") && on("clean") {
+		add("-update-on-approve cannot be used with -clean, which would remove the proposed baseline: remove -clean.")
+	}
+	if on("preflight") && value("remote") != "" {
+		add("-preflight is not checked with -remote, where the workers render the pages: remove -preflight, or compare locally.")
+	}
+
+	// Flags that have no effect without one of the others. The pages of the merged PDFs are chosen by -changed-only
+	// for -merge and for the combined images of -sidebyside; the layout of the PDFs is recorded in the manifest for
+	// the report subcommand even without them.
+	requires := []struct {
+		flag  string
+		needs []string
+		fix   string
+	}{
+		{"verticalalign", []string{"sidebyside"}, "add -sidebyside, or remove -verticalalign"},
+		{"changed-only", []string{"merge", "sidebyside"}, "add -merge, or use -skip-identical to only skip the images of identical pages"},
+		{"summary-top", []string{"summary"}, "add -summary summary.md, or remove -summary-top"},
+		{"chunk", []string{"remote"}, "add -remote host:port, or remove -chunk"},
+		{"remote-tls", []string{"remote"}, "add -remote host:port, or remove -remote-tls"},
+		{"remote-ca", []string{"remote"}, "add -remote host:port, or remove -remote-ca"},
+		{"remote-token-file", []string{"remote"}, "add -remote host:port, or remove -remote-token-file"},
+		{"ocr-lang", []string{"ocr"}, "add -ocr, or remove -ocr-lang"},
+		{"heartbeat-text", []string{"heartbeat"}, "add -heartbeat 30s, or remove -heartbeat-text"},
+		{"region-thumbnails", []string{"json"}, "add -json report.json, or remove -region-thumbnails"},
+		{"report-quarantine", []string{"quarantine"}, "add -quarantine quarantine.txt, or remove -report-quarantine"},
+		{"update-on-approve", []string{"store"}, "verify against a baseline with -store, or remove -update-on-approve"},
+	}
+	for _, r := range requires {
+		if !set[r.flag] {
+			continue
+		}
+		needed := false
+		for _, name := range r.needs {
+			if v := value(name); v != "" && v != "false" && v != "0s" {
+				needed = true
+			}
+		}
+		if !needed {
+			add("-%s has no effect without -%s: %s.", r.flag, strings.Join(r.needs, " or -"), r.fix)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+// testFlags returns the flags of a comparison that flagProblems checks, parsed from args
+func testFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Bool("merge", false, "")
+	flags.Bool("sidebyside", false, "")
+	flags.Bool("verticalalign", false, "")
+	flags.Bool("changed-only", false, "")
+	flags.Bool("align", false, "")
+	flags.Bool("fail-fast", false, "")
+	flags.Bool("heatmap", false, "")
+	flags.Int("offset", 0, "")
+	flags.Int("startoffset", 0, "")
+	flags.Int("page1", 0, "")
+	flags.Int("page2", 0, "")
+	flags.Int("nice", 0, "")
+	flags.Int("allow-changed-pages", 0, "")
+	flags.Int("region-thumbnails", 0, "")
+	flags.Int("chunk", 0, "")
+	flags.Float64("dpi", pdfdiff.DefaultDPI, "")
+	flags.Float64("min-ssim", 0, "")
+	flags.String("orientation", pdfdiff.Auto, "")
+	flags.String("printsize", pdfdiff.Auto, "")
+	flags.String("multi-dpi", "", "")
+	flags.String("color-old", pdfdiff.DefaultColorOld, "")
+	flags.String("json", "", "")
+	flags.String("remote", "", "")
+	budgetFlags(flags)
+	heartbeatFlags(flags)
+	summaryFlags(flags)
+	reflowFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return flags
+}
+
+func TestFlagProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// want are parts of the problems reported, one for each
+		want []string
+	}{
+		{"defaults", nil, nil},
+		{"valid flags", []string{"-merge", "-changed-only", "-json", "r.json", "-region-thumbnails", "128", "-heartbeat", "30s", "-page2", "7"}, nil},
+		{"invalid orientation", []string{"-orientation", "X"}, []string{`orientation "X" is invalid`}},
+		{"invalid print size", []string{"-printsize", "B5"}, []string{`print size "B5"`}},
+		{"invalid resolutions", []string{"-multi-dpi", "72,abc"}, []string{`resolution "abc" in -multi-dpi`}},
+		{"nice out of range", []string{"-nice", "20"}, []string{"nice value 20"}},
+		{"negative thumbnails", []string{"-region-thumbnails", "-1", "-json", "r.json"}, []string{"-region-thumbnails -1"}},
+		{"negative allowed pages", []string{"-allow-changed-pages", "-2"}, []string{"-allow-changed-pages -2"}},
+		{"negative font size", []string{"-reflow-font-size", "-3"}, []string{"-reflow-font-size -3"}},
+		{"percentage over 100", []string{"-max-page-diff-percent", "150"}, []string{"-max-page-diff-percent 150"}},
+		{"short heartbeat", []string{"-heartbeat", "1ms"}, []string{"-heartbeat 1ms"}},
+		{"disabled heartbeat", []string{"-heartbeat", "0"}, nil},
+		{"page 0", []string{"-page1", "0"}, []string{"-page1 0"}},
+		{"pages and align", []string{"-page1", "2", "-align"}, []string{"-page1 and -page2 cannot be used with -align"}},
+		{"pages and offset", []string{"-page2", "2", "-offset", "1"}, []string{"-page1 and -page2 cannot be used with -offset"}},
+		{"align and offset", []string{"-align", "-startoffset", "1"}, []string{"-offset and -startoffset cannot be used with -align"}},
+		{"fail fast and budget", []string{"-fail-fast", "-max-diff-percent", "1"}, []string{"-fail-fast cannot be used"}},
+		{"min-ssim and budget", []string{"-min-ssim", "0.9", "-max-page-diff-percent", "1"}, []string{"-min-ssim cannot be used"}},
+		{"dpi and multi-dpi", []string{"-dpi", "150", "-multi-dpi", "72,150"}, []string{"-dpi cannot be used with -multi-dpi"}},
+		{"heatmap and colors", []string{"-heatmap", "-color-old", "00FF00"}, []string{"-color-old and -color-new are not used by -heatmap"}},
+		{"vertical align alone", []string{"-verticalalign"}, []string{"-verticalalign has no effect without -sidebyside"}},
+		{"changed only alone", []string{"-changed-only"}, []string{"-changed-only has no effect without -merge or -sidebyside"}},
+		{"changed only with side by side", []string{"-changed-only", "-sidebyside"}, nil},
+		{"chunk alone", []string{"-chunk", "8"}, []string{"-chunk has no effect without -remote"}},
+		{"heartbeat text alone", []string{"-heartbeat-text", "..."}, []string{"-heartbeat-text has no effect without -heartbeat"}},
+		{"heartbeat text with a disabled heartbeat", []string{"-heartbeat-text", "...", "-heartbeat", "0"}, []string{"-heartbeat-text has no effect"}},
+		{"summary top alone", []string{"-summary-top", "3"}, []string{"-summary-top has no effect without -summary"}},
+		// Every problem is reported at once
+		{"several problems", []string{"-nice", "-1", "-align", "-offset", "2", "-verticalalign"},
+			[]string{"nice value -1", "-offset and -startoffset cannot be used with -align", "-verticalalign has no effect"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			problems := flagProblems(testFlags(t, test.args...))
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems = %q, want %d", problems, len(test.want))
+			}
+			for i, want := range test.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFlagProblemsMissingFlags(t *testing.T) {
+	// The subcommands without a flag never report it
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	reflowFlags(flags)
+	if err := flags.Parse([]string{"-reflow-size", "360x540"}); err != nil {
+		t.Fatal(err)
+	}
+	if problems := flagProblems(flags); len(problems) > 0 {
+		t.Errorf("problems = %q, want none", problems)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// stdin reads the lines of the standard input, shared by the prompts so none of them loses what another buffered
+var stdin = bufio.NewReader(os.Stdin)
+
+// passwordFlags adds the flags giving the passwords of encrypted documents
+func passwordFlags(flags *flag.FlagSet) (*string, *string) {
+	password1 := flags.String("password1", "", "the user or owner password of the first document if it is an encrypted PDF, or - to read it from a line of the standard input")
+	password2 := flags.String("password2", "", "the password of the second document, or - to read it from the next line of the standard input")
+	return password1, password2
+}
+
+// readPasswords replaces the passwords given as - with the lines of the standard input, in the order of the documents.
+// When the standard input is a terminal, the encrypted PDFs given without a password are asked for one instead.
+func readPasswords(files []string, passwords []*string) error {
+	for i, password := range passwords {
+		if *password != "-" {
+			continue
+		}
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return &pdfdiff.InputError{File: files[i], Err: errors.New("no password on the standard input")}
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	for i, password := range passwords {
+		if *password != "" || !strings.EqualFold(filepath.Ext(files[i]), ".pdf") {
+			continue
+		}
+		doc, err := pdfdiff.Open(files[i])
+		if err == nil {
+			doc.Close()
+			continue
+		}
+		if !errors.Is(err, pdfdiff.ErrPassword) {
+			return &pdfdiff.InputError{File: files[i], Err: err}
+		}
+		fmt.Fprintf(os.Stderr, "Password for %s: ", files[i])
+		// Nothing typed leaves the password missing, and the comparison reports it
+		*password = readHidden()
+		fmt.Fprintln(os.Stderr)
+	}
+	return nil
+}
+
+// readHidden reads a line of the terminal without echoing it. The echo is turned off with stty, so where it is
+// missing the password is simply shown as it is typed.
+func readHidden() string {
+	if stty(os.Stdin, "-echo") == nil {
+		defer stty(os.Stdin, "echo")
+	}
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func stty(tty *os.File, arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = tty
+	return cmd.Run()
+}
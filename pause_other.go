@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "PdfDiff/pdfdiff"
+
+// handlePauseSignals does nothing on platforms without SIGUSR1 and SIGUSR2
+func handlePauseSignals(p *pdfdiff.Pauser) {}
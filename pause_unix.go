@@ -0,0 +1,29 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"PdfDiff/pdfdiff"
+)
+
+// handlePauseSignals pauses the comparison on SIGUSR1 and resumes it on SIGUSR2
+func handlePauseSignals(p *pdfdiff.Pauser) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 && !p.Paused() {
+				p.Pause()
+				fmt.Printf("Paused after the pages being compared, send SIGUSR2 to resume (kill -USR2 %d)\n", os.Getpid())
+			} else if sig == syscall.SIGUSR2 && p.Paused() {
+				p.Resume()
+				fmt.Println("Resumed")
+			}
+		}
+	}()
+}
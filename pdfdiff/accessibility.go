@@ -0,0 +1,138 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// readingOrderTolerance is the share of the reading order the second document may lose, e.g. to deleted content,
+// before it is a regression
+const readingOrderTolerance = 0.1
+
+// Accessibility are the basic accessibility signals of a PDF document, read from its structure tree
+type Accessibility struct {
+	// Tagged is set for documents marked as tagged with a structure tree, which assistive technologies read
+	Tagged bool `json:"tagged"`
+	// Language is the natural language of the document, e.g. en-US, or "" if it is not set
+	Language string `json:"language,omitempty"`
+	// Encrypted is set for encrypted documents, whose strings, such as the language, are not read
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Figures are the figures of the structure tree, and FiguresWithAlt the ones with an alternate text
+	Figures        int `json:"figures"`
+	FiguresWithAlt int `json:"figures_with_alt"`
+	// ReadingOrder is the number of marked contents in the logical reading order of the structure tree
+	ReadingOrder int `json:"reading_order"`
+}
+
+// AccessibilityResult compares the accessibility signals of two PDF documents
+type AccessibilityResult struct {
+	Document1 Accessibility `json:"document1"`
+	Document2 Accessibility `json:"document2"`
+	// Regressions describe the signals the second document lost
+	Regressions []string `json:"regressions,omitempty"`
+}
+
+// CheckAccessibility reads the accessibility signals of two PDF documents and reports the regressions of the second
+// one: no longer tagged, without its language, with more figures missing an alternate text, or with a reading order
+// shorter by more than a tenth. It returns nil if a document is not a PDF.
+func CheckAccessibility(file1, file2 string) (*AccessibilityResult, error) {
+	var signals [2]Accessibility
+	for n, file := range []string{file1, file2} {
+		if strings.ToLower(filepath.Ext(file)) != ".pdf" {
+			return nil, nil
+		}
+		f, err := readPDF(file)
+		if err != nil {
+			return nil, &InputError{File: file, Err: err}
+		}
+		signals[n] = f.accessibility()
+	}
+	a, b := signals[0], signals[1]
+	result := &AccessibilityResult{Document1: a, Document2: b}
+	regress := func(format string, args ...interface{}) {
+		result.Regressions = append(result.Regressions, fmt.Sprintf(format, args...))
+	}
+	if a.Tagged && !b.Tagged {
+		regress("the second document is no longer tagged")
+	}
+	if a.Language != "" && b.Language == "" && !b.Encrypted {
+		regress("the second document no longer sets its language (%s)", a.Language)
+	}
+	if missing1, missing2 := a.Figures-a.FiguresWithAlt, b.Figures-b.FiguresWithAlt; missing2 > missing1 {
+		regress("%d figures have no alternate text, instead of %d", missing2, missing1)
+	}
+	if b.Tagged && float64(b.ReadingOrder) < float64(a.ReadingOrder)*(1-readingOrderTolerance) {
+		regress("the reading order has %d marked contents, instead of %d", b.ReadingOrder, a.ReadingOrder)
+	}
+	return result, nil
+}
+
+// accessibility reads the accessibility signals of the catalog and the structure tree of the document
+func (f *pdfFile) accessibility() Accessibility {
+	var a Accessibility
+	root := f.dict(f.trailer["Root"])
+	if root == nil {
+		return a
+	}
+	if a.Encrypted = f.trailer["Encrypt"] != nil; !a.Encrypted {
+		a.Language = strings.TrimSpace(f.text(root["Lang"]))
+	}
+	tree := f.dict(root["StructTreeRoot"])
+	marked, _ := f.resolve(f.dict(root["MarkInfo"])["Marked"]).(bool)
+	a.Tagged = marked && tree != nil
+	if tree == nil {
+		return a
+	}
+	// The role map turns the custom structure types into standard ones, such as Figure
+	roles := f.dict(tree["RoleMap"])
+	role := func(s pdfName) pdfName {
+		for i := 0; i < 8; i++ {
+			mapped, ok := f.resolve(roles[string(s)]).(pdfName)
+			if !ok || mapped == s {
+				break
+			}
+			s = mapped
+		}
+		return s
+	}
+	seen := make(map[pdfRef]bool)
+	var walk func(kid interface{}, depth int)
+	walk = func(kid interface{}, depth int) {
+		if ref, ok := kid.(pdfRef); ok {
+			if seen[ref] {
+				return
+			}
+			seen[ref] = true
+		}
+		if depth > 256 {
+			return
+		}
+		switch k := f.resolve(kid).(type) {
+		case float64:
+			// A marked content of the page of the element, by its identifier
+			a.ReadingOrder++
+		case []interface{}:
+			for _, v := range k {
+				walk(v, depth+1)
+			}
+		case pdfDict:
+			switch k["Type"] {
+			case pdfName("MCR"):
+				a.ReadingOrder++
+				return
+			case pdfName("OBJR"):
+				return
+			}
+			if s, _ := f.resolve(k["S"]).(pdfName); role(s) == "Figure" {
+				a.Figures++
+				if strings.TrimSpace(f.text(k["Alt"])) != "" || strings.TrimSpace(f.text(k["ActualText"])) != "" {
+					a.FiguresWithAlt++
+				}
+			}
+			walk(k["K"], depth+1)
+		}
+	}
+	walk(tree["K"], 0)
+	return a
+}
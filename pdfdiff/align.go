@@ -0,0 +1,178 @@
+package pdfdiff
+
+import (
+	"crypto/sha256"
+	"image"
+)
+
+// alignDPI is the resolution of the pass that fingerprints the pages to align the documents
+const alignDPI = 36.0
+
+// alignDistance is the largest perceptual-hash distance of two pages that are the same page with changes
+const alignDistance = 10
+
+// PagePair is an output page of an aligned comparison: the pages of the first and second documents it compares,
+// counted from 0, or -1 for a page deleted from the first document or inserted in the second one
+type PagePair struct {
+	Page1, Page2 int
+	// Similarity tells how alike the pages look at low resolution, from 0 to 1: 1 for pages rendered identically,
+	// 0 for inserted and deleted pages
+	Similarity float64
+}
+
+// fingerprint identifies a page: sum is the same only for pages rendered to the same pixels, hash is close for
+// pages that look alike
+type fingerprint struct {
+	sum  [32]byte
+	hash uint64
+}
+
+// AlignPages pairs the pages of two documents, so that pages inserted in or deleted from the second document do not
+// shift every following page. Pages rendered identically are matched first with a longest common subsequence, then
+// the similar pages between them, and the rest are paired in order, the extra ones being inserted or deleted.
+func AlignPages(doc1, doc2 Document) ([]PagePair, error) {
+	f1, err := fingerprints(doc1)
+	if err != nil {
+		return nil, err
+	}
+	f2, err := fingerprints(doc2)
+	if err != nil {
+		return nil, err
+	}
+	identical := func(i, j int) bool { return f1[i].sum == f2[j].sum }
+	similar := func(i, j int) bool { return hammingDistance(f1[i].hash, f2[j].hash) <= alignDistance }
+
+	var pairs []PagePair
+	align(0, len(f1), 0, len(f2), identical, func(i0, i1, j0, j1 int) {
+		align(i0, i1, j0, j1, similar, func(i0, i1, j0, j1 int) {
+			pairs = appendGap(pairs, i0, i1, j0, j1)
+		}, func(i, j int) {
+			pairs = append(pairs, PagePair{Page1: i, Page2: j})
+		})
+	}, func(i, j int) {
+		pairs = append(pairs, PagePair{Page1: i, Page2: j, Similarity: 1})
+	})
+	for n, pair := range pairs {
+		if pair.Page1 >= 0 && pair.Page2 >= 0 && pair.Similarity == 0 {
+			pairs[n].Similarity = 1 - float64(hammingDistance(f1[pair.Page1].hash, f2[pair.Page2].hash))/64
+		}
+	}
+	return pairs, nil
+}
+
+// fingerprints renders every page of a document at low resolution and fingerprints it
+func fingerprints(doc Document) ([]fingerprint, error) {
+	prints := make([]fingerprint, doc.NumPage())
+	for page := range prints {
+		img, err := RenderPage(doc, page, alignDPI)
+		if err == ErrPageMissing {
+			continue
+		}
+		if err != nil {
+			return nil, &PageError{Page: page, Err: err}
+		}
+		prints[page] = fingerprint{sum: pixelSum(img), hash: dHash(img)}
+	}
+	return prints, nil
+}
+
+// pixelSum hashes the size and pixels of an image
+func pixelSum(img image.Image) [32]byte {
+	h := sha256.New()
+	b := img.Bounds()
+	h.Write([]byte{byte(b.Dx() >> 8), byte(b.Dx()), byte(b.Dy() >> 8), byte(b.Dy())})
+	row := make([]byte, 0, 4*b.Dx())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row = row[:0]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			row = append(row, byte(r>>8), byte(g>>8), byte(bl>>8), byte(a>>8))
+		}
+		h.Write(row)
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// align matches the pages [i0, i1) of the first document with the pages [j0, j1) of the second one with a longest
+// common subsequence of equal pages, calling match for the matched pages and gap for the ranges between them, in order
+func align(i0, i1, j0, j1 int, equal func(i, j int) bool, gap func(i0, i1, j0, j1 int), match func(i, j int)) {
+	// Most documents share long runs of pages at both ends, which keeps the table small
+	start := 0
+	for i0+start < i1 && j0+start < j1 && equal(i0+start, j0+start) {
+		match(i0+start, j0+start)
+		start++
+	}
+	i0, j0 = i0+start, j0+start
+	end := 0
+	for i1-end > i0 && j1-end > j0 && equal(i1-end-1, j1-end-1) {
+		end++
+	}
+	n, m := i1-end-i0, j1-end-j0
+
+	// lengths[i*(m+1)+j] is the length of the longest common subsequence of the pages from i and j on
+	lengths := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case equal(i0+i, j0+j):
+				lengths[i*(m+1)+j] = lengths[(i+1)*(m+1)+j+1] + 1
+			case lengths[(i+1)*(m+1)+j] >= lengths[i*(m+1)+j+1]:
+				lengths[i*(m+1)+j] = lengths[(i+1)*(m+1)+j]
+			default:
+				lengths[i*(m+1)+j] = lengths[i*(m+1)+j+1]
+			}
+		}
+	}
+	i, j, gi, gj := 0, 0, 0, 0
+	for i < n && j < m {
+		switch {
+		case equal(i0+i, j0+j):
+			if gi < i || gj < j {
+				gap(i0+gi, i0+i, j0+gj, j0+j)
+			}
+			match(i0+i, j0+j)
+			i, j = i+1, j+1
+			gi, gj = i, j
+		case lengths[(i+1)*(m+1)+j] >= lengths[i*(m+1)+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	if gi < n || gj < m {
+		gap(i0+gi, i0+n, j0+gj, j0+m)
+	}
+	for k := 0; k < end; k++ {
+		match(i1-end+k, j1-end+k)
+	}
+}
+
+// appendGap pairs the unmatched pages between two matches in order, as changed pages, and appends the extra pages of
+// the first document as deleted and those of the second document as inserted
+func appendGap(pairs []PagePair, i0, i1, j0, j1 int) []PagePair {
+	for i0 < i1 && j0 < j1 {
+		pairs = append(pairs, PagePair{Page1: i0, Page2: j0})
+		i0, j0 = i0+1, j0+1
+	}
+	for ; i0 < i1; i0++ {
+		pairs = append(pairs, PagePair{Page1: i0, Page2: -1})
+	}
+	for ; j0 < j1; j0++ {
+		pairs = append(pairs, PagePair{Page1: -1, Page2: j0})
+	}
+	return pairs
+}
+
+// sourcePages returns the pages of the first and second documents compared for page j of the first document,
+// or for output page j of an aligned comparison
+func (opts *Options) sourcePages(j int) (int, int) {
+	if opts.Alignment != nil {
+		return opts.Alignment[j].Page1, opts.Alignment[j].Page2
+	}
+	if j >= opts.StartOffset {
+		return j, j + opts.Offset
+	}
+	return j, j
+}
@@ -0,0 +1,101 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// annotationAuthor is the author of the exported annotations, as shown by PDF viewers
+const annotationAuthor = "PdfDiffGo"
+
+// annotation is a changed region in the coordinates of a PDF page
+type annotation struct {
+	page                     int // 0-based page of the second document
+	left, bottom, right, top float64
+	contents                 string
+}
+
+// WriteAnnotations exports the changed regions of the report as square annotations that reviewers can import into
+// the second document, e.g. with Acrobat's Import Data File. The format is FDF if the output ends in .fdf, XFDF otherwise.
+func WriteAnnotations(report Report, output string) error {
+	annotations := regionAnnotations(report)
+	href := filepath.Base(report.File2)
+	var buf bytes.Buffer
+	if strings.ToLower(filepath.Ext(output)) == ".fdf" {
+		writeFDF(&buf, href, annotations)
+	} else if err := writeXFDF(&buf, href, annotations); err != nil {
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0644)
+}
+
+// regionAnnotations converts the regions of the changed pages from image pixels to PDF points, with the origin at the
+// bottom left of the page. Pages missing from the second document have nothing to attach the annotations to.
+func regionAnnotations(report Report) []annotation {
+	scale := 72 / report.dpi()
+	var annotations []annotation
+	for _, page := range report.ChangedPages() {
+		if page.MissingIn == 2 {
+			continue
+		}
+		height := float64(page.Height) * scale
+		for n, r := range page.Regions {
+			annotations = append(annotations, annotation{
+				page:     page.Page,
+				left:     float64(r.X) * scale,
+				bottom:   height - float64(r.Y+r.Height)*scale,
+				right:    float64(r.X+r.Width) * scale,
+				top:      height - float64(r.Y)*scale,
+				contents: fmt.Sprintf("Difference %d of %d on page %d (%.2f%% of the page changed)", n+1, len(page.Regions), page.Page+1, page.PercentChanged),
+			})
+		}
+	}
+	return annotations
+}
+
+// writeXFDF writes the annotations as XFDF, the XML flavour of FDF
+func writeXFDF(buf *bytes.Buffer, href string, annotations []annotation) error {
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<xfdf xmlns="http://ns.adobe.com/xfdf/" xml:space="preserve">` + "\n")
+	buf.WriteString(`<f href="`)
+	if err := xml.EscapeText(buf, []byte(href)); err != nil {
+		return err
+	}
+	buf.WriteString("\"/>\n<annots>\n")
+	for n, a := range annotations {
+		fmt.Fprintf(buf, `<square page="%d" rect="%.2f,%.2f,%.2f,%.2f" color="#FF0000" title="%s" subject="Difference" name="pdfdiff-%d">`,
+			a.page, a.left, a.bottom, a.right, a.top, annotationAuthor, n+1)
+		buf.WriteString("<contents>")
+		if err := xml.EscapeText(buf, []byte(a.contents)); err != nil {
+			return err
+		}
+		buf.WriteString("</contents></square>\n")
+	}
+	buf.WriteString("</annots>\n</xfdf>\n")
+	return nil
+}
+
+// writeFDF writes the annotations as an FDF file, one indirect object per annotation
+func writeFDF(buf *bytes.Buffer, href string, annotations []annotation) {
+	buf.WriteString("%FDF-1.2\n")
+	var refs []string
+	for n := range annotations {
+		refs = append(refs, fmt.Sprintf("%d 0 R", n+2))
+	}
+	fmt.Fprintf(buf, "1 0 obj\n<< /FDF << /F %s /Annots [%s] >> >>\nendobj\n", fdfString(href), strings.Join(refs, " "))
+	for n, a := range annotations {
+		fmt.Fprintf(buf, "%d 0 obj\n<< /Type /Annot /Subtype /Square /Page %d /Rect [%.2f %.2f %.2f %.2f] /C [1 0 0] /T %s /Subj (Difference) /NM (pdfdiff-%d) /Contents %s >>\nendobj\n",
+			n+2, a.page, a.left, a.bottom, a.right, a.top, fdfString(annotationAuthor), n+1, fdfString(a.contents))
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+}
+
+// fdfString quotes a string as a PDF literal string
+func fdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	return "(" + r.Replace(s) + ")"
+}
@@ -0,0 +1,100 @@
+package pdfdiff
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveReportName is the name of the JSON report in an archive written by WriteArchive
+const archiveReportName = "report.json"
+
+// WriteArchive packages the outputs of a comparison in a ZIP archive, to attach them to a ticket or an email: the
+// files, such as the merged PDF and the reports, under their base names, the page images of the report, and the JSON
+// report as report.json unless one of the files is a .json report already. The images keep their names, so the paths
+// of the JSON report point to them inside the archive. The images are read from the Dir of the report, or its Images.
+func WriteArchive(report Report, files []string, output string) (err error) {
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	zw := zip.NewWriter(out)
+
+	// The same name is only written once, e.g. a JSON report also given in the files
+	written := make(map[string]bool)
+	now := time.Now()
+	add := func(name string, data func(w io.Writer) error) error {
+		if written[name] {
+			return nil
+		}
+		written[name] = true
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		return data(w)
+	}
+
+	for _, file := range files {
+		if err := add(filepath.Base(file), func(w io.Writer) error { return copyFile(w, file) }); err != nil {
+			return err
+		}
+		// The JSON report of the files replaces the one of the archive, whatever its name
+		if strings.EqualFold(filepath.Ext(file), ".json") {
+			written[archiveReportName] = true
+		}
+	}
+	for _, page := range report.Pages {
+		names := []string{page.DiffImage, page.CombinedImage, page.Image1, page.Image2}
+		if page.Vectors != nil {
+			names = append(names, page.Vectors.Overlay)
+		}
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			err := add(name, func(w io.Writer) error {
+				if report.Images != nil {
+					_, err := w.Write(report.Images.Bytes(name))
+					return err
+				}
+				return copyFile(w, filepath.Join(report.Dir, name))
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = add(archiveReportName, func(w io.Writer) error {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// copyFile copies the content of a file to w
+func copyFile(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
@@ -0,0 +1,73 @@
+package pdfdiff
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriteArchiveJSONReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  []string
+		want   []string
+		report string // the content of report.json, when it is one of the files
+	}{
+		{"generated", []string{"report.html"}, []string{"report.html", "report.json"}, ""},
+		{"same name", []string{"report.json"}, []string{"report.json"}, "report.json"},
+		{"other name", []string{"results.json", "report.html"}, []string{"report.html", "results.json"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var files []string
+			for _, name := range test.files {
+				file := filepath.Join(dir, name)
+				if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, file)
+			}
+			output := filepath.Join(dir, "out.zip")
+			if err := WriteArchive(Report{File1: "a.pdf", File2: "b.pdf"}, files, output); err != nil {
+				t.Fatal(err)
+			}
+
+			zr, err := zip.OpenReader(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer zr.Close()
+			var names []string
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+				if f.Name != archiveReportName {
+					continue
+				}
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.report != "" && string(data) != test.report {
+					t.Errorf("report.json = %q, want the file of the user", data)
+				}
+				if test.report == "" && !strings.Contains(string(data), `"a.pdf"`) {
+					t.Errorf("report.json = %q, want the report of the comparison", data)
+				}
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(test.want, ",") {
+				t.Errorf("archive = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+)
+
+// The paper color of a page is the most common light color, sampled every backgroundSample pixels. It must cover
+// at least backgroundMinShare of the samples, or the page is mostly content and is left alone.
+const (
+	backgroundSample   = 4
+	backgroundMinShare = 0.2
+)
+
+// Paper colors closer than backgroundTolerance in every channel are the same background
+const backgroundTolerance = 4
+
+// backgroundNoise is how far below white the grain of a normalized scanned paper can stay, and becomes white
+const backgroundNoise = 12
+
+// BackgroundChange is a change of the paper color of a page, e.g. a scan on gray paper compared with a white
+// original, reported once instead of as changed pixels
+type BackgroundChange struct {
+	// Color1 and Color2 are the paper colors of the pages of the first and second documents, as #RRGGBB
+	Color1 string `json:"color1"`
+	Color2 string `json:"color2"`
+}
+
+// backgroundChanges records the background changes found by the preprocess stage, by output page
+type backgroundChanges struct {
+	mu     sync.Mutex
+	byPage map[int]*BackgroundChange
+}
+
+// paperColor estimates the background color of a page, reporting false if the page has no dominant light color
+func paperColor(img image.Image) (color.RGBA, bool) {
+	b := img.Bounds()
+	type bucket struct {
+		count    int
+		r, g, bl int
+	}
+	buckets := make(map[[3]uint8]*bucket)
+	var best *bucket
+	samples := 0
+	for y := b.Min.Y; y < b.Max.Y; y += backgroundSample {
+		for x := b.Min.X; x < b.Max.X; x += backgroundSample {
+			samples++
+			c := img.At(x, y)
+			if brightness(c) < 128 {
+				continue
+			}
+			r, g, bl, _ := c.RGBA()
+			key := [3]uint8{uint8(r >> 11), uint8(g >> 11), uint8(bl >> 11)}
+			k := buckets[key]
+			if k == nil {
+				k = &bucket{}
+				buckets[key] = k
+			}
+			k.count++
+			k.r, k.g, k.bl = k.r+int(r>>8), k.g+int(g>>8), k.bl+int(bl>>8)
+			if best == nil || k.count > best.count {
+				best = k
+			}
+		}
+	}
+	if best == nil || float64(best.count) < backgroundMinShare*float64(samples) {
+		return color.RGBA{}, false
+	}
+	return color.RGBA{uint8((best.r + best.count/2) / best.count), uint8((best.g + best.count/2) / best.count), uint8((best.bl + best.count/2) / best.count), 255}, true
+}
+
+// whiten divides the pixels of a page by its paper color, so the paper becomes white and the content keeps its contrast
+func whiten(img image.Image, paper color.RGBA) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	scale := [3]float64{255 / float64(max(int(paper.R), 1)), 255 / float64(max(int(paper.G), 1)), 255 / float64(max(int(paper.B), 1))}
+	for i := 0; i < len(out.Pix); i += 4 {
+		for ch := 0; ch < 3; ch++ {
+			v := min(255, float64(out.Pix[i+ch])*scale[ch])
+			if v >= 255-backgroundNoise {
+				v = 255
+			}
+			out.Pix[i+ch] = uint8(v)
+		}
+	}
+	return out
+}
+
+// normalizeBackgrounds returns the preprocess stage that whitens the paper of both pages when their paper colors
+// differ, recording the change
+func normalizeBackgrounds(changes *backgroundChanges) PreprocessFunc {
+	return func(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+		paper1, ok1 := paperColor(img1)
+		paper2, ok2 := paperColor(img2)
+		if !ok1 || !ok2 || !pixelsDiffer(paper1, paper2, backgroundTolerance) {
+			return img1, img2, nil
+		}
+		changes.mu.Lock()
+		changes.byPage[page] = &BackgroundChange{Color1: hexColor(paper1), Color2: hexColor(paper2)}
+		changes.mu.Unlock()
+		return whiten(img1, paper1), whiten(img2, paper2), nil
+	}
+}
@@ -0,0 +1,282 @@
+package pdfdiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BaselineStore keeps the approved page images of documents, the baselines that later renders are verified against,
+// by name. A baseline is a directory of page images like the ones of the render subcommand.
+type BaselineStore interface {
+	// Fetch returns the directory holding the page images of the named baseline, downloading them below cache
+	// when the store is not a local directory
+	Fetch(ctx context.Context, name, cache string) (string, error)
+	// Save stores the page images of dir as the named baseline, replacing the previous one
+	Save(ctx context.Context, name, dir string) error
+}
+
+// OpenBaselineStore returns the store of a specification: an http:// or https:// URL for an HTTPStore, lfs:path
+// for an LFSStore in the Git repository of the current directory, or the path of a local directory for a DirStore.
+// The token, if any, authenticates with an HTTP store.
+func OpenBaselineStore(spec, token string) (BaselineStore, error) {
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("no baseline store given")
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return &HTTPStore{URL: strings.TrimSuffix(spec, "/"), Token: token}, nil
+	case strings.HasPrefix(spec, "lfs:"):
+		return &LFSStore{Repo: ".", Path: strings.TrimPrefix(spec, "lfs:")}, nil
+	}
+	return DirStore(spec), nil
+}
+
+// checkBaselineName rejects the names that would leave the store, such as ../other
+func checkBaselineName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid baseline name %q, it should not be empty nor contain a path", name)
+	}
+	return nil
+}
+
+// DirStore keeps every baseline in a subdirectory of a local directory, e.g. one shared over the network
+type DirStore string
+
+// Fetch returns the subdirectory of the baseline
+func (s DirStore) Fetch(ctx context.Context, name, cache string) (string, error) {
+	if err := checkBaselineName(name); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(string(s), name)
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("baseline %s not found in %s", name, string(s))
+	}
+	return dir, nil
+}
+
+// Save copies the page images to the subdirectory of the baseline
+func (s DirStore) Save(ctx context.Context, name, dir string) error {
+	if err := checkBaselineName(name); err != nil {
+		return err
+	}
+	return replaceImages(dir, filepath.Join(string(s), name))
+}
+
+// LFSStore keeps every baseline in a subdirectory of a Git repository whose images are tracked by Git LFS, so the
+// repository only holds pointers to them. Fetch downloads the images of a single baseline, and Save tracks and
+// stages the images of the baseline, leaving the commit to the user. Git LFS must be installed.
+type LFSStore struct {
+	// Repo is the working tree of the repository, and Path the directory of the baselines in it
+	Repo, Path string
+}
+
+// Fetch downloads the images of the baseline, if they are only pointers, and returns its directory in the working tree
+func (s *LFSStore) Fetch(ctx context.Context, name, cache string) (string, error) {
+	if err := checkBaselineName(name); err != nil {
+		return "", err
+	}
+	rel := path.Join(filepath.ToSlash(s.Path), name)
+	if err := s.git(ctx, "lfs", "pull", "--include="+rel+"/**"); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.Repo, filepath.FromSlash(rel))
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("baseline %s not found in %s", name, rel)
+	}
+	return dir, nil
+}
+
+// Save copies the page images to the directory of the baseline and stages them with Git LFS
+func (s *LFSStore) Save(ctx context.Context, name, dir string) error {
+	if err := checkBaselineName(name); err != nil {
+		return err
+	}
+	rel := path.Join(filepath.ToSlash(s.Path), name)
+	if err := replaceImages(dir, filepath.Join(s.Repo, filepath.FromSlash(rel))); err != nil {
+		return err
+	}
+	if err := s.git(ctx, "lfs", "track", path.Join(filepath.ToSlash(s.Path), "**", "*.png")); err != nil {
+		return err
+	}
+	return s.git(ctx, "add", "--all", ".gitattributes", rel)
+}
+
+func (s *LFSStore) git(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.Repo}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// HTTPStore keeps every baseline as a ZIP archive of its page images on an HTTP server, at the URL of the store
+// followed by /name.zip. Fetch downloads it with GET and Save uploads it with PUT, e.g. to a WebDAV share or an
+// artifact repository.
+type HTTPStore struct {
+	URL string
+	// Token, if set, is sent as a bearer token with every request
+	Token string
+	// Client sends the requests (Default: http.DefaultClient)
+	Client *http.Client
+}
+
+// Fetch downloads the archive of the baseline and extracts its images below cache
+func (s *HTTPStore) Fetch(ctx context.Context, name, cache string) (string, error) {
+	if err := checkBaselineName(name); err != nil {
+		return "", err
+	}
+	resp, err := s.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("baseline %s: %v", name, err)
+	}
+
+	dir := filepath.Join(cache, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	for _, file := range archive.File {
+		// The images are extracted flat, so no name of the archive can leave the directory
+		base := path.Base(file.Name)
+		if file.FileInfo().IsDir() || !isRasterImage(base) {
+			continue
+		}
+		r, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, base), content, 0644); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// Save uploads the page images of dir as the archive of the baseline
+func (s *HTTPStore) Save(ctx context.Context, name, dir string) error {
+	if err := checkBaselineName(name); err != nil {
+		return err
+	}
+	names, err := baselineImages(dir)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, base := range names {
+		content, err := os.ReadFile(filepath.Join(dir, base))
+		if err != nil {
+			return err
+		}
+		// The images are already compressed
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: base, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, name, &buf)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request for the archive of a baseline, and fails unless it succeeds
+func (s *HTTPStore) do(ctx context.Context, method, name string, body io.Reader) (*http.Response, error) {
+	url := s.URL + "/" + name + ".zip"
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	return resp, nil
+}
+
+// baselineImages lists the page images of a directory, in name order
+func baselineImages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isRasterImage(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// replaceImages replaces the page images of dst with the ones of src, so no page of a longer previous baseline is left
+func replaceImages(src, dst string) error {
+	names, err := baselineImages(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	old, err := baselineImages(dst)
+	if err != nil {
+		return err
+	}
+	for _, base := range old {
+		if err := os.Remove(filepath.Join(dst, base)); err != nil {
+			return err
+		}
+	}
+	for _, base := range names {
+		content, err := os.ReadFile(filepath.Join(src, base))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, base), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The kinds of the break changes
+const (
+	BreakPage = "page"
+	BreakLine = "line"
+)
+
+// breakQuoteWords is the number of words quoted before a page break
+const breakQuoteWords = 4
+
+// BreakChange is a page break, or line breaks, that moved between the documents, found with TextDiff. The text
+// around the breaks may be the same, so the layout changes are reviewed apart from the text changes.
+type BreakChange struct {
+	// Page is the page of the second document, from 0
+	Page int `json:"page"`
+	// Kind is page, for the break at the end of the page, or line
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	// Paragraph and Line are where the page now breaks, counted from 1 on the page, and After its last words
+	Paragraph int    `json:"paragraph,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	After     string `json:"after,omitempty"`
+	// Paragraphs are the paragraphs of the page whose lines break elsewhere, from 1, and Lines the number of line
+	// breaks that moved
+	Paragraphs []int `json:"paragraphs,omitempty"`
+	Lines      int   `json:"lines,omitempty"`
+}
+
+// breakWord is a word of the text of a document, where it is, and the breaks after it
+type breakWord struct {
+	text                  string
+	page, paragraph, line int
+	lineEnd, pageEnd      bool
+}
+
+// documentWords returns the words of the text of every page of a document. The paragraphs are the blocks of the
+// text, separated by empty lines.
+func documentWords(doc Document) ([]breakWord, error) {
+	var words []breakWord
+	for page := 0; page < doc.NumPage(); page++ {
+		text, err := pageText(doc, page)
+		if err != nil {
+			return nil, err
+		}
+		start := len(words)
+		paragraph := 0
+		for _, block := range strings.Split(text, "\n\n") {
+			line := 0
+			for _, l := range strings.Split(block, "\n") {
+				fields := strings.Fields(l)
+				for _, w := range fields {
+					words = append(words, breakWord{text: w, page: page, paragraph: paragraph, line: line})
+				}
+				if len(fields) > 0 {
+					words[len(words)-1].lineEnd = true
+					line++
+				}
+			}
+			if line > 0 {
+				paragraph++
+			}
+		}
+		if len(words) > start {
+			words[len(words)-1].pageEnd = true
+		}
+	}
+	return words, nil
+}
+
+// BreakChanges pairs the words of the text of both documents and returns the page breaks and the line breaks that
+// moved: the pages of the second document ending after other words than the same pages of the first one, and the
+// words paired that end a line in one document only, by page
+func BreakChanges(doc1, doc2 Document) ([]BreakChange, error) {
+	words1, err := documentWords(doc1)
+	if err != nil {
+		return nil, err
+	}
+	words2, err := documentWords(doc2)
+	if err != nil {
+		return nil, err
+	}
+	a, b := make([]string, len(words1)), make([]string, len(words2))
+	for i, w := range words1 {
+		a[i] = w.text
+	}
+	for j, w := range words2 {
+		b[j] = w.text
+	}
+	pairs := make(map[int]int)
+	for _, e := range wordEdits(a, b) {
+		if e.op == "equal" {
+			pairs[e.j] = e.i
+		}
+	}
+	// The last word of every page of the first document
+	pageEnds := make(map[int]int)
+	for i, w := range words1 {
+		if w.pageEnd {
+			pageEnds[w.page] = i
+		}
+	}
+
+	var changes []BreakChange
+	lines := -1 // the line break change of the page
+	for j, w := range words2 {
+		i, paired := pairs[j]
+		if w.pageEnd && w.page < doc2.NumPage()-1 {
+			// The pairing of repeated words is ambiguous, so a page ending with the same words did not change
+			end, ok := pageEnds[w.page]
+			if (!paired || i != end) && (!ok || lastWords(words2, j) != lastWords(words1, end)) {
+				changes = append(changes, pageBreakChange(w, j, words2, words1, end, ok))
+			}
+		}
+		// The breaks at the end of the pages are the page breaks, whose lines are not compared
+		if !paired || w.pageEnd || words1[i].pageEnd || w.lineEnd == words1[i].lineEnd {
+			continue
+		}
+		if lines < 0 || changes[lines].Page != w.page {
+			changes = append(changes, BreakChange{Page: w.page, Kind: BreakLine})
+			lines = len(changes) - 1
+		}
+		c := &changes[lines]
+		c.Lines++
+		if n := len(c.Paragraphs); n == 0 || c.Paragraphs[n-1] != w.paragraph+1 {
+			c.Paragraphs = append(c.Paragraphs, w.paragraph+1)
+		}
+	}
+	for k := range changes {
+		if c := &changes[k]; c.Kind == BreakLine {
+			c.Description = fmt.Sprintf("%d line breaks moved in %s", c.Lines, listParagraphs(c.Paragraphs))
+		}
+	}
+	return changes, nil
+}
+
+// pageBreakChange describes the page break after the word j of the second document, and the one the same page of
+// the first document had after its word end, if it has that page
+func pageBreakChange(w breakWord, j int, words2, words1 []breakWord, end int, ok bool) BreakChange {
+	c := BreakChange{Page: w.page, Kind: BreakPage, Paragraph: w.paragraph + 1, Line: w.line + 1, After: lastWords(words2, j)}
+	c.Description = fmt.Sprintf("the page now breaks after paragraph %d, line %d (%q)", c.Paragraph, c.Line, "..."+c.After)
+	if ok {
+		before := words1[end]
+		c.Description += fmt.Sprintf(", instead of after paragraph %d, line %d (%q)", before.paragraph+1, before.line+1, "..."+lastWords(words1, end))
+	}
+	return c
+}
+
+// lastWords returns the last words of a page up to the word i
+func lastWords(words []breakWord, i int) string {
+	start := i
+	for start > 0 && i-start+1 < breakQuoteWords && words[start-1].page == words[i].page {
+		start--
+	}
+	text := make([]string, 0, i-start+1)
+	for _, w := range words[start : i+1] {
+		text = append(text, w.text)
+	}
+	return strings.Join(text, " ")
+}
+
+// listParagraphs names the paragraphs of a page, e.g. "paragraph 2" or "paragraphs 2, 3 and 5"
+func listParagraphs(paragraphs []int) string {
+	if len(paragraphs) == 1 {
+		return fmt.Sprintf("paragraph %d", paragraphs[0])
+	}
+	list := make([]string, len(paragraphs))
+	for i, p := range paragraphs {
+		list[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("paragraphs %s and %s", strings.Join(list[:len(list)-1], ", "), list[len(list)-1])
+}
@@ -0,0 +1,362 @@
+package pdfdiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/disintegration/imaging"
+	"github.com/nwaples/rardecode"
+)
+
+// bundleExtensions lists the archive formats that are read as a sequence of page images
+var bundleExtensions = map[string]bool{
+	".cbz": true,
+	".zip": true,
+	".cbr": true,
+}
+
+// bundleImageExtensions lists the page image formats recognised inside an archive
+var bundleImageExtensions = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+}
+
+// imageBundle is a page source backed by the page images of a zip/cbz or rar/cbr archive.
+// Pages are ordered by name; an empty name means the page only exists in the document it was matched with.
+type imageBundle struct {
+	names []string
+	pages map[string][]byte
+}
+
+// isBundle reports whether the file is an archive of page images
+func isBundle(filename string) bool {
+	return bundleExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// openImageBundle reads all the page images of a zip/cbz or rar/cbr archive into memory
+func openImageBundle(filename string) (*imageBundle, error) {
+	if strings.ToLower(filepath.Ext(filename)) == ".cbr" {
+		rc, err := rardecode.OpenReader(filename, "")
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readRarBundle(filename, &rc.Reader)
+	}
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return readZipBundle(filename, &zr.Reader)
+}
+
+// openImageDir reads the page images of a directory, such as the golden pages of a visual regression suite.
+// The pages are placed by the last number of their names, page_001.png being the first page as written by the render
+// subcommand, so a missing image is a missing page; directories whose images are not all numbered are ordered by name.
+func openImageDir(dir string) (*imageBundle, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	b := newImageBundle()
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		err = b.add(dir, entry.Name(), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(b.names) == 0 {
+		return nil, fmt.Errorf("directory %s does not contain any page images", dir)
+	}
+	sortNatural(b.names)
+	b.placeByNumber()
+	return b, nil
+}
+
+// pageNumbers finds the numbers of a page image name, the last of which is its page number
+var pageNumbers = regexp.MustCompile(`[0-9]+`)
+
+// placeByNumber moves every page to the index told by the number of its name, counted from 1 unless a page is
+// numbered 0. The pages are left in name order if a name has no number or two names have the same one.
+func (b *imageBundle) placeByNumber() {
+	numbers := make([]int, len(b.names))
+	first := 1
+	for i, name := range b.names {
+		found := pageNumbers.FindAllString(bundlePageKey(name), -1)
+		if len(found) == 0 {
+			return
+		}
+		n, err := strconv.Atoi(found[len(found)-1])
+		// Numbers far too large for pages are dates or serial numbers rather than page numbers
+		if err != nil || n > 100000 {
+			return
+		}
+		numbers[i] = n
+		if n == 0 {
+			first = 0
+		}
+	}
+	var names []string
+	for i, n := range numbers {
+		for len(names) <= n-first {
+			names = append(names, "")
+		}
+		if names[n-first] != "" {
+			return
+		}
+		names[n-first] = b.names[i]
+	}
+	b.names = names
+}
+
+// readImageBundle reads the page images of an archive held in memory, whose format is told by the extension of its name
+func readImageBundle(name string, r io.ReaderAt, size int64) (*imageBundle, error) {
+	if strings.ToLower(filepath.Ext(name)) == ".cbr" {
+		rr, err := rardecode.NewReader(io.NewSectionReader(r, 0, size), "")
+		if err != nil {
+			return nil, err
+		}
+		return readRarBundle(name, rr)
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return readZipBundle(name, zr)
+}
+
+func readRarBundle(filename string, rr *rardecode.Reader) (*imageBundle, error) {
+	b := newImageBundle()
+	for {
+		header, err := rr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.IsDir {
+			continue
+		}
+		if err := b.add(filename, header.Name, rr); err != nil {
+			return nil, err
+		}
+	}
+	return b.sorted(filename)
+}
+
+func readZipBundle(filename string, zr *zip.Reader) (*imageBundle, error) {
+	b := newImageBundle()
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = b.add(filename, f.Name, r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b.sorted(filename)
+}
+
+func newImageBundle() *imageBundle {
+	return &imageBundle{pages: make(map[string][]byte)}
+}
+
+// add reads an entry of the archive, if it is a page image
+func (b *imageBundle) add(filename, name string, r io.Reader) error {
+	if !bundleImageExtensions[strings.ToLower(path.Ext(name))] {
+		return nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading %s from %s: %v", name, filename, err)
+	}
+	b.names = append(b.names, name)
+	b.pages[name] = data
+	return nil
+}
+
+// sorted orders the pages of the archive by name once they are all read
+func (b *imageBundle) sorted(filename string) (*imageBundle, error) {
+	if len(b.names) == 0 {
+		return nil, fmt.Errorf("archive %s does not contain any page images", filename)
+	}
+	sortNatural(b.names)
+	return b, nil
+}
+
+// NumPage returns the number of pages in the bundle, including pages that only exist in the matched bundle
+func (b *imageBundle) NumPage() int {
+	return len(b.names)
+}
+
+// Image decodes the page image at the given index
+func (b *imageBundle) Image(pageNumber int) (image.Image, error) {
+	if pageNumber < 0 || pageNumber >= len(b.names) {
+		return nil, ErrPageMissing
+	}
+	data, ok := b.pages[b.names[pageNumber]]
+	if !ok {
+		return nil, ErrPageMissing
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
+
+// Close releases the page images held in memory
+func (b *imageBundle) Close() error {
+	b.pages = nil
+	return nil
+}
+
+// matchBundlePages lines up the pages of two bundles by file name so that scanning tools which insert or
+// drop a page do not shift every following comparison. Names that exist in only one bundle become a missing page in the other.
+func matchBundlePages(b1, b2 *imageBundle) {
+	seen := make(map[string]bool)
+	var union []string
+	for _, name := range append(append([]string{}, b1.names...), b2.names...) {
+		// Pages missing from a numbered directory have no name to match
+		if name == "" {
+			continue
+		}
+		key := bundlePageKey(name)
+		if !seen[key] {
+			seen[key] = true
+			union = append(union, name)
+		}
+	}
+	sortNatural(union)
+
+	rebuild := func(b *imageBundle) {
+		byKey := make(map[string]string)
+		for _, name := range b.names {
+			byKey[bundlePageKey(name)] = name
+		}
+		names := make([]string, len(union))
+		for i, name := range union {
+			if own, ok := byKey[bundlePageKey(name)]; ok {
+				names[i] = own
+			}
+			// Names left empty have no data, so Image reports the page as missing
+		}
+		b.names = names
+	}
+	rebuild(b1)
+	rebuild(b2)
+}
+
+// bundlePageKey returns the name used to pair pages between bundles: the base name without directory or extension
+func bundlePageKey(name string) string {
+	base := path.Base(name)
+	return strings.ToLower(strings.TrimSuffix(base, path.Ext(base)))
+}
+
+// sortNatural sorts names so that embedded numbers compare by value (page2 before page10)
+func sortNatural(names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return naturalLess(bundlePageKey(names[i]), bundlePageKey(names[j]))
+	})
+}
+
+// naturalLess compares two strings treating runs of digits as numbers
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ra, rb := rune(a[0]), rune(b[0])
+		if unicode.IsDigit(ra) && unicode.IsDigit(rb) {
+			na, restA := splitDigits(a)
+			nb, restB := splitDigits(b)
+			// Compare by length first to avoid overflowing on long digit runs, then lexically
+			trimA, trimB := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(trimA) != len(trimB) {
+				return len(trimA) < len(trimB)
+			}
+			if trimA != trimB {
+				return trimA < trimB
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ra != rb {
+			return ra < rb
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// splitDigits splits the leading run of digits from s
+func splitDigits(s string) (string, string) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// isRasterImage reports whether the file is a page image, compared at its own pixels like the pages of an archive
+func isRasterImage(filename string) bool {
+	return bundleImageExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// rasterImage is a document of a single page backed by an image file, such as a rendered proof. The page is not
+// rendered again at the resolution of the comparison, so its pixels are compared as they are.
+type rasterImage struct {
+	data []byte
+}
+
+// openRasterImage checks that the image can be decoded and keeps it as a document of one page
+func openRasterImage(filename string, data []byte) (*rasterImage, error) {
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("cannot decode the image %s: %v", filename, err)
+	}
+	return &rasterImage{data: data}, nil
+}
+
+// NumPage returns 1, the page of the image
+func (r *rasterImage) NumPage() int {
+	return 1
+}
+
+// Image decodes the image, every time so the stages of the comparison never share the pixels of a page
+func (r *rasterImage) Image(pageNumber int) (image.Image, error) {
+	if pageNumber != 0 || r.data == nil {
+		return nil, ErrPageMissing
+	}
+	return imaging.Decode(bytes.NewReader(r.data))
+}
+
+// Close releases the image held in memory
+func (r *rasterImage) Close() error {
+	r.data = nil
+	return nil
+}
@@ -0,0 +1,365 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// chartTolerance is the smallest change of a chart reported, in points, since producers round their coordinates
+const chartTolerance = 0.5
+
+// A bar chart is at least minBars filled rectangles standing on the same axis with the same thickness, and the line
+// of a line chart a stroked polyline of at least minLinePoints vertices from left to right
+const (
+	minBars       = 2
+	minLinePoints = 3
+)
+
+// ChartChange is a quantitative change of a vector chart, found by comparing the paths it is drawn with
+type ChartChange struct {
+	// Kind is "bar", "line" or "axis"
+	Kind string `json:"kind"`
+	// Chart is the bar chart of the page, counted from 1 in reading order, 0 for a line
+	Chart int `json:"chart,omitempty"`
+	// Index is the bar of the chart, left to right or top to bottom, or the line of the page, counted from 1
+	Index int `json:"index,omitempty"`
+	// Point is the vertex of a line, counted from 1
+	Point int `json:"point,omitempty"`
+	// Value1 and Value2 are the length of the bar, the height of the point above the axis of the line (or its
+	// position from the top of the page when the line has no axis), the position of the axis, or the number of
+	// points of a line that gained or lost some, in the first and second documents. Lengths are in points.
+	Value1 float64 `json:"value1"`
+	Value2 float64 `json:"value2"`
+	// Percent is the relative change of the value, omitted for added and removed bars and for moves
+	Percent float64 `json:"percent,omitempty"`
+	// Description tells the change, e.g. "bar 3 of chart 1 grew 12.0%"
+	Description string `json:"description"`
+}
+
+// barSeries is a group of bars standing on the same axis
+type barSeries struct {
+	vertical bool         // the bars grow upwards from a horizontal axis, rightwards from a vertical one otherwise
+	baseline float64      // position of the axis: its y for vertical bars, its x for horizontal ones
+	bars     [][4]float64 // left, top, right and bottom of the bars, in order along the axis
+	box      [4]float64
+}
+
+// chartLine is a polyline of a line chart
+type chartLine struct {
+	points  [][2]float64
+	box     [4]float64
+	axis    float64 // y of the horizontal axis below the line
+	hasAxis bool
+}
+
+// pageCharts are the charts drawn on a page
+type pageCharts struct {
+	series []barSeries
+	lines  []chartLine
+}
+
+// parseSVGCharts finds the bars, lines and axes of the charts drawn by an SVG page, in points from the top left corner
+func parseSVGCharts(svg string) pageCharts {
+	var rects [][4]float64
+	var lines []chartLine
+	var axes [][3]float64 // y, left and right of the horizontal stroked segments
+	walkSVG(svg, func(name string, attrs map[string]string, m svgMatrix, hidden bool) {
+		if name != "path" || hidden {
+			return
+		}
+		isStroked, _ := stroked(attrs)
+		filled := attrs["fill"] != "none"
+		for _, sub := range parsePath(attrs["d"]) {
+			if sub.curved {
+				continue
+			}
+			points := make([][2]float64, len(sub.points))
+			for i, p := range sub.points {
+				points[i] = [2]float64{m[0]*p[0] + m[2]*p[1] + m[4], m[1]*p[0] + m[3]*p[1] + m[5]}
+			}
+			switch {
+			case filled && sub.closed:
+				if r, ok := axisAlignedRect(points); ok {
+					rects = append(rects, r)
+				}
+			case isStroked && !filled && len(points) == 2 && math.Abs(points[0][1]-points[1][1]) < 0.01:
+				axes = append(axes, [3]float64{points[0][1], math.Min(points[0][0], points[1][0]), math.Max(points[0][0], points[1][0])})
+			case isStroked && !filled && !sub.closed && len(points) >= minLinePoints && increasingX(points):
+				lines = append(lines, chartLine{points: points, box: pointsBox(points)})
+			}
+		}
+	})
+
+	var charts pageCharts
+	vertical, rest := groupBars(rects, true)
+	horizontal, _ := groupBars(rest, false)
+	charts.series = append(vertical, horizontal...)
+	sort.SliceStable(charts.series, func(i, j int) bool { return readingOrder(charts.series[i].box, charts.series[j].box) })
+
+	// The axis of a line is the nearest horizontal segment below it that spans it
+	for i := range lines {
+		l := &lines[i]
+		for _, a := range axes {
+			if a[0] >= l.box[3]-chartTolerance && a[1] <= l.box[0]+chartTolerance && a[2] >= l.box[2]-chartTolerance && (!l.hasAxis || a[0] < l.axis) {
+				l.axis, l.hasAxis = a[0], true
+			}
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return readingOrder(lines[i].box, lines[j].box) })
+	charts.lines = lines
+	return charts
+}
+
+// axisAlignedRect returns the rectangle a closed subpath draws, if it is one with its sides along the axes
+func axisAlignedRect(points [][2]float64) ([4]float64, bool) {
+	if n := len(points); n == 5 && points[4] == points[0] {
+		points = points[:4]
+	}
+	if len(points) != 4 {
+		return [4]float64{}, false
+	}
+	for i := range points {
+		p, q := points[i], points[(i+1)%4]
+		if math.Abs(p[0]-q[0]) > 0.01 && math.Abs(p[1]-q[1]) > 0.01 {
+			return [4]float64{}, false
+		}
+	}
+	r := pointsBox(points)
+	return r, r[2]-r[0] > 0 && r[3]-r[1] > 0
+}
+
+// increasingX reports whether the vertices go from left to right, as the values of a line chart do
+func increasingX(points [][2]float64) bool {
+	for i := 1; i < len(points); i++ {
+		if points[i][0] <= points[i-1][0] {
+			return false
+		}
+	}
+	return true
+}
+
+func pointsBox(points [][2]float64) [4]float64 {
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points {
+		box[0], box[1] = math.Min(box[0], p[0]), math.Min(box[1], p[1])
+		box[2], box[3] = math.Max(box[2], p[0]), math.Max(box[3], p[1])
+	}
+	return box
+}
+
+// readingOrder reports whether box a comes before box b, top to bottom and then left to right
+func readingOrder(a, b [4]float64) bool {
+	if math.Abs(a[1]-b[1]) > chartTolerance {
+		return a[1] < b[1]
+	}
+	return a[0] < b[0]
+}
+
+// groupBars groups the rectangles standing on the same axis with the same thickness into bar series, and returns
+// the rectangles left over
+func groupBars(rects [][4]float64, vertical bool) ([]barSeries, [][4]float64) {
+	shape := barSeries{vertical: vertical}
+	var series []barSeries
+	var rest [][4]float64
+	used := make([]bool, len(rects))
+	for i, r := range rects {
+		if used[i] {
+			continue
+		}
+		group := [][4]float64{r}
+		for j := i + 1; j < len(rects); j++ {
+			if !used[j] && math.Abs(shape.base(rects[j])-shape.base(r)) <= chartTolerance && math.Abs(shape.thickness(rects[j])-shape.thickness(r)) <= chartTolerance {
+				group = append(group, rects[j])
+				used[j] = true
+			}
+		}
+		if len(group) < minBars {
+			rest = append(rest, r)
+			continue
+		}
+		s := barSeries{vertical: vertical, baseline: shape.base(r), bars: group}
+		sort.Slice(s.bars, func(a, b int) bool {
+			if vertical {
+				return s.bars[a][0] < s.bars[b][0]
+			}
+			return s.bars[a][1] < s.bars[b][1]
+		})
+		var points [][2]float64
+		for _, b := range s.bars {
+			points = append(points, [2]float64{b[0], b[1]}, [2]float64{b[2], b[3]})
+		}
+		s.box = pointsBox(points)
+		series = append(series, s)
+	}
+	return series, rest
+}
+
+// base returns the position of the side of a bar on the axis
+func (s barSeries) base(bar [4]float64) float64 {
+	if s.vertical {
+		return bar[3]
+	}
+	return bar[0]
+}
+
+// length returns the length of a bar from its axis
+func (s barSeries) length(bar [4]float64) float64 {
+	if s.vertical {
+		return bar[3] - bar[1]
+	}
+	return bar[2] - bar[0]
+}
+
+// thickness returns the size of a bar along the axis
+func (s barSeries) thickness(bar [4]float64) float64 {
+	if s.vertical {
+		return bar[2] - bar[0]
+	}
+	return bar[3] - bar[1]
+}
+
+// center returns the position of a bar along the axis
+func (s barSeries) center(bar [4]float64) float64 {
+	if s.vertical {
+		return (bar[0] + bar[2]) / 2
+	}
+	return (bar[1] + bar[3]) / 2
+}
+
+// overlap returns the area common to two boxes
+func overlap(a, b [4]float64) float64 {
+	w, h := math.Min(a[2], b[2])-math.Max(a[0], b[0]), math.Min(a[3], b[3])-math.Max(a[1], b[1])
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// percentChange returns the relative change from v1 to v2, in percent
+func percentChange(v1, v2 float64) float64 {
+	if v1 == 0 {
+		return 0
+	}
+	return (v2 - v1) / v1 * 100
+}
+
+// compareCharts pairs the charts of both pages by position and returns the changes of their bars, lines and axes
+func compareCharts(c1, c2 pageCharts) []ChartChange {
+	var changes []ChartChange
+	used := make([]bool, len(c2.series))
+	for n, s1 := range c1.series {
+		best, bestArea := -1, 0.0
+		for i, s2 := range c2.series {
+			if area := overlap(s1.box, s2.box); !used[i] && s2.vertical == s1.vertical && area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			changes = append(changes, compareBars(n+1, s1, c2.series[best])...)
+		}
+	}
+
+	used = make([]bool, len(c2.lines))
+	for n, l1 := range c1.lines {
+		best, bestArea := -1, 0.0
+		for i, l2 := range c2.lines {
+			// Flat lines have an empty box, so they are grown by the tolerance to overlap
+			grow := func(b [4]float64) [4]float64 {
+				return [4]float64{b[0] - chartTolerance, b[1] - chartTolerance, b[2] + chartTolerance, b[3] + chartTolerance}
+			}
+			if area := overlap(grow(l1.box), grow(l2.box)); !used[i] && area > bestArea {
+				best, bestArea = i, area
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			changes = append(changes, compareLines(n+1, l1, c2.lines[best])...)
+		}
+	}
+	return changes
+}
+
+// compareBars returns the changes of the bars and the axis of a chart
+func compareBars(chart int, s1, s2 barSeries) []ChartChange {
+	var changes []ChartChange
+	if moved := s2.baseline - s1.baseline; math.Abs(moved) > chartTolerance {
+		direction := map[bool]string{true: "down", false: "up"}[moved > 0]
+		if !s1.vertical {
+			direction = map[bool]string{true: "right", false: "left"}[moved > 0]
+		}
+		changes = append(changes, ChartChange{Kind: "axis", Chart: chart, Value1: s1.baseline, Value2: s2.baseline,
+			Description: fmt.Sprintf("the axis of chart %d moved %s %.1f pt", chart, direction, math.Abs(moved))})
+	}
+
+	// Bars are paired by position, unless both charts have the same number of bars
+	pairs := make([]int, len(s1.bars))
+	matched := make([]bool, len(s2.bars))
+	for i, b1 := range s1.bars {
+		pairs[i] = -1
+		if len(s1.bars) == len(s2.bars) {
+			pairs[i], matched[i] = i, true
+			continue
+		}
+		for j, b2 := range s2.bars {
+			if !matched[j] && math.Abs(s1.center(b1)-s2.center(b2)) <= (s1.thickness(b1)+s2.thickness(b2))/4+chartTolerance {
+				pairs[i], matched[j] = j, true
+				break
+			}
+		}
+	}
+	for i, j := range pairs {
+		l1 := s1.length(s1.bars[i])
+		if j < 0 {
+			changes = append(changes, ChartChange{Kind: "bar", Chart: chart, Index: i + 1, Value1: l1,
+				Description: fmt.Sprintf("bar %d of chart %d was removed", i+1, chart)})
+			continue
+		}
+		l2 := s2.length(s2.bars[j])
+		if math.Abs(l2-l1) <= chartTolerance {
+			continue
+		}
+		verb := "grew"
+		if l2 < l1 {
+			verb = "shrank"
+		}
+		percent := percentChange(l1, l2)
+		changes = append(changes, ChartChange{Kind: "bar", Chart: chart, Index: i + 1, Value1: l1, Value2: l2, Percent: percent,
+			Description: fmt.Sprintf("bar %d of chart %d %s %.1f%%", i+1, chart, verb, math.Abs(percent))})
+	}
+	for j, ok := range matched {
+		if !ok {
+			changes = append(changes, ChartChange{Kind: "bar", Chart: chart, Index: j + 1, Value2: s2.length(s2.bars[j]),
+				Description: fmt.Sprintf("bar %d was added to chart %d", j+1, chart)})
+		}
+	}
+	return changes
+}
+
+// compareLines returns the changes of the vertices of a line, measured from its axis when it has one
+func compareLines(line int, l1, l2 chartLine) []ChartChange {
+	if len(l1.points) != len(l2.points) {
+		return []ChartChange{{Kind: "line", Index: line, Value1: float64(len(l1.points)), Value2: float64(len(l2.points)),
+			Description: fmt.Sprintf("line %d has %d points instead of %d", line, len(l2.points), len(l1.points))}}
+	}
+	var changes []ChartChange
+	for i := range l1.points {
+		y1, y2 := l1.points[i][1], l2.points[i][1]
+		if math.Abs(y2-y1) <= chartTolerance {
+			continue
+		}
+		direction := map[bool]string{true: "rose", false: "fell"}[y2 < y1]
+		change := ChartChange{Kind: "line", Index: line, Point: i + 1}
+		if l1.hasAxis && l2.hasAxis {
+			change.Value1, change.Value2 = l1.axis-y1, l2.axis-y2
+			change.Percent = percentChange(change.Value1, change.Value2)
+			change.Description = fmt.Sprintf("point %d of line %d %s %.1f%%", i+1, line, direction, math.Abs(change.Percent))
+		} else {
+			change.Value1, change.Value2 = y1, y2
+			change.Description = fmt.Sprintf("point %d of line %d %s %.1f pt", i+1, line, direction, math.Abs(y2-y1))
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
@@ -0,0 +1,254 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+)
+
+// The types of the checks of the template fields
+const (
+	CheckSum   = "sum"
+	CheckRange = "range"
+)
+
+// FieldCheck is a rule the values of the template fields of every document must follow, e.g. the line items of an
+// invoice add up to its total, or its due date falls within the year
+type FieldCheck struct {
+	// Name identifies the check in the report (Default: its Total or Field)
+	Name string `json:"name,omitempty" yaml:"name"`
+	// Type is sum or range
+	Type string `json:"type" yaml:"type"`
+	// Items are the fields added up by a sum check, or patterns of their names such as line_*, on every page
+	Items []string `json:"items,omitempty" yaml:"items"`
+	// Total is the field the items of a sum check add up to
+	Total string `json:"total,omitempty" yaml:"total"`
+	// Tolerance is the largest difference between the sum of the items and the total (Default: half a cent)
+	Tolerance float64 `json:"tolerance,omitempty" yaml:"tolerance"`
+	// Field is the field of a range check, whose values on every page are between Min and Max, included. They are
+	// values of the type of the field, or the names of other fields such as the invoice date.
+	Field string `json:"field,omitempty" yaml:"field"`
+	Min   string `json:"min,omitempty" yaml:"min"`
+	Max   string `json:"max,omitempty" yaml:"max"`
+}
+
+// CheckResult is the result of a check in both documents
+type CheckResult struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Passed1 and Passed2 tell whether the first and second documents pass the check
+	Passed1 bool `json:"passed1"`
+	Passed2 bool `json:"passed2"`
+	// Failure1 and Failure2 describe why a document fails the check
+	Failure1 string `json:"failure1,omitempty"`
+	Failure2 string `json:"failure2,omitempty"`
+}
+
+// name returns the name of the check in the report
+func (c FieldCheck) name() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Type == CheckSum:
+		return c.Total
+	}
+	return c.Field
+}
+
+// checkCheck verifies the definition of a check against the fields it refers to
+func checkCheck(c FieldCheck, fields []TemplateField) error {
+	byName := fieldsByName(fields)
+	switch c.Type {
+	case CheckSum:
+		if len(c.Items) == 0 || c.Total == "" {
+			return fmt.Errorf("the sum check %s needs the items and the total it adds them up to", c.name())
+		}
+		for _, item := range c.Items {
+			if _, err := path.Match(item, ""); err != nil {
+				return fmt.Errorf("invalid item %q of the sum check %s: %v", item, c.name(), err)
+			}
+		}
+		if _, ok := byName[c.Total]; !ok {
+			return fmt.Errorf("the sum check %s refers to the unknown field %s", c.name(), c.Total)
+		}
+	case CheckRange:
+		f, ok := byName[c.Field]
+		if !ok {
+			return fmt.Errorf("the range check %s refers to the unknown field %q", c.name(), c.Field)
+		}
+		if c.Min == "" && c.Max == "" {
+			return fmt.Errorf("the range check %s needs a min, a max or both", c.name())
+		}
+		for _, bound := range []string{c.Min, c.Max} {
+			if _, isField := byName[bound]; bound == "" || isField {
+				continue
+			}
+			if _, err := normalizeField(f, bound); err != nil {
+				return fmt.Errorf("invalid bound of the range check %s: %v", c.name(), err)
+			}
+		}
+	default:
+		return fmt.Errorf("the check %s has the unknown type %q, use sum or range", c.name(), c.Type)
+	}
+	return nil
+}
+
+// fieldsByName returns the template fields by name, the first one for a name defined on several pages
+func fieldsByName(fields []TemplateField) map[string]TemplateField {
+	byName := make(map[string]TemplateField)
+	for _, f := range fields {
+		if _, ok := byName[f.Name]; !ok {
+			byName[f.Name] = f
+		}
+	}
+	return byName
+}
+
+// CheckFields runs the checks on the values of the template fields read on the pages, in both documents
+func CheckFields(checks []FieldCheck, fields []TemplateField, pages []PageResult) []CheckResult {
+	byName := fieldsByName(fields)
+	var results []CheckResult
+	for _, c := range checks {
+		result := CheckResult{Name: c.name(), Type: c.Type}
+		for doc := 1; doc <= 2; doc++ {
+			// The values of the fields in the document, in page order
+			values := make(map[string][]string)
+			for _, page := range pages {
+				for _, v := range page.Fields {
+					value := v.Value1
+					if doc == 2 {
+						value = v.Value2
+					}
+					if value != "" {
+						values[v.Name] = append(values[v.Name], value)
+					}
+				}
+			}
+			var failure string
+			switch c.Type {
+			case CheckSum:
+				failure = checkSum(c, values)
+			case CheckRange:
+				failure = checkRange(c, byName, values)
+			}
+			if doc == 1 {
+				result.Passed1, result.Failure1 = failure == "", failure
+			} else {
+				result.Passed2, result.Failure2 = failure == "", failure
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkSum returns why the items of a document do not add up to its total, or an empty string if they do
+func checkSum(c FieldCheck, values map[string][]string) string {
+	if len(values[c.Total]) == 0 {
+		return fmt.Sprintf("the total %s was not found", c.Total)
+	}
+	total, err := parseNumber(values[c.Total][0])
+	if err != nil {
+		return fmt.Sprintf("the total %s: %v", c.Total, err)
+	}
+	sum, items := 0.0, 0
+	for name, list := range values {
+		if name == c.Total || !matchesAny(c.Items, name) {
+			continue
+		}
+		for _, value := range list {
+			n, err := parseNumber(value)
+			if err != nil {
+				return fmt.Sprintf("the item %s: %v", name, err)
+			}
+			sum += n
+			items++
+		}
+	}
+	tolerance := c.Tolerance
+	if tolerance <= 0 {
+		tolerance = 0.005
+	}
+	if math.Abs(sum-total) > tolerance {
+		return fmt.Sprintf("the %d items add up to %s, but %s is %s", items, formatNumber(sum), c.Total, formatNumber(total))
+	}
+	return ""
+}
+
+// checkRange returns why a value of the field of a document is out of range, or an empty string if none is
+func checkRange(c FieldCheck, byName map[string]TemplateField, values map[string][]string) string {
+	f := byName[c.Field]
+	// The bounds are the values of other fields, or values of the type of the field
+	bound := func(b string) (string, bool) {
+		if _, isField := byName[b]; isField {
+			if len(values[b]) == 0 {
+				return "", false
+			}
+			b = values[b][0]
+		}
+		n, err := normalizeField(f, b)
+		return n, err == nil && n != ""
+	}
+	below, above := "below", "above"
+	if f.Type == FieldDate {
+		below, above = "before", "after"
+	}
+	for _, value := range values[c.Field] {
+		v, err := normalizeField(f, value)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", c.Field, err)
+		}
+		if lo, ok := bound(c.Min); ok && lessField(f, v, lo) {
+			return fmt.Sprintf("%s %s is %s %s", c.Field, value, below, describeBound(c.Min, lo, byName))
+		}
+		if hi, ok := bound(c.Max); ok && lessField(f, hi, v) {
+			return fmt.Sprintf("%s %s is %s %s", c.Field, value, above, describeBound(c.Max, hi, byName))
+		}
+	}
+	return ""
+}
+
+// lessField compares two normalized values of a field: numbers by value, dates and text in order
+func lessField(f TemplateField, a, b string) bool {
+	if f.Type == FieldNumber || f.Type == FieldAmount {
+		x, _ := strconv.ParseFloat(a, 64)
+		y, _ := strconv.ParseFloat(b, 64)
+		return x < y
+	}
+	return a < b
+}
+
+// describeBound names the bound of a range check in its failure, with the field it is read from
+func describeBound(bound, value string, byName map[string]TemplateField) string {
+	if _, isField := byName[bound]; isField {
+		return fmt.Sprintf("the %s %s", bound, value)
+	}
+	return value
+}
+
+// matchesAny reports whether a field name matches one of the patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formatNumber formats a sum for the failure of a check, with two decimals for amounts
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', 2, 64)
+}
+
+// FailedChecks returns the checks that the second document, the revision being verified, fails
+func (r Report) FailedChecks() []CheckResult {
+	var failed []CheckResult
+	for _, c := range r.Checks {
+		if !c.Passed2 {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,86 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// The default highlight colors of the changed pixels
+const (
+	DefaultColorOld = "#FF0000"
+	DefaultColorNew = "#0000FF"
+)
+
+// The highlight styles of the difference images
+const (
+	HighlightPixels = "pixels"
+	HighlightBoxes  = "boxes"
+)
+
+// ParseHexColor parses a color written as RRGGBB or RRGGBBAA, with or without a leading #.
+// An alpha below FF makes the highlight a semi-transparent overlay.
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, it should be RRGGBB or RRGGBBAA", s)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, it should be RRGGBB or RRGGBBAA", s)
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// highlightColor returns the color of a changed pixel: the highlight itself if it is opaque, otherwise the highlight
+// blended over the pixel, so the content stays visible under the overlay
+func highlightColor(highlight color.RGBA, pixel color.Color) color.Color {
+	if highlight.A == 255 {
+		return highlight
+	}
+	r, g, b, _ := pixel.RGBA()
+	a := uint32(highlight.A)
+	blend := func(h uint8, p uint32) uint8 {
+		return uint8((uint32(h)*a + (p>>8)*(255-a)) / 255)
+	}
+	return color.RGBA{R: blend(highlight.R, r), G: blend(highlight.G, g), B: blend(highlight.B, b), A: 255}
+}
+
+// highlightColors parses the highlight colors of the options, using the defaults for the empty ones
+func highlightColors(opts Options) (color.RGBA, color.RGBA, error) {
+	oldHex, newHex := opts.ColorOld, opts.ColorNew
+	if oldHex == "" {
+		oldHex = DefaultColorOld
+	}
+	if newHex == "" {
+		newHex = DefaultColorNew
+	}
+	colorOld, err := ParseHexColor(oldHex)
+	if err != nil {
+		return colorOld, colorOld, err
+	}
+	colorNew, err := ParseHexColor(newHex)
+	return colorOld, colorNew, err
+}
+
+// heatColor returns the color of a changed pixel in a heatmap, from blue for the subtlest shade shifts through yellow
+// to red for the largest differences, given the largest difference of its color channels (0-255)
+func heatColor(delta int) color.RGBA {
+	t := float64(delta) / 255
+	if t < 0.5 {
+		// Blue to yellow
+		v := uint8(t * 2 * 255)
+		return color.RGBA{R: v, G: v, B: 255 - v, A: 255}
+	}
+	// Yellow to red
+	return color.RGBA{R: 255, G: uint8((1 - t) * 2 * 255), A: 255}
+}
+
+// hexColor formats a color as #RRGGBB
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
@@ -0,0 +1,176 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/phpdave11/gofpdf"
+)
+
+// The images and highlight colors of the merged PDFs are DeviceRGB, whose meaning is left to the printer. An sRGB
+// output intent declares them sRGB, so color-managed workflows print the highlights predictably. gofpdf cannot write
+// output intents, so they are added to its output by an incremental update, which leaves the images untouched.
+
+// srgbDescription names the color space of the output intent
+const srgbDescription = "sRGB IEC61966-2.1"
+
+var startXref = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+
+// writePDF saves a PDF built with gofpdf with an sRGB output intent
+func writePDF(pdf *gofpdf.Fpdf, output string) error {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return err
+	}
+	data, err := addOutputIntent(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, data, 0644)
+}
+
+// addOutputIntent appends to a PDF with a cross-reference table the sRGB profile and a new catalog declaring it as
+// the output intent of the document
+func addOutputIntent(data []byte) ([]byte, error) {
+	f, err := parsePDF(data, "the merged PDF")
+	if err != nil {
+		return nil, err
+	}
+	match := startXref.FindSubmatch(data)
+	root, ok := f.trailer["Root"].(pdfRef)
+	size, _ := f.trailer["Size"].(float64)
+	catalog := f.dict(root)
+	if match == nil || !ok || size <= 0 || catalog == nil {
+		return nil, fmt.Errorf("the merged PDF has no catalog to add the output intent to")
+	}
+	prev, _ := strconv.Atoi(string(match[1]))
+
+	var profile bytes.Buffer
+	w := zlib.NewWriter(&profile)
+	w.Write(srgbProfile())
+	w.Close()
+
+	out := bytes.NewBuffer(append([]byte(nil), data...))
+	profileNum := int(size)
+	profileOffset := out.Len()
+	fmt.Fprintf(out, "%d 0 obj\n", profileNum)
+	writePDFObject(out, pdfDict{"N": float64(3), "Filter": pdfName("FlateDecode"), "Length": float64(profile.Len())})
+	out.WriteString("\nstream\n")
+	out.Write(profile.Bytes())
+	out.WriteString("\nendstream\nendobj\n")
+
+	newCatalog := make(pdfDict, len(catalog)+1)
+	for k, v := range catalog {
+		newCatalog[k] = v
+	}
+	newCatalog["OutputIntents"] = []interface{}{pdfDict{
+		"Type":                      pdfName("OutputIntent"),
+		"S":                         pdfName("GTS_PDFA1"),
+		"OutputConditionIdentifier": srgbDescription,
+		"Info":                      srgbDescription,
+		"RegistryName":              "http://www.color.org",
+		"DestOutputProfile":         pdfRef{num: profileNum},
+	}}
+	catalogOffset := out.Len()
+	fmt.Fprintf(out, "%d %d obj\n", root.num, root.gen)
+	writePDFObject(out, newCatalog)
+	out.WriteString("\nendobj\n")
+
+	xref := out.Len()
+	fmt.Fprintf(out, "xref\n%d 1\n%010d %05d n \n%d 1\n%010d 00000 n \n", root.num, catalogOffset, root.gen, profileNum, profileOffset)
+	trailer := pdfDict{"Size": float64(profileNum + 1), "Prev": float64(prev)}
+	for _, key := range []string{"Root", "Info", "ID"} {
+		if v, ok := f.trailer[key]; ok {
+			trailer[key] = v
+		}
+	}
+	out.WriteString("trailer\n")
+	writePDFObject(out, trailer)
+	fmt.Fprintf(out, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return out.Bytes(), nil
+}
+
+// srgbProfile returns an ICC version 2 display profile of sRGB, with the primaries adapted to D50 and the sRGB tone
+// curve. Its rendering intent is media-relative colorimetric, which keeps the hue of the highlights in gamut.
+func srgbProfile() []byte {
+	xyz := func(x, y, z float64) []byte {
+		b := []byte("XYZ \x00\x00\x00\x00")
+		for _, v := range []float64{x, y, z} {
+			b = binary.BigEndian.AppendUint32(b, uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	desc := []byte("desc\x00\x00\x00\x00")
+	desc = binary.BigEndian.AppendUint32(desc, uint32(len(srgbDescription)+1))
+	desc = append(desc, srgbDescription+"\x00"...)
+	desc = append(desc, make([]byte, 4+4+2+1+67)...) // no Unicode nor ScriptCode description
+	curve := []byte("curv\x00\x00\x00\x00")
+	curve = binary.BigEndian.AppendUint32(curve, 1024)
+	for i := 0; i < 1024; i++ {
+		v := float64(i) / 1023
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		curve = binary.BigEndian.AppendUint16(curve, uint16(math.Round(v*65535)))
+	}
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		{"rXYZ", xyz(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", xyz(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", xyz(0.1431, 0.0606, 0.7141)},
+		{"rTRC", curve},
+		{"gTRC", curve},
+		{"bTRC", curve},
+	}
+
+	// The tag data follows the header and the tag table, each aligned to 4 bytes; the three curves share theirs
+	var table, body []byte
+	offset := 128 + 4 + 12*len(tags)
+	var curveOffset uint32
+	for _, tag := range tags {
+		start := uint32(offset + len(body))
+		if tag.sig[1:] == "TRC" && curveOffset != 0 {
+			start = curveOffset
+		} else {
+			if tag.sig[1:] == "TRC" {
+				curveOffset = start
+			}
+			body = append(body, tag.data...)
+			for len(body)%4 != 0 {
+				body = append(body, 0)
+			}
+		}
+		table = append(table, tag.sig...)
+		table = binary.BigEndian.AppendUint32(table, start)
+		table = binary.BigEndian.AppendUint32(table, uint32(len(tag.data)))
+	}
+
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(offset+len(body)))
+	binary.BigEndian.PutUint32(header[8:], 0x02100000) // version 2.1
+	copy(header[12:], "mntrRGB XYZ ")
+	for i, v := range []uint16{2024, 1, 1} { // a fixed creation date, so the same images give the same PDF
+		binary.BigEndian.PutUint16(header[24+2*i:], v)
+	}
+	copy(header[36:], "acsp")
+	binary.BigEndian.PutUint32(header[64:], 1) // media-relative colorimetric
+	copy(header[68:], xyz(0.9642, 1, 0.8249)[8:])
+
+	profile := append(header, binary.BigEndian.AppendUint32(nil, uint32(len(tags)))...)
+	profile = append(profile, table...)
+	return append(profile, body...)
+}
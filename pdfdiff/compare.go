@@ -0,0 +1,551 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// pageResult is sent on the done channel when a worker finishes a job
+type pageResult struct {
+	page          int   // index of the compared page in the output
+	changedPixels int   // number of pixels that differ between the two pages
+	width, height int   // size of the difference image
+	inserted      []int // output pages written from the second document only, because of the offset
+	regions       []Region
+	ssim          float64 // structural similarity of the two pages
+	threshold     int     // threshold estimated from the noise of the page
+	content       *ContentDiffs
+	charts        []ChartChange
+	equations     []Equation
+	glyphs        *GlyphDiff
+	vectors       *VectorChanges
+	dimensions    []DimensionChange
+	textChanges   []TextChange
+	maskedText    []string
+	critical      []CriticalChange
+	fields        []FieldValue
+	languages     []string
+	missingIn     int   // document (1 or 2) that does not have the page, 0 if both have it
+	sourcePages   []int // pages of both documents compared in an aligned comparison
+	err           error // error that prevented the page from being compared
+}
+
+// Brightness calculates the perceived brightness of a color. It uses an algorithm that approximates human perception
+func brightness(c color.Color) uint8 {
+	r, g, b, _ := c.RGBA()
+	return uint8((r*19595 + g*38470 + b*7471) >> 16) // Perform the brightness calculation using integer arithmetic to maintain precision and avoid floating point calculations, which are slower in Go compared with bitwise operations. The coefficients used here (19595 for red, 38470 for green, and 7471 for blue) were chosen based on a study of human color perception that approximates the luma or luminance value more accurately than simple calculations would suggest.
+}
+
+// pixelsDiffer reports whether two pixels differ by more than the threshold in any color channel
+func pixelsDiffer(c1, c2 color.Color, threshold int) bool {
+	if threshold == 0 {
+		return c1 != c2
+	}
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return channelDelta(r1, r2) > threshold || channelDelta(g1, g2) > threshold ||
+		channelDelta(b1, b2) > threshold || channelDelta(a1, a2) > threshold
+}
+
+// pixelDelta returns the largest difference of the color channels of two pixels, scaled to 0-255
+func pixelDelta(c1, c2 color.Color) int {
+	r1, g1, b1, _ := c1.RGBA()
+	r2, g2, b2, _ := c2.RGBA()
+	return max(channelDelta(r1, r2), max(channelDelta(g1, g2), channelDelta(b1, b2)))
+}
+
+// antialiased reports whether a differing pixel is an edge that moved by at most one pixel: the pixel of each image
+// has an equal pixel in the 3x3 neighbourhood of the other image
+func antialiased(img1, img2 image.Image, x, y, threshold int) bool {
+	return hasNeighbour(img2, img1.At(x, y), x, y, threshold) && hasNeighbour(img1, img2.At(x, y), x, y, threshold)
+}
+
+// hasNeighbour reports whether a pixel in the 3x3 neighbourhood of (x, y) in img is equal to c
+func hasNeighbour(img image.Image, c color.Color, x, y, threshold int) bool {
+	bounds := img.Bounds()
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			p := image.Pt(x+dx, y+dy)
+			if (dx != 0 || dy != 0) && p.In(bounds) && !pixelsDiffer(c, img.At(p.X, p.Y), threshold) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// channelDelta returns the difference between two 16 bit color channels, scaled to 0-255
+func channelDelta(a, b uint32) int {
+	if a > b {
+		return int((a - b) >> 8)
+	}
+	return int((b - a) >> 8)
+}
+
+// worker is a function that will be run in a separate goroutine. It processes jobs from the jobs channel and sends a result to the done channel when it finishes a job.
+// It takes images from two documents and compares them, creating a new image that highlights the differences.
+func worker(ctx context.Context, jobs <-chan int, done chan<- pageResult, doc1 Document, doc2 Document, opts *Options) {
+	offset, startOffset := opts.Offset, opts.StartOffset
+	for j := range jobs {
+		result := pageResult{page: j}
+		if j >= startOffset {
+			result.page = j + offset
+		}
+
+		// Stop comparing pages once the comparison has been cancelled, but keep draining the jobs.
+		// A paused comparison waits here, before starting the next page.
+		if err := opts.Pauser.Wait(ctx); err != nil {
+			result.err = err
+			done <- result
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			result.err = err
+			done <- result
+			continue
+		}
+
+		if opts.OnPageStart != nil {
+			if err := opts.OnPageStart(result.page); err != nil {
+				result.err = err
+				done <- result
+				continue
+			}
+		}
+		if err := comparePage(j, &result, doc1, doc2, opts); err != nil {
+			result.err = &PageError{Page: result.page, Err: err}
+		}
+
+		// Signal that the job is done
+		done <- result
+	}
+}
+
+// comparePage compares page j of the first document with its counterpart in the second document and saves the difference image,
+// recording the pages it wrote, the number of differing pixels and the size of the difference image in the result.
+func comparePage(j int, result *pageResult, doc1 Document, doc2 Document, opts *Options) error {
+	offset, startOffset := opts.Offset, opts.StartOffset
+
+	// If we've reached the startOffset, create images for the pages from startOffset to startOffset+offset in file2
+	if j == startOffset {
+		for i := startOffset; i < startOffset+offset; i++ {
+			if i < doc2.NumPage() {
+				img, _, err := opts.Pipeline.render(doc2, i-1, opts.DPI)
+				if err != nil {
+					return err
+				}
+				err = opts.writeArtifact(i, img, filepath.Join(opts.OutputDir, diffImageName(opts.Prefix, i)))
+				if err != nil {
+					return err
+				}
+				result.inserted = append(result.inserted, i)
+			}
+		}
+	}
+
+	// Extract the images from the documents or create a white image if the page does not exist
+	page1, pagToCompare := opts.sourcePages(j)
+	if opts.Alignment != nil {
+		result.sourcePages = []int{page1, pagToCompare}
+	}
+	img1, missing1, err := opts.Pipeline.render(doc1, page1, opts.DPI)
+	if err != nil {
+		return err
+	}
+
+	img2, missing2, err := opts.Pipeline.render(doc2, pagToCompare, opts.DPI)
+	if err != nil {
+		return err
+	}
+	if missing1 {
+		result.missingIn = 1
+	} else if missing2 {
+		result.missingIn = 2
+	}
+
+	if img1, img2, err = opts.Pipeline.preprocess(result.page, img1, img2); err != nil {
+		return err
+	}
+	// The content zones, the charts, the equations, the glyphs, the vector paths, the dimensions and the text to ignore are read from the SVG renderings of the pages
+	pageOpts := *opts
+	if opts.Segment || opts.Charts || opts.Equations || opts.Glyphs || opts.VectorOverlay != "" || opts.Dimensions || len(opts.ignoreText) > 0 || len(opts.Fields) > 0 && !opts.OCR {
+		svg1, svg2, err := pageSVGs(doc1, page1, doc2, pagToCompare)
+		if err != nil {
+			return err
+		}
+		if opts.Segment {
+			pageOpts.zones = newContentZones(svg1, svg2, opts.DPI, img1.Bounds())
+		}
+		if opts.Charts && svg1 != "" && svg2 != "" {
+			result.charts = compareCharts(parseSVGCharts(svg1), parseSVGCharts(svg2))
+		}
+		if len(opts.ignoreText) > 0 {
+			for _, svg := range []string{svg1, svg2} {
+				rects, matches := maskText(svg, opts.ignoreText, opts.DPI)
+				pageOpts.masked = append(pageOpts.masked, rects...)
+				result.maskedText = append(result.maskedText, matches...)
+			}
+		}
+		if opts.Equations {
+			equations, err := compareEquations(svg1, svg2, equationRenderer(opts, doc1, page1, doc2, pagToCompare), opts.DPI)
+			if err != nil {
+				return err
+			}
+			result.equations, pageOpts.unchanged = equations.equations, equations.unchanged
+		}
+		if opts.Glyphs && svg1 != "" && svg2 != "" {
+			result.glyphs = compareGlyphs(svg1, svg2, img1, img2, opts.DPI)
+		}
+		if opts.VectorOverlay != "" && svg1 != "" && svg2 != "" {
+			path := filepath.Join(opts.OutputDir, vectorOverlayName(opts.Prefix, result.page, opts.VectorOverlay))
+			if result.vectors, err = opts.writeVectorOverlay(result.page, svg1, svg2, path); err != nil {
+				return err
+			}
+		}
+		if opts.Dimensions && svg1 != "" && svg2 != "" {
+			result.dimensions = compareDimensions(svg1, svg2)
+		}
+		if len(opts.Fields) > 0 && !opts.OCR {
+			svgs := []string{svg1, svg2}
+			result.fields = compareFields(opts.Fields, result.page, img1.Bounds().Dy(), opts.DPI, func(doc int, r image.Rectangle) string {
+				return svgFieldText(svgs[doc-1], r, opts.DPI)
+			})
+		}
+	}
+	if opts.OCR {
+		words1, lang1, err := ocrPageLanguage(img1, opts, 1)
+		if err != nil {
+			return err
+		}
+		words2, lang2, err := ocrPageLanguage(img2, opts, 2)
+		if err != nil {
+			return err
+		}
+		result.languages = pageLanguages(lang1, lang2)
+		var unchanged []image.Rectangle
+		result.textChanges, unchanged = compareOCRWords(words1, words2)
+		pageOpts.unchanged = append(pageOpts.unchanged, unchanged...)
+		if len(opts.Fields) > 0 {
+			words := [][]ocrWord{words1, words2}
+			result.fields = compareFields(opts.Fields, result.page, img1.Bounds().Dy(), opts.DPI, func(doc int, r image.Rectangle) string {
+				return ocrFieldText(words[doc-1], r)
+			})
+		}
+	} else if opts.TextDiff || opts.Language != "" {
+		text1, err := pageText(doc1, page1)
+		if err != nil {
+			return err
+		}
+		text2, err := pageText(doc2, pagToCompare)
+		if err != nil {
+			return err
+		}
+		lang1, lang2 := textLanguage(opts.Language, 1, text1), textLanguage(opts.Language, 2, text2)
+		result.languages = pageLanguages(lang1, lang2)
+		if opts.TextDiff {
+			result.textChanges = diffWords(lang1, lang2, text1, text2)
+		}
+	}
+	if len(opts.MultiDPI) > 0 {
+		if pageOpts.scales, err = scaleMasks(opts, result.page, doc1, page1, doc2, pagToCompare); err != nil {
+			return err
+		}
+	}
+	diff, err := opts.Pipeline.compare(result.page, img1, img2, pageOpts)
+	if err != nil {
+		return err
+	}
+	if err := opts.Pipeline.postprocess(result.page, &diff); err != nil {
+		return err
+	}
+	diffImg := diff.Image
+	result.changedPixels, result.regions, result.ssim, result.threshold = diff.ChangedPixels, diff.Regions, diff.SSIM, diff.Threshold
+	result.content, result.critical = diff.Content, diff.Critical
+	if opts.RegionThumbnails > 0 {
+		if err := addThumbnails(diffImg, result.regions, opts.RegionThumbnails); err != nil {
+			return err
+		}
+	}
+	result.width, result.height = diffImg.Bounds().Dx(), diffImg.Bounds().Dy()
+
+	// Save the rendered pages of both documents for the HTML report
+	if opts.PageImages {
+		if err := opts.writeArtifact(result.page, img1, filepath.Join(opts.OutputDir, pageImageName(opts.Prefix, 1, result.page))); err != nil {
+			return err
+		}
+		if err := opts.writeArtifact(result.page, img2, filepath.Join(opts.OutputDir, pageImageName(opts.Prefix, 2, result.page))); err != nil {
+			return err
+		}
+	}
+
+	// Save the difference image
+	diffImgPath := filepath.Join(opts.OutputDir, diffImageName(opts.Prefix, j))
+	if j >= startOffset {
+		diffImgPath = filepath.Join(opts.OutputDir, diffImageName(opts.Prefix, j+offset))
+	}
+	if result.changedPixels == 0 && opts.SkipIdentical && opts.images != nil {
+		if opts.PageImages {
+			opts.images.link(pageImageName(opts.Prefix, 1, result.page), filepath.Base(diffImgPath))
+		}
+	} else if result.changedPixels == 0 && opts.SkipIdentical {
+		// Do not leave the difference image of a previous run behind
+		if err := os.Remove(diffImgPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if opts.PageImages {
+			// The difference image of an identical page is the page itself
+			if err := linkImage(filepath.Join(opts.OutputDir, pageImageName(opts.Prefix, 1, result.page)), diffImgPath); err != nil {
+				return err
+			}
+			if opts.OnArtifact != nil {
+				if err := opts.OnArtifact(result.page, diffImgPath); err != nil {
+					return err
+				}
+			}
+		}
+	} else if err := opts.writeArtifact(result.page, diffImg, diffImgPath); err != nil {
+		return err
+	}
+
+	// Save the combined image in the same page if sidebyside enabled
+	combinedImgPath := filepath.Join(opts.OutputDir, combinedImageName(opts.Prefix, j))
+	if opts.SideBySide && result.changedPixels == 0 && opts.SkipIdentical {
+		if opts.images == nil {
+			if err := os.Remove(combinedImgPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	} else if opts.SideBySide {
+		combinedImg := combineImages(img1, img2, opts.VerticalAlign)
+		err = opts.writeArtifact(result.page, combinedImg, combinedImgPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeArtifact encodes an image of an output page and reports it to the OnArtifact callback, if any
+func (opts *Options) writeArtifact(page int, img image.Image, path string) error {
+	if err := opts.Pipeline.encode(img, path); err != nil {
+		return err
+	}
+	if opts.OnArtifact != nil {
+		return opts.OnArtifact(page, path)
+	}
+	return nil
+}
+
+// pageSVGs returns the SVG renderings of the pages compared together
+func pageSVGs(doc1 Document, page1 int, doc2 Document, page2 int) (string, string, error) {
+	svg1, err := pageSVG(doc1, page1)
+	if err != nil {
+		return "", "", err
+	}
+	svg2, err := pageSVG(doc2, page2)
+	return svg1, svg2, err
+}
+
+// diffImages compares two pages pixel by pixel and returns the difference image, the number of differing pixels
+// and the regions that contain them, and the differences by content type with Segment. Differences in the ignore
+// rectangles are not counted nor highlighted, nor the ones in the equations found unchanged and the ones not found
+// at the other resolutions of MultiDPI.
+func diffImages(img1, img2 image.Image, ignore []image.Rectangle, opts *Options) (*image.RGBA, int, []Region, *ContentDiffs) {
+	// Create an image to show the differences
+	bounds := img1.Bounds()
+	diffImg := image.NewRGBA(bounds)
+	parallelism := 2 // Number of Goroutines to use
+	var wg sync.WaitGroup
+	// Each goroutine counts the differing pixels of its own rows, so no locking is needed
+	changedPixels := make([]int, parallelism)
+	contentChanges := make([][contentTypes + 1]int, parallelism)
+	grids := make([]*regionGrid, parallelism)
+	// The boxes of HighlightBoxes are drawn over the second page, keeping its pixels
+	boxes := opts.HighlightStyle == HighlightBoxes
+	// changed reports whether the pixels of both pages at x, y differ, outside of the excluded areas
+	changed := func(x, y int, c1, c2 color.Color, critical bool) bool {
+		return critical && pixelsDiffer(c1, c2, 0) || pixelsDiffer(c1, c2, opts.Threshold) && !(opts.IgnoreAntialiasing && antialiased(img1, img2, x, y, opts.Threshold)) &&
+			!ignored(opts.unchanged, x, y) && consistent(opts.scales, x, y)
+	}
+
+	for p := 0; p < parallelism; p++ {
+		wg.Add(1)
+		grids[p] = newRegionGrid(bounds)
+		go func(p int) {
+			defer wg.Done()
+			for y := bounds.Min.Y + p; y < bounds.Max.Y; y += parallelism {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					c1 := img1.At(x, y)
+					c2 := img2.At(x, y)
+					// Check if the pixels at the same position in both images are different
+					critical := ignored(opts.critical, x, y)
+					if ignored(ignore, x, y) && !critical {
+						// Excluded areas are marked so they are not mistaken for unchanged content
+						diffImg.Set(x, y, excludedColor(c1, x, y))
+					} else if changed(x, y, c1, c2, critical) {
+						changedPixels[p]++
+						// The brightness is calculated as the sum of the squares of the RGB components
+						b1 := brightness(c1)
+						b2 := brightness(c2)
+						grids[p].add(x, y, b1 > b2)
+						if opts.zones != nil {
+							contentChanges[p][opts.zones.kind(x, y)]++
+						}
+						if boxes {
+							diffImg.Set(x, y, c2)
+							continue
+						}
+						if opts.Heatmap {
+							// The color shows how much the pixel changed rather than which page is brighter
+							diffImg.Set(x, y, heatColor(pixelDelta(c1, c2)))
+							continue
+						}
+						// If the pixels are different, color the pixel depending on which image has the brighter pixel
+						// Translucent highlights are laid over the darker pixel, which holds the content
+						if b1 > b2 {
+							// If the pixel in the first image is brighter, color the pixel in the difference image red
+							diffImg.Set(x, y, highlightColor(opts.colorOld, c2)) // red for image 1
+						} else {
+							// If the pixel in the second image is brighter, color the pixel in the difference image blue
+							diffImg.Set(x, y, highlightColor(opts.colorNew, c1)) // blue for image 2
+						}
+					} else if boxes {
+						diffImg.Set(x, y, c2)
+					} else {
+						// If the pixels are the same, use the original pixel in the difference image
+						diffImg.Set(x, y, c1)
+					}
+				}
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	total := 0
+	for p, n := range changedPixels {
+		total += n
+		if p > 0 {
+			grids[0].merge(grids[p])
+			for k, n := range contentChanges[p] {
+				contentChanges[0][k] += n
+			}
+		}
+	}
+	regions := grids[0].regions()
+	if opts.minRegionArea > 0 {
+		// The specks and the jitter of the rasterization are too small to be changes, unless they are critical
+		var kept []Region
+		for _, r := range regions {
+			box := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height).Add(bounds.Min)
+			if r.Area >= opts.minRegionArea || intersects(opts.critical, box) {
+				kept = append(kept, r)
+				continue
+			}
+			// The bounding boxes of the regions may overlap, their cells do not: every changed pixel is cleared once
+			total -= r.Area
+			for _, i := range r.cells {
+				cell := grids[0].cells[i]
+				for y := cell.Min.Y; y < cell.Max.Y; y++ {
+					for x := cell.Min.X; x < cell.Max.X; x++ {
+						c1, c2 := img1.At(x, y), img2.At(x, y)
+						if ignored(ignore, x, y) || !changed(x, y, c1, c2, false) {
+							continue
+						}
+						if boxes {
+							diffImg.Set(x, y, c2)
+						} else {
+							diffImg.Set(x, y, c1)
+						}
+						if opts.zones != nil {
+							contentChanges[0][opts.zones.kind(x, y)]--
+						}
+					}
+				}
+			}
+		}
+		regions = kept
+	}
+	var content *ContentDiffs
+	if opts.zones != nil {
+		content = opts.zones.diffs(contentChanges[0])
+	}
+	return diffImg, total, regions, content
+}
+
+// identicalImages reports whether two rendered pages have exactly the same pixels, without looking at every pixel
+// when the renderer returned RGBA buffers
+func identicalImages(img1, img2 image.Image) bool {
+	rgba1, ok1 := img1.(*image.RGBA)
+	rgba2, ok2 := img2.(*image.RGBA)
+	if !ok1 || !ok2 || rgba1.Rect != rgba2.Rect || rgba1.Stride != rgba2.Stride {
+		return false
+	}
+	return bytes.Equal(rgba1.Pix, rgba2.Pix)
+}
+
+// linkImage makes dst a symbolic link to src, in the same directory, or a copy where links are not supported
+func linkImage(src, dst string) error {
+	if err := os.Symlink(filepath.Base(src), dst); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+// combineImages places the two pages next to each other, or one above the other when verticalAlign is set
+func combineImages(img1, img2 image.Image, verticalAlign bool) *image.RGBA {
+	var combinedWidth, combinedHeight int
+	//Combine imege verticaly or horizontally
+	if verticalAlign {
+		// For vertical alignment
+		combinedWidth = max(img1.Bounds().Dx(), img2.Bounds().Dx())
+		combinedHeight = img1.Bounds().Dy() + img2.Bounds().Dy()
+	} else {
+		// For horizontal alignment
+		combinedWidth = img1.Bounds().Dx() + img2.Bounds().Dx()
+		combinedHeight = max(img1.Bounds().Dy(), img2.Bounds().Dy())
+	}
+
+	combinedImg := image.NewRGBA(image.Rect(0, 0, combinedWidth, combinedHeight))
+
+	// Copy img1 to combinedImg
+	for y := 0; y < img1.Bounds().Dy(); y++ {
+		for x := 0; x < img1.Bounds().Dx(); x++ {
+			combinedImg.Set(x, y, img1.At(x, y))
+		}
+	}
+
+	if verticalAlign {
+		// Copy img2 to combinedImg for vertical alignment
+		for y := 0; y < img2.Bounds().Dy(); y++ {
+			for x := 0; x < img2.Bounds().Dx(); x++ {
+				combinedImg.Set(x, y+img1.Bounds().Dy(), img2.At(x, y))
+			}
+		}
+	} else {
+		// Copy img2 to combinedImg for horizontal alignment
+		for y := 0; y < img2.Bounds().Dy(); y++ {
+			for x := 0; x < img2.Bounds().Dx(); x++ {
+				combinedImg.Set(x+img1.Bounds().Dx(), y, img2.At(x, y))
+			}
+		}
+	}
+	return combinedImg
+}
+
+// max returns the larger of two int numbers.
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
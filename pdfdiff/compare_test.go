@@ -0,0 +1,64 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// whitePage returns a white page of the given size in pixels
+func whitePage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return img
+}
+
+func TestDiffImagesMinRegionSizeOverlappingBoxes(t *testing.T) {
+	img1 := whitePage(5*regionCell, 3*regionCell)
+	img2 := whitePage(5*regionCell, 3*regionCell)
+	// An L-shaped region along the top and down the third column of cells, whose bounding box holds a speck in
+	// the bottom left cell, a region of its own as the cells in between have no changed pixels
+	lShape := []image.Point{{5, 5}, {40, 5}, {70, 5}, {70, 40}, {70, 70}}
+	speck := image.Point{5, 70}
+	for _, p := range append(lShape, speck) {
+		img2.Set(p.X, p.Y, color.Black)
+	}
+
+	tests := []struct {
+		name          string
+		minRegionArea int
+		wantChanged   int
+		wantRegions   int
+	}{
+		{"kept", 0, 6, 2},
+		{"speck dropped", 2, 5, 1},
+		{"both dropped", 10, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := &Options{minRegionArea: test.minRegionArea}
+			diff, changed, regions, _ := diffImages(img1, img2, nil, opts)
+			if changed != test.wantChanged {
+				t.Errorf("changed pixels = %d, want %d", changed, test.wantChanged)
+			}
+			if len(regions) != test.wantRegions {
+				t.Errorf("regions = %d, want %d", len(regions), test.wantRegions)
+			}
+			area := 0
+			for _, r := range regions {
+				area += r.Area
+			}
+			if area != changed {
+				t.Errorf("area of the regions = %d, want the %d changed pixels", area, changed)
+			}
+			if test.wantChanged == 0 {
+				for _, p := range append(lShape, speck) {
+					if got := color.RGBAModel.Convert(diff.At(p.X, p.Y)); got != color.RGBAModel.Convert(color.White) {
+						t.Errorf("pixel %v of a dropped region = %v, want the first page", p, got)
+					}
+				}
+			}
+		})
+	}
+}
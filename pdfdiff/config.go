@@ -0,0 +1,98 @@
+package pdfdiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ConfigChange is a setting that differs between the configurations of two runs
+type ConfigChange struct {
+	Key string
+	// Value1 and Value2 are the JSON encoded values of the setting in both runs, "" where it is not set
+	Value1, Value2 string
+}
+
+// EffectiveConfig returns the settings of a comparison by their name in the reports, e.g. dpi or ignore_regions,
+// once the defaults are applied, followed by the version of the renderer. Callbacks and custom pipelines have no
+// value to record and are left out, as are the passwords.
+func EffectiveConfig(opts Options) map[string]interface{} {
+	config := make(map[string]interface{})
+	v := reflect.ValueOf(opts)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Type.Kind() == reflect.Ptr || field.Type.Kind() == reflect.Func || field.Tag.Get("config") == "-" {
+			continue
+		}
+		if _, err := json.Marshal(v.Field(i).Interface()); err != nil {
+			continue
+		}
+		config[snakeCase(field.Name)] = v.Field(i).Interface()
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/gen2brain/go-fitz" {
+				config["renderer"] = dep.Path + " " + dep.Version
+			}
+		}
+	}
+	return config
+}
+
+// snakeCase converts the name of a field to the style of the reports, e.g. MultiDPI to multi_dpi
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		// A word starts at an upper case letter following a lower case one, or before the last letter of an acronym
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// CompareConfigs returns the settings that differ between the configurations of two runs, as recorded in the
+// Config of their reports, sorted by name
+func CompareConfigs(config1, config2 map[string]interface{}) []ConfigChange {
+	keys := make(map[string]bool)
+	for k := range config1 {
+		keys[k] = true
+	}
+	for k := range config2 {
+		keys[k] = true
+	}
+	var changes []ConfigChange
+	for k := range keys {
+		v1, v2 := configValue(config1, k), configValue(config2, k)
+		if v1 != v2 {
+			changes = append(changes, ConfigChange{Key: k, Value1: v1, Value2: v2})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// configValue returns the JSON encoding of a setting, so values read back from a report compare equal to the
+// values they were written from, or "" if the setting is not set
+func configValue(config map[string]interface{}, key string) string {
+	v, ok := config[key]
+	if !ok {
+		return ""
+	}
+	// Settings recorded as structs are read back as maps, whose keys are encoded in another order
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return ""
+	}
+	data, _ = json.Marshal(decoded)
+	return string(data)
+}
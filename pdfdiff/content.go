@@ -0,0 +1,373 @@
+package pdfdiff
+
+import (
+	"encoding/xml"
+	"image"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Content types of the zones of a page, in the order a pixel covered by several of them is counted: text drawn over
+// a picture is text, and anything not drawn as text or as a raster image is a vector graphic
+const (
+	contentText = iota
+	contentImage
+	contentGraphics
+	contentTypes
+)
+
+// ContentDiff is the part of a page covered by one type of content, in both documents, and how much of it changed
+type ContentDiff struct {
+	Pixels         int     `json:"pixels"`
+	ChangedPixels  int     `json:"changed_pixels"`
+	PercentChanged float64 `json:"percent_changed"`
+}
+
+// ContentDiffs are the differences of a page by type of content, as a 1% change of a photo matters much less than
+// a 1% change of the text
+type ContentDiffs struct {
+	Text     ContentDiff `json:"text"`
+	Image    ContentDiff `json:"image"`
+	Graphics ContentDiff `json:"graphics"`
+}
+
+// svgRenderer is implemented by documents that can describe the content of a page as SVG, such as *fitz.Document
+type svgRenderer interface {
+	SVG(pageNumber int) (string, error)
+}
+
+// contentZones holds the content type of every pixel of a page, or contentTypes for the pixels nothing is drawn on
+type contentZones struct {
+	bounds image.Rectangle
+	kinds  []uint8
+}
+
+// contentZone is the bounding box of something drawn on a page, in points from the top left corner
+type contentZone struct {
+	kind int
+	rect [4]float64
+}
+
+// newContentZones returns the zones of the pages of both documents compared together, from their SVG renderings,
+// for pages rendered at dpi into the given bounds. Documents that cannot describe their content, such as archives of
+// page images, have no SVG; nil is returned when neither page has any zone.
+func newContentZones(svg1, svg2 string, dpi float64, bounds image.Rectangle) *contentZones {
+	z := &contentZones{bounds: bounds, kinds: make([]uint8, bounds.Dx()*bounds.Dy())}
+	for i := range z.kinds {
+		z.kinds[i] = contentTypes
+	}
+	found := false
+	for _, svg := range []string{svg1, svg2} {
+		for _, zone := range parseSVGZones(svg) {
+			z.fill(zone, dpi/72)
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return z
+}
+
+// pageSVG returns the SVG rendering of a page, or "" for documents that cannot describe their content and for
+// missing pages
+func pageSVG(doc Document, page int) (string, error) {
+	r, ok := doc.(svgRenderer)
+	if !ok || page < 0 || page >= doc.NumPage() {
+		return "", nil
+	}
+	defer lockDocument(doc)()
+	return r.SVG(page)
+}
+
+// fill marks the pixels of a zone, grown by a pixel for the anti-aliased edges, unless a type counted first is there
+func (z *contentZones) fill(zone contentZone, scale float64) {
+	r := image.Rect(int(math.Floor(zone.rect[0]*scale))-1, int(math.Floor(zone.rect[1]*scale))-1,
+		int(math.Ceil(zone.rect[2]*scale))+1, int(math.Ceil(zone.rect[3]*scale))+1).Add(z.bounds.Min).Intersect(z.bounds)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if i := z.index(x, y); int(z.kinds[i]) > zone.kind {
+				z.kinds[i] = uint8(zone.kind)
+			}
+		}
+	}
+}
+
+func (z *contentZones) index(x, y int) int {
+	return (y-z.bounds.Min.Y)*z.bounds.Dx() + x - z.bounds.Min.X
+}
+
+// kind returns the content type of a pixel, or contentTypes where nothing is drawn
+func (z *contentZones) kind(x, y int) int {
+	if !image.Pt(x, y).In(z.bounds) {
+		return contentTypes
+	}
+	return int(z.kinds[z.index(x, y)])
+}
+
+// diffs returns the differences by content type, given the changed pixels of every type and of the pixels nothing
+// is drawn on, such as the edges of a thick stroke, which count as graphics
+func (z *contentZones) diffs(changed [contentTypes + 1]int) *ContentDiffs {
+	var pixels [contentTypes + 1]int
+	for _, k := range z.kinds {
+		pixels[k]++
+	}
+	pixels[contentGraphics] += changed[contentTypes]
+	changed[contentGraphics] += changed[contentTypes]
+	var d [contentTypes]ContentDiff
+	for k := range d {
+		d[k] = ContentDiff{Pixels: pixels[k], ChangedPixels: changed[k]}
+		if d[k].Pixels > 0 {
+			d[k].PercentChanged = float64(d[k].ChangedPixels) / float64(d[k].Pixels) * 100
+		}
+	}
+	return &ContentDiffs{Text: d[contentText], Image: d[contentImage], Graphics: d[contentGraphics]}
+}
+
+// svgNumber matches the numbers of SVG path data and transforms, which MuPDF writes without separators when it can
+var svgNumber = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// svgPathToken matches the commands and numbers of SVG path data
+var svgPathToken = regexp.MustCompile(`[A-Za-z]|[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// svgMatrix is an SVG transform, mapping (x, y) to (a*x + c*y + e, b*x + d*y + f)
+type svgMatrix [6]float64
+
+var identityMatrix = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// parseTransform parses the matrix transforms written by MuPDF; other transforms are ignored
+func parseTransform(s string) svgMatrix {
+	if !strings.HasPrefix(strings.TrimSpace(s), "matrix(") {
+		return identityMatrix
+	}
+	numbers := svgNumber.FindAllString(s, -1)
+	if len(numbers) != 6 {
+		return identityMatrix
+	}
+	var m svgMatrix
+	for i, n := range numbers {
+		m[i], _ = strconv.ParseFloat(n, 64)
+	}
+	return m
+}
+
+// then returns the transform that applies m, then the outer transform
+func (m svgMatrix) then(outer svgMatrix) svgMatrix {
+	return svgMatrix{
+		outer[0]*m[0] + outer[2]*m[1], outer[1]*m[0] + outer[3]*m[1],
+		outer[0]*m[2] + outer[2]*m[3], outer[1]*m[2] + outer[3]*m[3],
+		outer[0]*m[4] + outer[2]*m[5] + outer[4], outer[1]*m[4] + outer[3]*m[5] + outer[5],
+	}
+}
+
+// apply returns the bounding box of the transformed corners of a box
+func (m svgMatrix) apply(box [4]float64) [4]float64 {
+	out := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range [][2]float64{{box[0], box[1]}, {box[2], box[1]}, {box[0], box[3]}, {box[2], box[3]}} {
+		x, y := m[0]*p[0]+m[2]*p[1]+m[4], m[1]*p[0]+m[3]*p[1]+m[5]
+		out[0], out[1] = math.Min(out[0], x), math.Min(out[1], y)
+		out[2], out[3] = math.Max(out[2], x), math.Max(out[3], y)
+	}
+	return out
+}
+
+// svgSubpath is a subpath of SVG path data: the vertices it goes through, the control points of its curves, also
+// by the vertex their segment ends at, whether it is closed and whether any of its segments is curved
+type svgSubpath struct {
+	points   [][2]float64
+	controls [][2]float64
+	curves   [][][2]float64
+	closed   bool
+	curved   bool
+}
+
+// parsePath splits SVG path data into its subpaths, with absolute coordinates
+func parsePath(d string) []svgSubpath {
+	var subpaths []svgSubpath
+	var x, y, startX, startY float64
+	cmd := byte('M')
+	var args []float64
+	// Every command takes its arguments in groups, repeated until the next command
+	group := map[byte]int{'M': 2, 'L': 2, 'T': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'A': 7}
+	for _, token := range svgPathToken.FindAllString(d, -1) {
+		if c := token[0]; (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') {
+			cmd, args = c, args[:0]
+			if (cmd == 'Z' || cmd == 'z') && len(subpaths) > 0 {
+				x, y = startX, startY
+				subpaths[len(subpaths)-1].closed = true
+			}
+			continue
+		}
+		v, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			continue
+		}
+		upper := cmd &^ 0x20
+		n := group[upper]
+		if n == 0 {
+			continue
+		}
+		args = append(args, v)
+		if len(args) < n {
+			continue
+		}
+		relative := cmd != upper
+		var ox, oy float64
+		if relative {
+			ox, oy = x, y
+		}
+		var controls [][2]float64
+		switch upper {
+		case 'H':
+			x = args[0] + ox
+		case 'V':
+			y = args[0] + oy
+		case 'A':
+			x, y = args[5]+ox, args[6]+oy
+		default:
+			for i := 0; i+3 < n; i += 2 {
+				controls = append(controls, [2]float64{args[i] + ox, args[i+1] + oy})
+			}
+			x, y = args[n-2]+ox, args[n-1]+oy
+		}
+		if upper == 'M' || len(subpaths) == 0 || subpaths[len(subpaths)-1].closed {
+			subpaths = append(subpaths, svgSubpath{})
+			startX, startY = x, y
+		}
+		sub := &subpaths[len(subpaths)-1]
+		sub.points = append(sub.points, [2]float64{x, y})
+		sub.controls = append(sub.controls, controls...)
+		sub.curves = append(sub.curves, controls)
+		if upper != 'M' && upper != 'L' && upper != 'H' && upper != 'V' {
+			sub.curved = true
+		}
+		if upper == 'M' {
+			// Further pairs of a move are lines
+			if relative {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		}
+		args = args[:0]
+	}
+	return subpaths
+}
+
+// pathBox returns the bounding box of the points of SVG path data, control points included, and whether it has any.
+// The control points are inside the bounding box of a curve, so taking them keeps the box a bound.
+func pathBox(d string) ([4]float64, bool) {
+	box := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, sub := range parsePath(d) {
+		for _, p := range append(sub.points, sub.controls...) {
+			box[0], box[1] = math.Min(box[0], p[0]), math.Min(box[1], p[1])
+			box[2], box[3] = math.Max(box[2], p[0]), math.Max(box[3], p[1])
+		}
+	}
+	return box, box[0] <= box[2]
+}
+
+// walkSVG calls visit with every element of an SVG page, its attributes, its transform to page coordinates and
+// whether it is hidden in definitions, clip paths or masks, which are not drawn where they are
+func walkSVG(svg string, visit func(name string, attrs map[string]string, m svgMatrix, hidden bool)) {
+	hidden, depth := 0, 0
+	transforms := []svgMatrix{identityMatrix}
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	dec.Strict = false
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			attrs := make(map[string]string)
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			m := parseTransform(attrs["transform"]).then(transforms[len(transforms)-1])
+			transforms = append(transforms, m)
+			switch t.Name.Local {
+			case "defs", "clipPath", "mask", "symbol", "pattern":
+				if hidden == 0 {
+					hidden = depth
+				}
+			}
+			visit(t.Name.Local, attrs, m, hidden > 0)
+		case xml.EndElement:
+			if hidden == depth {
+				hidden = 0
+			}
+			depth--
+			transforms = transforms[:len(transforms)-1]
+		}
+	}
+}
+
+// stroked reports whether an SVG element is stroked, and returns the width of its stroke
+func stroked(attrs map[string]string) (bool, float64) {
+	if attrs["stroke"] == "" || attrs["stroke"] == "none" {
+		return false, 0
+	}
+	width := 1.0
+	if w, err := strconv.ParseFloat(attrs["stroke-width"], 64); err == nil {
+		width = w
+	}
+	return true, width
+}
+
+// parseSVGZones returns the bounding boxes of the glyphs, raster images and vector paths drawn by an SVG page
+// written by MuPDF with its text as paths
+func parseSVGZones(svg string) []contentZone {
+	type definition struct {
+		kind  int
+		box   [4]float64
+		glyph bool
+	}
+	defs := make(map[string]*definition)
+	var zones []contentZone
+	walkSVG(svg, func(name string, attrs map[string]string, m svgMatrix, hidden bool) {
+		var zone contentZone
+		var ok bool
+		switch name {
+		case "path":
+			var box [4]float64
+			if box, ok = pathBox(attrs["d"]); !ok {
+				return
+			}
+			if ok, width := stroked(attrs); ok {
+				box = [4]float64{box[0] - width/2, box[1] - width/2, box[2] + width/2, box[3] + width/2}
+			}
+			zone = contentZone{kind: contentGraphics, rect: box}
+			if id := attrs["id"]; hidden && id != "" {
+				defs[id] = &definition{kind: contentGraphics, box: box, glyph: strings.HasPrefix(id, "font_")}
+			}
+		case "image":
+			width, _ := strconv.ParseFloat(attrs["width"], 64)
+			height, _ := strconv.ParseFloat(attrs["height"], 64)
+			x, _ := strconv.ParseFloat(attrs["x"], 64)
+			y, _ := strconv.ParseFloat(attrs["y"], 64)
+			zone, ok = contentZone{kind: contentImage, rect: [4]float64{x, y, x + width, y + height}}, width > 0 && height > 0
+			if id := attrs["id"]; ok && id != "" {
+				defs[id] = &definition{kind: contentImage, box: zone.rect}
+			}
+		case "use":
+			def := defs[strings.TrimPrefix(attrs["href"], "#")]
+			if def == nil {
+				return
+			}
+			zone, ok = contentZone{kind: def.kind, rect: def.box}, true
+			if _, text := attrs["data-text"]; text || def.glyph {
+				zone.kind = contentText
+			}
+		}
+		if ok && !hidden {
+			zone.rect = m.apply(zone.rect)
+			zones = append(zones, zone)
+		}
+	})
+	return zones
+}
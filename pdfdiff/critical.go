@@ -0,0 +1,49 @@
+package pdfdiff
+
+import "image"
+
+// CriticalChange is a difference inside one of the CriticalRegions of the options, in pixels of the difference image
+type CriticalChange struct {
+	X             int `json:"x"`
+	Y             int `json:"y"`
+	Width         int `json:"width"`
+	Height        int `json:"height"`
+	ChangedPixels int `json:"changed_pixels"`
+	// Reason is the reason of the region, e.g. "dosage table"
+	Reason string `json:"reason,omitempty"`
+}
+
+// criticalChanges counts the pixels that differ at all inside every critical region of a page, whatever the
+// threshold, and returns the regions that changed
+func criticalChanges(img1, img2 image.Image, regions []IgnoreRegion, page int, dpi float64) []CriticalChange {
+	bounds := img1.Bounds()
+	var changes []CriticalChange
+	for _, region := range regions {
+		for _, r := range ignoreRects([]IgnoreRegion{region}, page, bounds.Dy(), dpi) {
+			r = r.Intersect(bounds)
+			n := 0
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					if pixelsDiffer(img1.At(x, y), img2.At(x, y), 0) {
+						n++
+					}
+				}
+			}
+			if n > 0 {
+				changes = append(changes, CriticalChange{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy(), ChangedPixels: n, Reason: region.Reason})
+			}
+		}
+	}
+	return changes
+}
+
+// CriticalPages returns the pages of the report with differences inside a critical region, except the quarantined ones
+func (r Report) CriticalPages() []PageResult {
+	var critical []PageResult
+	for _, page := range r.Pages {
+		if len(page.Critical) > 0 && !page.Quarantined {
+			critical = append(critical, page)
+		}
+	}
+	return critical
+}
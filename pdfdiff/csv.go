@@ -0,0 +1,58 @@
+package pdfdiff
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// csvHeader are the columns of the CSV files written by WriteCSV
+var csvHeader = []string{"document", "page", "changed", "pixels", "changed_pixels", "percent_changed", "ssim", "missing_in", "quarantined", "diff_image", "combined_image", "image1", "image2"}
+
+// WriteCSV saves the metrics of every page of the report as a row of a CSV file, to chart the drift of a document
+// from build to build: the second document, the page from 1, the pixels compared and changed, the percentage and the
+// structural similarity, and the paths of the images written for the page, empty when they are kept in memory
+func WriteCSV(report Report, output string) (err error) {
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w := csv.NewWriter(f)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	path := func(name string) string {
+		if name == "" || report.Images != nil {
+			return ""
+		}
+		return filepath.Join(report.Dir, name)
+	}
+	for _, page := range report.Pages {
+		row := []string{
+			report.File2,
+			fmt.Sprint(page.Page + 1),
+			fmt.Sprint(page.Changed),
+			fmt.Sprint(page.Width * page.Height),
+			fmt.Sprint(page.ChangedPixels),
+			fmt.Sprintf("%.4f", page.PercentChanged),
+			fmt.Sprintf("%.4f", page.SSIM),
+			fmt.Sprint(page.MissingIn),
+			fmt.Sprint(page.Quarantined),
+			path(page.DiffImage),
+			path(page.CombinedImage),
+			path(page.Image1),
+			path(page.Image2),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,501 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrPassword is returned for encrypted documents opened without their password, or with a wrong one
+var ErrPassword = errors.New("the document is encrypted and the password is missing or wrong")
+
+// The renderer cannot be given the password of a document, so encrypted PDFs are decrypted with the parser of
+// pdfobj.go and written again without their encryption, in memory, for the renderer. The standard security handler is
+// supported, with RC4 and AES keys (revisions 2 to 6) and the user or owner password.
+
+// passwordPadding pads the passwords of revisions 2 to 4
+var passwordPadding = []byte("\x28\xbf\x4e\x5e\x4e\x75\x8a\x41\x64\x00\x4e\x56\xff\xfa\x01\x08\x2e\x2e\x00\xb6\xd0\x68\x3e\x80\x2f\x0c\xa9\xfe\x64\x53\x69\x7a")
+
+// pdfSecurity is the standard security handler of an encrypted PDF once it is unlocked
+type pdfSecurity struct {
+	key []byte
+	// stream and str are the methods of the streams and strings: "None", "V2" (RC4), "AESV2" or "AESV3"
+	stream, str     string
+	encryptMetadata bool
+}
+
+// DecryptPDF returns a copy of an encrypted PDF without its encryption, unlocked with its user or owner password.
+// The content of a PDF that is not encrypted is returned as it is.
+func DecryptPDF(data []byte, password string) ([]byte, error) {
+	f, err := parsePDF(data, "document")
+	if err != nil {
+		return nil, err
+	}
+	encrypt := f.dict(f.trailer["Encrypt"])
+	if encrypt == nil {
+		return data, nil
+	}
+	sec, err := f.unlock(encrypt, password)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptRef, _ := f.trailer["Encrypt"].(pdfRef)
+	for num, obj := range f.objects {
+		if f.compressed[num] || (num == encryptRef.num && encryptRef.num != 0) {
+			continue
+		}
+		if stream, ok := obj.(pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			continue
+		}
+		if f.objects[num], err = sec.decryptObject(obj, num, f.gens[num]); err != nil {
+			return nil, fmt.Errorf("object %d: %w", num, err)
+		}
+	}
+	// The objects of the object streams can only be read once the streams are decrypted
+	for _, obj := range f.objects {
+		if stream, ok := obj.(pdfStream); ok && stream.dict["Type"] == pdfName("ObjStm") {
+			f.readObjectStream(stream)
+		}
+	}
+	return f.write(encryptRef.num), nil
+}
+
+// unlock computes the key of an encrypted PDF from its user or owner password
+func (f *pdfFile) unlock(encrypt pdfDict, password string) (*pdfSecurity, error) {
+	if filter := f.resolve(encrypt["Filter"]); filter != pdfName("Standard") {
+		return nil, fmt.Errorf("unsupported security handler %v", filter)
+	}
+	v, _ := f.resolve(encrypt["V"]).(float64)
+	r, _ := f.resolve(encrypt["R"]).(float64)
+	o, _ := f.resolve(encrypt["O"]).(string)
+	u, _ := f.resolve(encrypt["U"]).(string)
+	sec := &pdfSecurity{stream: "V2", str: "V2", encryptMetadata: f.resolve(encrypt["EncryptMetadata"]) != false}
+	if v >= 4 {
+		cf := f.dict(encrypt["CF"])
+		method := func(name interface{}) string {
+			if name = f.resolve(name); name == nil || name == pdfName("Identity") {
+				return "None"
+			}
+			n, _ := name.(pdfName)
+			// The filters without a method do not encrypt
+			if m, _ := f.resolve(f.dict(cf[string(n)])["CFM"]).(pdfName); m != "" {
+				return string(m)
+			}
+			return "None"
+		}
+		sec.stream, sec.str = method(encrypt["StmF"]), method(encrypt["StrF"])
+	}
+
+	if r >= 5 {
+		oe, _ := f.resolve(encrypt["OE"]).(string)
+		ue, _ := f.resolve(encrypt["UE"]).(string)
+		if len(o) < 48 || len(u) < 48 || len(oe) < 32 || len(ue) < 32 {
+			return nil, errors.New("damaged encryption dictionary")
+		}
+		pw := []byte(saslprep(password))
+		if len(pw) > 127 {
+			pw = pw[:127]
+		}
+		var key []byte
+		var err error
+		switch {
+		case bytes.Equal(passwordHash(int(r), pw, []byte(o[32:40]), []byte(u[:48])), []byte(o[:32])):
+			key, err = decryptAESNoIV(passwordHash(int(r), pw, []byte(o[40:48]), []byte(u[:48])), []byte(oe[:32]))
+		case bytes.Equal(passwordHash(int(r), pw, []byte(u[32:40]), nil), []byte(u[:32])):
+			key, err = decryptAESNoIV(passwordHash(int(r), pw, []byte(u[40:48]), nil), []byte(ue[:32]))
+		default:
+			return nil, ErrPassword
+		}
+		if err != nil {
+			return nil, fmt.Errorf("damaged encryption dictionary: %w", err)
+		}
+		sec.key = key
+		return sec, nil
+	}
+
+	length := 5
+	if r >= 3 {
+		if l, ok := f.resolve(encrypt["Length"]).(float64); ok && l >= 40 && l <= 128 {
+			length = int(l) / 8
+		}
+	}
+	p, _ := f.resolve(encrypt["P"]).(float64)
+	var id string
+	if ids := f.array(f.trailer["ID"]); len(ids) > 0 {
+		id, _ = f.resolve(ids[0]).(string)
+	}
+	fileKey := func(user []byte) []byte {
+		h := md5.New()
+		h.Write(padPassword(user))
+		h.Write([]byte(o))
+		binary.Write(h, binary.LittleEndian, int32(p))
+		h.Write([]byte(id))
+		if r >= 4 && !sec.encryptMetadata {
+			h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+		}
+		key := h.Sum(nil)
+		if r >= 3 {
+			for i := 0; i < 50; i++ {
+				sum := md5.Sum(key[:length])
+				key = sum[:]
+			}
+		}
+		return key[:length]
+	}
+	checkUser := func(key []byte) bool {
+		if r == 2 {
+			return bytes.Equal(rc4Crypt(key, passwordPadding), []byte(u))
+		}
+		h := md5.New()
+		h.Write(passwordPadding)
+		h.Write([]byte(id))
+		check := h.Sum(nil)
+		for i := 0; i < 20; i++ {
+			check = rc4Crypt(xorKey(key, byte(i)), check)
+		}
+		return len(u) >= 16 && bytes.Equal(check, []byte(u[:16]))
+	}
+
+	if key := fileKey([]byte(password)); checkUser(key) {
+		sec.key = key
+		return sec, nil
+	}
+	// The owner password decrypts the padded user password from O
+	sum := md5.Sum(padPassword([]byte(password)))
+	ownerKey := sum[:]
+	if r >= 3 {
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(ownerKey)
+			ownerKey = sum[:]
+		}
+	}
+	ownerKey = ownerKey[:length]
+	user := []byte(o)
+	if r == 2 {
+		user = rc4Crypt(ownerKey, user)
+	} else {
+		for i := 19; i >= 0; i-- {
+			user = rc4Crypt(xorKey(ownerKey, byte(i)), user)
+		}
+	}
+	if key := fileKey(user); checkUser(key) {
+		sec.key = key
+		return sec, nil
+	}
+	return nil, ErrPassword
+}
+
+// padPassword pads or truncates a password to 32 bytes, for revisions 2 to 4
+func padPassword(password []byte) []byte {
+	padded := append([]byte(nil), password...)
+	if len(padded) > 32 {
+		padded = padded[:32]
+	}
+	return append(padded, passwordPadding[:32-len(padded)]...)
+}
+
+func xorKey(key []byte, b byte) []byte {
+	k := make([]byte, len(key))
+	for i := range key {
+		k[i] = key[i] ^ b
+	}
+	return k
+}
+
+func rc4Crypt(key, data []byte) []byte {
+	c, err := rc4.NewCipher(key)
+	if err != nil {
+		return nil
+	}
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+// passwordHash is the hash of a password of revisions 5 and 6 with a salt, and with the U string for the owner
+// password. Revision 6 hashes the result again at least 64 times with SHA-256, SHA-384 and SHA-512.
+func passwordHash(r int, password, salt, udata []byte) []byte {
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(udata)
+	k := h.Sum(nil)
+	if r == 5 {
+		return k
+	}
+	for i := 0; ; i++ {
+		k1 := bytes.Repeat(append(append(append([]byte(nil), password...), k...), udata...), 64)
+		block, _ := aes.NewCipher(k[:16])
+		e := make([]byte, len(k1))
+		cipher.NewCBCEncrypter(block, k[16:32]).CryptBlocks(e, k1)
+		sum := 0
+		for _, b := range e[:16] {
+			sum += int(b)
+		}
+		var next hash.Hash
+		switch sum % 3 {
+		case 0:
+			next = sha256.New()
+		case 1:
+			next = sha512.New384()
+		default:
+			next = sha512.New()
+		}
+		next.Write(e)
+		k = next.Sum(nil)
+		if i >= 63 && int(e[len(e)-1]) <= i+1-32 {
+			return k[:32]
+		}
+	}
+}
+
+// saslprep prepares a password of revisions 5 and 6 with the SASLprep profile of stringprep (RFC 4013), so the
+// passwords typed in another normalization form or with other spaces unlock the document: the non-ASCII spaces are
+// mapped to a space, the characters commonly mapped to nothing are removed and the result is normalized to NFKC. The
+// prohibited characters are kept, as the password is only hashed.
+func saslprep(password string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r == 0xad || r == 0x34f || r == 0x1806 || (r >= 0x180b && r <= 0x180d) || (r >= 0x200b && r <= 0x200d) ||
+			r == 0x2060 || (r >= 0xfe00 && r <= 0xfe0f) || r == 0xfeff:
+			return -1
+		case r > 0x7f && unicode.Is(unicode.Zs, r):
+			return ' '
+		}
+		return r
+	}, password)
+	return norm.NFKC.String(mapped)
+}
+
+// decryptAESNoIV decrypts the file key of revisions 5 and 6 with AES-256 and a zero initialization vector
+func decryptAESNoIV(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("the encrypted key is %d bytes, not whole AES blocks", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out, nil
+}
+
+// decryptObject decrypts the strings and the stream data of an object
+func (sec *pdfSecurity) decryptObject(obj interface{}, num, gen int) (interface{}, error) {
+	switch v := obj.(type) {
+	case string:
+		data, err := sec.decrypt(sec.str, []byte(v), num, gen)
+		return string(data), err
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i := range v {
+			var err error
+			if a[i], err = sec.decryptObject(v[i], num, gen); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case pdfDict:
+		d := make(pdfDict, len(v))
+		for k, value := range v {
+			var err error
+			if d[k], err = sec.decryptObject(value, num, gen); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case pdfStream:
+		dict, err := sec.decryptObject(v.dict, num, gen)
+		if err != nil {
+			return nil, err
+		}
+		stream := pdfStream{dict: dict.(pdfDict), data: v.data}
+		if v.dict["Type"] != pdfName("Metadata") || sec.encryptMetadata {
+			if stream.data, err = sec.decrypt(sec.stream, v.data, num, gen); err != nil {
+				return nil, err
+			}
+		}
+		return stream, nil
+	}
+	return obj, nil
+}
+
+// decrypt decrypts a string or the data of a stream of an object with the given method
+func (sec *pdfSecurity) decrypt(method string, data []byte, num, gen int) ([]byte, error) {
+	if method == "None" {
+		return data, nil
+	}
+	key := sec.key
+	if method != "AESV3" {
+		h := md5.New()
+		h.Write(sec.key)
+		h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(gen), byte(gen >> 8)})
+		if method == "AESV2" {
+			h.Write([]byte("sAlT"))
+		}
+		key = h.Sum(nil)
+		if n := len(sec.key) + 5; n < len(key) {
+			key = key[:n]
+		}
+	}
+	if method == "V2" {
+		return rc4Crypt(key, data), nil
+	}
+	if method != "AESV2" && method != "AESV3" {
+		return nil, fmt.Errorf("unsupported encryption method %s", method)
+	}
+	if len(data) == 0 {
+		// An empty string is sometimes left empty rather than encrypted with its padding
+		return data, nil
+	}
+
+	// The AES data starts with its initialization vector and is padded to full blocks
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("the %d bytes of AES data are not an initialization vector and whole blocks", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	pad := int(out[len(out)-1])
+	if pad < 1 || pad > aes.BlockSize {
+		return nil, fmt.Errorf("invalid AES padding")
+	}
+	return out[:len(out)-pad], nil
+}
+
+// write writes the objects of the file as a new PDF without encryption, leaving out the encryption dictionary and
+// the object and cross-reference streams, whose objects are written one by one
+func (f *pdfFile) write(skip int) []byte {
+	var nums []int
+	for num, obj := range f.objects {
+		if stream, ok := obj.(pdfStream); ok && (stream.dict["Type"] == pdfName("ObjStm") || stream.dict["Type"] == pdfName("XRef")) {
+			continue
+		}
+		if num > 0 && (num != skip || skip == 0) {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make(map[int]int)
+	size := 1
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d %d obj\n", num, f.gens[num])
+		if stream, ok := f.objects[num].(pdfStream); ok {
+			dict := make(pdfDict, len(stream.dict))
+			for k, v := range stream.dict {
+				dict[k] = v
+			}
+			dict["Length"] = float64(len(stream.data))
+			writePDFObject(&buf, dict)
+			buf.WriteString("\nstream\n")
+			buf.Write(stream.data)
+			buf.WriteString("\nendstream")
+		} else {
+			writePDFObject(&buf, f.objects[num])
+		}
+		buf.WriteString("\nendobj\n")
+		size = num + 1
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for num := 1; num < size; num++ {
+		if offset, ok := offsets[num]; ok {
+			fmt.Fprintf(&buf, "%010d %05d n \n", offset, f.gens[num])
+		} else {
+			buf.WriteString("0000000000 00000 f \n")
+		}
+	}
+	trailer := pdfDict{"Size": float64(size)}
+	for _, key := range []string{"Root", "Info", "ID"} {
+		if v, ok := f.trailer[key]; ok {
+			trailer[key] = v
+		}
+	}
+	buf.WriteString("trailer\n")
+	writePDFObject(&buf, trailer)
+	fmt.Fprintf(&buf, "\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// writePDFObject writes an object in PDF syntax, strings as hex strings and dictionaries with sorted keys
+func writePDFObject(buf *bytes.Buffer, obj interface{}) {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			buf.WriteString(strconv.FormatInt(int64(v), 10))
+		} else {
+			buf.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	case string:
+		fmt.Fprintf(buf, "<%x>", v)
+	case pdfName:
+		writePDFName(buf, v)
+	case pdfRef:
+		fmt.Fprintf(buf, "%d %d R", v.num, v.gen)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writePDFObject(buf, item)
+		}
+		buf.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writePDFName(buf, pdfName(k))
+			buf.WriteByte(' ')
+			writePDFObject(buf, v[k])
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(">>")
+	}
+}
+
+// writePDFName writes a name with its delimiters and special characters escaped as #xx
+func writePDFName(buf *bytes.Buffer, name pdfName) {
+	buf.WriteByte('/')
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x21 || c > 0x7e || isDelimiter(c) || c == '#' {
+			fmt.Fprintf(buf, "#%02X", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures of testdata/encrypted are written by MuPDF (see gen.go) from a page whose content stream shows
+// fixturePage, with fixtureTitle in its Info dictionary
+const (
+	fixturePage  = "(Encrypted page) Tj"
+	fixtureTitle = "Encrypted fixture"
+)
+
+// checkDecrypted checks that a decrypted fixture has no encryption left and shows its page and title
+func checkDecrypted(t *testing.T, data []byte) {
+	t.Helper()
+	f, err := parsePDF(data, "decrypted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.trailer["Encrypt"]; ok {
+		t.Error("the decrypted document is still encrypted")
+	}
+	if title := f.text(f.dict(f.trailer["Info"])["Title"]); title != fixtureTitle {
+		t.Errorf("title = %q, want %q", title, fixtureTitle)
+	}
+	found := false
+	for _, obj := range f.objects {
+		if stream, ok := obj.(pdfStream); ok && bytes.Contains(stream.data, []byte(fixturePage)) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no content stream shows %s", fixturePage)
+	}
+}
+
+func TestDecryptPDF(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		password string
+		wantErr  error
+	}{
+		{"r2-rc4-40.pdf", "user", nil},
+		{"r2-rc4-40.pdf", "owner", nil},
+		{"r2-rc4-40.pdf", "guess", ErrPassword},
+		{"r3-rc4-128.pdf", "user", nil},
+		{"r3-rc4-128.pdf", "owner", nil},
+		{"r3-rc4-128.pdf", "", ErrPassword},
+		{"r4-aesv2.pdf", "user", nil},
+		{"r4-aesv2.pdf", "owner", nil},
+		{"r4-aesv2.pdf", "guess", ErrPassword},
+		{"r6-aesv3.pdf", "user", nil},
+		{"r6-aesv3.pdf", "owner", nil},
+		{"r6-aesv3.pdf", "guess", ErrPassword},
+		{"r6-aesv3-unicode.pdf", "caf\u00e9 secret", nil},
+		// The decomposed accent and the no-break space are prepared with SASLprep to the password of the fixture
+		{"r6-aesv3-unicode.pdf", "cafe\u0301\u00a0secret", nil},
+		{"r6-aesv3-unicode.pdf", "cafe secret", ErrPassword},
+	}
+	for _, test := range tests {
+		t.Run(test.fixture+"/"+test.password, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "encrypted", test.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := DecryptPDF(data, test.password)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("err = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDecrypted(t, decrypted)
+		})
+	}
+}
+
+// The fixtures MuPDF cannot write, revision 4 with RC4 and revision 5, are encrypted here following the
+// specification, with fixed salts and initialization vectors
+
+var fixtureID = []byte("0123456789abcdef")
+
+// encryptFixture returns the page of the fixtures encrypted with the given revision, 4 for RC4 or 5 for AES-256,
+// and the user and owner passwords. contentData replaces the encrypted content stream when set.
+func encryptFixture(t *testing.T, r int, user, owner string, contentData []byte) []byte {
+	t.Helper()
+	var encrypt string
+	var crypt func(data []byte, num int) []byte
+	switch r {
+	case 4:
+		const n = 16
+		// Algorithm 3: the owner key encrypts the padded user password
+		sum := md5.Sum(padPassword([]byte(owner)))
+		ownerKey := sum[:]
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(ownerKey)
+			ownerKey = sum[:]
+		}
+		o := padPassword([]byte(user))
+		for i := 0; i < 20; i++ {
+			o = testRC4(xorKey(ownerKey[:n], byte(i)), o)
+		}
+		// Algorithm 2: the file key
+		h := md5.New()
+		h.Write(padPassword([]byte(user)))
+		h.Write(o)
+		binary.Write(h, binary.LittleEndian, int32(-4))
+		h.Write(fixtureID)
+		key := h.Sum(nil)
+		for i := 0; i < 50; i++ {
+			sum = md5.Sum(key[:n])
+			key = sum[:]
+		}
+		key = key[:n]
+		// Algorithm 5: the user check, padded to 32 bytes
+		h = md5.New()
+		h.Write(passwordPadding)
+		h.Write(fixtureID)
+		u := h.Sum(nil)
+		for i := 0; i < 20; i++ {
+			u = testRC4(xorKey(key, byte(i)), u)
+		}
+		u = append(u, make([]byte, 16)...)
+		encrypt = fmt.Sprintf("<< /Filter /Standard /V 4 /R 4 /Length 128 /P -4 /O <%x> /U <%x> "+
+			"/CF << /StdCF << /CFM /V2 /Length 16 >> >> /StmF /StdCF /StrF /StdCF >>", o, u)
+		crypt = func(data []byte, num int) []byte {
+			sum := md5.Sum(append(append([]byte(nil), key...), byte(num), byte(num>>8), byte(num>>16), 0, 0))
+			return testRC4(sum[:], data)
+		}
+	case 5:
+		key := bytes.Repeat([]byte{0x42}, 32)
+		uSalts, oSalts := []byte("uvalsaltukeysalt"), []byte("ovalsaltokeysalt")
+		hash := func(password string, salt, udata []byte) []byte {
+			sum := sha256.Sum256(append(append([]byte(password), salt...), udata...))
+			return sum[:]
+		}
+		u := append(hash(user, uSalts[:8], nil), uSalts...)
+		ue := testAESNoIV(hash(user, uSalts[8:], nil), key)
+		o := append(hash(owner, oSalts[:8], u), oSalts...)
+		oe := testAESNoIV(hash(owner, oSalts[8:], u), key)
+		encrypt = fmt.Sprintf("<< /Filter /Standard /V 5 /R 5 /Length 256 /P -4 /O <%x> /U <%x> /OE <%x> /UE <%x> "+
+			"/CF << /StdCF << /CFM /AESV3 /Length 32 >> >> /StmF /StdCF /StrF /StdCF >>", o, u, oe, ue)
+		crypt = func(data []byte, num int) []byte {
+			iv := bytes.Repeat([]byte{byte(num)}, aes.BlockSize)
+			pad := aes.BlockSize - len(data)%aes.BlockSize
+			padded := append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+			block, _ := aes.NewCipher(key)
+			out := make([]byte, len(padded))
+			cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, padded)
+			return append(iv, out...)
+		}
+	default:
+		t.Fatalf("no fixture of revision %d", r)
+	}
+
+	content := []byte("BT /F1 24 Tf 10 40 Td " + fixturePage + " ET")
+	if contentData == nil {
+		contentData = crypt(content, 4)
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(contentData), contentData),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Title <%x> >>", crypt([]byte(fixtureTitle), 6)),
+		encrypt,
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Root 1 0 R /Info 6 0 R /Encrypt 7 0 R /ID [<%x> <%x>] /Size %d >>\nstartxref\n%d\n%%%%EOF\n",
+		fixtureID, fixtureID, len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func testRC4(key, data []byte) []byte {
+	c, _ := rc4.NewCipher(key)
+	out := make([]byte, len(data))
+	c.XORKeyStream(out, data)
+	return out
+}
+
+func testAESNoIV(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+	return out
+}
+
+func TestDecryptPDFEncryptedHere(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        int
+		password string
+		content  []byte
+		wantErr  string
+	}{
+		{"r4 rc4 user", 4, "user", nil, ""},
+		{"r4 rc4 owner", 4, "owner", nil, ""},
+		{"r4 rc4 wrong", 4, "guess", nil, ErrPassword.Error()},
+		{"r5 aesv3 user", 5, "user", nil, ""},
+		{"r5 aesv3 owner", 5, "owner", nil, ""},
+		{"r5 aesv3 wrong", 5, "guess", nil, ErrPassword.Error()},
+		{"r5 truncated aes stream", 5, "user", bytes.Repeat([]byte{1}, 20), "AES data"},
+		{"r5 aes stream without padding", 5, "user", bytes.Repeat([]byte{0}, 2*aes.BlockSize), "AES padding"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decrypted, err := DecryptPDF(encryptFixture(t, test.r, "user", "owner", test.content), test.password)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("err = %v, want %q", err, test.wantErr)
+				}
+				if decrypted != nil {
+					t.Error("a failed decryption returned a document")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDecrypted(t, decrypted)
+		})
+	}
+}
+
+func TestSASLprep(t *testing.T) {
+	tests := []struct {
+		password, want string
+	}{
+		{"user", "user"},
+		{"I\u00adX", "IX"},
+		{"\u2168", "IX"},
+		{"cafe\u0301", "caf\u00e9"},
+		{"a\u00a0b\u3000c", "a b c"},
+		{"\ufeffpass\u200bword", "password"},
+	}
+	for _, test := range tests {
+		if got := saslprep(test.password); got != test.want {
+			t.Errorf("saslprep(%q) = %q, want %q", test.password, got, test.want)
+		}
+	}
+}
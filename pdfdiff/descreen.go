@@ -0,0 +1,125 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+// descreenBlock is the side in pixels of the blocks classified as halftone or not
+const descreenBlock = 16
+
+// descreenEdges is the share of the pixels of a block that differ from their right or lower neighbour by more than
+// descreenContrast in a halftone: the dots of a print screen alternate every few pixels, where text strokes are several
+// pixels wide and leave most of a block plain. The light and dark ends of a picture have fewer edges, so a halftone
+// extends into the touching blocks with at least descreenGrowEdges.
+const (
+	descreenEdges     = 0.4
+	descreenGrowEdges = 0.1
+	descreenContrast  = 64
+)
+
+// descreenSigma is the blur radius in inches that merges the dots of screens down to about 50 lines per inch into a
+// continuous tone
+const descreenSigma = 0.01
+
+// halftoneBlocks returns the blocks of a page whose pixels alternate like the dots of a halftone, by row
+func halftoneBlocks(img image.Image) (mask []bool, cols, rows int) {
+	b := img.Bounds()
+	cols, rows = (b.Dx()+descreenBlock-1)/descreenBlock, (b.Dy()+descreenBlock-1)/descreenBlock
+	edges := make([]int, cols*rows)
+	for y := b.Min.Y; y < b.Max.Y-1; y++ {
+		for x := b.Min.X; x < b.Max.X-1; x++ {
+			c := int(brightness(img.At(x, y)))
+			right, below := int(brightness(img.At(x+1, y))), int(brightness(img.At(x, y+1)))
+			if abs(c-right) > descreenContrast || abs(c-below) > descreenContrast {
+				edges[(y-b.Min.Y)/descreenBlock*cols+(x-b.Min.X)/descreenBlock]++
+			}
+		}
+	}
+	mask = make([]bool, cols*rows)
+	var stack []int
+	for i, n := range edges {
+		if float64(n) >= descreenEdges*descreenBlock*descreenBlock {
+			mask[i] = true
+			stack = append(stack, i)
+		}
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		col, row := i%cols, i/cols
+		for _, n := range [][2]int{{col - 1, row}, {col + 1, row}, {col, row - 1}, {col, row + 1}} {
+			if n[0] < 0 || n[1] < 0 || n[0] >= cols || n[1] >= rows {
+				continue
+			}
+			if j := n[1]*cols + n[0]; !mask[j] && float64(edges[j]) >= descreenGrowEdges*descreenBlock*descreenBlock {
+				mask[j] = true
+				stack = append(stack, j)
+			}
+		}
+	}
+	return mask, cols, rows
+}
+
+// descreen blurs the blocks of the mask, and the blocks around them, so the halftone becomes the tone it prints
+func descreen(img image.Image, mask []bool, cols, rows int, sigma float64) image.Image {
+	b := img.Bounds()
+	blurred := imaging.Blur(img, sigma)
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if !nearHalftone(mask, cols, rows, col, row) {
+				continue
+			}
+			r := image.Rect(col*descreenBlock, row*descreenBlock, (col+1)*descreenBlock, (row+1)*descreenBlock)
+			draw.Draw(out, r.Add(b.Min).Intersect(b), blurred, r.Min, draw.Src)
+		}
+	}
+	return out
+}
+
+// nearHalftone reports whether a block or one of its neighbours is a halftone, so the edges of a picture are blurred too
+func nearHalftone(mask []bool, cols, rows, col, row int) bool {
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			c, r := col+dx, row+dy
+			if c >= 0 && r >= 0 && c < cols && r < rows && mask[r*cols+c] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// descreenStage returns the preprocess stage that blurs the halftones of either page at dpi. Both pages are blurred
+// in the same areas, so a picture printed with another screen compares as the same picture.
+func descreenStage(dpi float64) PreprocessFunc {
+	return func(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+		mask, cols, rows := halftoneBlocks(img1)
+		mask2, cols2, rows2 := halftoneBlocks(img2)
+		if cols2 != cols || rows2 != rows {
+			return img1, img2, nil
+		}
+		found := false
+		for i := range mask {
+			mask[i] = mask[i] || mask2[i]
+			found = found || mask[i]
+		}
+		if !found {
+			return img1, img2, nil
+		}
+		sigma := descreenSigma * dpi
+		return descreen(img1, mask, cols, rows, sigma), descreen(img2, mask, cols, rows, sigma), nil
+	}
+}
+
+// abs returns the absolute value of an int
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,98 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// despeckleSize is the side in inches of the square covering as many pixels as the largest speck of dust removed by
+// Despeckle, anti-aliased edge included. A period of 10 point body text covers about twice as many, so punctuation survives.
+const despeckleSize = 0.015
+
+// A pixel can belong to a speck when it is darker than the paper by more than despeckleContrast, or darker than
+// despeckleInk on pages without a dominant paper color
+const (
+	despeckleContrast = 16
+	despeckleInk      = 192
+)
+
+// despeckle removes the specks of a page: an area opening that paints every group of touching dark pixels covering
+// at most maxArea pixels, and the anti-aliased pixels around it, with the color of the paper. Unlike a median filter
+// it keeps hairlines.
+func despeckle(img image.Image, maxArea int) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	ink := uint8(despeckleInk)
+	paper, hasPaper := paperColor(img)
+	if hasPaper && brightness(paper) > despeckleContrast {
+		ink = brightness(paper) - despeckleContrast
+	}
+	dark := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dark[y*w+x] = brightness(out.RGBAAt(b.Min.X+x, b.Min.Y+y)) < ink
+		}
+	}
+
+	seen := make([]bool, len(dark))
+	var speck, ring, stack []int
+	for start := range dark {
+		if !dark[start] || seen[start] {
+			continue
+		}
+		// Flood fill the group, collecting the light pixels around it
+		speck, ring, stack = speck[:0], ring[:0], append(stack[:0], start)
+		seen[start] = true
+		var r, g, bl int
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(speck) <= maxArea {
+				speck = append(speck, i)
+			}
+			x, y := i%w, i/w
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					j := ny*w + nx
+					switch {
+					case dark[j] && !seen[j]:
+						seen[j] = true
+						stack = append(stack, j)
+					case !dark[j] && len(speck) <= maxArea:
+						c := out.RGBAAt(b.Min.X+nx, b.Min.Y+ny)
+						r, g, bl = r+int(c.R), g+int(c.G), bl+int(c.B)
+						ring = append(ring, j)
+					}
+				}
+			}
+		}
+		if len(speck) > maxArea || len(ring) == 0 {
+			continue
+		}
+		// Pages without a paper color take the average color around the speck
+		fill := paper
+		if !hasPaper {
+			fill = color.RGBA{uint8(r / len(ring)), uint8(g / len(ring)), uint8(bl / len(ring)), 255}
+		}
+		for _, i := range append(speck, ring...) {
+			out.SetRGBA(b.Min.X+i%w, b.Min.Y+i/w, fill)
+		}
+	}
+	return out
+}
+
+// despeckleStage returns the preprocess stage that removes the specks of both pages rendered at dpi
+func despeckleStage(dpi float64) PreprocessFunc {
+	side := despeckleSize * dpi
+	maxArea := max(int(side*side), 1)
+	return func(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+		return despeckle(img1, maxArea), despeckle(img2, maxArea), nil
+	}
+}
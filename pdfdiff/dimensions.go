@@ -0,0 +1,125 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// The kinds of the dimension changes
+const (
+	DimensionChanged = "changed"
+	DimensionAdded   = "added"
+	DimensionRemoved = "removed"
+)
+
+// dimensionDistance is how far, in points, a dimension may move between the pages, e.g. when its value got wider,
+// and still be paired with the other one
+const dimensionDistance = 20
+
+// dimensionPattern matches the values of the dimensions and annotations of a technical drawing: a number with a
+// unit, a diameter, radius or thread prefix, or a tolerance, e.g. 120 mm, Ø30, R5, 45° or 12.5±0.1
+var dimensionPattern = regexp.MustCompile(`(?:[Ø⌀∅]|\b[RM])\s?\d+(?:[.,]\d+)?(?:\s?(?:mm|cm|m|in|ft|"|°))?(?:\s?[±+-]\s?\d+(?:[.,]\d+)?)?|\b\d+(?:[.,]\d+)?(?:\s?[±]\s?\d+(?:[.,]\d+)?(?:\s?(?:mm|cm|m|in|ft|"|°))?|\s?(?:mm|cm|m|in|ft)\b|\s?["°′″])`)
+
+// bareDimension matches the lines made of a number only, the dimensions of the drawings whose unit is given once in
+// their title block
+var bareDimension = regexp.MustCompile(`^\d+(?:[.,]\d+)?$`)
+
+// DimensionChange is a measurement of a technical drawing that changed, was added or was removed between the pages,
+// found with Dimensions apart from the geometry of the drawing
+type DimensionChange struct {
+	// Kind is changed, added or removed
+	Kind string `json:"kind"`
+	// Value1 and Value2 are the text of the dimension on the first and the second page, empty when it is not there
+	Value1 string `json:"value1,omitempty"`
+	Value2 string `json:"value2,omitempty"`
+	// X and Y are the center of the dimension, on the second page unless it was removed, in points from the top
+	// left corner
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	// Description tells the change, e.g. "dimension 120 mm → 125 mm"
+	Description string `json:"description"`
+}
+
+// dimension is a measurement of an SVG page, with the center of its text
+type dimension struct {
+	value string
+	x, y  float64
+}
+
+// pageDimensions returns the dimensions of the text of an SVG page
+func pageDimensions(svg string) []dimension {
+	var dims []dimension
+	for _, line := range svgTextLines(svg) {
+		matches := dimensionPattern.FindAllStringIndex(line.text, -1)
+		if trimmed := strings.TrimSpace(line.text); len(matches) == 0 && bareDimension.MatchString(trimmed) {
+			start := strings.Index(line.text, trimmed)
+			matches = [][]int{{start, start + len(trimmed)}}
+		}
+		for _, m := range matches {
+			// The lines have a box by character
+			start, end := utf8.RuneCountInString(line.text[:m[0]]), utf8.RuneCountInString(line.text[:m[1]])
+			box := line.boxes[start]
+			for _, b := range line.boxes[start:end] {
+				box = [4]float64{math.Min(box[0], b[0]), math.Min(box[1], b[1]), math.Max(box[2], b[2]), math.Max(box[3], b[3])}
+			}
+			dims = append(dims, dimension{value: line.text[m[0]:m[1]], x: (box[0] + box[2]) / 2, y: (box[1] + box[3]) / 2})
+		}
+	}
+	return dims
+}
+
+// compareDimensions pairs the dimensions of both SVG pages by their position and returns the ones whose value
+// changed, and those of one page only. The dimensions with the same value nearby are paired first, so a value
+// moved next to another one is not reported.
+func compareDimensions(svg1, svg2 string) []DimensionChange {
+	dims1, dims2 := pageDimensions(svg1), pageDimensions(svg2)
+	paired1, paired2 := make([]bool, len(dims1)), make([]bool, len(dims2))
+	var changes []DimensionChange
+	for _, sameValue := range []bool{true, false} {
+		for i, d1 := range dims1 {
+			if paired1[i] {
+				continue
+			}
+			best, bestDistance := -1, float64(dimensionDistance)
+			for j, d2 := range dims2 {
+				if paired2[j] || sameValue != (normalizeDimension(d1.value) == normalizeDimension(d2.value)) {
+					continue
+				}
+				if dist := math.Hypot(d2.x-d1.x, d2.y-d1.y); dist <= bestDistance {
+					best, bestDistance = j, dist
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			paired1[i], paired2[best] = true, true
+			if !sameValue {
+				d2 := dims2[best]
+				changes = append(changes, DimensionChange{Kind: DimensionChanged, Value1: d1.value, Value2: d2.value, X: roundPoints(d2.x), Y: roundPoints(d2.y),
+					Description: fmt.Sprintf("dimension %s → %s", d1.value, d2.value)})
+			}
+		}
+	}
+	for i, d := range dims1 {
+		if !paired1[i] {
+			changes = append(changes, DimensionChange{Kind: DimensionRemoved, Value1: d.value, X: roundPoints(d.x), Y: roundPoints(d.y),
+				Description: fmt.Sprintf("dimension %s removed", d.value)})
+		}
+	}
+	for j, d := range dims2 {
+		if !paired2[j] {
+			changes = append(changes, DimensionChange{Kind: DimensionAdded, Value2: d.value, X: roundPoints(d.x), Y: roundPoints(d.y),
+				Description: fmt.Sprintf("dimension %s added", d.value)})
+		}
+	}
+	return changes
+}
+
+// normalizeDimension returns the value of a dimension without its spaces and with a decimal point, so a dimension
+// typeset again is not changed
+func normalizeDimension(value string) string {
+	return strings.ReplaceAll(strings.Join(strings.Fields(value), ""), ",", ".")
+}
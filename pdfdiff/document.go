@@ -0,0 +1,242 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// DefaultDPI is the resolution used to rasterize pages, shared by comparisons and renders
+const DefaultDPI = 300.0
+
+// ErrPageMissing is returned by a document when the requested page exists in the other document only
+var ErrPageMissing = errors.New("page missing")
+
+// Mutex to avoid race conditions when multiple goroutines access the same memory. It serializes the calls to the
+// documents of types that cannot be map keys; the other documents are locked one by one with lockDocument, so
+// concurrent comparisons of different documents do not wait for each other.
+var mutex = &sync.Mutex{}
+
+// documentLock serializes the calls to a document, which it is removed from documentLocks after once unused
+type documentLock struct {
+	sync.Mutex
+	users int
+}
+
+var (
+	locksMutex    sync.Mutex
+	documentLocks = make(map[Document]*documentLock)
+)
+
+// lockDocument waits until no other goroutine uses the document, and returns the function releasing it
+func lockDocument(doc Document) func() {
+	if doc == nil || !reflect.TypeOf(doc).Comparable() {
+		mutex.Lock()
+		return mutex.Unlock
+	}
+	locksMutex.Lock()
+	l := documentLocks[doc]
+	if l == nil {
+		l = &documentLock{}
+		documentLocks[doc] = l
+	}
+	l.users++
+	locksMutex.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		locksMutex.Lock()
+		if l.users--; l.users == 0 {
+			delete(documentLocks, doc)
+		}
+		locksMutex.Unlock()
+	}
+}
+
+// supportedFormats lists the document formats the fitz backend can open for comparison. Images are documents of a
+// single page.
+var supportedFormats = map[string]string{
+	".pdf":   "PDF",
+	".epub":  "EPUB",
+	".xps":   "XPS",
+	".oxps":  "OpenXPS",
+	".fb2":   "FictionBook",
+	".png":   "PNG",
+	".jpg":   "JPEG",
+	".jpeg":  "JPEG",
+	".gif":   "GIF",
+	".bmp":   "BMP",
+	".tif":   "TIFF",
+	".tiff":  "TIFF",
+	".jp2":   "JPEG 2000",
+	".jpx":   "JPEG 2000",
+	".jxr":   "JPEG XR",
+	".wdp":   "JPEG XR",
+	".hdp":   "JPEG XR",
+	".pam":   "PAM",
+	".pbm":   "PBM",
+	".pgm":   "PGM",
+	".ppm":   "PPM",
+	".pnm":   "PNM",
+	".pfm":   "PFM",
+	".jb2":   "JBIG2",
+	".jbig2": "JBIG2",
+}
+
+// Document is implemented by every input that can be compared page by page.
+// *fitz.Document satisfies it, as do archives of page images.
+type Document interface {
+	NumPage() int
+	Image(pageNumber int) (image.Image, error)
+	Close() error
+}
+
+// dpiRenderer is implemented by documents that can rasterize a page at a chosen resolution, such as *fitz.Document
+type dpiRenderer interface {
+	ImageDPI(pageNumber int, dpi float64) (image.Image, error)
+}
+
+// CheckInput verifies that the file exists and that its format can be compared. Directories are read as their page
+// images.
+func CheckInput(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("file %s does not exist", path)
+	}
+	if err == nil && info.IsDir() {
+		return nil
+	}
+	return checkFormat(path)
+}
+
+// checkFormat verifies that the format told by the extension of a file name can be compared
+func checkFormat(name string) error {
+	if _, ok := supportedFormats[strings.ToLower(filepath.Ext(name))]; !ok && !isBundle(name) {
+		return fmt.Errorf("file %s has an unsupported format. Supported formats are PDF, EPUB, XPS, OXPS, FB2, images (PNG, JPEG, GIF, BMP, TIFF, JPEG 2000, JPEG XR, PNM, JBIG2) and CBZ/CBR/ZIP image archives", name)
+	}
+	return nil
+}
+
+// Open opens a document with the fitz backend, or reads it as a sequence of page images if it is an archive or a
+// directory, or as a single page if it is a PNG, JPEG, GIF, BMP or TIFF image
+func Open(filename string) (Document, error) {
+	return OpenWithPassword(filename, "")
+}
+
+// OpenWithPassword opens a document like Open, unlocking an encrypted PDF with its user or owner password.
+// Encrypted PDFs opened without their password, or with a wrong one, fail with ErrPassword.
+func OpenWithPassword(filename, password string) (Document, error) {
+	if err := CheckInput(filename); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(filename); err == nil && info.IsDir() {
+		return openImageDir(filename)
+	}
+	if isBundle(filename) {
+		return openImageBundle(filename)
+	}
+	if isRasterImage(filename) {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return openRasterImage(filename, data)
+	}
+	if password != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		return openMemory(data, password)
+	}
+	return openFitzFile(filename)
+}
+
+// openMemory opens a document held in a byte slice with the fitz backend, decrypting it first if it is a PDF
+// given with its password
+func openMemory(data []byte, password string) (Document, error) {
+	if password != "" && bytes.HasPrefix(data, []byte("%PDF")) {
+		var err error
+		if data, err = DecryptPDF(data, password); err != nil {
+			return nil, err
+		}
+	}
+	return openFitzMemory(data)
+}
+
+// Input is a document held in memory rather than in a file, such as an upload to a server
+type Input struct {
+	// Name is the file name of the document, whose extension tells its format, and names it in the report
+	Name string
+	Data io.ReaderAt
+	Size int64
+	// Password unlocks the document if it is an encrypted PDF
+	Password string
+}
+
+// BytesInput returns the Input of a document held in a byte slice
+func BytesInput(name string, data []byte) Input {
+	return Input{Name: name, Data: bytes.NewReader(data), Size: int64(len(data))}
+}
+
+// OpenInput opens a document held in memory like Open. The fitz backend needs the whole document in a byte slice,
+// so the content is read from the reader first.
+func OpenInput(in Input) (Document, error) {
+	if err := checkFormat(in.Name); err != nil {
+		return nil, err
+	}
+	if isBundle(in.Name) {
+		return readImageBundle(in.Name, in.Data, in.Size)
+	}
+	if in.Size <= 0 {
+		return nil, fmt.Errorf("file %s is empty", in.Name)
+	}
+	data := make([]byte, in.Size)
+	if _, err := io.ReadFull(io.NewSectionReader(in.Data, 0, in.Size), data); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", in.Name, err)
+	}
+	if isRasterImage(in.Name) {
+		return openRasterImage(in.Name, data)
+	}
+	return openMemory(data, in.Password)
+}
+
+// MatchPages pairs the pages of two archives of page images by file name instead of by position.
+// It does nothing for other documents.
+func MatchPages(doc1, doc2 Document) {
+	if b1, ok := doc1.(*imageBundle); ok {
+		if b2, ok := doc2.(*imageBundle); ok {
+			matchBundlePages(b1, b2)
+		}
+	}
+}
+
+// RenderPage rasterizes a page at the given resolution. Documents that are already raster images are returned as they are.
+func RenderPage(doc Document, page int, dpi float64) (image.Image, error) {
+	defer lockDocument(doc)()
+	if r, ok := doc.(dpiRenderer); ok {
+		return r.ImageDPI(page, dpi)
+	}
+	return doc.Image(page)
+}
+
+// pageImage extracts the image of a page, or creates a white image if the page does not exist in the document.
+// It also reports whether the page is missing.
+func pageImage(doc Document, page int, dpi float64) (image.Image, bool, error) {
+	if page < 0 || page >= doc.NumPage() {
+		return image.NewRGBA(image.Rect(0, 0, 595, 842)), true, nil // dimensions of an A4 page in points
+	}
+	img, err := RenderPage(doc, page, dpi)
+	if err == ErrPageMissing {
+		return image.NewRGBA(image.Rect(0, 0, 595, 842)), true, nil
+	}
+	return img, false, err
+}
@@ -0,0 +1,383 @@
+package pdfdiff
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// equationScale is how much finer than the pages the equations are rendered to be compared
+const equationScale = 2.0
+
+// equationTolerance is how far, in inches, the strokes of a re-typeset equation may move and still match, once
+// the equations are aligned with each other
+const equationTolerance = 0.01
+
+// An equation is unchanged when at most equationMismatch of its ink finds no match in the other document, and the
+// unmatched ink is only specks no larger than a stroke moved slightly further than the tolerance
+const equationMismatch = 0.005
+
+// equationDensity is the smallest share of math symbols, scripts and fraction bars among the glyphs of an equation
+const equationDensity = 0.2
+
+// minEquationGlyphs is the number of glyphs of the smallest equation, so a lone symbol is compared as usual
+const minEquationGlyphs = 3
+
+// mathSymbols are the characters that mark a cluster of glyphs as an equation, besides the Greek and math alphabets
+const mathSymbols = "=+−×÷±∑∏∫∮√∂∞≤≥≠≈≡∝∈∉⊂⊃⊆⊇∪∩∧∨¬→←↔⇒⇐⇔∀∃∇′^_|"
+
+// Equation is an equation found on a page, compared at a higher resolution with a relaxed tolerance on the position
+// of its strokes, as typesetting the same formula again moves its glyphs by a fraction of a point
+type Equation struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Changed reports that the equation differs, or exists in one document only
+	Changed bool `json:"changed"`
+}
+
+// svgGlyph is a glyph drawn by an SVG page, or a thin rule such as a fraction bar, in points from the top left corner
+type svgGlyph struct {
+	box    [4]float64
+	origin [2]float64 // origin of a glyph, on its baseline
+	size   float64    // font size in points, 0 for rules
+	text   string
+}
+
+// svgGlyphs returns the glyphs and the thin horizontal rules drawn by an SVG page written by MuPDF with its text as paths
+func svgGlyphs(svg string) []svgGlyph {
+	defs := make(map[string][4]float64)
+	var glyphs []svgGlyph
+	walkSVG(svg, func(name string, attrs map[string]string, m svgMatrix, hidden bool) {
+		switch {
+		case name == "path" && hidden && attrs["id"] != "":
+			if box, ok := pathBox(attrs["d"]); ok {
+				defs[attrs["id"]] = box
+			}
+		case name == "use" && !hidden:
+			id := strings.TrimPrefix(attrs["href"], "#")
+			box, ok := defs[id]
+			if _, text := attrs["data-text"]; ok && (text || strings.HasPrefix(id, "font_")) {
+				glyphs = append(glyphs, svgGlyph{box: m.apply(box), origin: [2]float64{m[4], m[5]}, size: math.Hypot(m[2], m[3]), text: attrs["data-text"]})
+			}
+		case name == "path" && !hidden:
+			box, ok := pathBox(attrs["d"])
+			if !ok {
+				return
+			}
+			if isStroked, width := stroked(attrs); isStroked {
+				box = [4]float64{box[0], box[1] - width/2, box[2], box[3] + width/2}
+			}
+			if box = m.apply(box); box[3]-box[1] <= 1.5 && box[2]-box[0] >= 3 {
+				glyphs = append(glyphs, svgGlyph{box: box})
+			}
+		}
+	})
+	return glyphs
+}
+
+// findEquations returns the areas of the equations drawn by an SVG page, in points from the top left corner: the
+// clusters of glyphs, with their scripts and fraction bars, dense in math symbols
+func findEquations(svg string) [][4]float64 {
+	glyphs := svgGlyphs(svg)
+	// Sort by top, so only the glyphs close enough vertically are compared with each other
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i].box[1] < glyphs[j].box[1] })
+	parent := make([]int, len(glyphs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	// Glyphs join when they are as close as the letters of a word, and rules join the glyphs right above or below them
+	grow := func(g svgGlyph) [4]float64 {
+		if g.size == 0 {
+			return [4]float64{g.box[0], g.box[1] - 3, g.box[2], g.box[3] + 3}
+		}
+		return [4]float64{g.box[0] - 0.25*g.size, g.box[1] - 0.05*g.size, g.box[2] + 0.25*g.size, g.box[3] + 0.05*g.size}
+	}
+	for i := range glyphs {
+		gi := grow(glyphs[i])
+		for j := i + 1; j < len(glyphs) && glyphs[j].box[1] <= gi[3]+3; j++ {
+			if glyphs[i].size == 0 && glyphs[j].size == 0 {
+				continue
+			}
+			if overlap(gi, grow(glyphs[j])) > 0 {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	clusters := make(map[int][]svgGlyph)
+	for i, g := range glyphs {
+		clusters[find(i)] = append(clusters[find(i)], g)
+	}
+	var equations [][4]float64
+	for _, cluster := range clusters {
+		if box, ok := equationBox(cluster); ok {
+			equations = append(equations, box)
+		}
+	}
+	return equations
+}
+
+// equationBox returns the area of a cluster of glyphs, and whether it is dense enough in math to be an equation
+func equationBox(cluster []svgGlyph) ([4]float64, bool) {
+	var sizes []float64
+	var rules []svgGlyph
+	for _, g := range cluster {
+		if g.size > 0 {
+			sizes = append(sizes, g.size)
+		} else {
+			rules = append(rules, g)
+		}
+	}
+	if len(sizes) < minEquationGlyphs {
+		return [4]float64{}, false
+	}
+	sort.Float64s(sizes)
+	body := sizes[len(sizes)/2]
+
+	marks := 0
+	var points [][2]float64
+	for _, g := range cluster {
+		points = append(points, [2]float64{g.box[0], g.box[1]}, [2]float64{g.box[2], g.box[3]})
+		if g.size == 0 {
+			continue
+		}
+		// Scripts are set smaller than the body of the equation
+		if g.size < 0.85*body || isMathText(g.text) {
+			marks++
+		}
+	}
+	// A fraction bar has glyphs above and below it
+	for _, r := range rules {
+		above, below := false, false
+		for _, g := range cluster {
+			if g.size == 0 || g.box[2] < r.box[0] || g.box[0] > r.box[2] {
+				continue
+			}
+			above = above || g.box[3] <= r.box[1]+0.5
+			below = below || g.box[1] >= r.box[3]-0.5
+		}
+		if above && below {
+			marks += 2
+		}
+	}
+	return pointsBox(points), float64(marks) >= equationDensity*float64(len(sizes))
+}
+
+// isMathText reports whether the text of a glyph is a math symbol, a Greek letter or a letter of a math alphabet
+func isMathText(text string) bool {
+	for _, r := range text {
+		if strings.ContainsRune(mathSymbols, r) || (r >= 0x391 && r <= 0x3C9) || (r >= 0x1D400 && r <= 0x1D7FF) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageEquations are the equations of the pages compared together, in pixels of the pages, and the areas of the
+// ones found unchanged
+type pageEquations struct {
+	equations []Equation
+	unchanged []image.Rectangle
+}
+
+// compareEquations finds the equations of both pages, from their SVG renderings, and compares the ones found in
+// both documents at equationScale times the resolution of the comparison
+func compareEquations(svg1, svg2 string, render func(doc int, dpi float64) (image.Image, error), dpi float64) (pageEquations, error) {
+	var result pageEquations
+	eq1, eq2 := findEquations(svg1), findEquations(svg2)
+	// Equations of both documents in the same place are compared together, as one area
+	type area struct {
+		box      [4]float64
+		in1, in2 bool
+	}
+	var areas []area
+	add := func(box [4]float64, doc int) {
+		a := area{box: box, in1: doc == 1, in2: doc == 2}
+		for i := 0; i < len(areas); i++ {
+			if overlap(areas[i].box, a.box) > 0 {
+				b := areas[i].box
+				a.box = [4]float64{math.Min(a.box[0], b[0]), math.Min(a.box[1], b[1]), math.Max(a.box[2], b[2]), math.Max(a.box[3], b[3])}
+				a.in1, a.in2 = a.in1 || areas[i].in1, a.in2 || areas[i].in2
+				areas = append(areas[:i], areas[i+1:]...)
+				i = -1
+			}
+		}
+		areas = append(areas, a)
+	}
+	for _, box := range eq1 {
+		add(box, 1)
+	}
+	for _, box := range eq2 {
+		add(box, 2)
+	}
+	sort.Slice(areas, func(i, j int) bool { return readingOrder(areas[i].box, areas[j].box) })
+
+	var fine [2]image.Image
+	scale := dpi / 72
+	for _, a := range areas {
+		r := image.Rect(int(math.Floor(a.box[0]*scale))-2, int(math.Floor(a.box[1]*scale))-2,
+			int(math.Ceil(a.box[2]*scale))+2, int(math.Ceil(a.box[3]*scale))+2)
+		eq := Equation{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy(), Changed: true}
+		if a.in1 && a.in2 {
+			for n := range fine {
+				if fine[n] != nil {
+					continue
+				}
+				var err error
+				if fine[n], err = render(n+1, dpi*equationScale); err != nil {
+					return result, err
+				}
+			}
+			sr := image.Rect(r.Min.X*int(equationScale), r.Min.Y*int(equationScale), r.Max.X*int(equationScale), r.Max.Y*int(equationScale))
+			if sameInk(fine[0], fine[1], sr, int(math.Ceil(equationTolerance*dpi*equationScale))) {
+				eq.Changed = false
+				result.unchanged = append(result.unchanged, r)
+			}
+		}
+		result.equations = append(result.equations, eq)
+	}
+	return result, nil
+}
+
+// inkMask returns the dark pixels of an area of a page
+func inkMask(img image.Image, r image.Rectangle) []bool {
+	mask := make([]bool, r.Dx()*r.Dy())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if p := image.Pt(x, y); p.In(img.Bounds()) && brightness(img.At(x, y)) < 160 {
+				mask[(y-r.Min.Y)*r.Dx()+x-r.Min.X] = true
+			}
+		}
+	}
+	return mask
+}
+
+// inkBox returns the bounding box of the ink of a mask
+func inkBox(mask []bool, w, h int) image.Rectangle {
+	box := image.Rectangle{}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask[y*w+x] {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// dilateMask grows the ink of a mask by radius pixels in every direction
+func dilateMask(mask []bool, w, h, radius int) []bool {
+	rows := make([]bool, len(mask))
+	for y := 0; y < h; y++ {
+		last := -radius - 1 // last ink pixel seen on the row
+		for x := 0; x < w+radius; x++ {
+			if x < w && mask[y*w+x] {
+				last = x
+			}
+			if x-radius >= 0 && x-last <= 2*radius {
+				rows[y*w+x-radius] = true
+			}
+		}
+	}
+	out := make([]bool, len(mask))
+	for x := 0; x < w; x++ {
+		last := -radius - 1
+		for y := 0; y < h+radius; y++ {
+			if y < h && rows[y*w+x] {
+				last = y
+			}
+			if y-radius >= 0 && y-last <= 2*radius {
+				out[(y-radius)*w+x] = true
+			}
+		}
+	}
+	return out
+}
+
+// sameInk reports whether the ink of an area of two pages is the same once aligned, within radius pixels
+func sameInk(img1, img2 image.Image, r image.Rectangle, radius int) bool {
+	w, h := r.Dx(), r.Dy()
+	masks := [2][]bool{inkMask(img1, r), inkMask(img2, r)}
+	box1, box2 := inkBox(masks[0], w, h), inkBox(masks[1], w, h)
+	if box1.Empty() || box2.Empty() {
+		return box1.Empty() && box2.Empty()
+	}
+	// Align the equations on the top left corner of their ink, wherever the layout moved them
+	dx, dy := box2.Min.X-box1.Min.X, box2.Min.Y-box1.Min.Y
+	dilated := [2][]bool{dilateMask(masks[0], w, h, radius), dilateMask(masks[1], w, h, radius)}
+	for n, sign := range [2]int{1, -1} {
+		mask, other := masks[n], dilated[1-n]
+		misses := make([]bool, len(mask))
+		ink, missed := 0, 0
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if !mask[y*w+x] {
+					continue
+				}
+				ink++
+				ox, oy := x+sign*dx, y+sign*dy
+				if ox < 0 || oy < 0 || ox >= w || oy >= h || !other[oy*w+ox] {
+					misses[y*w+x] = true
+					missed++
+				}
+			}
+		}
+		if float64(missed) > equationMismatch*float64(ink) || largestComponent(misses, w, h) > 4*radius*radius {
+			return false
+		}
+	}
+	return true
+}
+
+// largestComponent returns the number of pixels of the largest group of connected pixels of a mask
+func largestComponent(mask []bool, w, h int) int {
+	seen := make([]bool, len(mask))
+	largest := 0
+	var stack []int
+	for i, set := range mask {
+		if !set || seen[i] {
+			continue
+		}
+		size := 0
+		stack = append(stack[:0], i)
+		seen[i] = true
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			x, y := p%w, p/w
+			for _, q := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if q[0] >= 0 && q[1] >= 0 && q[0] < w && q[1] < h && mask[q[1]*w+q[0]] && !seen[q[1]*w+q[0]] {
+					seen[q[1]*w+q[0]] = true
+					stack = append(stack, q[1]*w+q[0])
+				}
+			}
+		}
+		if size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+// equationRenderer returns the function that renders the pages compared together at another resolution
+func equationRenderer(opts *Options, doc1 Document, page1 int, doc2 Document, page2 int) func(doc int, dpi float64) (image.Image, error) {
+	return func(doc int, dpi float64) (image.Image, error) {
+		d, page := doc1, page1
+		if doc == 2 {
+			d, page = doc2, page2
+		}
+		img, _, err := opts.Pipeline.render(d, page, dpi)
+		return img, err
+	}
+}
@@ -0,0 +1,38 @@
+package pdfdiff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOptions is wrapped by the errors returned when the options do not fit the compared documents
+var ErrInvalidOptions = errors.New("invalid options")
+
+// InputError is returned when a document cannot be opened, because it is missing, unsupported or damaged
+type InputError struct {
+	File string
+	Err  error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("cannot open %s: %v", e.File, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
+
+// PageError is returned when a page cannot be rendered or its images cannot be written
+type PageError struct {
+	// Page is the index of the page in the output
+	Page int
+	Err  error
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("page %d: %v", e.Page, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
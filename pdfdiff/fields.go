@@ -0,0 +1,254 @@
+package pdfdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The types of the values of the template fields
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldAmount = "amount"
+	FieldDate   = "date"
+)
+
+// dateLayouts are the layouts tried for the date fields without a Format
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "02.01.2006", "2 January 2006", "2 Jan 2006", "January 2, 2006", "Jan 2, 2006"}
+
+// TemplateField is a named area of a form or invoice, such as the invoice number or the total, whose value is read
+// from the text of both documents and compared instead of its pixels. It uses the coordinates of IgnoreRegion.
+type TemplateField struct {
+	Name string `json:"name" yaml:"name"`
+	// Page is the output page, counted from 0 as in the report
+	Page int `json:"page" yaml:"page"`
+	// AllPages reads the field on every page instead of Page
+	AllPages bool `json:"all_pages,omitempty" yaml:"all_pages"`
+	// Rect is the area as left, bottom, right and top, in PDF points from the bottom left corner of the page
+	Rect [4]float64 `json:"rect" yaml:"rect"`
+	// Type is how the values are compared: text (Default), number, amount or date
+	Type string `json:"type,omitempty" yaml:"type"`
+	// Pattern is a regular expression picking the value out of the text of the area, its first group if it has one
+	Pattern string `json:"pattern,omitempty" yaml:"pattern"`
+	// Format is the Go layout of the dates, e.g. 01/02/2006, instead of trying the common ones
+	Format string `json:"format,omitempty" yaml:"format"`
+}
+
+// Template is a template file: the fields of the documents, and the checks of their values, see ReadTemplate
+type Template struct {
+	Fields []TemplateField `json:"fields" yaml:"fields"`
+	Checks []FieldCheck    `json:"checks,omitempty" yaml:"checks"`
+}
+
+// FieldValue is the value of a template field read on a page of both documents
+type FieldValue struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Value1 and Value2 are the text read in the first and second documents
+	Value1 string `json:"value1"`
+	Value2 string `json:"value2"`
+	// Changed is set when the values differ once normalized for their type, e.g. 1,200.00 and 1200 are the same amount
+	Changed bool `json:"changed"`
+	// Error is set when a value cannot be read as the type of the field, which is then compared as text
+	Error string `json:"error,omitempty"`
+}
+
+// ReadTemplate reads a template file, as YAML if its extension is .yaml or .yml and as JSON otherwise
+func ReadTemplate(path string) (Template, error) {
+	var t Template
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &t)
+	default:
+		err = json.Unmarshal(data, &t)
+	}
+	if err != nil {
+		return t, fmt.Errorf("%s: %v", path, err)
+	}
+	return t, nil
+}
+
+// checkField verifies the definition of a template field
+func checkField(f TemplateField) error {
+	if f.Name == "" {
+		return fmt.Errorf("every template field needs a name")
+	}
+	switch f.Type {
+	case "", FieldText, FieldNumber, FieldAmount, FieldDate:
+	default:
+		return fmt.Errorf("the template field %s has the unknown type %q, use text, number, amount or date", f.Name, f.Type)
+	}
+	if f.Rect[0] >= f.Rect[2] || f.Rect[1] >= f.Rect[3] {
+		return fmt.Errorf("the area of the template field %s should be given as left, bottom, right and top", f.Name)
+	}
+	if _, err := regexp.Compile(f.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern of the template field %s: %v", f.Name, err)
+	}
+	return nil
+}
+
+// fieldRect returns the area of a field on a page, in pixels, and whether the field is on the page
+func fieldRect(f TemplateField, page, height int, dpi float64) (image.Rectangle, bool) {
+	rects := ignoreRects([]IgnoreRegion{{Page: f.Page, AllPages: f.AllPages, Rect: f.Rect}}, page, height, dpi)
+	if len(rects) == 0 {
+		return image.Rectangle{}, false
+	}
+	return rects[0], true
+}
+
+// svgFieldText returns the text drawn by an SVG page inside an area, in pixels of the page rendered at dpi:
+// the characters whose glyph has its center in the area, line by line
+func svgFieldText(svg string, r image.Rectangle, dpi float64) string {
+	scale := dpi / 72
+	var lines []string
+	for _, line := range svgTextLines(svg) {
+		var text []rune
+		i := 0
+		for _, c := range line.text {
+			box := line.boxes[i]
+			i++
+			center := image.Pt(int(math.Round((box[0]+box[2])/2*scale)), int(math.Round((box[1]+box[3])/2*scale)))
+			if center.In(r) {
+				text = append(text, c)
+			}
+		}
+		if len(text) > 0 {
+			lines = append(lines, string(text))
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// ocrFieldText returns the words read by the OCR whose box has its center inside an area
+func ocrFieldText(words []ocrWord, r image.Rectangle) string {
+	var text []string
+	for _, w := range words {
+		if center := w.box.Min.Add(w.box.Max).Div(2); center.In(r) {
+			text = append(text, w.text)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// compareFields reads the fields of a page in both documents with the text function, which returns the text of an
+// area of a document (1 or 2), and compares their values
+func compareFields(fields []TemplateField, page, height int, dpi float64, text func(doc int, r image.Rectangle) string) []FieldValue {
+	var values []FieldValue
+	for _, f := range fields {
+		r, ok := fieldRect(f, page, height, dpi)
+		if !ok {
+			continue
+		}
+		v := FieldValue{Name: f.Name, Type: f.Type, Value1: fieldValue(f, text(1, r)), Value2: fieldValue(f, text(2, r))}
+		if v.Type == "" {
+			v.Type = FieldText
+		}
+		n1, err1 := normalizeField(f, v.Value1)
+		n2, err2 := normalizeField(f, v.Value2)
+		switch {
+		case err1 != nil:
+			v.Error = fmt.Sprintf("document 1: %v", err1)
+		case err2 != nil:
+			v.Error = fmt.Sprintf("document 2: %v", err2)
+		}
+		if v.Error != "" {
+			n1, n2 = strings.Join(strings.Fields(v.Value1), " "), strings.Join(strings.Fields(v.Value2), " ")
+		}
+		v.Changed = n1 != n2
+		values = append(values, v)
+	}
+	return values
+}
+
+// fieldValue picks the value of a field out of the text of its area with its Pattern
+func fieldValue(f TemplateField, text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if f.Pattern == "" {
+		return text
+	}
+	m := regexp.MustCompile(f.Pattern).FindStringSubmatch(text)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return strings.TrimSpace(m[1])
+	}
+	return m[0]
+}
+
+// numberPattern finds a number in the text of a field, with its thousands separators: 1,234.50 or 1.234,50
+var numberPattern = regexp.MustCompile(`-?\d[\d.,' ]*`)
+
+// normalizeField returns the value of a field in a form that is equal for equal values of its type
+func normalizeField(f TemplateField, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch f.Type {
+	case FieldNumber, FieldAmount:
+		n, err := parseNumber(value)
+		if err != nil {
+			return "", err
+		}
+		if f.Type == FieldAmount {
+			// Amounts are compared to the cent, whatever the currency symbol
+			return strconv.FormatFloat(n, 'f', 2, 64), nil
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case FieldDate:
+		layouts := dateLayouts
+		if f.Format != "" {
+			layouts = []string{f.Format}
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.Format("2006-01-02"), nil
+			}
+		}
+		return "", fmt.Errorf("%q is not a date", value)
+	}
+	return value, nil
+}
+
+// parseNumber reads the number of a value such as "$ 1,234.50", "1.234,50 €" or "(12)". With both separators, the
+// last one is the decimal separator; a single separator followed by three digits separates the thousands.
+func parseNumber(value string) (float64, error) {
+	digits := strings.TrimRight(numberPattern.FindString(value), ".,' ")
+	if digits == "" {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	digits = strings.NewReplacer("'", "", " ", "").Replace(digits)
+	if i := strings.LastIndexAny(digits, ".,"); i >= 0 {
+		integer, fraction := digits[:i], digits[i+1:]
+		thousands := !strings.ContainsAny(integer, ".,") && len(fraction) == 3 || strings.ContainsRune(integer, rune(digits[i]))
+		integer = strings.NewReplacer(".", "", ",", "").Replace(integer)
+		if thousands {
+			digits = integer + fraction
+		} else {
+			digits = integer + "." + fraction
+		}
+	}
+	n, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", value)
+	}
+	// Accounting formats write the negative amounts in parentheses
+	if v := strings.TrimSpace(value); strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		n = -n
+	}
+	return n, nil
+}
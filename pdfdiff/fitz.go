@@ -0,0 +1,31 @@
+//go:build !js
+
+package pdfdiff
+
+import (
+	"errors"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// openFitzFile opens a document file with the fitz backend
+func openFitzFile(filename string) (Document, error) {
+	return openFitz(fitz.New(filename))
+}
+
+// openFitzMemory opens a document held in a byte slice with the fitz backend
+func openFitzMemory(data []byte) (Document, error) {
+	return openFitz(fitz.NewFromMemory(data))
+}
+
+// openFitz returns a document opened by the fitz backend, closing the ones that need a password
+func openFitz(doc *fitz.Document, err error) (Document, error) {
+	if errors.Is(err, fitz.ErrNeedsPassword) {
+		doc.Close()
+		return nil, ErrPassword
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
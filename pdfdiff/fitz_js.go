@@ -0,0 +1,21 @@
+package pdfdiff
+
+import "fmt"
+
+// MuPDF cannot be compiled to WebAssembly, so the WebAssembly build only compares page images and archives of page
+// images, rendered beforehand, e.g. by pdf.js in the browser.
+
+// errNoRenderer is returned for the documents that need the fitz backend
+var errNoRenderer = fmt.Errorf("this build cannot render PDF, EPUB, XPS and FB2 documents: compare images of their pages instead")
+
+func openFitzFile(filename string) (Document, error) {
+	return nil, fmt.Errorf("%s: %w", filename, errNoRenderer)
+}
+
+func openFitzMemory(data []byte) (Document, error) {
+	return nil, errNoRenderer
+}
+
+func layoutFitz(doc Document, layout ReflowLayout) error {
+	return nil
+}
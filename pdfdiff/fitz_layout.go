@@ -0,0 +1,66 @@
+//go:build !js
+
+package pdfdiff
+
+/*
+#include <setjmp.h>
+
+// go-fitz does not expose the layout of MuPDF, so its functions are declared here as in the headers of MuPDF, whose
+// library go-fitz links. The exceptions of MuPDF are caught like fz_try and fz_catch do.
+
+typedef struct fz_context fz_context;
+typedef struct fz_document fz_document;
+
+#if defined(__APPLE__) || (defined(__unix) && !defined(__EMSCRIPTEN__))
+typedef sigjmp_buf fz_jmp_buf;
+#define fz_setjmp(buf) sigsetjmp(buf, 0)
+#else
+typedef jmp_buf fz_jmp_buf;
+#define fz_setjmp(buf) setjmp(buf)
+#endif
+
+fz_jmp_buf *fz_push_try(fz_context *ctx);
+int fz_do_try(fz_context *ctx);
+int fz_do_catch(fz_context *ctx);
+const char *fz_caught_message(fz_context *ctx);
+int fz_is_document_reflowable(fz_context *ctx, fz_document *doc);
+void fz_layout_document(fz_context *ctx, fz_document *doc, float w, float h, float em);
+
+static const char *layout_document(fz_context *ctx, fz_document *doc, float w, float h, float em) {
+	const char *failed = NULL;
+	if (!fz_setjmp(*fz_push_try(ctx))) if (fz_do_try(ctx)) do {
+		if (fz_is_document_reflowable(ctx, doc))
+			fz_layout_document(ctx, doc, w, h, em);
+	} while (0);
+	if (fz_do_catch(ctx))
+		failed = fz_caught_message(ctx);
+	return failed;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// layoutFitz lays out a reflowable document opened by the fitz backend, with the MuPDF context and document of its
+// unexported fields. It is called before the pages of the document are loaded.
+func layoutFitz(doc Document, layout ReflowLayout) error {
+	f, ok := doc.(*fitz.Document)
+	if !ok {
+		return nil
+	}
+	v := reflect.ValueOf(f).Elem()
+	ctx, d := v.FieldByName("ctx"), v.FieldByName("doc")
+	if ctx.Kind() != reflect.Ptr || d.Kind() != reflect.Ptr {
+		return errors.New("this version of go-fitz cannot reflow documents")
+	}
+	if failed := C.layout_document((*C.fz_context)(ctx.UnsafePointer()), (*C.fz_document)(d.UnsafePointer()),
+		C.float(layout.Width), C.float(layout.Height), C.float(layout.FontSize)); failed != nil {
+		return errors.New(C.GoString(failed))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package pdfdiff
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// subsetPrefix is the tag of a subset font name, e.g. ABCDEF+Helvetica
+var subsetPrefix = regexp.MustCompile(`^[A-Z]{6}\+`)
+
+// FontResult lists the fonts a page of a PDF uses without embedding them, which the renderer replaces with fonts of
+// its own or of the system, so the page may render differently on another machine
+type FontResult struct {
+	// Document is the document (1 or 2) of the page
+	Document int `json:"document"`
+	// Page is the page of the document, counted from 0
+	Page int `json:"page"`
+	// Fonts are the names of the fonts that are not embedded
+	Fonts []string `json:"fonts"`
+}
+
+// CheckFonts returns the pages of two PDF documents that use fonts that are not embedded, in document and page
+// order. Documents that are not PDFs are skipped.
+func CheckFonts(file1, file2 string) ([]FontResult, error) {
+	var results []FontResult
+	for n, file := range []string{file1, file2} {
+		if strings.ToLower(filepath.Ext(file)) != ".pdf" {
+			continue
+		}
+		f, err := readPDF(file)
+		if err != nil {
+			return nil, &InputError{File: file, Err: err}
+		}
+		for page, dict := range f.pages() {
+			missing := make(map[string]bool)
+			f.missingFonts(f.pageResources(dict), missing, make(map[pdfRef]bool))
+			if len(missing) == 0 {
+				continue
+			}
+			result := FontResult{Document: n + 1, Page: page}
+			for name := range missing {
+				result.Fonts = append(result.Fonts, name)
+			}
+			sort.Strings(result.Fonts)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// pageResources returns the resources of a page, which it may inherit from the nodes of the page tree above it
+func (f *pdfFile) pageResources(page pdfDict) pdfDict {
+	for node, depth := page, 0; node != nil && depth < 64; node, depth = f.dict(node["Parent"]), depth+1 {
+		if resources := f.dict(node["Resources"]); resources != nil {
+			return resources
+		}
+	}
+	return nil
+}
+
+// missingFonts adds the fonts that are not embedded of a resource dictionary, and of the forms it draws, to missing
+func (f *pdfFile) missingFonts(resources pdfDict, missing map[string]bool, seen map[pdfRef]bool) {
+	if resources == nil {
+		return
+	}
+	for _, obj := range f.dict(resources["Font"]) {
+		if ref, ok := obj.(pdfRef); ok {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+		}
+		font := f.dict(obj)
+		if font == nil || f.fontEmbedded(font) {
+			continue
+		}
+		name, _ := f.resolve(font["BaseFont"]).(pdfName)
+		if name == "" {
+			name = "unnamed font"
+		}
+		missing[subsetPrefix.ReplaceAllString(string(name), "")] = true
+	}
+	for _, obj := range f.dict(resources["XObject"]) {
+		if ref, ok := obj.(pdfRef); ok {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+		}
+		if form := f.dict(obj); form["Subtype"] == pdfName("Form") {
+			f.missingFonts(f.dict(form["Resources"]), missing, seen)
+		}
+	}
+}
+
+// fontEmbedded reports whether a font dictionary embeds the program of its font. Type 3 fonts draw their glyphs
+// with the content of the file, and composite fonts embed the program of their descendant font.
+func (f *pdfFile) fontEmbedded(font pdfDict) bool {
+	switch f.resolve(font["Subtype"]) {
+	case pdfName("Type3"):
+		return true
+	case pdfName("Type0"):
+		descendants := f.array(font["DescendantFonts"])
+		if len(descendants) == 0 {
+			return false
+		}
+		font = f.dict(descendants[0])
+	}
+	descriptor := f.dict(font["FontDescriptor"])
+	return descriptor["FontFile"] != nil || descriptor["FontFile2"] != nil || descriptor["FontFile3"] != nil
+}
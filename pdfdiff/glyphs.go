@@ -0,0 +1,281 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// The kinds of changes of a glyph found with Glyphs
+const (
+	GlyphShape    = "shape"
+	GlyphPosition = "position"
+	GlyphSpacing  = "spacing"
+)
+
+const (
+	// glyphTolerance is the smallest change, in points, of the baseline, the advance or the outline of a glyph that is
+	// reported: the coordinates of the text layer are exact, so the same typesetting gives the same ones
+	glyphTolerance = 0.01
+	// glyphRasterTolerance is the share of the inked pixels of a glyph rendered differently above which its shape
+	// changed, e.g. with new hinting
+	glyphRasterTolerance = 0.05
+	// maxGlyphChanges bounds the changes listed for a page, for a document set again in another font
+	maxGlyphChanges = 200
+)
+
+// GlyphStats are the statistics of the glyphs of both pages, or both documents
+type GlyphStats struct {
+	// Matched are the glyphs found in the text of both pages, Inserted and Deleted the ones only found in the
+	// second and first pages: the content changes, apart from the typesetting ones
+	Matched  int `json:"matched"`
+	Inserted int `json:"inserted"`
+	Deleted  int `json:"deleted"`
+	// Shape, Position and Spacing count the matched glyphs whose shape changed, that moved off the baseline of their
+	// line, and whose advance to the next glyph changed, e.g. with new kerning
+	Shape    int `json:"shape"`
+	Position int `json:"position"`
+	Spacing  int `json:"spacing"`
+	// MeanRaster and MaxRaster are the mean and largest shares of the pixels of the glyphs whose shape changed that
+	// are rendered differently
+	MeanRaster float64 `json:"mean_raster"`
+	MaxRaster  float64 `json:"max_raster"`
+	// MeanShift and MaxShift are the mean and largest shifts off the baseline of the glyphs that moved, in points
+	MeanShift float64 `json:"mean_shift"`
+	MaxShift  float64 `json:"max_shift"`
+	// MeanSpacing and MaxSpacing are the mean and largest changes of the advance of the glyphs respaced, in points
+	MeanSpacing float64 `json:"mean_spacing"`
+	MaxSpacing  float64 `json:"max_spacing"`
+	// Characters counts the changed glyphs by character, the ones a font or hinting regression affects
+	Characters map[string]int `json:"characters,omitempty"`
+}
+
+// GlyphDiff are the glyph changes of a page, found with Glyphs
+type GlyphDiff struct {
+	GlyphStats
+	// Changes are the changed glyphs in reading order, at most 200 of them
+	Changes []GlyphChange `json:"changes,omitempty"`
+}
+
+// GlyphChange is a glyph of both pages rendered differently
+type GlyphChange struct {
+	Text string `json:"text"`
+	// X, Y, Width and Height are the box of the glyph on the second page, in pixels
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Kinds are the kinds of the changes: shape, position and spacing
+	Kinds []string `json:"kinds"`
+	// Raster is the share of the inked pixels of the glyph rendered differently, once the glyphs are aligned, and
+	// DWidth and DHeight the changes of the size of its outline, in points
+	Raster  float64 `json:"raster"`
+	DWidth  float64 `json:"dwidth,omitempty"`
+	DHeight float64 `json:"dheight,omitempty"`
+	// Shift is how far the glyph moved off the baseline of its line, in points, downwards when positive
+	Shift float64 `json:"shift,omitempty"`
+	// Spacing is the change of the advance to the next glyph of the line, in points, negative when they got closer
+	Spacing float64 `json:"spacing,omitempty"`
+}
+
+// lineGlyph is a glyph with ink of the text of a page, and the line it is on
+type lineGlyph struct {
+	svgGlyph
+	line int
+}
+
+// inkedGlyphs returns the glyphs of the lines that draw something, in reading order: the spaces are left out
+func inkedGlyphs(lines [][]svgGlyph) []lineGlyph {
+	var glyphs []lineGlyph
+	for l, line := range lines {
+		for _, g := range line {
+			if strings.TrimSpace(g.text) != "" && g.box[2] > g.box[0] && g.box[3] > g.box[1] {
+				glyphs = append(glyphs, lineGlyph{svgGlyph: g, line: l})
+			}
+		}
+	}
+	return glyphs
+}
+
+// compareGlyphs pairs the glyphs of the text of both SVG pages, in reading order and by their characters, and
+// compares the glyphs of every pair: the outline and the pixels of the pages rendered at dpi, the baseline within
+// the line, and the advance to the next glyph when it is paired too. The glyphs inserted and deleted are the changes
+// of the content, and only counted, so the typesetting changes stand apart.
+func compareGlyphs(svg1, svg2 string, img1, img2 image.Image, dpi float64) *GlyphDiff {
+	glyphs1, glyphs2 := inkedGlyphs(glyphLines(svg1)), inkedGlyphs(glyphLines(svg2))
+	a, b := make([]string, len(glyphs1)), make([]string, len(glyphs2))
+	for i, g := range glyphs1 {
+		a[i] = g.text
+	}
+	for j, g := range glyphs2 {
+		b[j] = g.text
+	}
+	edits := wordEdits(a, b)
+	pairs := make(map[int]int)
+	for _, e := range edits {
+		if e.op == "equal" {
+			pairs[e.i] = e.j
+		}
+	}
+	// The first glyph of every line, whose baseline the others are measured from
+	lineStart := make(map[int]int)
+	for i := len(glyphs1) - 1; i >= 0; i-- {
+		lineStart[glyphs1[i].line] = i
+	}
+
+	scale := dpi / 72
+	diff := &GlyphDiff{}
+	stats := &diff.GlyphStats
+	for _, e := range edits {
+		switch e.op {
+		case "delete":
+			stats.Deleted++
+			continue
+		case "insert":
+			stats.Inserted++
+			continue
+		}
+		stats.Matched++
+		g1, g2 := glyphs1[e.i], glyphs2[e.j]
+		r1, r2 := glyphRect(g1.box, scale), glyphRect(g2.box, scale)
+		c := GlyphChange{
+			Text: g2.text, X: r2.Min.X, Y: r2.Min.Y, Width: r2.Dx(), Height: r2.Dy(),
+			Raster:  glyphRaster(img1, img2, r1, r2),
+			DWidth:  roundPoints((g2.box[2] - g2.box[0]) - (g1.box[2] - g1.box[0])),
+			DHeight: roundPoints((g2.box[3] - g2.box[1]) - (g1.box[3] - g1.box[1])),
+		}
+		if s, ok := pairs[lineStart[g1.line]]; ok && glyphs2[s].line == g2.line {
+			c.Shift = roundPoints((g2.origin[1] - glyphs2[s].origin[1]) - (g1.origin[1] - glyphs1[lineStart[g1.line]].origin[1]))
+		}
+		if next, ok := pairs[e.i+1]; ok && next == e.j+1 && glyphs1[e.i+1].line == g1.line && glyphs2[next].line == g2.line {
+			c.Spacing = roundPoints((glyphs2[next].origin[0] - g2.origin[0]) - (glyphs1[e.i+1].origin[0] - g1.origin[0]))
+		}
+
+		if c.Raster > glyphRasterTolerance || math.Abs(c.DWidth) > glyphTolerance || math.Abs(c.DHeight) > glyphTolerance {
+			c.Kinds = append(c.Kinds, GlyphShape)
+			stats.Shape++
+			stats.MeanRaster += c.Raster
+			stats.MaxRaster = math.Max(stats.MaxRaster, c.Raster)
+		}
+		if math.Abs(c.Shift) > glyphTolerance {
+			c.Kinds = append(c.Kinds, GlyphPosition)
+			stats.Position++
+			stats.MeanShift += math.Abs(c.Shift)
+			stats.MaxShift = math.Max(stats.MaxShift, math.Abs(c.Shift))
+		}
+		if math.Abs(c.Spacing) > glyphTolerance {
+			c.Kinds = append(c.Kinds, GlyphSpacing)
+			stats.Spacing++
+			stats.MeanSpacing += math.Abs(c.Spacing)
+			stats.MaxSpacing = math.Max(stats.MaxSpacing, math.Abs(c.Spacing))
+		}
+		if len(c.Kinds) == 0 {
+			continue
+		}
+		if stats.Characters == nil {
+			stats.Characters = make(map[string]int)
+		}
+		stats.Characters[c.Text]++
+		if len(diff.Changes) < maxGlyphChanges {
+			diff.Changes = append(diff.Changes, c)
+		}
+	}
+	stats.MeanRaster = mean(stats.MeanRaster, stats.Shape)
+	stats.MeanShift = mean(stats.MeanShift, stats.Position)
+	stats.MeanSpacing = mean(stats.MeanSpacing, stats.Spacing)
+	return diff
+}
+
+// glyphRect returns the box of a glyph in pixels, rounded outwards
+func glyphRect(box [4]float64, scale float64) image.Rectangle {
+	return image.Rect(int(math.Floor(box[0]*scale)), int(math.Floor(box[1]*scale)), int(math.Ceil(box[2]*scale)), int(math.Ceil(box[3]*scale)))
+}
+
+// glyphRaster returns the share of the inked pixels of a glyph rendered differently on both pages, aligning the
+// top left corners of its boxes r1 and r2, so a glyph that only moved is compared with itself. The boxes are
+// rounded to the pixel, so the alignment is also tried one pixel off in every direction and the best one kept.
+func glyphRaster(img1, img2 image.Image, r1, r2 image.Rectangle) float64 {
+	best := 1.0
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			inked, differ := 0, 0
+			for y := 0; y < max(r1.Dy(), r2.Dy()); y++ {
+				for x := 0; x < max(r1.Dx(), r2.Dx()); x++ {
+					g1, g2 := glyphGray(img1, r1.Min.Add(image.Pt(x, y))), glyphGray(img2, r2.Min.Add(image.Pt(x+dx, y+dy)))
+					if g1 < 128 || g2 < 128 {
+						inked++
+					}
+					if abs(g1-g2) > 64 {
+						differ++
+					}
+				}
+			}
+			if inked == 0 {
+				return 0
+			}
+			best = math.Min(best, float64(differ)/float64(inked))
+		}
+	}
+	return math.Round(best*1000) / 1000
+}
+
+// glyphGray returns the gray level of a pixel of a page, white outside the page
+func glyphGray(img image.Image, p image.Point) int {
+	if !p.In(img.Bounds()) {
+		return 255
+	}
+	return int(color.GrayModel.Convert(img.At(p.X, p.Y)).(color.Gray).Y)
+}
+
+// roundPoints rounds a length in points to a thousandth, below the precision of the text layers
+func roundPoints(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// mean returns the mean of n values adding up to sum, 0 for none
+func mean(sum float64, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return math.Round(sum/float64(n)*1000) / 1000
+}
+
+// GlyphTotals adds up the glyph statistics of the pages, or returns nil if the pages were not compared with Glyphs
+func GlyphTotals(pages []PageResult) *GlyphStats {
+	var total *GlyphStats
+	var raster, shift, spacing float64
+	for _, p := range pages {
+		if p.Glyphs == nil {
+			continue
+		}
+		if total == nil {
+			total = &GlyphStats{}
+		}
+		s := p.Glyphs.GlyphStats
+		total.Matched += s.Matched
+		total.Inserted += s.Inserted
+		total.Deleted += s.Deleted
+		total.Shape += s.Shape
+		total.Position += s.Position
+		total.Spacing += s.Spacing
+		raster += s.MeanRaster * float64(s.Shape)
+		shift += s.MeanShift * float64(s.Position)
+		spacing += s.MeanSpacing * float64(s.Spacing)
+		total.MaxRaster = math.Max(total.MaxRaster, s.MaxRaster)
+		total.MaxShift = math.Max(total.MaxShift, s.MaxShift)
+		total.MaxSpacing = math.Max(total.MaxSpacing, s.MaxSpacing)
+		for c, n := range s.Characters {
+			if total.Characters == nil {
+				total.Characters = make(map[string]int)
+			}
+			total.Characters[c] += n
+		}
+	}
+	if total != nil {
+		total.MeanRaster = mean(raster, total.Shape)
+		total.MeanShift = mean(shift, total.Position)
+		total.MeanSpacing = mean(spacing, total.Spacing)
+	}
+	return total
+}
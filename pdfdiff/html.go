@@ -0,0 +1,149 @@
+package pdfdiff
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// The images embedded in the HTML report are scaled down to keep the file small enough for a browser
+const (
+	htmlImageWidth     = 1200
+	htmlThumbnailWidth = 200
+)
+
+//go:embed report.html.tmpl
+var htmlReportTemplate string
+
+var htmlReport = template.Must(template.New("report").Parse(htmlReportTemplate))
+
+// htmlPage is a page of the HTML report, with its images embedded as data URLs
+type htmlPage struct {
+	Number    int
+	Changed   bool
+	Status    string
+	Thumbnail template.URL
+	Image1    template.URL
+	Image2    template.URL
+	Diff      template.URL
+	// Text are the words inserted and deleted on the page, shown next to the viewer
+	Text []TextChange
+	// Regions are outlined on the difference image
+	Regions []htmlRegion
+}
+
+// htmlRegion is a changed region of a page of the HTML report, in percents of the size of the page so it follows the
+// scaled image, with its description as a tooltip
+type htmlRegion struct {
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Title  string  `json:"title"`
+}
+
+// WriteHTMLReport writes a self-contained HTML report of the comparison, with a thumbnail and the share of
+// changed pixels for every page and a viewer to flip between the two documents and the difference image.
+// The rendered pages of both documents are only shown if the comparison was run with Options.PageImages, and the
+// words inserted and deleted on every page, next to the viewer, with Options.TextDiff.
+func WriteHTMLReport(report Report, output string) error {
+	data := struct {
+		File1, File2 string
+		ChangedCount int
+		// HasText shows the panel of the text changes, for comparisons run with Options.TextDiff or OCR
+		HasText bool
+		Pages   []htmlPage
+	}{File1: report.File1, File2: report.File2}
+
+	for _, page := range report.Pages {
+		if page.DiffImage == "" {
+			continue
+		}
+		diff, err := openImage(report.Dir, report.Images, page.DiffImage)
+		if err != nil {
+			return err
+		}
+		p := htmlPage{Number: page.Page + 1, Changed: page.Changed, Status: pageStatus(page), Text: page.TextChanges}
+		data.HasText = data.HasText || len(page.TextChanges) > 0
+		if p.Thumbnail, err = dataURL(diff, htmlThumbnailWidth); err != nil {
+			return err
+		}
+		if p.Diff, err = dataURL(diff, htmlImageWidth); err != nil {
+			return err
+		}
+		p.Regions = htmlRegions(page.Regions, diff.Bounds())
+		if p.Image1, err = imageDataURL(report, page.Image1); err != nil {
+			return err
+		}
+		if p.Image2, err = imageDataURL(report, page.Image2); err != nil {
+			return err
+		}
+		if page.Changed {
+			data.ChangedCount++
+		}
+		data.Pages = append(data.Pages, p)
+	}
+
+	var buf bytes.Buffer
+	if err := htmlReport.Execute(&buf, data); err != nil {
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0644)
+}
+
+// pageStatus describes how much of a page has changed
+func pageStatus(page PageResult) string {
+	switch {
+	case !page.Changed:
+		return "identical"
+	case page.MissingIn != 0:
+		return fmt.Sprintf("missing in document %d", page.MissingIn)
+	default:
+		return fmt.Sprintf("%.2f%% changed", page.PercentChanged)
+	}
+}
+
+// htmlRegions places the regions of a page on its difference image
+func htmlRegions(regions []Region, bounds image.Rectangle) []htmlRegion {
+	var boxes []htmlRegion
+	w, h := float64(bounds.Dx())/100, float64(bounds.Dy())/100
+	for n, r := range regions {
+		title := fmt.Sprintf("Difference %d of %d: %dx%d pixels at %d,%d, %d changed, centroid %.1f,%.1f", n+1, len(regions), r.Width, r.Height, r.X, r.Y, r.Area, r.CentroidX, r.CentroidY)
+		if mark := r.Mark(); mark != "" {
+			title += ", " + mark
+		}
+		boxes = append(boxes, htmlRegion{Left: float64(r.X) / w, Top: float64(r.Y) / h, Width: float64(r.Width) / w, Height: float64(r.Height) / h, Title: title})
+	}
+	return boxes
+}
+
+// imageDataURL loads an image of the report and returns it as a data URL, or an empty URL if there is no image
+func imageDataURL(report Report, name string) (template.URL, error) {
+	if name == "" {
+		return "", nil
+	}
+	img, err := openImage(report.Dir, report.Images, name)
+	if err != nil {
+		return "", err
+	}
+	return dataURL(img, htmlImageWidth)
+}
+
+// dataURL scales the image down to the given width and encodes it as a JPEG data URL
+func dataURL(img image.Image, width int) (template.URL, error) {
+	if img.Bounds().Dx() > width {
+		img = imaging.Resize(img, width, 0, imaging.Lanczos)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return "", err
+	}
+	return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
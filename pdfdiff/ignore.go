@@ -0,0 +1,356 @@
+package pdfdiff
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reviewKeywords marks the annotations of a reviewer that accept a difference, in their text or subject
+var reviewKeywords = regexp.MustCompile(`(?i)\b(expected|ignore|ignored)\b`)
+
+// IgnoreRegion is an area of a page whose differences are ignored, in PDF points from the bottom left corner of the page
+type IgnoreRegion struct {
+	// Page is the output page, counted from 0 as in the report
+	Page int `json:"page" yaml:"page"`
+	// AllPages applies the region to every page instead of Page, e.g. for a footer with the print date
+	AllPages bool `json:"all_pages,omitempty" yaml:"all_pages"`
+	// Rect is the area as left, bottom, right and top
+	Rect [4]float64 `json:"rect" yaml:"rect"`
+	// Reason is the text of the annotation the region was imported from, or why it is excluded
+	Reason string `json:"reason,omitempty" yaml:"reason"`
+}
+
+// ignoreFile is the file the ignore regions are kept in between runs, also called a mask file
+type ignoreFile struct {
+	Regions []IgnoreRegion `json:"regions" yaml:"regions"`
+}
+
+// ReadIgnoreRegions reads a mask file, as YAML if its extension is .yaml or .yml and as JSON otherwise,
+// such as the ones written by WriteIgnoreRegions
+func ReadIgnoreRegions(path string) ([]IgnoreRegion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f ignoreFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &f)
+	default:
+		err = json.Unmarshal(data, &f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return f.Regions, nil
+}
+
+// WriteIgnoreRegions writes the ignore regions as JSON
+func WriteIgnoreRegions(path string, regions []IgnoreRegion) error {
+	data, err := json.MarshalIndent(ignoreFile{Regions: regions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// MergeIgnoreRegions appends the regions that are not already in the list
+func MergeIgnoreRegions(regions []IgnoreRegion, more []IgnoreRegion) []IgnoreRegion {
+	for _, r := range more {
+		known := false
+		for _, k := range regions {
+			if k.Page == r.Page && k.AllPages == r.AllPages && k.Rect == r.Rect {
+				known = true
+				break
+			}
+		}
+		if !known {
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// reviewAnnotation is an annotation read back from a reviewed file
+type reviewAnnotation struct {
+	page      int
+	rect      [4]float64
+	contents  string
+	subject   string
+	name      string
+	inReplyTo string
+}
+
+// ImportAnnotations reads the annotations of a reviewed XFDF, FDF or PDF file and returns the areas the reviewers marked
+// as expected or to ignore, by writing "expected" or "ignore" in the comment, its subject or a reply to it.
+// Annotations exported by WriteAnnotations keep their area when imported back, since they use the same coordinates.
+func ImportAnnotations(path string) ([]IgnoreRegion, error) {
+	var annotations []reviewAnnotation
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xfdf", ".xml":
+		annotations, err = readXFDFAnnotations(path)
+	default:
+		annotations, err = readPDFAnnotations(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]reviewAnnotation)
+	for _, a := range annotations {
+		if a.name != "" {
+			byName[a.name] = a
+		}
+	}
+	var regions []IgnoreRegion
+	for _, a := range annotations {
+		if !reviewKeywords.MatchString(a.contents) && !reviewKeywords.MatchString(a.subject) {
+			continue
+		}
+		reason := strings.TrimSpace(a.contents)
+		if reason == "" {
+			reason = a.subject
+		}
+		region := IgnoreRegion{Page: a.page, Rect: a.rect, Reason: reason}
+		// A reply accepts the area of the comment it answers
+		if parent, ok := byName[a.inReplyTo]; ok {
+			region.Page, region.Rect = parent.page, parent.rect
+		}
+		regions = MergeIgnoreRegions(regions, []IgnoreRegion{region})
+	}
+	return regions, nil
+}
+
+// readXFDFAnnotations reads the annotations of an XFDF file
+func readXFDFAnnotations(path string) ([]reviewAnnotation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Annots struct {
+			Items []struct {
+				Attrs    []xml.Attr `xml:",any,attr"`
+				Contents string     `xml:"contents"`
+			} `xml:",any"`
+		} `xml:"annots"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	var annotations []reviewAnnotation
+	for _, item := range doc.Annots.Items {
+		attrs := make(map[string]string)
+		for _, attr := range item.Attrs {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		a := reviewAnnotation{contents: item.Contents, subject: attrs["subject"], name: attrs["name"], inReplyTo: attrs["inreplyto"]}
+		if a.page, err = strconv.Atoi(attrs["page"]); err != nil {
+			continue
+		}
+		rect := strings.Split(attrs["rect"], ",")
+		if len(rect) != 4 {
+			continue
+		}
+		for i, v := range rect {
+			a.rect[i], _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, nil
+}
+
+// readPDFAnnotations reads the annotations of an FDF file, which refer to pages by number, or of the pages of a PDF
+func readPDFAnnotations(path string) ([]reviewAnnotation, error) {
+	f, err := readPDF(path)
+	if err != nil {
+		return nil, err
+	}
+	var annotations []reviewAnnotation
+	add := func(annot pdfDict, page int, origin [2]float64) {
+		rect := f.numbers(annot["Rect"])
+		if len(rect) != 4 || annot["Subtype"] == pdfName("Popup") {
+			return
+		}
+		a := reviewAnnotation{
+			page:     page,
+			rect:     [4]float64{rect[0] - origin[0], rect[1] - origin[1], rect[2] - origin[0], rect[3] - origin[1]},
+			contents: f.text(annot["Contents"]),
+			subject:  f.text(annot["Subj"]),
+			name:     f.text(annot["NM"]),
+		}
+		// Replies refer to the annotation they answer
+		if parent := f.dict(annot["IRT"]); parent != nil {
+			a.inReplyTo = f.text(parent["NM"])
+		}
+		annotations = append(annotations, a)
+	}
+
+	if fdf := f.dict(f.dict(f.trailer["Root"])["FDF"]); fdf != nil {
+		for _, obj := range f.array(fdf["Annots"]) {
+			annot := f.dict(obj)
+			if page, ok := f.resolve(annot["Page"]).(float64); ok {
+				add(annot, int(page), [2]float64{})
+			}
+		}
+		return annotations, nil
+	}
+	pages := f.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%s has no pages", path)
+	}
+	for n, page := range pages {
+		var origin [2]float64
+		if box := f.numbers(page["MediaBox"]); len(box) == 4 {
+			origin = [2]float64{box[0], box[1]}
+		}
+		for _, obj := range f.array(page["Annots"]) {
+			add(f.dict(obj), n, origin)
+		}
+	}
+	return annotations, nil
+}
+
+// Margins are the margins of every page excluded from the comparison, e.g. for running headers and footers with
+// page numbers and dates. Every margin is a length in millimeters, such as "15" or "15mm", or a percentage of the page
+// height (top and bottom) or width (left and right), such as "5%". Empty margins are compared.
+type Margins struct {
+	Top    string `json:"top,omitempty"`
+	Bottom string `json:"bottom,omitempty"`
+	Left   string `json:"left,omitempty"`
+	Right  string `json:"right,omitempty"`
+}
+
+// marginPixels returns the size in pixels of a margin of a page of size pixels, rendered at dpi
+func marginPixels(margin string, size int, dpi float64) (int, error) {
+	margin = strings.TrimSpace(margin)
+	if margin == "" {
+		return 0, nil
+	}
+	percent := strings.HasSuffix(margin, "%")
+	v, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(margin, "%"), "mm")), 64)
+	if err != nil || v < 0 || (percent && v > 100) {
+		return 0, fmt.Errorf("invalid margin %q, expected millimeters (e.g. 15mm) or a percentage of the page (e.g. 5%%)", margin)
+	}
+	if percent {
+		return int(math.Ceil(v / 100 * float64(size))), nil
+	}
+	return int(math.Ceil(v / 25.4 * dpi)), nil
+}
+
+// marginRects returns the margins of a page excluded from the comparison, in pixels of the page
+func marginRects(m Margins, bounds image.Rectangle, dpi float64) ([]image.Rectangle, error) {
+	top, err := marginPixels(m.Top, bounds.Dy(), dpi)
+	if err != nil {
+		return nil, err
+	}
+	bottom, err := marginPixels(m.Bottom, bounds.Dy(), dpi)
+	if err != nil {
+		return nil, err
+	}
+	left, err := marginPixels(m.Left, bounds.Dx(), dpi)
+	if err != nil {
+		return nil, err
+	}
+	right, err := marginPixels(m.Right, bounds.Dx(), dpi)
+	if err != nil {
+		return nil, err
+	}
+	var rects []image.Rectangle
+	for _, r := range []image.Rectangle{
+		image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y+top),
+		image.Rect(bounds.Min.X, bounds.Max.Y-bottom, bounds.Max.X, bounds.Max.Y),
+		image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X+left, bounds.Max.Y),
+		image.Rect(bounds.Max.X-right, bounds.Min.Y, bounds.Max.X, bounds.Max.Y),
+	} {
+		if !r.Empty() {
+			rects = append(rects, r)
+		}
+	}
+	return rects, nil
+}
+
+// ignoreRects converts the ignore regions of a page to pixels of the page rendered at dpi, given its height in pixels
+func ignoreRects(regions []IgnoreRegion, page, height int, dpi float64) []image.Rectangle {
+	scale := dpi / 72
+	var rects []image.Rectangle
+	for _, r := range regions {
+		if r.Page != page && !r.AllPages {
+			continue
+		}
+		// Round outwards, so a region exported by WriteAnnotations covers all of its pixels again
+		left, right := math.Floor(r.Rect[0]*scale), math.Ceil(r.Rect[2]*scale)
+		bottom, top := math.Floor(r.Rect[1]*scale), math.Ceil(r.Rect[3]*scale)
+		rects = append(rects, image.Rect(int(left), height-int(top), int(right), height-int(bottom)).Canon())
+	}
+	return rects
+}
+
+// Excluded areas are grayed out and hatched in the difference images, so nobody mistakes them for unchanged content
+const (
+	hatchSpacing = 16 // pixels between the hatch lines
+	hatchWidth   = 2
+)
+
+// hatchColor is the color of the hatch lines of the excluded areas
+var hatchColor = color.RGBA{128, 128, 128, 255}
+
+// excludedColor returns the color of a pixel of an excluded area in the difference image
+func excludedColor(c color.Color, x, y int) color.Color {
+	if (x+y)%hatchSpacing < hatchWidth {
+		return hatchColor
+	}
+	// Blend the page with light gray
+	r, g, b, _ := c.RGBA()
+	return color.RGBA{uint8((r>>8 + 208) / 2), uint8((g>>8 + 208) / 2), uint8((b>>8 + 208) / 2), 255}
+}
+
+// hatchRects returns a copy of the page with the excluded areas marked
+func hatchRects(img image.Image, rects []image.Rectangle) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	for _, r := range rects {
+		r = r.Intersect(b)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				out.Set(x, y, excludedColor(img.At(x, y), x, y))
+			}
+		}
+	}
+	return out
+}
+
+// intersects reports whether a rectangle overlaps one of the rectangles
+func intersects(rects []image.Rectangle, r image.Rectangle) bool {
+	for _, o := range rects {
+		if o.Overlaps(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored reports whether a pixel is in one of the rectangles
+func ignored(rects []image.Rectangle, x, y int) bool {
+	p := image.Pt(x, y)
+	for _, r := range rects {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package pdfdiff
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// WriteJSONReport saves the report as indented JSON, following Schema
+func WriteJSONReport(report Report, output string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append(data, '\n'), 0644)
+}
+
+// WriteMultiReport saves the report of a comparison of several documents as indented JSON, following Schema
+func WriteMultiReport(report MultiReport, output string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append(data, '\n'), 0644)
+}
@@ -0,0 +1,110 @@
+package pdfdiff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// junitSuites is the root of a JUnit XML report, in the format read by Jenkins and GitLab
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit saves the report as a JUnit XML report for the test result views of CI systems: every page is a test
+// case, failing if it changed, or with minSSIM above 0 if its structural similarity is below minSSIM, or with
+// maxPercent above 0 if more than maxPercent of its pixels changed, and always if a critical region changed. The quarantined pages are skipped, and the checks of the template fields are test
+// cases too. The difference image of a failing page is attached, for the Jenkins JUnit attachments plugin.
+func WriteJUnit(report Report, output string, minSSIM, maxPercent float64) error {
+	name := fmt.Sprintf("%s vs %s", filepath.Base(report.File1), filepath.Base(report.File2))
+	className := strings.TrimSuffix(filepath.Base(report.File2), filepath.Ext(report.File2))
+	suite := junitSuite{Name: name}
+	for _, page := range report.Pages {
+		c := junitCase{Name: fmt.Sprintf("page %d", page.Page+1), ClassName: className}
+		failure := pageFailure(page, minSSIM, maxPercent)
+		switch {
+		case page.Quarantined && len(page.Critical) == 0:
+			c.Skipped = &junitMessage{Message: "quarantined"}
+			if failure != "" {
+				c.Skipped.Message += ": " + failure
+			}
+			suite.Skipped++
+		case failure != "":
+			c.Failure = &junitMessage{Message: failure, Text: describeRegions(page)}
+			if page.DiffImage != "" && report.Images == nil {
+				if path, err := filepath.Abs(filepath.Join(report.Dir, page.DiffImage)); err == nil {
+					c.SystemOut = fmt.Sprintf("[[ATTACHMENT|%s]]", path)
+				}
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	for _, check := range report.Checks {
+		c := junitCase{Name: fmt.Sprintf("check %s", check.Name), ClassName: className + ".checks"}
+		if !check.Passed2 {
+			c.Failure = &junitMessage{Message: check.Failure2}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Tests = len(suite.Cases)
+
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+// pageFailure returns why a page fails its test case, or an empty string if it passes
+func pageFailure(page PageResult, minSSIM, maxPercent float64) string {
+	switch {
+	case len(page.Critical) > 0:
+		return fmt.Sprintf("%d critical regions changed", len(page.Critical))
+	case page.MissingIn != 0:
+		return fmt.Sprintf("the page is missing in document %d", page.MissingIn)
+	case minSSIM > 0 && page.SSIM < minSSIM:
+		return fmt.Sprintf("the structural similarity %.4f is below %.4f", page.SSIM, minSSIM)
+	case maxPercent > 0:
+		if page.PercentChanged > maxPercent {
+			return fmt.Sprintf("%.2f%% of the page changed (%d pixels), above %.2f%%", page.PercentChanged, page.ChangedPixels, maxPercent)
+		}
+	case minSSIM <= 0 && page.Changed:
+		return fmt.Sprintf("%.2f%% of the page changed (%d pixels)", page.PercentChanged, page.ChangedPixels)
+	}
+	return ""
+}
+
+// describeRegions lists the changed regions of a page, one per line, for the details of its failure
+func describeRegions(page PageResult) string {
+	var lines []string
+	for _, r := range page.Regions {
+		lines = append(lines, fmt.Sprintf("changed region at %d,%d, %dx%d pixels, %d changed", r.X, r.Y, r.Width, r.Height, r.Area))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,338 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LanguageAuto detects the language of every page, see Options.Language
+const LanguageAuto = "auto"
+
+// language are the settings of the text of a language: its Tesseract language pack, and whether its words are not
+// separated by spaces, so its text is compared character by character
+type language struct {
+	ocr        string
+	characters bool
+}
+
+// languages are the languages detected and their settings, by their ISO 639-2 codes, which Tesseract uses too
+var languages = map[string]language{
+	"eng": {ocr: "eng"}, "deu": {ocr: "deu"}, "fra": {ocr: "fra"}, "spa": {ocr: "spa"}, "ita": {ocr: "ita"},
+	"por": {ocr: "por"}, "nld": {ocr: "nld"}, "swe": {ocr: "swe"}, "pol": {ocr: "pol"}, "ces": {ocr: "ces"},
+	"tur": {ocr: "tur"}, "rus": {ocr: "rus"}, "ukr": {ocr: "ukr"}, "ell": {ocr: "ell"}, "ara": {ocr: "ara"},
+	"heb": {ocr: "heb"}, "hin": {ocr: "hin"}, "kor": {ocr: "kor"},
+	"tha":     {ocr: "tha", characters: true},
+	"jpn":     {ocr: "jpn", characters: true},
+	"chi_sim": {ocr: "chi_sim", characters: true},
+}
+
+// stopwords are the most frequent words of the languages written in the Latin script, which tell them apart
+var stopwords = map[string][]string{
+	"eng": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "be", "this", "by", "or"},
+	"deu": {"der", "die", "und", "das", "ist", "nicht", "mit", "den", "von", "zu", "ein", "eine", "auf", "für", "im", "des", "dem", "sich"},
+	"fra": {"le", "la", "les", "et", "des", "est", "une", "un", "du", "dans", "pour", "que", "qui", "pas", "sur", "au", "avec", "ce"},
+	"spa": {"el", "la", "los", "las", "y", "de", "que", "en", "un", "una", "es", "por", "con", "para", "del", "se", "su", "al"},
+	"ita": {"il", "di", "che", "e", "un", "una", "per", "non", "con", "del", "della", "sono", "è", "gli", "si", "da", "nel", "alla"},
+	"por": {"o", "os", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "no", "na", "dos", "ao", "são"},
+	"nld": {"de", "het", "een", "en", "van", "is", "dat", "op", "te", "in", "niet", "met", "zijn", "voor", "die", "ook", "naar", "wordt"},
+	"swe": {"och", "att", "det", "som", "en", "är", "på", "av", "för", "med", "den", "till", "inte", "har", "ett", "om", "var", "jag"},
+	"pol": {"i", "w", "na", "z", "się", "nie", "do", "to", "że", "jest", "jak", "ale", "co", "od", "po", "dla", "są", "przez"},
+	"ces": {"a", "je", "v", "na", "se", "že", "to", "s", "z", "do", "jsou", "pro", "by", "ve", "jako", "ale", "od", "který"},
+	"tur": {"ve", "bir", "bu", "da", "de", "için", "ile", "olarak", "çok", "daha", "gibi", "ne", "ama", "olan", "her", "en", "kadar", "sonra"},
+}
+
+// scripts are the scripts of the languages detected by their letters alone
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "jpn"}, {unicode.Katakana, "jpn"}, {unicode.Han, "chi_sim"}, {unicode.Hangul, "kor"},
+	{unicode.Cyrillic, "rus"}, {unicode.Greek, "ell"}, {unicode.Arabic, "ara"}, {unicode.Hebrew, "heb"},
+	{unicode.Devanagari, "hin"}, {unicode.Thai, "tha"},
+}
+
+// minLanguageLetters is the number of letters below which the language of a text is not detected
+const minLanguageLetters = 20
+
+// DetectLanguage returns the dominant language of a text, as its ISO 639-2 code such as deu, or an empty string if
+// there is too little text to tell. The scripts other than Latin give their language away, the languages written
+// in Latin are told apart by their frequent words.
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	letters, latin, kana := 0, 0, 0
+	for _, c := range text {
+		if !unicode.IsLetter(c) {
+			continue
+		}
+		letters++
+		if unicode.Is(unicode.Latin, c) {
+			latin++
+			continue
+		}
+		for _, s := range scripts {
+			if unicode.Is(s.table, c) {
+				counts[s.lang]++
+				if s.lang == "jpn" {
+					kana++
+				}
+				break
+			}
+		}
+	}
+	if letters < minLanguageLetters {
+		return ""
+	}
+	if latin*2 < letters {
+		// Japanese mixes kana with the Han characters of Chinese, Ukrainian has letters Russian does not
+		if kana > 0 && counts["chi_sim"] > 0 {
+			counts["jpn"] += counts["chi_sim"]
+			delete(counts, "chi_sim")
+		}
+		lang := mostFrequent(counts)
+		if lang == "rus" && strings.ContainsAny(text, "іїєґІЇЄҐ") {
+			lang = "ukr"
+		}
+		return lang
+	}
+
+	counts = make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(c rune) bool { return !unicode.IsLetter(c) }) {
+		for lang, words := range stopwords {
+			for _, s := range words {
+				if w == s {
+					counts[lang]++
+					break
+				}
+			}
+		}
+	}
+	return mostFrequent(counts)
+}
+
+// mostFrequent returns the key with the highest count, the first in order on a tie, or an empty string if there is
+// none
+func mostFrequent(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	best := ""
+	for _, k := range keys {
+		if counts[k] > 0 && (best == "" || counts[k] > counts[best]) {
+			best = k
+		}
+	}
+	return best
+}
+
+// checkLanguage verifies the Language option: auto, a language code, or one for each document
+func checkLanguage(setting string) error {
+	if setting == "" || setting == LanguageAuto {
+		return nil
+	}
+	codes := strings.Split(setting, ",")
+	if len(codes) > 2 {
+		return fmt.Errorf("the language %q should be auto, a language code or one for each document, e.g. eng,deu", setting)
+	}
+	for _, code := range codes {
+		if _, ok := languages[code]; !ok && code != LanguageAuto {
+			return fmt.Errorf("unknown language %q, use auto or one of %s", code, strings.Join(languageCodes(), ", "))
+		}
+	}
+	return nil
+}
+
+// languageCodes returns the codes of the languages known, in order
+func languageCodes() []string {
+	codes := make([]string, 0, len(languages))
+	for code := range languages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// documentLanguage returns the language set for a document (1 or 2) by the Language option, auto to detect it, or
+// an empty string when the option is off
+func documentLanguage(setting string, doc int) string {
+	codes := strings.Split(setting, ",")
+	if doc == 2 && len(codes) == 2 {
+		return codes[1]
+	}
+	return codes[0]
+}
+
+// normalizeText prepares the text of a page in a language for the comparison: the characters are composed, the
+// typographic spaces, quotes, apostrophes and ligatures are replaced by their plain forms and the soft hyphens
+// removed, so text typeset again with another font or word processor has no changes
+func normalizeText(lang, text string) string {
+	if languages[lang].characters {
+		// The full-width Latin letters and digits and the half-width kana are folded too
+		text = norm.NFKC.String(text)
+	} else {
+		text = norm.NFC.String(text)
+	}
+	text = spaceReplacer.Replace(text)
+	if lang == "fra" {
+		// French sets a space before the high punctuation and inside the guillemets, which other typesetting drops
+		text = frenchReplacer.Replace(text)
+	}
+	return quoteReplacer.Replace(text)
+}
+
+// spaceReplacer replaces the no-break and thin spaces by plain ones, and removes the soft hyphens
+var spaceReplacer = strings.NewReplacer("\u00a0", " ", "\u202f", " ", "\u2009", " ", "\u2007", " ", "\u00ad", "")
+
+// frenchReplacer removes the spaces French puts before ; : ! ? and inside the guillemets
+var frenchReplacer = strings.NewReplacer(" ;", ";", " :", ":", " !", "!", " ?", "?", "« ", "«", " »", "»")
+
+// quoteReplacer replaces the typographic quotes, apostrophes and ligatures by their plain forms
+var quoteReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "′", "'", "‹", "'", "›", "'",
+	"“", `"`, "”", `"`, "„", `"`, "«", `"`, "»", `"`,
+	"ﬀ", "ff", "ﬁ", "fi", "ﬂ", "fl", "ﬃ", "ffi", "ﬄ", "ffl",
+)
+
+// textWords splits the text of a page in a language into the words compared, once normalized: the characters of
+// the languages written without spaces. The text of no language is split on its spaces alone.
+func textWords(lang, text string) []string {
+	if lang == "" {
+		return strings.Fields(text)
+	}
+	words := strings.Fields(normalizeText(lang, text))
+	if !languages[lang].characters {
+		return words
+	}
+	var chars []string
+	for _, w := range words {
+		for _, c := range w {
+			chars = append(chars, string(c))
+		}
+	}
+	return chars
+}
+
+// ocrLanguage returns the Tesseract language pack of a language, eng when it is unknown or not installed
+func ocrLanguage(lang string) string {
+	pack := languages[lang].ocr
+	if pack == "" || !ocrLanguageInstalled(pack) {
+		return "eng"
+	}
+	return pack
+}
+
+// ocrLanguages are the language packs of the Tesseract installed, listed once
+var ocrLanguages struct {
+	once  sync.Once
+	packs map[string]bool
+}
+
+// ocrLanguageInstalled reports whether Tesseract has a language pack
+func ocrLanguageInstalled(pack string) bool {
+	ocrLanguages.once.Do(func() {
+		ocrLanguages.packs = make(map[string]bool)
+		out, err := exec.Command(ocrCommand, "--list-langs").Output()
+		if err != nil {
+			return
+		}
+		// The first line is a header naming the directory of the packs
+		lines := strings.Split(string(bytes.TrimSpace(out)), "\n")
+		for _, line := range lines[1:] {
+			ocrLanguages.packs[strings.TrimSpace(line)] = true
+		}
+	})
+	return ocrLanguages.packs[pack]
+}
+
+// DocumentLanguages returns the dominant languages of both documents, the ones of most of their pages, from the
+// Languages of the pages
+func DocumentLanguages(pages []PageResult) []string {
+	var langs []string
+	for doc := 0; doc < 2; doc++ {
+		counts := make(map[string]int)
+		for _, p := range pages {
+			if len(p.Languages) == 2 && p.Languages[doc] != "" {
+				counts[p.Languages[doc]]++
+			}
+		}
+		langs = append(langs, mostFrequent(counts))
+	}
+	if langs[0] == "" && langs[1] == "" {
+		return nil
+	}
+	return langs
+}
+
+// scriptLanguages are the languages of the scripts found by ocrScript, whose pack reads a page well enough to tell
+// its language
+var scriptLanguages = map[string]string{
+	"Latin": "eng", "Cyrillic": "rus", "Greek": "ell", "Arabic": "ara", "Hebrew": "heb", "Han": "chi_sim",
+	"Japanese": "jpn", "Hangul": "kor", "Thai": "tha", "Devanagari": "hin",
+}
+
+// ocrPageLanguage reads the words of a page image of a document (1 or 2) with the OCR, and returns them normalized
+// for the language of the page: the one set by the Language option, or with auto the one detected in a first
+// reading with the pack of the script of the page, read again with the pack of the language. OCRLanguage, when set,
+// is the pack of every reading.
+func ocrPageLanguage(img image.Image, opts *Options, doc int) ([]ocrWord, string, error) {
+	lang := documentLanguage(opts.Language, doc)
+	pack := opts.OCRLanguage
+	switch {
+	case pack != "" || lang == "":
+	case lang == LanguageAuto:
+		pack = ocrLanguage(scriptLanguages[ocrScript(img, opts.DPI)])
+	default:
+		pack = ocrLanguage(lang)
+	}
+	words, err := ocrPage(img, pack, opts.DPI)
+	if err != nil || lang == "" {
+		return words, lang, err
+	}
+	if lang == LanguageAuto {
+		lang = DetectLanguage(ocrText(words))
+		if p := ocrLanguage(lang); opts.OCRLanguage == "" && lang != "" && p != pack {
+			if words, err = ocrPage(img, p, opts.DPI); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for i := range words {
+		words[i].text = normalizeText(lang, words[i].text)
+	}
+	return words, lang, nil
+}
+
+// ocrText returns the text of the words read by the OCR
+func ocrText(words []ocrWord) string {
+	text := make([]string, len(words))
+	for i, w := range words {
+		text[i] = w.text
+	}
+	return strings.Join(text, " ")
+}
+
+// textLanguage returns the language of the text of a page of a document (1 or 2): the one set by the Language
+// option, or the detected one with auto
+func textLanguage(setting string, doc int, text string) string {
+	if lang := documentLanguage(setting, doc); lang != LanguageAuto {
+		return lang
+	}
+	return DetectLanguage(text)
+}
+
+// pageLanguages returns the Languages of a page, none if the language of neither document is known
+func pageLanguages(lang1, lang2 string) []string {
+	if lang1 == "" && lang2 == "" {
+		return nil
+	}
+	return []string{lang1, lang2}
+}
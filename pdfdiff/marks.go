@@ -0,0 +1,138 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+)
+
+// Kinds of the changed regions that approval workflows look for
+const (
+	KindSignature = "signature"
+	KindStamp     = "stamp"
+)
+
+// A region is a mark of one document when at least markOnlyRatio of the ink that differs is in that document
+const markOnlyRatio = 0.9
+
+// Handwritten signatures are a few long strokes of thin ink: sizes in inches, and at most signatureComponents
+// separate strokes per inch of width, where a line of text has one per letter
+const (
+	signatureMinWidth, signatureMaxWidth   = 0.75, 5.0
+	signatureMinHeight, signatureMaxHeight = 0.25, 2.0
+	signatureMaxDensity                    = 0.2
+	signatureComponents                    = 3.0
+)
+
+// Stamps are compact marks mostly in a colored ink, sizes in inches
+const (
+	stampMinSize, stampMaxSize = 0.5, 4.0
+	stampMaxAspect             = 3.0
+	stampColoredRatio          = 0.5
+)
+
+// inked reports whether a pixel is dark or strongly colored, rather than paper or a light tint
+func inked(c color.Color) bool {
+	return brightness(c) < 192 || colored(c)
+}
+
+// colored reports whether a pixel is a saturated color, like the ink of a stamp
+func colored(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return channelDelta(r, g) >= 96 || channelDelta(g, b) >= 96 || channelDelta(r, b) >= 96
+}
+
+// classifyRegions marks the changed regions that look like a signature or a stamp present in one document only.
+// It is a heuristic on the shape and color of the ink, so it can miss marks or take a drawing for one.
+func classifyRegions(img1, img2 image.Image, regions []Region, dpi float64) {
+	b := img1.Bounds()
+	for i, r := range regions {
+		rect := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height).Add(b.Min).Intersect(b)
+		if rect.Empty() {
+			continue
+		}
+		// Keep the ink found in one document only
+		var only [2][]bool
+		var counts [2]int
+		var coloredCount [2]int
+		for n := range only {
+			only[n] = make([]bool, rect.Dx()*rect.Dy())
+		}
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				c1, c2 := img1.At(x, y), img2.At(x, y)
+				ink1, ink2 := inked(c1), inked(c2)
+				if ink1 == ink2 {
+					continue
+				}
+				n, c := 0, c1
+				if ink2 {
+					n, c = 1, c2
+				}
+				only[n][(y-rect.Min.Y)*rect.Dx()+x-rect.Min.X] = true
+				counts[n]++
+				if colored(c) {
+					coloredCount[n]++
+				}
+			}
+		}
+		n := 0
+		if counts[1] > counts[0] {
+			n = 1
+		}
+		if counts[n] == 0 || float64(counts[n]) < markOnlyRatio*float64(counts[0]+counts[1]) {
+			continue
+		}
+
+		width, height := float64(rect.Dx())/dpi, float64(rect.Dy())/dpi
+		density := float64(counts[n]) / float64(rect.Dx()*rect.Dy())
+		switch {
+		case width >= signatureMinWidth && width <= signatureMaxWidth && height >= signatureMinHeight && height <= signatureMaxHeight &&
+			width > height && density <= signatureMaxDensity &&
+			float64(strokes(only[n], rect.Dx(), rect.Dy(), int(dpi/10))) <= signatureComponents*width:
+			regions[i].Kind = KindSignature
+		case width >= stampMinSize && width <= stampMaxSize && height >= stampMinSize && height <= stampMaxSize &&
+			width <= stampMaxAspect*height && height <= stampMaxAspect*width &&
+			float64(coloredCount[n]) >= stampColoredRatio*float64(counts[n]):
+			regions[i].Kind = KindStamp
+		default:
+			continue
+		}
+		regions[i].In = n + 1
+	}
+}
+
+// strokes counts the groups of touching ink pixels of a mask, ignoring the ones smaller than minPixels like dots
+func strokes(mask []bool, width, height, minPixels int) int {
+	seen := make([]bool, len(mask))
+	count := 0
+	for start := range mask {
+		if !mask[start] || seen[start] {
+			continue
+		}
+		size := 0
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			x, y := i%width, i/width
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					if j := ny*width + nx; mask[j] && !seen[j] {
+						seen[j] = true
+						stack = append(stack, j)
+					}
+				}
+			}
+		}
+		if size >= minPixels {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,104 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image"
+	"path/filepath"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// MemoryImages keeps the images of a comparison in memory, encoded like the files they replace, so a comparison run
+// with Options.InMemory writes nothing next to its outputs. It is safe for concurrent use.
+type MemoryImages struct {
+	mu     sync.Mutex
+	images map[string][]byte // by file name
+}
+
+// NewMemoryImages returns an empty image store
+func NewMemoryImages() *MemoryImages {
+	return &MemoryImages{images: make(map[string][]byte)}
+}
+
+// Encode is an EncodeFunc that keeps the image under the file name of the path, in the format of its extension
+func (m *MemoryImages) Encode(img image.Image, path string) error {
+	format, err := imaging.FormatFromFilename(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, format); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.images[filepath.Base(path)] = buf.Bytes()
+	m.mu.Unlock()
+	return nil
+}
+
+// Add keeps an already encoded image under the given file name
+func (m *MemoryImages) Add(name string, data []byte) {
+	m.mu.Lock()
+	m.images[filepath.Base(name)] = data
+	m.mu.Unlock()
+}
+
+// Bytes returns the encoded image with the given file name, or nil if there is none
+func (m *MemoryImages) Bytes(name string) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.images[name]
+}
+
+// Open decodes the image with the given file name
+func (m *MemoryImages) Open(name string) (image.Image, error) {
+	data := m.Bytes(name)
+	if data == nil {
+		return nil, fmt.Errorf("%s: no such image in memory", name)
+	}
+	return imaging.Decode(bytes.NewReader(data))
+}
+
+// GobEncode encodes the images, so a Report can be sent with encoding/gob
+func (m *MemoryImages) GobEncode() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(m.images)
+	return buf.Bytes(), err
+}
+
+// GobDecode decodes the images encoded by GobEncode
+func (m *MemoryImages) GobDecode(data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&m.images)
+}
+
+// link makes the image dst the same as src, like linkImage does with files
+func (m *MemoryImages) link(src, dst string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data, ok := m.images[src]; ok {
+		m.images[dst] = data
+	}
+}
+
+// openImage decodes an image of a comparison, from memory if the comparison kept its images there or else from dir
+func openImage(dir string, images *MemoryImages, name string) (image.Image, error) {
+	if images != nil {
+		return images.Open(name)
+	}
+	return imaging.Open(filepath.Join(dir, name))
+}
+
+// imageExists reports whether a comparison wrote an image, to memory or to dir
+func imageExists(dir string, images *MemoryImages, name string) bool {
+	if images != nil {
+		return images.Bytes(name) != nil
+	}
+	return fileExists(dir, name)
+}
@@ -0,0 +1,359 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/phpdave11/gofpdf"
+)
+
+// Layout configures the PDF files built from the page images of a comparison
+type Layout struct {
+	// Orientation of the pages, P for portrait or L for landscape, or Auto to turn every page like its image
+	Orientation string
+	// PrintSize is the page format of the PDF, e.g. A4 or A3, or Auto for the A format closest to the size of most
+	// of the compared pages
+	PrintSize string
+	// TilePrint, if set, is an A format, e.g. A4, the difference images of the pages larger than it are split on,
+	// in overlapping tiles printed at the actual size of the page with assembly marks, for reviewers without a
+	// plotter
+	TilePrint string
+	// DPI is the resolution of the page images, which the Auto print size measures the pages with (Default: DefaultDPI)
+	DPI float64
+	// Progress, if set, is called every time an image has been added to the PDF
+	Progress func(completed, total int)
+	// Images, if set, holds the page images instead of the directory, see Options.InMemory
+	Images *MemoryImages
+	// PageNumbers stamps the number of the compared page in the corner of every page, for PDFs that leave out
+	// the unchanged pages
+	PageNumbers bool
+	// Summary, if set, is the comparison described on a page added before the images: the documents, their
+	// modification times and page counts, the changes of every page and a table of the changed pages
+	Summary *Report
+}
+
+// Auto selects the orientation of every page or the print size of a Layout from the page images
+const Auto = "auto"
+
+// printSizes are the A formats a Layout can print on, in mm, from the smallest
+var printSizes = []struct {
+	name   string
+	width  float64
+	height float64
+}{{"A4", 210, 297}, {"A3", 297, 420}, {"A2", 420, 594}, {"A1", 594, 841}, {"A0", 841, 1189}}
+
+// printSize returns the print size of the pages. The Auto print size is the A format closest to the most common
+// size of the pages, measured in mm at the resolution of their images.
+func (layout Layout) printSize(pages []PageResult) string {
+	if layout.PrintSize != Auto {
+		return layout.PrintSize
+	}
+	dpi := layout.dpi()
+	type size struct{ long, short int }
+	counts := make(map[size]int)
+	var dominant size
+	for _, page := range pages {
+		if page.Width <= 0 || page.Height <= 0 {
+			continue
+		}
+		w, h := int(math.Round(float64(page.Width)/dpi*25.4)), int(math.Round(float64(page.Height)/dpi*25.4))
+		s := size{w, h}
+		if h > w {
+			s = size{h, w}
+		}
+		// The first of the most common sizes wins, so the choice does not depend on the order of a map
+		if counts[s]++; counts[s] > counts[dominant] {
+			dominant = s
+		}
+	}
+	best, bestDiff := "A4", math.Inf(1)
+	for _, format := range printSizes {
+		diff := math.Abs(format.height-float64(dominant.long)) + math.Abs(format.width-float64(dominant.short))
+		if diff < bestDiff {
+			best, bestDiff = format.name, diff
+		}
+	}
+	return best
+}
+
+// dpi returns the resolution of the page images
+func (layout Layout) dpi() float64 {
+	if layout.DPI <= 0 {
+		return DefaultDPI
+	}
+	return layout.DPI
+}
+
+// orientation returns the orientation of the page of an image of the given extent
+func (layout Layout) orientation(imgW, imgH float64) string {
+	if layout.Orientation != Auto {
+		return layout.Orientation
+	}
+	if imgW > imgH {
+		return "L"
+	}
+	return "P"
+}
+
+// summaryColumns are the columns of the table of the changed pages of a summary page, with their widths in mm
+var summaryColumns = []struct {
+	title string
+	width float64
+}{{"Page", 20}, {"Changed", 25}, {"Pixels", 30}, {"Regions", 25}, {"SSIM", 25}}
+
+// addSummaryPage adds a page describing the comparison to the PDF, so the merged PDF can be shared on its own
+func addSummaryPage(pdf *gofpdf.Fpdf, report *Report) {
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+	pdf.Bookmark(outlineText("Summary"), 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "PDF comparison", "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	changed := report.ChangedPages()
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range []string{
+		describeDocument("First document", report.File1, report.Pages1),
+		describeDocument("Second document", report.File2, report.Pages2),
+		"Generated: " + time.Now().Format("2006-01-02 15:04:05"),
+		fmt.Sprintf("%d of %d pages changed, mean structural similarity (SSIM) %.4f", len(changed), len(report.Pages), report.SSIM),
+	} {
+		pdf.MultiCell(0, 5, tr(line), "", "L", false)
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, "Changed pages", "", 1, "L", false, 0, "")
+	if len(changed) == 0 {
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 5, "No page changed", "", 1, "L", false, 0, "")
+	} else {
+		pdf.SetFont("Helvetica", "B", 10)
+		for _, c := range summaryColumns {
+			pdf.CellFormat(c.width, 6, c.title, "1", 0, "C", false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 10)
+		for _, page := range changed {
+			cells := []string{
+				fmt.Sprint(page.Page + 1),
+				fmt.Sprintf("%.2f%%", page.PercentChanged),
+				fmt.Sprint(page.ChangedPixels),
+				fmt.Sprint(len(page.Regions)),
+				fmt.Sprintf("%.4f", page.SSIM),
+			}
+			for i, c := range summaryColumns {
+				pdf.CellFormat(c.width, 6, cells[i], "1", 0, "R", false, 0, "")
+			}
+			pdf.Ln(-1)
+		}
+	}
+
+	// The percentages of every page, changed or not, several to a line
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 7, "All pages", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	const cellWidth = 30.0
+	left, _, right, _ := pdf.GetMargins()
+	pageWidth, _ := pdf.GetPageSize()
+	perLine := int((pageWidth - left - right) / cellWidth)
+	for i, page := range report.Pages {
+		ln := 0
+		if (i+1)%perLine == 0 || i == len(report.Pages)-1 {
+			ln = 1
+		}
+		pdf.CellFormat(cellWidth, 5, fmt.Sprintf("%d: %.2f%%", page.Page+1, page.PercentChanged), "", ln, "L", false, 0, "")
+	}
+}
+
+// describeDocument describes a compared document on the summary page, with its modification time when the file
+// can still be found
+func describeDocument(label, path string, pages int) string {
+	text := fmt.Sprintf("%s: %s, %d pages", label, path, pages)
+	if pages == 1 {
+		text = fmt.Sprintf("%s: %s, 1 page", label, path)
+	}
+	if info, err := os.Stat(path); err == nil {
+		text += ", modified " + info.ModTime().Format("2006-01-02 15:04:05")
+	}
+	return text
+}
+
+// stampPageNumber writes the number of an output page in the top left corner of the current PDF page, on white so
+// it stays readable over the image
+func stampPageNumber(pdf *gofpdf.Fpdf, page int) {
+	text := fmt.Sprintf("Page %d", page+1)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(255, 255, 255)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetXY(2, 2)
+	pdf.CellFormat(pdf.GetStringWidth(text)+4, 6, text, "1", 0, "C", true, 0, "")
+}
+
+// bookmarkPage adds an entry pointing at the current PDF page to the outline of the PDF if the page has changed,
+// e.g. "Page 17 – 4.2% changed", so reviewers can jump to the changed pages from the bookmarks of their viewer
+func bookmarkPage(pdf *gofpdf.Fpdf, page PageResult) {
+	if page.Changed {
+		pdf.Bookmark(outlineText(fmt.Sprintf("Page %d – %.1f%% changed", page.Page+1, page.PercentChanged)), 0, 0)
+	}
+}
+
+// outlineText encodes the title of a bookmark as UTF-16 with a byte order mark, which PDF viewers read whatever the
+// fonts of the PDF
+func outlineText(text string) string {
+	var b strings.Builder
+	b.WriteString("\xfe\xff")
+	for _, u := range utf16.Encode([]rune(text)) {
+		b.WriteByte(byte(u >> 8))
+		b.WriteByte(byte(u))
+	}
+	return b.String()
+}
+
+// registerImage adds an image of the comparison to the PDF, from memory or from its file, and returns the name
+// to draw it with
+func (layout Layout) registerImage(pdf *gofpdf.Fpdf, path string, options gofpdf.ImageOptions) (string, *gofpdf.ImageInfoType) {
+	if layout.Images == nil {
+		return path, pdf.RegisterImageOptions(path, options)
+	}
+	name := filepath.Base(path)
+	data := layout.Images.Bytes(name)
+	if data == nil {
+		pdf.SetErrorf("%s: no such image in memory", name)
+		return name, nil
+	}
+	options.ImageType = strings.TrimPrefix(filepath.Ext(name), ".")
+	return name, pdf.RegisterImageOptionsReader(name, options, bytes.NewReader(data))
+}
+
+// WriteDiffPDF adds the difference images of the pages to a PDF, one image per page, scaled to fit the print size,
+// or split on tiles at their actual size when they are larger than the TilePrint format. The image paths are relative to dir, or name images of layout.Images.
+func WriteDiffPDF(dir string, pages []PageResult, output string, layout Layout) error {
+	var paths []string
+	var merged []PageResult
+	for _, page := range pages {
+		if page.DiffImage != "" {
+			paths = append(paths, filepath.Join(dir, page.DiffImage))
+			merged = append(merged, page)
+		}
+	}
+
+	// Create a new PDF for the difference images. The pages of the summary and of the Auto orientation start portrait.
+	printSize := layout.printSize(merged)
+	pdf := gofpdf.New(layout.orientation(0, 0), "mm", printSize, "")
+	imgOptions := gofpdf.ImageOptions{
+		ImageType:             "",
+		ReadDpi:               true,
+		AllowNegativePosition: true,
+	}
+	size := pdf.GetPageSizeStr(printSize)
+	if layout.Summary != nil {
+		addSummaryPage(pdf, layout.Summary)
+	}
+
+	progressInterval := len(paths) / 10
+	if progressInterval == 0 {
+		progressInterval = 1
+	}
+
+	for i, diffImgPath := range paths {
+		// Register each image inside the loop if they are not the same
+		name, imgInfo := layout.registerImage(pdf, diffImgPath, imgOptions)
+		if imgInfo == nil {
+			break
+		}
+		imgW, imgH := imgInfo.Extent()
+		// The images are read at 72 dpi, while the actual size of the pages is at the resolution of the images
+		width, height := imgW*72/layout.dpi(), imgH*72/layout.dpi()
+		if grid := layout.newTileGrid(width, height); grid != nil {
+			addTiles(pdf, name, width, height, imgOptions, grid, merged[i])
+		} else {
+			layout.addScaledPage(pdf, name, imgW, imgH, size, imgOptions, merged[i])
+		}
+
+		// Update the progress less frequently to improve performance
+		if layout.Progress != nil && (i%progressInterval == 0 || i == len(paths)-1) {
+			layout.Progress(i+1, len(paths))
+		}
+	}
+
+	// Save the PDF
+	return writePDF(pdf, output)
+}
+
+// addScaledPage adds the image of a page to the PDF on a page of the print size, scaled to fit and centered
+func (layout Layout) addScaledPage(pdf *gofpdf.Fpdf, name string, imgW, imgH float64, size gofpdf.SizeType, options gofpdf.ImageOptions, page PageResult) {
+	pdf.AddPageFormat(layout.orientation(imgW, imgH), size)
+	pdfW, pdfH := pdf.GetPageSize()
+	scale := min(pdfW/imgW, pdfH/imgH)
+	scaledImgW := imgW * scale
+	scaledImgH := imgH * scale
+
+	// Calculate the position of the image so that it is centered on the page
+	x := (pdfW - scaledImgW) / 2
+	y := (pdfH - scaledImgH) / 2
+
+	// Add the image to the PDF
+	pdf.ImageOptions(name, x, y, scaledImgW, scaledImgH, false, options, 0, "")
+	if layout.PageNumbers {
+		stampPageNumber(pdf, page.Page)
+	}
+	bookmarkPage(pdf, page)
+}
+
+// WriteCombinedPDF adds the side-by-side images of the pages to a PDF, each on a page with the exact size of the image.
+// The image paths are relative to dir, or name images of layout.Images.
+func WriteCombinedPDF(dir string, pages []PageResult, output string, layout Layout) error {
+	// Create a new PDF for the combined images, whose print size is only used by the summary page
+	pdf := gofpdf.New(layout.orientation(0, 0), "mm", layout.printSize(pages), "")
+	if layout.Summary != nil {
+		addSummaryPage(pdf, layout.Summary)
+	}
+
+	// Loop through all combined images and add them to the PDF
+	for _, page := range pages {
+		if page.CombinedImage == "" {
+			continue
+		}
+		combinedImgPath := filepath.Join(dir, page.CombinedImage)
+		imgOptions := gofpdf.ImageOptions{
+			ImageType:             "",
+			ReadDpi:               true,
+			AllowNegativePosition: true,
+		}
+		name, imgInfo := layout.registerImage(pdf, combinedImgPath, imgOptions)
+		if imgInfo == nil {
+			break
+		}
+
+		// Convert the image dimensions from points to millimeters (assuming 72 dpi)
+		imgWidthMM := imgInfo.Width() / 2.83465
+		imgHeightMM := imgInfo.Height() / 2.83465
+
+		// Add a new page with the exact size of the image
+		pdf.AddPageFormat("P", gofpdf.SizeType{Wd: imgWidthMM, Ht: imgHeightMM})
+
+		// Add the image to the PDF
+		pdf.ImageOptions(name, 0, 0, imgWidthMM, imgHeightMM, false, imgOptions, 0, "")
+		if layout.PageNumbers {
+			stampPageNumber(pdf, page.Page)
+		}
+		bookmarkPage(pdf, page)
+	}
+
+	// Save the PDF
+	return writePDF(pdf, output)
+}
+
+// min returns the smaller of two float64 numbers.
+func min(a, b float64) float64 {
+	return math.Min(a, b)
+}
@@ -0,0 +1,69 @@
+package pdfdiff
+
+import "math"
+
+// scaleMask holds the pixels that differ between the pages compared at another resolution
+type scaleMask struct {
+	changed       []bool
+	width, height int
+	scale         float64 // resolution of the mask divided by the resolution of the comparison
+}
+
+// confirms reports whether a pixel of the comparison, or one next to it, also differs at the resolution of the mask
+func (m scaleMask) confirms(x, y int) bool {
+	mx, my := int(math.Floor(float64(x)*m.scale)), int(math.Floor(float64(y)*m.scale))
+	if mx < 0 || my < 0 || mx >= m.width || my >= m.height {
+		return false
+	}
+	return m.changed[my*m.width+mx]
+}
+
+// consistent reports whether a pixel that differs at the resolution of the comparison also differs at every other
+// resolution of Options.MultiDPI
+func consistent(masks []scaleMask, x, y int) bool {
+	for _, m := range masks {
+		if !m.confirms(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// scaleMasks renders the pages compared together at the other resolutions of opts.MultiDPI and returns the pixels
+// that differ at each of them, grown by a pixel so a change drawn one pixel further at another resolution still matches
+func scaleMasks(opts *Options, page int, doc1 Document, page1 int, doc2 Document, page2 int) ([]scaleMask, error) {
+	var masks []scaleMask
+	for _, dpi := range opts.MultiDPI {
+		if dpi == opts.DPI {
+			continue
+		}
+		img1, _, err := opts.Pipeline.render(doc1, page1, dpi)
+		if err != nil {
+			return nil, err
+		}
+		img2, _, err := opts.Pipeline.render(doc2, page2, dpi)
+		if err != nil {
+			return nil, err
+		}
+		if img1, img2, err = opts.Pipeline.preprocess(page, img1, img2); err != nil {
+			return nil, err
+		}
+		b := img1.Bounds()
+		changed := make([]bool, b.Dx()*b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if pixelsDiffer(img1.At(x, y), img2.At(x, y), opts.Threshold) &&
+					!(opts.IgnoreAntialiasing && antialiased(img1, img2, x, y, opts.Threshold)) {
+					changed[(y-b.Min.Y)*b.Dx()+x-b.Min.X] = true
+				}
+			}
+		}
+		masks = append(masks, scaleMask{
+			changed: dilateMask(changed, b.Dx(), b.Dy(), 1),
+			width:   b.Dx(),
+			height:  b.Dy(),
+			scale:   dpi / opts.DPI,
+		})
+	}
+	return masks, nil
+}
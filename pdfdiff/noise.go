@@ -0,0 +1,45 @@
+package pdfdiff
+
+import "image"
+
+// noiseMargin is the share of the width and height of the page, on every side, presumed unchanged and used to
+// estimate the noise of a page: headers and footers rarely change, and nothing else is there in most documents
+const noiseMargin = 0.05
+
+// noisePercentile of the channel differences of the margins is the noise of the page, which leaves out the few
+// pixels of a real change such as a new page number
+const noisePercentile = 0.99
+
+// maxAdaptiveThreshold caps the estimated threshold, so margins that really changed do not hide the other changes
+const maxAdaptiveThreshold = 64
+
+// estimateNoise returns the largest color channel difference of the margins of both pages, ignoring the top
+// percentile, as the threshold that absorbs the noise of the page
+func estimateNoise(img1, img2 image.Image) int {
+	b := img1.Bounds().Intersect(img2.Bounds())
+	mx, my := int(float64(b.Dx())*noiseMargin), int(float64(b.Dy())*noiseMargin)
+	inner := image.Rect(b.Min.X+mx, b.Min.Y+my, b.Max.X-mx, b.Max.Y-my)
+	var histogram [256]int
+	total := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// Skip the middle of the page
+			if y >= inner.Min.Y && y < inner.Max.Y && x == inner.Min.X {
+				x = inner.Max.X - 1
+				continue
+			}
+			histogram[pixelDelta(img1.At(x, y), img2.At(x, y))]++
+			total++
+		}
+	}
+	count := 0
+	for delta, n := range histogram {
+		if count += n; float64(count) >= noisePercentile*float64(total) {
+			if delta > maxAdaptiveThreshold {
+				return maxAdaptiveThreshold
+			}
+			return delta
+		}
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+package pdfdiff
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"path/filepath"
+)
+
+// RoundColors are the colors of the changes of every round of a comparison of several documents: the changes from
+// the first document to the second are drawn in the first color, those from the second to the third in the next one,
+// and so on. The colors repeat after the last one.
+var RoundColors = []string{"#FF0000", "#0000FF", "#00A000", "#FF8000", "#A000FF", "#00A0A0"}
+
+// MultiReport is the result of a comparison of several documents, e.g. the rounds of a contract negotiation
+type MultiReport struct {
+	// SchemaVersion is the version of the JSON schema the report conforms to, see Schema
+	SchemaVersion string   `json:"schema_version"`
+	Files         []string `json:"files"`
+	// Pairs are the comparisons of every pair of documents, the matrix of their differences
+	Pairs []PairReport `json:"pairs"`
+	// Rounds are the pages of the last document with the changes of every round drawn in its color of RoundColors
+	Rounds []PageResult `json:"rounds"`
+	// Dir is the directory the page image paths are relative to
+	Dir string `json:"-"`
+}
+
+// PairReport is the comparison of two of the documents of a MultiReport
+type PairReport struct {
+	// Doc1 and Doc2 are the indexes of the documents in the Files of the report, Doc1 before Doc2
+	Doc1   int    `json:"doc1"`
+	Doc2   int    `json:"doc2"`
+	Report Report `json:"report"`
+}
+
+// Changed reports whether any two of the documents differ
+func (r MultiReport) Changed() bool {
+	for _, pair := range r.Pairs {
+		if pair.Report.Changed() {
+			return true
+		}
+	}
+	return false
+}
+
+// Pair returns the comparison of the documents with the given indexes, in either order
+func (r MultiReport) Pair(doc1, doc2 int) (PairReport, bool) {
+	for _, pair := range r.Pairs {
+		if (pair.Doc1 == doc1 && pair.Doc2 == doc2) || (pair.Doc1 == doc2 && pair.Doc2 == doc1) {
+			return pair, true
+		}
+	}
+	return PairReport{}, false
+}
+
+// CompareDocuments compares every pair of the documents, in the order they were written, with the given options.
+// The images of a pair are written with the prefix doc1_vs_doc3_ after Options.Prefix. The pages of the documents
+// are also compared in position order round after round, and the changes of every round are drawn on the pages of
+// the last document in its color of RoundColors, to the images rounds_N.png.
+// Password1, Password2 and InMemory cannot be used.
+func CompareDocuments(ctx context.Context, files []string, opts Options) (MultiReport, error) {
+	report := MultiReport{SchemaVersion: SchemaVersion, Files: files, Dir: opts.OutputDir}
+	if report.Dir == "" {
+		report.Dir = "."
+	}
+	var problems optionProblems
+	if len(files) < 2 {
+		problems.add("at least two documents are needed, %d given", len(files))
+	}
+	if opts.InMemory {
+		problems.add("InMemory cannot be used to compare several documents, whose round images are written to OutputDir")
+	}
+	if opts.Password1 != "" || opts.Password2 != "" {
+		problems.add("Password1 and Password2 cannot be used to compare several documents: decrypt them first")
+	}
+	if err := problems.err(); err != nil {
+		return report, err
+	}
+
+	// The progress of every pair and of the rounds is reported as a share of the whole run
+	steps := len(files)*(len(files)-1)/2 + 1
+	step := 0
+	progress := func(completed, total int) {
+		if opts.Progress != nil && total > 0 {
+			opts.Progress(step*total+completed, steps*total)
+		}
+	}
+	for i := range files {
+		for j := i + 1; j < len(files); j++ {
+			pairOpts := opts
+			pairOpts.Prefix = fmt.Sprintf("%sdoc%d_vs_doc%d_", opts.Prefix, i+1, j+1)
+			pairOpts.Progress = progress
+			pair, err := Compare(ctx, files[i], files[j], pairOpts)
+			if err != nil {
+				return report, err
+			}
+			report.Pairs = append(report.Pairs, PairReport{Doc1: i, Doc2: j, Report: pair})
+			step++
+		}
+	}
+
+	rounds, err := compareRounds(ctx, files, opts, progress)
+	report.Rounds = rounds
+	return report, err
+}
+
+// compareRounds draws the changes of every round on the pages of the last document
+func compareRounds(ctx context.Context, files []string, opts Options, progress func(completed, total int)) ([]PageResult, error) {
+	highlights := make([]color.RGBA, len(files)-1)
+	for k := range highlights {
+		c, err := ParseHexColor(RoundColors[k%len(RoundColors)])
+		if err != nil {
+			return nil, err
+		}
+		highlights[k] = c
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	dir := opts.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+
+	docs := make([]Document, len(files))
+	numPages := 0
+	for i, file := range files {
+		doc, err := Open(file)
+		if err != nil {
+			return nil, &InputError{File: file, Err: err}
+		}
+		defer doc.Close()
+		if err := Reflow(doc, opts.Reflow); err != nil {
+			return nil, &InputError{File: file, Err: err}
+		}
+		docs[i] = doc
+		numPages = max(numPages, doc.NumPage())
+	}
+
+	var pages []PageResult
+	for page := 0; page < numPages; page++ {
+		if err := ctx.Err(); err != nil {
+			return pages, err
+		}
+		imgs := make([]image.Image, len(docs))
+		var bounds image.Rectangle
+		for i, doc := range docs {
+			img, _, err := opts.Pipeline.render(doc, page, dpi)
+			if err != nil {
+				return pages, &PageError{Page: page, Err: fmt.Errorf("%s: %w", files[i], err)}
+			}
+			imgs[i] = img
+			bounds = bounds.Union(img.Bounds())
+		}
+
+		// A later round overwrites the changes of the earlier ones on the pixels changed several times
+		last := imgs[len(imgs)-1]
+		out := image.NewRGBA(bounds)
+		changed := 0
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				base := roundPixel(last, x, y)
+				out.Set(x, y, base)
+				differs := false
+				for k, highlight := range highlights {
+					if pixelsDiffer(roundPixel(imgs[k], x, y), roundPixel(imgs[k+1], x, y), opts.Threshold) {
+						out.Set(x, y, highlightColor(highlight, base))
+						differs = true
+					}
+				}
+				if differs {
+					changed++
+				}
+			}
+		}
+
+		result := PageResult{Page: page, Changed: changed > 0, ChangedPixels: changed, Width: bounds.Dx(), Height: bounds.Dy()}
+		if area := bounds.Dx() * bounds.Dy(); area > 0 {
+			result.PercentChanged = float64(changed) / float64(area) * 100
+		}
+		if changed > 0 || !opts.SkipIdentical {
+			name := roundsImageName(opts.Prefix, page)
+			if err := opts.writeArtifact(page, out, filepath.Join(dir, name)); err != nil {
+				return pages, &PageError{Page: page, Err: err}
+			}
+			result.DiffImage = name
+		}
+		pages = append(pages, result)
+		progress(page+1, numPages)
+	}
+	return pages, nil
+}
+
+// roundPixel returns the pixel of a page, white outside a page smaller than the others
+func roundPixel(img image.Image, x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}.In(img.Bounds())) {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return img.At(x, y)
+}
+
+// roundsImageName returns the file name of the image of the changes of every round of an output page
+func roundsImageName(prefix string, page int) string {
+	return fmt.Sprintf("%srounds_%d.png", prefix, page)
+}
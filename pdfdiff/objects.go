@@ -0,0 +1,613 @@
+package pdfdiff
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IsObjectURL reports whether a path is the URL of an object to download or upload: an http:// or https:// URL,
+// s3://bucket/key for Amazon S3 (or a compatible store) or gs://bucket/object for Google Cloud Storage
+func IsObjectURL(p string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://", "gs://"} {
+		if strings.HasPrefix(p, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// DownloadObject downloads the object at a URL to dir, under the last element of its path, which tells its format,
+// and returns the path of the file. S3 and GCS objects are read with the credentials of the environment, as found by
+// their SDKs; http(s) URLs with $PDFDIFF_HTTP_TOKEN as a bearer token, if set.
+func DownloadObject(ctx context.Context, rawURL, dir string) (string, error) {
+	req, err := objectRequest(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := sendObjectRequest(req, rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	u, _ := url.Parse(rawURL)
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", fmt.Errorf("%s does not name a file", rawURL)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, name)
+	out, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, resp.Body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", rawURL, err)
+	}
+	return file, nil
+}
+
+// UploadObject uploads a file to the URL of an object, with PUT for http(s) URLs
+func UploadObject(ctx context.Context, file, rawURL string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	req, err := objectRequest(ctx, http.MethodPut, rawURL, data)
+	if err != nil {
+		return err
+	}
+	resp, err := sendObjectRequest(req, rawURL)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// objectRequest returns the authenticated request of a method on the object at a URL
+func objectRequest(ctx context.Context, method, rawURL string, body []byte) (*http.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid S3 URL %s, it should be s3://bucket/key", rawURL)
+		}
+		return s3Request(ctx, method, u.Host, key, body)
+	case "gs":
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("invalid GCS URL %s, it should be gs://bucket/object", rawURL)
+		}
+		return gcsRequest(ctx, method, u.Host, key, body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("PDFDIFF_HTTP_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// sendObjectRequest sends a request on an object, and fails unless it succeeds
+func sendObjectRequest(req *http.Request, rawURL string) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The stores explain the failure in the body, e.g. an expired token
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s: %s %s", req.Method, rawURL, resp.Status, bytes.TrimSpace(detail))
+	}
+	return resp, nil
+}
+
+// awsCredentials are the credentials of an AWS account or role. The temporary credentials of ECS and EC2 have an
+// Expiration.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string `json:"Token"`
+	Expiration      time.Time
+}
+
+// awsCreds caches the credentials of S3 for the process, or that there are none, until they expire
+var awsCreds struct {
+	sync.Mutex
+	found bool
+	creds *awsCredentials
+}
+
+// s3Request returns the request of a method on an S3 object, signed with Signature Version 4. The endpoint of
+// S3-compatible stores, such as MinIO, is given by $AWS_ENDPOINT_URL_S3 or $AWS_ENDPOINT_URL, with path-style URLs.
+func s3Request(ctx context.Context, method, bucket, key string, body []byte) (*http.Request, error) {
+	region := awsRegion()
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_S3")
+	if endpoint == "" {
+		endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	var target string
+	switch {
+	case endpoint != "":
+		target = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + s3EscapePath(key)
+	case strings.Contains(bucket, "."):
+		// The certificate of the virtual host does not cover the bucket names with dots
+		target = fmt.Sprintf("https://s3.%s.amazonaws.com/%s/%s", region, bucket, s3EscapePath(key))
+	default:
+		target = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, s3EscapePath(key))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPut {
+		if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+			req.Header.Set("Content-Type", t)
+		}
+	}
+	creds, err := cachedAWSCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if creds != nil {
+		signS3(req, body, *creds, region, time.Now())
+	}
+	return req, nil
+}
+
+// s3EscapePath escapes an object key for the URL and the canonical request, every byte but the unreserved characters
+// of RFC 3986 and the slashes, as Signature Version 4 expects: S3 escapes "$" or "=" of the key and would not find
+// the same signature otherwise
+func s3EscapePath(key string) string {
+	return awsEscape(key, true)
+}
+
+// awsEscape escapes a string like the URI encoding of Signature Version 4, keeping the slashes if asked
+func awsEscape(s string, slashes bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~', c == '/' && slashes:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signS3 signs a request to S3 with Signature Version 4, over its Host and x-amz-* headers and the other headers
+// already set
+func signS3(req *http.Request, body []byte, creds awsCredentials, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payload[:])}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query of a request sorted by name, as signed by Signature Version 4
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, awsEscape(name, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsRegion returns the region of the S3 buckets: $AWS_REGION, $AWS_DEFAULT_REGION, the region of the profile in
+// the AWS config file, or us-east-1
+func awsRegion() string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(name); region != "" {
+			return region
+		}
+	}
+	config := os.Getenv("AWS_CONFIG_FILE")
+	if home, err := os.UserHomeDir(); config == "" && err == nil {
+		config = filepath.Join(home, ".aws", "config")
+	}
+	section := "default"
+	if profile := awsProfile(); profile != "default" {
+		section = "profile " + profile
+	}
+	if region := readINI(config, section)["region"]; region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+// awsProfile returns the profile of the AWS credentials, $AWS_PROFILE or default
+func awsProfile() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// cachedAWSCredentials returns the credentials of S3 found by the first request of the process, so the metadata
+// services are not asked again for every object, even when there are none. Temporary credentials are found again
+// five minutes before they expire.
+func cachedAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	awsCreds.Lock()
+	defer awsCreds.Unlock()
+	if awsCreds.found && (awsCreds.creds == nil || awsCreds.creds.Expiration.IsZero() || time.Until(awsCreds.creds.Expiration) > 5*time.Minute) {
+		return awsCreds.creds, nil
+	}
+	creds, err := findAWSCredentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	awsCreds.found, awsCreds.creds = true, creds
+	return creds, nil
+}
+
+// findAWSCredentials finds the credentials of S3 in the order of the AWS SDKs: the environment, the shared
+// credentials file, the container credentials of ECS and the instance profile of EC2. Without any, the requests
+// are sent unsigned, for public buckets.
+func findAWSCredentials(ctx context.Context) (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{AccessKeyID: id, SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"), SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if home, err := os.UserHomeDir(); file == "" && err == nil {
+		file = filepath.Join(home, ".aws", "credentials")
+	}
+	if values := readINI(file, awsProfile()); values["aws_access_key_id"] != "" {
+		return &awsCredentials{AccessKeyID: values["aws_access_key_id"], SecretAccessKey: values["aws_secret_access_key"], SessionToken: values["aws_session_token"]}, nil
+	}
+	container := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		container = "http://169.254.170.2" + uri
+	}
+	if container != "" {
+		var header map[string]string
+		if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+			header = map[string]string{"Authorization": token}
+		}
+		var creds awsCredentials
+		if err := getMetadata(ctx, http.MethodGet, container, header, &creds); err != nil {
+			return nil, err
+		}
+		return &creds, nil
+	}
+	if os.Getenv("AWS_EC2_METADATA_DISABLED") == "true" {
+		return nil, nil
+	}
+	// IMDSv2 answers within a second on EC2, and not at all elsewhere
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	imds := "http://169.254.169.254"
+	if endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); endpoint != "" {
+		imds = strings.TrimSuffix(endpoint, "/")
+	}
+	imds += "/latest"
+	var token string
+	if err := getMetadata(ctx, http.MethodPut, imds+"/api/token", map[string]string{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": "300"}, &token); err != nil {
+		return nil, nil
+	}
+	header := map[string]string{"X-Aws-Ec2-Metadata-Token": token}
+	var role string
+	if err := getMetadata(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/", header, &role); err != nil {
+		return nil, nil
+	}
+	var creds awsCredentials
+	if err := getMetadata(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/"+strings.TrimSpace(role), header, &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// getMetadata reads the answer of a metadata service, as text for a string and as JSON otherwise
+func getMetadata(ctx context.Context, method, target string, header map[string]string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := sendObjectRequest(req, target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if s, ok := v.(*string); ok {
+		*s = string(data)
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// readINI returns the keys of a section of an INI file such as ~/.aws/credentials, none if it cannot be read
+func readINI(file, section string) map[string]string {
+	values := make(map[string]string)
+	f, err := os.Open(file)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+	in := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "["):
+			in = strings.TrimSpace(strings.Trim(line, "[]")) == section
+		case in:
+			if key, value, ok := strings.Cut(line, "="); ok {
+				values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+	}
+	return values
+}
+
+// gcsRequest returns the request of a method on a GCS object, with the JSON API. $STORAGE_EMULATOR_HOST, as for the
+// Cloud Storage client libraries, sends the unauthenticated requests to an emulator instead.
+func gcsRequest(ctx context.Context, method, bucket, object string, body []byte) (*http.Request, error) {
+	base := "https://storage.googleapis.com"
+	emulator := os.Getenv("STORAGE_EMULATOR_HOST")
+	if emulator != "" {
+		base = strings.TrimSuffix(emulator, "/")
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	}
+	var target string
+	if method == http.MethodPut {
+		method = http.MethodPost
+		target = fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", base, url.PathEscape(bucket), url.QueryEscape(object))
+	} else {
+		target = fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", base, url.PathEscape(bucket), url.PathEscape(object))
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == http.MethodPost {
+		contentType := mime.TypeByExtension(path.Ext(object))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	if emulator != "" {
+		return req, nil
+	}
+	token, err := gcsToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// gcsScope is the OAuth scope of the requests to GCS
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// gcsTokens caches the access token of GCS until it expires, or that there is none for the process
+var gcsTokens struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+	none    bool
+}
+
+// gcsToken returns the access token of GCS, found in the order of the Application Default Credentials:
+// $GOOGLE_OAUTH_ACCESS_TOKEN, the credentials file of $GOOGLE_APPLICATION_CREDENTIALS or of gcloud auth
+// application-default login (a service account key or a user's refresh token), or the metadata server of Google
+// Cloud. Without any, the requests are sent unauthenticated, for public buckets.
+func gcsToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	gcsTokens.Lock()
+	defer gcsTokens.Unlock()
+	if gcsTokens.none || gcsTokens.token != "" && time.Now().Before(gcsTokens.expires) {
+		return gcsTokens.token, nil
+	}
+
+	var answer struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if file == "" {
+		file = gcloudCredentialsFile()
+	}
+	data, err := os.ReadFile(file)
+	switch {
+	case err == nil:
+		var creds struct {
+			Type         string `json:"type"`
+			ClientEmail  string `json:"client_email"`
+			PrivateKey   string `json:"private_key"`
+			TokenURI     string `json:"token_uri"`
+			ClientID     string `json:"client_id"`
+			ClientSecret string `json:"client_secret"`
+			RefreshToken string `json:"refresh_token"`
+		}
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return "", fmt.Errorf("%s: %v", file, err)
+		}
+		if creds.TokenURI == "" {
+			creds.TokenURI = "https://oauth2.googleapis.com/token"
+		}
+		form := url.Values{}
+		switch creds.Type {
+		case "service_account":
+			assertion, err := serviceAccountJWT(creds.ClientEmail, creds.PrivateKey, creds.TokenURI)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", file, err)
+			}
+			form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+			form.Set("assertion", assertion)
+		case "authorized_user":
+			form.Set("grant_type", "refresh_token")
+			form.Set("client_id", creds.ClientID)
+			form.Set("client_secret", creds.ClientSecret)
+			form.Set("refresh_token", creds.RefreshToken)
+		default:
+			return "", fmt.Errorf("%s: credentials of type %q are not supported, use a service account key or gcloud auth application-default login", file, creds.Type)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, creds.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := sendObjectRequest(req, creds.TokenURI)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+			return "", fmt.Errorf("%s: %v", creds.TokenURI, err)
+		}
+	case !os.IsNotExist(err):
+		return "", err
+	default:
+		// The metadata server answers within a second on Google Cloud, and not at all elsewhere
+		host := os.Getenv("GCE_METADATA_HOST")
+		if host == "" {
+			host = "metadata.google.internal"
+		}
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		target := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/token"
+		if err := getMetadata(ctx, http.MethodGet, target, map[string]string{"Metadata-Flavor": "Google"}, &answer); err != nil {
+			// Off Google Cloud, the next requests do not wait for the metadata server again
+			gcsTokens.none = true
+			return "", nil
+		}
+	}
+	// The token is renewed a minute before it expires
+	gcsTokens.token = answer.AccessToken
+	gcsTokens.expires = time.Now().Add(time.Duration(answer.ExpiresIn)*time.Second - time.Minute)
+	return answer.AccessToken, nil
+}
+
+// gcloudCredentialsFile returns the file of the credentials written by gcloud auth application-default login
+func gcloudCredentialsFile() string {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, "application_default_credentials.json")
+	}
+	if dir := os.Getenv("APPDATA"); dir != "" {
+		return filepath.Join(dir, "gcloud", "application_default_credentials.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// serviceAccountJWT returns the signed assertion a service account exchanges for an access token
+func serviceAccountJWT(email, privateKey, audience string) (string, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", fmt.Errorf("the private key of the service account is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return "", err
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("the private key of the service account is not an RSA key")
+	}
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{"iss": email, "scope": gcsScope, "aud": audience,
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix()})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
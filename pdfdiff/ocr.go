@@ -0,0 +1,166 @@
+package pdfdiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ocrCommand is the Tesseract command line tool reading the text of scanned pages. It is only needed with the OCR
+// option, so the tool builds and runs without it.
+const ocrCommand = "tesseract"
+
+// ocrMargin grows the box of an unchanged word, in pixels, for the anti-aliased edges and the jitter of the scans
+const ocrMargin = 2
+
+// ocrWord is a word read by the OCR, with its box in pixels of the page
+type ocrWord struct {
+	text string
+	box  image.Rectangle
+}
+
+// checkOCR verifies that the OCR command can be run
+func checkOCR() error {
+	if _, err := exec.LookPath(ocrCommand); err != nil {
+		return fmt.Errorf("OCR needs the %s command, install Tesseract or leave OCR off", ocrCommand)
+	}
+	return nil
+}
+
+// ocrPage reads the words of a page image with Tesseract, in reading order
+func ocrPage(img image.Image, lang string, dpi float64) ([]ocrWord, error) {
+	args := []string{"--dpi", strconv.Itoa(int(dpi))}
+	if lang != "" {
+		args = append(args, "-l", lang)
+	}
+	out, err := tesseract(img, append(args, "tsv")...)
+	if err != nil {
+		return nil, err
+	}
+	return parseOCRWords(out, img.Bounds().Min), nil
+}
+
+// ocrScript returns the script of a page image found by the orientation and script detection of Tesseract, e.g.
+// Latin or Cyrillic, or an empty string if it cannot tell or its osd pack is not installed
+func ocrScript(img image.Image, dpi float64) string {
+	out, err := tesseract(img, "--dpi", strconv.Itoa(int(dpi)), "--psm", "0")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if script, ok := strings.CutPrefix(line, "Script:"); ok {
+			return strings.TrimSpace(script)
+		}
+	}
+	return ""
+}
+
+// tesseract runs Tesseract on a page image with the arguments, and returns its output
+func tesseract(img image.Image, args ...string) ([]byte, error) {
+	f, err := os.CreateTemp("", "pdfdiff-ocr-*.png")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(ocrCommand, append([]string{f.Name(), "stdout"}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", ocrCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// parseOCRWords reads the words of the TSV output of Tesseract: level, page, block, paragraph, line and word
+// numbers, left, top, width, height, confidence and text, with a header line
+func parseOCRWords(tsv []byte, origin image.Point) []ocrWord {
+	var words []ocrWord
+	scanner := bufio.NewScanner(bytes.NewReader(tsv))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		// Level 5 lines are words, and the words Tesseract is not confident about at all have a confidence of -1
+		if len(fields) < 12 || fields[0] != "5" || strings.HasPrefix(fields[10], "-") {
+			continue
+		}
+		text := strings.TrimSpace(fields[11])
+		if text == "" {
+			continue
+		}
+		var n [4]int
+		valid := true
+		for k := range n {
+			v, err := strconv.Atoi(fields[6+k])
+			if err != nil {
+				valid = false
+				break
+			}
+			n[k] = v
+		}
+		if valid {
+			box := image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]).Add(origin)
+			words = append(words, ocrWord{text: text, box: box})
+		}
+	}
+	return words
+}
+
+// compareOCRWords compares the words read on two pages. It returns the words inserted and deleted, and the areas
+// of the words found at the same place on both pages, whose pixels need not be compared. Words that moved, and words
+// overlapping a changed word, are left to the pixel comparison.
+func compareOCRWords(words1, words2 []ocrWord) ([]TextChange, []image.Rectangle) {
+	a, b := make([]string, len(words1)), make([]string, len(words2))
+	for i, w := range words1 {
+		a[i] = w.text
+	}
+	for j, w := range words2 {
+		b[j] = w.text
+	}
+	edits := wordEdits(a, b)
+
+	var changed []image.Rectangle
+	for _, e := range edits {
+		switch e.op {
+		case "delete":
+			changed = append(changed, words1[e.i].box)
+		case "insert":
+			changed = append(changed, words2[e.j].box)
+		}
+	}
+	var unchanged []image.Rectangle
+	for _, e := range edits {
+		if e.op != "equal" {
+			continue
+		}
+		box1, box2 := words1[e.i].box.Inset(-ocrMargin), words2[e.j].box.Inset(-ocrMargin)
+		if !box1.Overlaps(box2) {
+			continue
+		}
+		box := box1.Union(box2)
+		overlaps := false
+		for _, r := range changed {
+			if box.Overlaps(r) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			unchanged = append(unchanged, box)
+		}
+	}
+	return textChanges(edits, a, b), unchanged
+}
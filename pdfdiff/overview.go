@@ -0,0 +1,244 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/phpdave11/gofpdf"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// overviewFormat is the A format of the sheet of an overview, for a wall print
+	overviewFormat = "A0"
+	// overviewDPI is the resolution of the thumbnails of an overview, and of the overviews written as images
+	overviewDPI = 100.0
+	// overviewSaturation is the percentage of changed pixels from which the pages of an overview are tinted red
+	overviewSaturation = 10.0
+	// overviewTintOpacity is the opacity of the tint over the difference images of the changed pages
+	overviewTintOpacity = 0.3
+)
+
+// The layout of an overview sheet, in mm
+const (
+	overviewMargin = 15.0
+	overviewTitle  = 22.0
+	overviewLabel  = 10.0
+	overviewGap    = 5.0
+	overviewFrame  = 2.0
+)
+
+// overviewLegend explains the tint of the pages of an overview
+const overviewLegend = "Tinted from yellow for the slightest changes to red for 10% of changed pixels or more, and the missing pages"
+
+// overviewGrid places the pages of an overview on its sheet, all in mm
+type overviewGrid struct {
+	width, height float64
+	cols, rows    int
+	// cellW and cellH are the box of the thumbnail of a page, above its label
+	cellW, cellH float64
+}
+
+// newOverviewGrid returns the grid of n pages of the given aspect on the overview sheet, in the orientation and with
+// the number of columns that show the pages the largest
+func newOverviewGrid(n int, pageW, pageH float64) overviewGrid {
+	w, h, _ := formatSize(overviewFormat)
+	var best overviewGrid
+	bestScale := -1.0
+	for _, sheet := range [][2]float64{{w, h}, {h, w}} {
+		areaW, areaH := sheet[0]-2*overviewMargin, sheet[1]-2*overviewMargin-overviewTitle
+		for cols := 1; cols <= n; cols++ {
+			rows := (n + cols - 1) / cols
+			cellW := (areaW - float64(cols-1)*overviewGap) / float64(cols)
+			cellH := (areaH-float64(rows-1)*overviewGap)/float64(rows) - overviewLabel
+			if scale := math.Min(cellW/pageW, cellH/pageH); cellH > 0 && scale > bestScale {
+				best, bestScale = overviewGrid{width: sheet[0], height: sheet[1], cols: cols, rows: rows, cellW: cellW, cellH: cellH}, scale
+			}
+		}
+	}
+	return best
+}
+
+// cell returns the top left corner of the box of the thumbnail of the nth page
+func (g overviewGrid) cell(n int) (float64, float64) {
+	col, row := n%g.cols, n/g.cols
+	return overviewMargin + float64(col)*(g.cellW+overviewGap), overviewMargin + overviewTitle + float64(row)*(g.cellH+overviewLabel+overviewGap)
+}
+
+// WriteOverview writes all the pages of the comparison on a single A0 sheet, to print and pin on a wall: a grid of
+// their difference images, scaled down, tinted and framed by how much they changed and labelled with their number
+// and their share of changed pixels. The format depends on the extension of the output: .pdf for a PDF page, or an
+// image otherwise, e.g. overview.png.
+func WriteOverview(report Report, output string) error {
+	if len(report.Pages) == 0 {
+		return fmt.Errorf("the comparison has no pages")
+	}
+	// The pages are fitted in cells of the aspect of the largest one
+	pageW, pageH := 0.0, 0.0
+	for _, page := range report.Pages {
+		pageW, pageH = math.Max(pageW, float64(page.Width)), math.Max(pageH, float64(page.Height))
+	}
+	if pageW == 0 || pageH == 0 {
+		pageW, pageH, _ = formatSize("A4")
+	}
+	grid := newOverviewGrid(len(report.Pages), pageW, pageH)
+	boxW, boxH := overviewPixels(grid.cellW), overviewPixels(grid.cellH)
+
+	thumbs := make([]image.Image, len(report.Pages))
+	labels := make([]string, len(report.Pages))
+	for n, page := range report.Pages {
+		var err error
+		if thumbs[n], err = overviewThumbnail(report, page, boxW, boxH); err != nil {
+			return err
+		}
+		labels[n] = fmt.Sprintf("Page %d: %s", page.Page+1, pageStatus(page))
+	}
+	title := fmt.Sprintf("%s vs %s: %d of %d pages changed", report.File1, report.File2, len(report.ChangedPages()), len(report.Pages))
+
+	if strings.ToLower(filepath.Ext(output)) == ".pdf" {
+		return writePDFOverview(grid, title, thumbs, labels, output)
+	}
+	return writeImageOverview(grid, title, thumbs, labels, output)
+}
+
+// overviewTint returns the tint of a page of an overview, from yellow to red by its share of changed pixels, and
+// false for the unchanged pages
+func overviewTint(page PageResult) (color.RGBA, bool) {
+	if !page.Changed {
+		return color.RGBA{}, false
+	}
+	t := 1.0
+	if page.MissingIn == 0 {
+		t = math.Min(1, page.PercentChanged/overviewSaturation)
+	}
+	return heatColor(128 + int(t*127)), true
+}
+
+// overviewThumbnail returns the difference image of a page scaled down to fit a box of the given size in pixels,
+// tinted and framed with the tint of the page, or a blank page for the pages without a difference image, such as
+// the identical pages with SkipIdentical
+func overviewThumbnail(report Report, page PageResult, width, height int) (image.Image, error) {
+	var img *image.NRGBA
+	if page.DiffImage != "" {
+		diff, err := openImage(report.Dir, report.Images, page.DiffImage)
+		if err != nil {
+			return nil, err
+		}
+		img = imaging.Fit(diff, width, height, imaging.Lanczos)
+	} else {
+		w, h := width, height
+		if page.Width > 0 && page.Height > 0 {
+			scale := math.Min(float64(width)/float64(page.Width), float64(height)/float64(page.Height))
+			w, h = max(1, int(float64(page.Width)*scale)), max(1, int(float64(page.Height)*scale))
+		}
+		img = imaging.New(w, h, color.White)
+	}
+
+	frame, c := 1, color.RGBA{R: 160, G: 160, B: 160, A: 255}
+	if tint, ok := overviewTint(page); ok {
+		img = imaging.Overlay(img, imaging.New(img.Bounds().Dx(), img.Bounds().Dy(), tint), image.Point{}, overviewTintOpacity)
+		frame, c = overviewPixels(overviewFrame), tint
+	}
+	b := img.Bounds()
+	inner := b.Inset(frame)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !image.Pt(x, y).In(inner) {
+				img.Set(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
+
+// writePDFOverview writes the overview on a single PDF page
+func writePDFOverview(grid overviewGrid, title string, thumbs []image.Image, labels []string, output string) error {
+	pdf := gofpdf.New("P", "mm", "", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPageFormat("P", gofpdf.SizeType{Wd: grid.width, Ht: grid.height})
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 28)
+	pdf.Text(overviewMargin, overviewMargin+10, title)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.Text(overviewMargin, overviewMargin+18, overviewLegend)
+
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	for n, thumb := range thumbs {
+		var img bytes.Buffer
+		if err := png.Encode(&img, thumb); err != nil {
+			return err
+		}
+		name := fmt.Sprintf("overview%d", n)
+		pdf.RegisterImageOptionsReader(name, options, &img)
+		x, y := grid.cell(n)
+		w := float64(thumb.Bounds().Dx()) / overviewDPI * 25.4
+		h := float64(thumb.Bounds().Dy()) / overviewDPI * 25.4
+		pdf.ImageOptions(name, x+(grid.cellW-w)/2, y, w, h, false, options, 0, "")
+
+		// The labels of the narrow cells are set smaller
+		size := 14.0
+		pdf.SetFont("Helvetica", "", size)
+		for size > 4 && pdf.GetStringWidth(labels[n]) > grid.cellW {
+			size--
+			pdf.SetFontSize(size)
+		}
+		pdf.Text(x+(grid.cellW-pdf.GetStringWidth(labels[n]))/2, y+h+overviewLabel*0.6, labels[n])
+	}
+	return pdf.OutputFileAndClose(output)
+}
+
+// writeImageOverview writes the overview as an image of the sheet at overviewDPI, in the format of the extension of
+// the output
+func writeImageOverview(grid overviewGrid, title string, thumbs []image.Image, labels []string, output string) error {
+	sheet := image.NewRGBA(image.Rect(0, 0, overviewPixels(grid.width), overviewPixels(grid.height)))
+	draw.Draw(sheet, sheet.Bounds(), image.White, image.Point{}, draw.Src)
+	margin := overviewPixels(overviewMargin)
+	drawOverviewText(sheet, title, margin, margin, 4, sheet.Bounds().Dx()-2*margin)
+	drawOverviewText(sheet, overviewLegend, margin, margin+overviewPixels(14), 2, sheet.Bounds().Dx()-2*margin)
+
+	cellW := overviewPixels(grid.cellW)
+	for n, thumb := range thumbs {
+		x, y := grid.cell(n)
+		left, top := overviewPixels(x), overviewPixels(y)
+		at := image.Pt(left+(cellW-thumb.Bounds().Dx())/2, top)
+		draw.Draw(sheet, thumb.Bounds().Sub(thumb.Bounds().Min).Add(at), thumb, thumb.Bounds().Min, draw.Src)
+
+		scale := 2
+		if len(labels[n])*basicfont.Face7x13.Advance*scale > cellW {
+			scale = 1
+		}
+		textW := min(float64(len(labels[n])*basicfont.Face7x13.Advance*scale), float64(cellW))
+		drawOverviewText(sheet, labels[n], left+(cellW-int(textW))/2, at.Y+thumb.Bounds().Dy()+overviewPixels(2), scale, cellW)
+	}
+	return imaging.Save(sheet, output)
+}
+
+// drawOverviewText draws a line of text on an image at its top left corner, in a bitmap font enlarged scale times,
+// cut at width pixels
+func drawOverviewText(img *image.RGBA, text string, x, y, scale, width int) {
+	face := basicfont.Face7x13
+	line := image.NewRGBA(image.Rect(0, 0, max(1, len(text)*face.Advance), face.Height))
+	d := font.Drawer{Dst: line, Src: image.Black, Face: face, Dot: fixed.P(0, face.Ascent)}
+	d.DrawString(text)
+	scaled := imaging.Resize(line, line.Bounds().Dx()*scale, line.Bounds().Dy()*scale, imaging.NearestNeighbor)
+	r := scaled.Bounds()
+	if r.Dx() > width {
+		r.Max.X = r.Min.X + width
+	}
+	draw.Draw(img, r.Add(image.Pt(x, y)), scaled, image.Point{}, draw.Over)
+}
+
+// overviewPixels converts a length of the overview sheet in mm to pixels at overviewDPI
+func overviewPixels(mm float64) int {
+	return int(math.Round(mm / 25.4 * overviewDPI))
+}
@@ -0,0 +1,60 @@
+package pdfdiff
+
+import (
+	"context"
+	"sync"
+)
+
+// Pauser pauses and resumes a running comparison. When paused, the workers finish the page they are comparing
+// and wait before starting the next one. The zero value is not paused.
+type Pauser struct {
+	mu     sync.Mutex
+	paused bool
+	resume chan struct{} // closed when the comparison is resumed
+}
+
+// Pause stops the workers from starting new pages
+func (p *Pauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.resume = make(chan struct{})
+	}
+}
+
+// Resume lets the workers continue with the next pages
+func (p *Pauser) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		close(p.resume)
+	}
+}
+
+// Paused reports whether the comparison is paused
+func (p *Pauser) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
+
+// Wait blocks while the comparison is paused, or until the context is cancelled. A nil Pauser never blocks.
+func (p *Pauser) Wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	paused, resume := p.paused, p.resume
+	p.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,828 @@
+// Package pdfdiff compares two documents page by page and produces images that highlight the differences.
+//
+// The pdfdiff command line tool is a thin wrapper over this package, so services can embed the same
+// comparison instead of shelling out to the binary:
+//
+//	report, err := pdfdiff.Compare(ctx, "old.pdf", "new.pdf", pdfdiff.Options{OutputDir: "out"})
+//	if err != nil {
+//		return err
+//	}
+//	for _, page := range report.Pages {
+//		fmt.Println(page.Page, page.Changed, page.DiffImage)
+//	}
+package pdfdiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Options configures a comparison. The zero value compares every page with one worker per CPU
+// and writes the page images to the current directory.
+type Options struct {
+	// Offset is the number of pages to skip in the second document
+	Offset int
+	// StartOffset is the page of the first document where the offset starts
+	StartOffset int
+	// Align pairs the pages with AlignPages instead of by position, so inserted and deleted pages are found
+	// without an Offset. The output pages then follow the alignment.
+	Align bool
+	// Alignment is the page alignment to use, e.g. computed once for a comparison split between machines.
+	// It is computed when Align is set and Alignment is nil.
+	Alignment []PagePair
+	// From and To limit the comparison to the pages of the first document in [From, To), counted from 0,
+	// or to the output pages of an aligned comparison, so a large comparison can be split between machines.
+	// A zero To compares every page.
+	From, To int
+	// SkipIdentical does not write the difference image of pages without differences, or links it to the rendered
+	// page of the first document when PageImages is set, nor their side-by-side image. Such pages are listed in the
+	// report without a DiffImage.
+	SkipIdentical bool
+	// Threshold is the largest difference of any color channel (0-255) still considered equal, to ignore rendering noise.
+	// Zero only treats identical pixels as equal.
+	Threshold int
+	// AdaptiveThreshold raises the threshold of every page to the noise estimated from its margins, up to
+	// maxAdaptiveThreshold, so scans and rasterizer noise need no threshold tuned per document type
+	AdaptiveThreshold bool
+	// IgnoreAntialiasing ignores differences along glyph and line edges, where a differing pixel of each page
+	// matches a neighbouring pixel of the other page, as produced by different rasterizer versions
+	IgnoreAntialiasing bool
+	// MinRegionSize discards the changed regions of fewer changed pixels than a number of pixels, such as "20" or
+	// "20px", or than an area in square millimeters, such as "0.5mm2", so dust specks and the jitter of the
+	// rasterization do not mark a page as changed. The regions touching a critical region are kept.
+	MinRegionSize string
+	// DPI is the resolution the pages are rasterized at (Default: DefaultDPI). Higher values catch hairline changes,
+	// lower ones are faster and write smaller images.
+	DPI float64
+	// MultiDPI are other resolutions the pages are also compared at: a difference found at DPI is only reported
+	// where the pages also differ at every one of them, which filters out the artifacts of the rasterizer that
+	// appear at a single resolution
+	MultiDPI []float64
+	// RemoveWatermarks looks for a light watermark repeated on every page of one of the documents, such as "DRAFT",
+	// and paints it white before comparing, so it does not hide the real changes
+	RemoveWatermarks bool
+	// NormalizeBackground whitens the paper of both pages when their background colors differ, e.g. a scan on gray
+	// paper compared with the original, and reports the change once in the Background of the page
+	NormalizeBackground bool
+	// Descreen blurs the halftones of scanned print material on both pages before comparing, so a picture printed
+	// with another screen is not reported as changed
+	Descreen bool
+	// Segment splits the pages into text, raster image and vector graphic zones, read from the content of the
+	// documents, and reports the differences of every type of content in the Content of the page
+	Segment bool
+	// Charts compares the bars, lines and axes of the vector charts of both pages and reports their changes, such
+	// as a bar that grew 12%, in the Charts of the page
+	Charts bool
+	// Equations finds the equations of both pages, clusters of glyphs dense in math symbols, scripts and fraction
+	// bars, and compares them at a higher resolution with a relaxed tolerance on the position of their strokes, so a
+	// formula typeset again is not reported as changed. They are listed in the Equations of the page.
+	Equations bool
+	// Glyphs pairs the glyphs of the text of both pages and compares every pair, for font and typesetting
+	// regression testing: its shape, against the pixels of both renders, its baseline and its advance to the next
+	// glyph, so kerning and hinting changes are told apart from the glyphs inserted and deleted by content changes.
+	// The changes and their statistics are the Glyphs of the page and of the report.
+	Glyphs bool
+	// VectorOverlay, svg or dxf, compares the vector paths of the drawings of both pages, for technical drawings,
+	// and writes the paths added and removed in an overlay of that format for CAD tools, named like the difference
+	// images, e.g. vectors_0.dxf. The numbers of paths and the name of the overlay are the Vectors of the page.
+	VectorOverlay string
+	// Dimensions reads the measurements of technical drawings from the text of both pages, such as 120 mm, Ø30 or
+	// 45°, pairs them by their position and reports the values that changed, apart from the geometry of the
+	// drawing, in the Dimensions of the page
+	Dimensions bool
+	// TextDiff extracts the text of both pages and reports the words inserted and deleted in the TextChanges of the
+	// page, which the HTML report shows next to the difference image, and the page breaks and line breaks that
+	// moved in the Breaks of the report
+	TextDiff bool
+	// Typography finds the typographic defects of both documents, the widows, the orphans and the lines of text
+	// overflowing the page box, in the Typography of the report, and marks the ones the second document introduced
+	Typography bool
+	// OCR reads the text of the rendered pages with Tesseract instead, for scanned documents, and reports the words
+	// inserted and deleted in the TextChanges of the page. The pixels of the words found unchanged at the same place
+	// are not compared, so the scanner noise around them is not reported. The tesseract command must be installed.
+	OCR bool
+	// OCRLanguage is the Tesseract language of the documents, e.g. deu or eng+fra (Default: eng)
+	OCRLanguage string
+	// Language is the language of the text of the documents, for multilingual document sets: auto detects the
+	// dominant language of every page, a code such as deu sets it, and two codes such as eng,deu (or eng,auto) set
+	// the language of each document. The text compared with TextDiff and OCR is normalized for its language, e.g.
+	// the spaces of French punctuation, and split in characters for the languages written without spaces. OCR reads
+	// the pages with the Tesseract pack of their language, unless OCRLanguage is set. The languages are listed in
+	// the Languages of the pages and of the report.
+	Language string
+	// Preflight renders the first page of each document twice before comparing and reports the documents rendered
+	// differently in the NondeterministicIn of the report, e.g. because of fonts loaded from the system
+	Preflight bool
+	// Despeckle removes the specks of dust smaller than a period from both pages before comparing, for scanned inputs
+	Despeckle bool
+	// Workers is the number of pages compared concurrently (Default: CPU count)
+	Workers int
+	// SideBySide also writes an image with both pages next to each other for every page
+	SideBySide bool
+	// VerticalAlign stacks the pages of the side-by-side images vertically instead of horizontally
+	VerticalAlign bool
+	// PageImages also writes the rendered pages of both documents for every page, as used by the HTML report
+	PageImages bool
+	// RegionThumbnails, if positive, embeds a thumbnail of every changed region in the report, at most this many
+	// pixels wide and high, so a bot can show the changes without fetching the images
+	RegionThumbnails int
+	// ColorOld and ColorNew are the hex colors (RRGGBB or RRGGBBAA) of the changed pixels where the first or the
+	// second page is brighter (Default: DefaultColorOld and DefaultColorNew). Translucent colors are blended over the page.
+	ColorOld, ColorNew string
+	// Heatmap colors the changed pixels by the magnitude of their difference, from blue to yellow to red,
+	// instead of with ColorOld and ColorNew
+	Heatmap bool
+	// HighlightStyle is HighlightPixels, the default, to color the changed pixels, or HighlightBoxes to draw a
+	// rectangle around every changed region over the second page instead, whose content then stays legible
+	HighlightStyle string
+	// BoxWidth is the width in pixels of the rectangles of HighlightBoxes (Default: a quarter of a millimeter)
+	BoxWidth int
+	// Prioritize compares the pages that are most likely to have changed first, estimated from a quick low resolution pass
+	Prioritize bool
+	// OutputDir is the directory where the page images are written (Default: the current directory)
+	OutputDir string
+	// Prefix is prepended to the file names of the page images, e.g. "invoiceA_vs_invoiceB_", so the images of
+	// several comparisons can share a directory
+	Prefix string
+	// InMemory keeps the page images in the Images of the report instead of writing them to OutputDir,
+	// unless the Pipeline has its own encode stage
+	InMemory bool
+	// Pauser, if set, pauses and resumes the comparison between pages
+	Pauser *Pauser
+	// Progress, if set, is called every time a page has been compared
+	Progress func(completed, total int)
+	// PageDone, if set, is called with the result of every page as soon as it has been compared.
+	// The image paths of the result are not set yet.
+	PageDone func(page PageResult)
+	// OnPageStart, OnPageDone and OnArtifact, if set, are called before an output page is compared, with its result
+	// once it has been compared like PageDone, and with the path of every image written for it. An error returned by
+	// any of them stops the comparison, which fails with that error. OnPageStart and OnArtifact are called by the
+	// workers, concurrently; with InMemory, the base name of the path is the name of the image in the Images of the report.
+	// A remote comparison calls OnPageDone and OnArtifact as the results come back, and never OnPageStart.
+	OnPageStart func(page int) error
+	OnPageDone  func(page PageResult) error
+	OnArtifact  func(page int, path string) error
+	// Pipeline replaces or extends the stages every page goes through, see Pipeline
+	Pipeline Pipeline
+	// Quarantine are the output pages (0-based) known to render nondeterministically, see ReadQuarantine. They are
+	// compared and reported as Quarantined, but their differences do not make the report Changed.
+	Quarantine []int
+	// IgnoreRegions are areas of the pages whose differences are ignored, e.g. imported with ImportAnnotations
+	IgnoreRegions []IgnoreRegion
+	// CriticalRegions are areas of the pages that must match exactly, such as legal disclaimers or dosage tables:
+	// any pixel that differs inside them is a difference, whatever the threshold or the ignored areas, and the
+	// regions that changed are listed in the Critical of the page. They use the format of the mask files.
+	CriticalRegions []IgnoreRegion
+	// Password1 and Password2 unlock the documents that are encrypted PDFs, with their user or owner password.
+	// They are not recorded in the Config of the report.
+	Password1 string `config:"-"`
+	Password2 string `config:"-"`
+	// Crop excludes the margins of every page from the comparison, like IgnoreRegions covering them
+	Crop Margins
+	// Reflow paginates the EPUB and FB2 documents with another page size or font size than the defaults of MuPDF
+	Reflow ReflowLayout
+	// IgnoreText are regular expressions, such as `Printed on \S+`, whose matches in the text of both pages are
+	// excluded from the comparison like IgnoreRegions, e.g. for dates and invoice numbers. The text is the one drawn,
+	// line by line, and the matches are listed in the MaskedText of the page.
+	IgnoreText []string
+	// Fields are the template fields, such as the invoice number or the total, whose values are read from the text of
+	// both pages (or with OCR) and compared, see ReadTemplate. The values are listed in the Fields of the page.
+	Fields []TemplateField
+	// Checks are the rules the values of the Fields must follow in both documents, such as the line items adding up
+	// to the total, see FieldCheck. Their results are the Checks of the report.
+	Checks []FieldCheck
+
+	// colorOld and colorNew are the parsed highlight colors
+	colorOld, colorNew color.RGBA
+	// images is where the page images are kept with InMemory
+	images *MemoryImages
+	// zones are the content zones of the compared page with Segment
+	zones *contentZones
+	// scales are the pixels that differ at the other resolutions of MultiDPI
+	scales []scaleMask
+	// ignoreText are the compiled IgnoreText patterns, and masked the areas of the compared page they match
+	ignoreText []*regexp.Regexp
+	masked     []image.Rectangle
+	// unchanged are the areas of the compared page holding equations found unchanged with Equations
+	unchanged []image.Rectangle
+	// critical are the areas of the compared page covered by CriticalRegions
+	critical []image.Rectangle
+	// minRegionArea is MinRegionSize in pixels of the compared page
+	minRegionArea int
+}
+
+// Report is the result of a comparison
+type Report struct {
+	// SchemaVersion is the version of the JSON schema the report conforms to, see Schema
+	SchemaVersion string `json:"schema_version"`
+	File1         string `json:"file1"`
+	File2         string `json:"file2"`
+	Pages1        int    `json:"pages1"`
+	Pages2        int    `json:"pages2"`
+	// SSIM is the mean structural similarity of the pages
+	SSIM float64 `json:"ssim"`
+	// DPI is the resolution the pages were rasterized at, which the pixel coordinates of the pages depend on
+	DPI float64 `json:"dpi,omitempty"`
+	// WatermarkIn lists the documents (1 or 2) whose watermark was removed before comparing
+	WatermarkIn []int `json:"watermark_in,omitempty"`
+	// NondeterministicIn lists the documents (1 or 2) whose first page was rendered differently twice by the
+	// Preflight check
+	NondeterministicIn []int `json:"nondeterministic_in,omitempty"`
+	// Dir is the directory the page image paths are relative to
+	Dir string `json:"-"`
+	// Images holds the page images instead of Dir for a comparison run with Options.InMemory
+	Images *MemoryImages `json:"-"`
+	Pages  []PageResult  `json:"pages"`
+	// Partial is set when the comparison was cancelled or timed out: Pages only lists the pages completed before
+	Partial bool `json:"partial,omitempty"`
+	// Checks are the results of the checks of the template fields, see Options.Checks
+	Checks []CheckResult `json:"checks,omitempty"`
+	// Breaks are the page breaks and line breaks that moved between the documents, found with TextDiff
+	Breaks []BreakChange `json:"breaks,omitempty"`
+	// Typography are the typographic defects of both documents, found with Typography
+	Typography []TypographyDefect `json:"typography,omitempty"`
+	// Glyphs are the statistics of the glyphs of all the pages, see Options.Glyphs
+	Glyphs *GlyphStats `json:"glyphs,omitempty"`
+	// Languages are the dominant languages of the first and second documents, see Options.Language
+	Languages []string `json:"languages,omitempty"`
+	// Thumbnails are the checked thumbnails embedded in the documents, see CheckThumbnails
+	Thumbnails []ThumbnailResult `json:"thumbnails,omitempty"`
+	// Accessibility are the accessibility signals of the documents, see CheckAccessibility
+	Accessibility *AccessibilityResult `json:"accessibility,omitempty"`
+	// Config are the settings the comparison ran with, see EffectiveConfig
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// PageResult describes one page of the output and the images produced for it
+type PageResult struct {
+	Page           int     `json:"page"`
+	Changed        bool    `json:"changed"`
+	ChangedPixels  int     `json:"changed_pixels"`
+	PercentChanged float64 `json:"percent_changed"`
+	// SSIM is the structural similarity of the two pages, 1 for identical pages
+	SSIM float64 `json:"ssim"`
+	// Threshold is the threshold estimated for the page with AdaptiveThreshold
+	Threshold int `json:"threshold,omitempty"`
+	Width     int `json:"width"`
+	Height    int `json:"height"`
+	// Regions are the bounding boxes of the groups of changed pixels
+	Regions []Region `json:"regions,omitempty"`
+	// Critical are the critical regions of the page that changed, see Options.CriticalRegions
+	Critical []CriticalChange `json:"critical,omitempty"`
+	// Quarantined is set for the pages of Options.Quarantine
+	Quarantined bool `json:"quarantined,omitempty"`
+	// MissingIn is the document (1 or 2) that does not have the page, or 0 if both have it
+	MissingIn int `json:"missing_in,omitempty"`
+	// SourcePages are the pages of the first and second documents compared on this page in an aligned
+	// comparison, -1 for a page deleted from the first document or inserted in the second one
+	SourcePages []int `json:"source_pages,omitempty"`
+	// Background is the change of the paper color of the page, found with NormalizeBackground
+	Background *BackgroundChange `json:"background,omitempty"`
+	// Charts are the quantitative changes of the vector charts of the page, found with Charts
+	Charts []ChartChange `json:"charts,omitempty"`
+	// Equations are the equations of the page, found with Equations
+	Equations []Equation `json:"equations,omitempty"`
+	// Glyphs are the glyph changes of the page, found with Glyphs
+	Glyphs *GlyphDiff `json:"glyphs,omitempty"`
+	// Vectors are the vector paths added and removed on the page, found with VectorOverlay
+	Vectors *VectorChanges `json:"vectors,omitempty"`
+	// Dimensions are the measurements of the drawing of the page that changed, found with Dimensions
+	Dimensions []DimensionChange `json:"dimensions,omitempty"`
+	// TextChanges are the words inserted and deleted on the page, found with TextDiff
+	TextChanges []TextChange `json:"text_changes,omitempty"`
+	// MaskedText is the text of the page matched by the IgnoreText patterns, and excluded from the comparison
+	MaskedText []string `json:"masked_text,omitempty"`
+	// Fields are the values of the template fields of the page, see Options.Fields
+	Fields []FieldValue `json:"fields,omitempty"`
+	// Languages are the languages of the page in the first and second documents, see Options.Language
+	Languages []string `json:"languages,omitempty"`
+	// Content are the differences by type of content, found with Segment
+	Content       *ContentDiffs `json:"content,omitempty"`
+	DiffImage     string        `json:"diff_image,omitempty"`
+	CombinedImage string        `json:"combined_image,omitempty"`
+	Image1        string        `json:"image1,omitempty"`
+	Image2        string        `json:"image2,omitempty"`
+}
+
+// dpi returns the resolution of the pages, DefaultDPI for reports written before it was recorded
+func (r Report) dpi() float64 {
+	if r.DPI <= 0 {
+		return DefaultDPI
+	}
+	return r.DPI
+}
+
+// Changed reports whether any page of the report has differences, leaving out the quarantined pages
+func (r Report) Changed() bool {
+	for _, page := range r.Pages {
+		if page.Changed && !page.Quarantined {
+			return true
+		}
+	}
+	return false
+}
+
+// PercentChanged returns the mean share of the pixels of the pages that changed, leaving out the quarantined pages:
+// every page weighs the same, and the pages missing in a document changed entirely
+func (r Report) PercentChanged() float64 {
+	var changed float64
+	pages := 0
+	for _, page := range r.Pages {
+		if !page.Quarantined {
+			changed += page.PercentChanged
+			pages++
+		}
+	}
+	if pages == 0 {
+		return 0
+	}
+	return changed / float64(pages)
+}
+
+// ChangedPages returns the pages of the report that have differences
+func (r Report) ChangedPages() []PageResult {
+	var changed []PageResult
+	for _, page := range r.Pages {
+		if page.Changed {
+			changed = append(changed, page)
+		}
+	}
+	return changed
+}
+
+// Comparer compares documents with a fixed set of options. It can be shared by several goroutines comparing at once,
+// e.g. by the handlers of a server, when they keep their images InMemory or give them another Prefix: the options are
+// prepared once, and only the calls to the same document wait for each other.
+type Comparer struct {
+	opts Options
+}
+
+// NewComparer returns a Comparer using the given options. The options are copied, so changing the regions or
+// patterns they refer to afterwards does not change the Comparer.
+func NewComparer(opts Options) *Comparer {
+	opts.IgnoreRegions = append([]IgnoreRegion(nil), opts.IgnoreRegions...)
+	opts.CriticalRegions = append([]IgnoreRegion(nil), opts.CriticalRegions...)
+	opts.IgnoreText = append([]string(nil), opts.IgnoreText...)
+	opts.Fields = append([]TemplateField(nil), opts.Fields...)
+	opts.Checks = append([]FieldCheck(nil), opts.Checks...)
+	opts.MultiDPI = append([]float64(nil), opts.MultiDPI...)
+	if opts.Alignment != nil {
+		opts.Alignment = append(make([]PagePair, 0, len(opts.Alignment)), opts.Alignment...)
+	}
+	opts.Pipeline.Preprocess = append([]PreprocessFunc(nil), opts.Pipeline.Preprocess...)
+	// The patterns are compiled once for all the comparisons; invalid ones are reported by CheckOptions
+	opts.ignoreText = nil
+	for _, pattern := range opts.IgnoreText {
+		if re, err := regexp.Compile(pattern); err == nil {
+			opts.ignoreText = append(opts.ignoreText, re)
+		}
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = "."
+	}
+	if opts.DPI == 0 {
+		opts.DPI = DefaultDPI
+	}
+	return &Comparer{opts: opts}
+}
+
+// Compare compares two documents page by page with the given options
+func Compare(ctx context.Context, file1, file2 string, opts Options) (Report, error) {
+	return NewComparer(opts).Compare(ctx, file1, file2)
+}
+
+// CompareInputs compares two documents held in memory page by page with the given options
+func CompareInputs(ctx context.Context, in1, in2 Input, opts Options) (Report, error) {
+	return NewComparer(opts).CompareInputs(ctx, in1, in2)
+}
+
+// Compare compares two documents page by page, writing a difference image for every page of the output.
+// If ctx is cancelled or times out, the error of ctx is returned along with a Partial report of the pages completed.
+func (c *Comparer) Compare(ctx context.Context, file1, file2 string) (Report, error) {
+	return c.compare(ctx, file1, file2, func() (Document, error) { return OpenWithPassword(file1, c.opts.Password1) },
+		func() (Document, error) { return OpenWithPassword(file2, c.opts.Password2) })
+}
+
+// CompareInputs compares two documents held in memory like Compare, e.g. uploads that are never written to disk.
+// The report names the documents by the Name of their Input.
+func (c *Comparer) CompareInputs(ctx context.Context, in1, in2 Input) (Report, error) {
+	return c.compare(ctx, in1.Name, in2.Name, func() (Document, error) { return OpenInput(in1) }, func() (Document, error) { return OpenInput(in2) })
+}
+
+func (c *Comparer) compare(ctx context.Context, file1, file2 string, open1, open2 func() (Document, error)) (Report, error) {
+	opts := c.opts
+	report := Report{SchemaVersion: SchemaVersion, File1: file1, File2: file2, DPI: opts.DPI, Dir: opts.OutputDir, Config: EffectiveConfig(opts)}
+
+	// Open the documents and ensure they are closed after use
+	doc1, err := open1()
+	if err != nil {
+		return report, &InputError{File: file1, Err: err}
+	}
+	defer doc1.Close()
+	doc2, err := open2()
+	if err != nil {
+		return report, &InputError{File: file2, Err: err}
+	}
+	defer doc2.Close()
+	if err := Reflow(doc1, opts.Reflow); err != nil {
+		return report, &InputError{File: file1, Err: err}
+	}
+	if err := Reflow(doc2, opts.Reflow); err != nil {
+		return report, &InputError{File: file2, Err: err}
+	}
+
+	// When both inputs are archives of page images, pair the pages by file name instead of by position
+	MatchPages(doc1, doc2)
+	report.Pages1 = doc1.NumPage()
+	report.Pages2 = doc2.NumPage()
+
+	if err := CheckOptions(opts, doc1.NumPage(), doc2.NumPage()); err != nil {
+		return report, err
+	}
+
+	if opts.InMemory {
+		report.Images = NewMemoryImages()
+		opts.images = report.Images
+		if opts.Pipeline.Encode == nil {
+			opts.Pipeline.Encode = report.Images.Encode
+		}
+	} else if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return report, err
+	}
+
+	// A renderer that draws the same page differently twice would report sporadic differences
+	if opts.Preflight {
+		for n, doc := range []Document{doc1, doc2} {
+			same, err := deterministicRendering(opts.Pipeline, doc, opts.DPI)
+			if err != nil {
+				return report, err
+			}
+			if !same {
+				report.NondeterministicIn = append(report.NondeterministicIn, n+1)
+			}
+		}
+	}
+
+	// The paper is whitened first, so the watermarks and custom preprocessing see the pages on white paper
+	backgrounds := &backgroundChanges{byPage: make(map[int]*BackgroundChange)}
+	var preprocess []PreprocessFunc
+	if opts.NormalizeBackground {
+		preprocess = append(preprocess, normalizeBackgrounds(backgrounds))
+	}
+	// Halftone dots would be taken for specks
+	if opts.Descreen {
+		preprocess = append(preprocess, descreenStage(opts.DPI))
+	}
+	if opts.Despeckle {
+		preprocess = append(preprocess, despeckleStage(opts.DPI))
+	}
+
+	// The watermarks are removed between rendering and comparing, before any custom preprocessing
+	if opts.RemoveWatermarks {
+		watermarks, err := detectWatermarks(doc1, doc2)
+		if err != nil {
+			return report, err
+		}
+		if len(watermarks) > 0 {
+			for _, w := range watermarks {
+				report.WatermarkIn = append(report.WatermarkIn, w.doc)
+			}
+			preprocess = append(preprocess, removeWatermarks(watermarks))
+		}
+	}
+	opts.Pipeline.Preprocess = append(preprocess, opts.Pipeline.Preprocess...)
+
+	// Pair the pages before any of them is compared, since the output pages follow the alignment
+	if opts.Align && opts.Alignment == nil {
+		if opts.Alignment, err = AlignPages(doc1, doc2); err != nil {
+			return report, err
+		}
+	}
+	numJobs := max(doc1.NumPage(), doc2.NumPage())
+	numPages := max(doc1.NumPage()+opts.Offset, doc2.NumPage()+opts.Offset)
+	if opts.Alignment != nil {
+		numJobs, numPages = len(opts.Alignment), len(opts.Alignment)
+	}
+
+	// Compare the pages in document order, or the most likely changed first if requested
+	order := make([]int, numJobs)
+	for i := range order {
+		order[i] = i
+	}
+	if opts.Prioritize {
+		if order, err = prioritizeJobs(doc1, doc2, numJobs, &opts); err != nil {
+			return report, err
+		}
+	}
+	if opts.To > 0 {
+		order = pageRange(order, opts.From, opts.To)
+		numJobs = len(order)
+	}
+
+	// Create a channel for the jobs
+	jobs := make(chan int, numJobs)
+
+	// Create a channel to signal job completion
+	done := make(chan pageResult)
+
+	// Create the workers, which skip the remaining pages once a page has failed
+	workCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	for w := 1; w <= opts.Workers; w++ {
+		go worker(workCtx, jobs, done, doc1, doc2, &opts)
+	}
+
+	// Iterate over all the pages of the documents
+	for _, i := range order {
+		// Send the job to the workers
+		jobs <- i
+	}
+
+	// Close the jobs channel to signal that there are no more jobs to do
+	close(jobs)
+
+	// Wait for all jobs to be completed, remembering the result of every output page
+	results := make(map[int]pageResult)
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			stop()
+		}
+	}
+	for i := 0; i < numJobs; i++ {
+		result := <-done
+		if result.err != nil {
+			fail(result.err)
+		} else if firstErr == nil {
+			results[result.page] = result
+			if err := pageDone(&opts, result); err != nil {
+				fail(err)
+			}
+		}
+		for _, page := range result.inserted {
+			if firstErr != nil {
+				break
+			}
+			// Pages that only exist in the second document are always a difference
+			results[page] = pageResult{page: page, changedPixels: -1, missingIn: 1}
+			if err := pageDone(&opts, results[page]); err != nil {
+				fail(err)
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, numJobs)
+		}
+	}
+	// A cancelled comparison still reports the pages completed before it stopped, along with the error
+	err = ctx.Err()
+	if firstErr != nil && (err == nil || !errors.Is(firstErr, err)) {
+		return report, firstErr
+	}
+	report.Partial = err != nil
+
+	report.Pages = collectPages(opts.OutputDir, opts.Prefix, report.Images, numPages, results)
+	for i := range report.Pages {
+		report.Pages[i].Background = backgrounds.byPage[report.Pages[i].Page]
+	}
+	quarantine(report.Pages, opts.Quarantine)
+	report.Checks = CheckFields(opts.Checks, opts.Fields, report.Pages)
+	report.Languages = DocumentLanguages(report.Pages)
+	report.Glyphs = GlyphTotals(report.Pages)
+	report.SSIM = MeanSSIM(report.Pages)
+	// The text moves from page to page, so the breaks are found in the text of the whole documents
+	if opts.TextDiff && !opts.OCR && !report.Partial {
+		if report.Breaks, err = BreakChanges(doc1, doc2); err != nil {
+			return report, err
+		}
+	}
+	if opts.Typography && !report.Partial {
+		if report.Typography, err = TypographyDefects(doc1, doc2); err != nil {
+			return report, err
+		}
+	}
+	return report, err
+}
+
+// CheckOptions returns an error wrapping ErrInvalidOptions if the options do not fit documents with the given number
+// of pages. Every problem found is described in the error, with the way to fix it, so they can all be fixed at once.
+func CheckOptions(opts Options, pages1, pages2 int) error {
+	var problems optionProblems
+	// Check that the offset and startoffset are valid
+	if opts.Offset < 0 || opts.Offset >= pages2 {
+		problems.add("the offset should be between 0 and %d, since the second document has %d pages", pages2-1, pages2)
+	}
+	if opts.StartOffset < 0 || opts.StartOffset >= pages1 {
+		problems.add("the startOffset should be between 0 and %d, since the first document has %d pages", pages1-1, pages1)
+	}
+	if (opts.Align || opts.Alignment != nil) && (opts.Offset != 0 || opts.StartOffset != 0) {
+		problems.add("the offset cannot be used with the page alignment, which pairs the pages itself: remove one of them")
+	}
+	for _, pair := range opts.Alignment {
+		if pair.Page1 < -1 || pair.Page1 >= pages1 {
+			problems.add("page %d of the first document cannot be compared, it has %d pages", pair.Page1+1, pages1)
+		}
+		if pair.Page2 < -1 || pair.Page2 >= pages2 {
+			problems.add("page %d of the second document cannot be compared, it has %d pages", pair.Page2+1, pages2)
+		}
+	}
+	if opts.DPI < 0 {
+		problems.add("the dpi should be greater than 0")
+	}
+	for _, dpi := range opts.MultiDPI {
+		if dpi <= 0 {
+			problems.add("the resolutions to compare at should be greater than 0")
+			break
+		}
+	}
+	if opts.Threshold < 0 || opts.Threshold > 255 {
+		problems.add("the threshold should be between 0 and 255")
+	}
+	if _, _, err := highlightColors(opts); err != nil {
+		problems.add("%v", err)
+	}
+	if opts.From < 0 || opts.To < 0 || (opts.To > 0 && opts.From >= opts.To) {
+		problems.add("the page range should start before it ends")
+	} else if jobs := max(pages1, pages2); opts.Alignment == nil && !opts.Align && opts.From >= jobs {
+		problems.add("the page range starts at page %d, after the last page %d of the documents", opts.From+1, jobs)
+	}
+	if _, err := marginRects(opts.Crop, image.Rect(0, 0, 1, 1), 1); err != nil {
+		problems.add("%v", err)
+	}
+	if _, err := regionPixels(opts.MinRegionSize, 1); err != nil {
+		problems.add("%v", err)
+	}
+	if opts.OCR {
+		if err := checkOCR(); err != nil {
+			problems.add("%v", err)
+		}
+	}
+	for _, pattern := range opts.IgnoreText {
+		if _, err := regexp.Compile(pattern); err != nil {
+			problems.add("invalid pattern of text to ignore: %v", err)
+		}
+	}
+	if opts.HighlightStyle != "" && opts.HighlightStyle != HighlightPixels && opts.HighlightStyle != HighlightBoxes {
+		problems.add("the highlight style %q should be pixels or boxes", opts.HighlightStyle)
+	} else if opts.HighlightStyle == HighlightBoxes && opts.Heatmap {
+		problems.add("the boxes highlight style cannot be used with the heatmap, which colors the changed pixels")
+	}
+	if opts.BoxWidth < 0 {
+		problems.add("the width of the boxes should be a number of pixels")
+	}
+	if opts.VectorOverlay != "" && opts.VectorOverlay != VectorSVG && opts.VectorOverlay != VectorDXF {
+		problems.add("the vector overlay %q should be svg or dxf", opts.VectorOverlay)
+	}
+	if err := checkLanguage(opts.Language); err != nil {
+		problems.add("%v", err)
+	}
+	for _, f := range opts.Fields {
+		if err := checkField(f); err != nil {
+			problems.add("%v", err)
+		}
+	}
+	for _, c := range opts.Checks {
+		if err := checkCheck(c, opts.Fields); err != nil {
+			problems.add("%v", err)
+		}
+	}
+	if strings.ContainsAny(opts.Prefix, `/\`) {
+		problems.add("the prefix of the image names cannot contain a directory, use the output directory instead")
+	}
+	return problems.err()
+}
+
+// optionProblems collects the problems found in the options
+type optionProblems []string
+
+func (p *optionProblems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// err returns the problems as one error wrapping ErrInvalidOptions, one problem per line if there are several
+func (p optionProblems) err() error {
+	switch len(p) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%w: %s", ErrInvalidOptions, p[0])
+	}
+	return fmt.Errorf("%w:\n  %s", ErrInvalidOptions, strings.Join(p, "\n  "))
+}
+
+// pageRange keeps the jobs in [from, to), preserving their order
+func pageRange(jobs []int, from, to int) []int {
+	var kept []int
+	for _, j := range jobs {
+		if j >= from && j < to {
+			kept = append(kept, j)
+		}
+	}
+	return kept
+}
+
+// collectPages lists the compared output pages and the images written for them, to dir or to images, in page order
+func collectPages(dir, prefix string, images *MemoryImages, numPages int, results map[int]pageResult) []PageResult {
+	var pages []PageResult
+	for i := 0; i < numPages; i++ {
+		// Pages without a result were not compared, e.g. outside the page range
+		result, ok := results[i]
+		if !ok {
+			continue
+		}
+		page := newPageResult(result)
+		page.Page = i
+		if name := diffImageName(prefix, i); imageExists(dir, images, name) {
+			page.DiffImage = name
+		}
+		if name := combinedImageName(prefix, i); imageExists(dir, images, name) {
+			page.CombinedImage = name
+		}
+		if name := pageImageName(prefix, 1, i); imageExists(dir, images, name) {
+			page.Image1 = name
+		}
+		if name := pageImageName(prefix, 2, i); imageExists(dir, images, name) {
+			page.Image2 = name
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// pageDone reports the result of a page to the PageDone and OnPageDone callbacks, if any
+func pageDone(opts *Options, result pageResult) error {
+	if opts.PageDone != nil {
+		opts.PageDone(newPageResult(result))
+	}
+	if opts.OnPageDone != nil {
+		return opts.OnPageDone(newPageResult(result))
+	}
+	return nil
+}
+
+// newPageResult converts the result of a worker into the statistics of a page, without the image paths
+func newPageResult(result pageResult) PageResult {
+	page := PageResult{
+		Page:          result.page,
+		Changed:       result.changedPixels != 0,
+		ChangedPixels: max(result.changedPixels, 0),
+		Width:         result.width,
+		Height:        result.height,
+		Regions:       result.regions,
+		MissingIn:     result.missingIn,
+		SourcePages:   result.sourcePages,
+		SSIM:          result.ssim,
+		Threshold:     result.threshold,
+		Content:       result.content,
+		Charts:        result.charts,
+		Equations:     result.equations,
+		Glyphs:        result.glyphs,
+		Vectors:       result.vectors,
+		Dimensions:    result.dimensions,
+		TextChanges:   result.textChanges,
+		MaskedText:    result.maskedText,
+		Critical:      result.critical,
+		Fields:        result.fields,
+		Languages:     result.languages,
+	}
+	switch {
+	case result.changedPixels < 0:
+		// Pages inserted by the offset only exist in the second document, so the whole page has changed
+		page.PercentChanged = 100
+	case result.width*result.height > 0:
+		page.PercentChanged = float64(result.changedPixels) / float64(result.width*result.height) * 100
+	}
+	return page
+}
+
+// diffImageName returns the file name of the difference image of an output page
+func diffImageName(prefix string, page int) string {
+	return fmt.Sprintf("%sdifferences_%d.png", prefix, page)
+}
+
+// combinedImageName returns the file name of the side-by-side image of an output page
+func combinedImageName(prefix string, page int) string {
+	return fmt.Sprintf("%scombined_%d.png", prefix, page)
+}
+
+// pageImageName returns the file name of the rendered page of the first or second document for an output page
+func pageImageName(prefix string, doc, page int) string {
+	return fmt.Sprintf("%spage%d_%d.png", prefix, doc, page)
+}
+
+// fileExists reports whether the file exists in the directory
+func fileExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package pdfdiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		// want are parts of the problems reported, one for each
+		want []string
+	}{
+		{"defaults", Options{}, nil},
+		{"valid options", Options{DPI: 150, Offset: 2, StartOffset: 1, Threshold: 255, From: 1, To: 3, MinRegionSize: "0.5mm2", HighlightStyle: HighlightBoxes, BoxWidth: 3}, nil},
+		{"offset past the second document", Options{Offset: 4}, []string{"offset should be between 0 and 3"}},
+		{"negative start offset", Options{StartOffset: -1}, []string{"startOffset should be between 0 and 2"}},
+		{"offset and alignment", Options{Align: true, Offset: 1}, []string{"offset cannot be used with the page alignment"}},
+		{"aligned page past the documents", Options{Alignment: []PagePair{{Page1: 0, Page2: 0}, {Page1: 3, Page2: 4}}},
+			[]string{"page 4 of the first document", "page 5 of the second document"}},
+		{"negative dpi", Options{DPI: -1}, []string{"dpi should be greater than 0"}},
+		{"zero resolution", Options{MultiDPI: []float64{72, 0, -1}}, []string{"resolutions to compare at"}},
+		{"threshold out of range", Options{Threshold: 256}, []string{"threshold should be between 0 and 255"}},
+		{"invalid color", Options{ColorOld: "red"}, []string{"red"}},
+		{"page range backwards", Options{From: 2, To: 1}, []string{"page range should start before it ends"}},
+		{"page range past the documents", Options{From: 4}, []string{"starts at page 5, after the last page 4"}},
+		{"invalid crop", Options{Crop: Margins{Top: "a lot"}}, []string{`"a lot"`}},
+		{"invalid region size", Options{MinRegionSize: "2cm"}, []string{`minimum region size "2cm"`}},
+		{"invalid text pattern", Options{IgnoreText: []string{"Date: ("}}, []string{"pattern of text to ignore"}},
+		{"unknown highlight style", Options{HighlightStyle: "circles"}, []string{`highlight style "circles"`}},
+		{"boxes and heatmap", Options{HighlightStyle: HighlightBoxes, Heatmap: true}, []string{"boxes highlight style cannot be used with the heatmap"}},
+		{"negative box width", Options{BoxWidth: -2}, []string{"width of the boxes"}},
+		{"unknown vector overlay", Options{VectorOverlay: "pdf"}, []string{`vector overlay "pdf"`}},
+		{"prefix with a directory", Options{Prefix: "out/page"}, []string{"prefix of the image names cannot contain a directory"}},
+		// Every problem is reported at once
+		{"several problems", Options{DPI: -1, Threshold: -1, BoxWidth: -1},
+			[]string{"dpi should be greater than 0", "threshold should be between 0 and 255", "width of the boxes"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckOptions(test.opts, 3, 4)
+			if test.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want none", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Fatalf("err = %v, want ErrInvalidOptions", err)
+			}
+			problems := strings.Split(strings.TrimPrefix(err.Error(), ErrInvalidOptions.Error()+":"), "\n")
+			if len(test.want) > 1 {
+				problems = problems[1:]
+			}
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems = %q, want %d", problems, len(test.want))
+			}
+			for i, want := range test.want {
+				if !strings.Contains(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// The renderer does not expose the objects of a document, so the few features that need them (the annotations of a
+// reviewed PDF, the embedded page thumbnails) read the file with this minimal parser. It collects every object of the
+// file, including the ones of compressed object streams, without following the cross-reference table, so it also
+// reads files with a damaged one. Later definitions of an object replace earlier ones, as in incremental updates.
+
+// pdfName is a PDF name, without the leading slash
+type pdfName string
+
+// pdfDict is a PDF dictionary, by key without the leading slash
+type pdfDict map[string]interface{}
+
+// pdfRef is a reference to an indirect object
+type pdfRef struct {
+	num, gen int
+}
+
+// pdfStream is a stream object, with its raw (still encoded) data
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+// pdfFile holds the objects of a PDF or FDF file
+type pdfFile struct {
+	objects map[int]interface{}
+	// gens are the generation numbers of the objects, and compressed the objects read from object streams
+	gens       map[int]int
+	compressed map[int]bool
+	trailer    pdfDict
+}
+
+var objHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// readPDF reads the objects of a PDF or FDF file
+func readPDF(path string) (*pdfFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePDF(data, path)
+}
+
+// parsePDF reads the objects of the content of a PDF or FDF file
+func parsePDF(data []byte, path string) (*pdfFile, error) {
+	f := &pdfFile{objects: make(map[int]interface{}), gens: make(map[int]int), compressed: make(map[int]bool)}
+	var objStreams []pdfStream
+	for pos := 0; pos < len(data); {
+		loc := objHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		gen, _ := strconv.Atoi(string(data[pos+loc[4] : pos+loc[5]]))
+		p := &pdfParser{data: data, pos: pos + loc[1]}
+		obj, err := p.object()
+		if err != nil {
+			// Not an object after all, e.g. text in a stream: look further
+			pos += loc[1]
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok && p.keyword("stream") {
+			stream := pdfStream{dict: dict, data: p.streamData(f, dict)}
+			obj = stream
+			if dict["Type"] == pdfName("ObjStm") {
+				objStreams = append(objStreams, stream)
+			}
+			if dict["Type"] == pdfName("XRef") {
+				// Cross-reference streams hold the trailer entries
+				f.trailer = dict
+			}
+		}
+		f.objects[num] = obj
+		f.gens[num] = gen
+		pos = p.pos
+	}
+	for _, stream := range objStreams {
+		f.readObjectStream(stream)
+	}
+	if i := bytes.LastIndex(data, []byte("trailer")); i >= 0 {
+		p := &pdfParser{data: data, pos: i + len("trailer")}
+		if obj, err := p.object(); err == nil {
+			if dict, ok := obj.(pdfDict); ok {
+				f.trailer = dict
+			}
+		}
+	}
+	if f.trailer == nil {
+		return nil, fmt.Errorf("%s is not a PDF or FDF file", path)
+	}
+	return f, nil
+}
+
+// readObjectStream adds the objects of a compressed object stream that are not defined outside of it
+func (f *pdfFile) readObjectStream(stream pdfStream) {
+	data, err := f.decode(stream)
+	if err != nil {
+		return
+	}
+	n, _ := f.resolve(stream.dict["N"]).(float64)
+	first, _ := f.resolve(stream.dict["First"]).(float64)
+	header := &pdfParser{data: data}
+	for i := 0; i < int(n); i++ {
+		num, err1 := header.object()
+		offset, err2 := header.object()
+		if err1 != nil || err2 != nil {
+			return
+		}
+		num1, _ := num.(float64)
+		offset1, _ := offset.(float64)
+		if _, ok := f.objects[int(num1)]; ok {
+			continue
+		}
+		p := &pdfParser{data: data, pos: int(first + offset1)}
+		if obj, err := p.object(); err == nil {
+			f.objects[int(num1)] = obj
+			f.compressed[int(num1)] = true
+		}
+	}
+}
+
+// resolve follows references until a direct object
+func (f *pdfFile) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = f.objects[ref.num]
+	}
+	return nil
+}
+
+// dict returns the dictionary an object refers to, or a stream's dictionary, or nil
+func (f *pdfFile) dict(obj interface{}) pdfDict {
+	switch v := f.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// array returns the array an object refers to, or nil
+func (f *pdfFile) array(obj interface{}) []interface{} {
+	a, _ := f.resolve(obj).([]interface{})
+	return a
+}
+
+// numbers returns the numbers of an array, or nil if it has other objects
+func (f *pdfFile) numbers(obj interface{}) []float64 {
+	var numbers []float64
+	for _, v := range f.array(obj) {
+		n, ok := f.resolve(v).(float64)
+		if !ok {
+			return nil
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// text returns the string an object refers to, decoding UTF-16 text strings, or ""
+func (f *pdfFile) text(obj interface{}) string {
+	s, _ := f.resolve(obj).(string)
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		var runes []rune
+		for i := 2; i+1 < len(s); i += 2 {
+			runes = append(runes, rune(s[i])<<8|rune(s[i+1]))
+		}
+		return string(runes)
+	}
+	return s
+}
+
+// pages returns the page dictionaries of the document in page order
+func (f *pdfFile) pages() []pdfDict {
+	root := f.dict(f.trailer["Root"])
+	if root == nil {
+		return nil
+	}
+	var pages []pdfDict
+	var walk func(node pdfDict, depth int)
+	walk = func(node pdfDict, depth int) {
+		if node == nil || depth > 64 {
+			return
+		}
+		if node["Type"] == pdfName("Page") {
+			pages = append(pages, node)
+			return
+		}
+		for _, kid := range f.array(node["Kids"]) {
+			walk(f.dict(kid), depth+1)
+		}
+	}
+	walk(f.dict(root["Pages"]), 0)
+	return pages
+}
+
+// decode returns the decoded data of a stream. Only Flate-encoded and unencoded streams are supported.
+func (f *pdfFile) decode(stream pdfStream) ([]byte, error) {
+	filters := []interface{}{f.resolve(stream.dict["Filter"])}
+	if a := f.array(stream.dict["Filter"]); a != nil {
+		filters = a
+	}
+	data := stream.data
+	for _, filter := range filters {
+		switch f.resolve(filter) {
+		case nil:
+		case pdfName("FlateDecode"):
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			// Streams are often truncated or padded, so keep what could be decoded
+			data, err = io.ReadAll(r)
+			if err != nil && len(data) == 0 {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %v", filter)
+		}
+	}
+	return data, nil
+}
+
+// pdfParser reads PDF objects from a buffer
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+// isDelimiter reports whether c ends a PDF token
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0 || isSpace(c)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// skipSpace skips white space and comments
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		} else if !isSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// token returns the next regular token without consuming it
+func (p *pdfParser) token() string {
+	p.skipSpace()
+	end := p.pos
+	for end < len(p.data) && !isDelimiter(p.data[end]) {
+		end++
+	}
+	return string(p.data[p.pos:end])
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *pdfParser) keyword(k string) bool {
+	if p.token() != k {
+		return false
+	}
+	p.pos += len(k)
+	return true
+}
+
+// streamData returns the data of the stream starting after the stream keyword, and moves after endstream
+func (p *pdfParser) streamData(f *pdfFile, dict pdfDict) []byte {
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	// The length may refer to an object that is not read yet, so fall back to looking for endstream
+	if length, ok := f.resolve(dict["Length"]).(float64); ok && start+int(length) <= len(p.data) {
+		end := &pdfParser{data: p.data, pos: start + int(length)}
+		if end.keyword("endstream") {
+			p.pos = end.pos
+			return p.data[start : start+int(length)]
+		}
+	}
+	i := bytes.Index(p.data[start:], []byte("endstream"))
+	if i < 0 {
+		p.pos = len(p.data)
+		return p.data[start:]
+	}
+	p.pos = start + i + len("endstream")
+	return bytes.TrimRight(p.data[start:start+i], "\r\n")
+}
+
+// object parses the next object, turning "n g R" into references
+func (p *pdfParser) object() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		name := p.token()
+		p.pos += len(name)
+		return pdfName(unescapeName(name)), nil
+	case c == '(':
+		return p.literalString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict := pdfDict{}
+		for {
+			p.skipSpace()
+			if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+				p.pos += 2
+				return dict, nil
+			}
+			key, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, fmt.Errorf("invalid dictionary key at offset %d", p.pos)
+			}
+			value, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			dict[string(name)] = value
+		}
+	case c == '<':
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		var array []interface{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return array, nil
+			}
+			v, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+	}
+
+	tok := p.token()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.data[p.pos], p.pos)
+	}
+	switch tok {
+	case "true", "false":
+		p.pos += len(tok)
+		return tok == "true", nil
+	case "null":
+		p.pos += len(tok)
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok, p.pos)
+	}
+	p.pos += len(tok)
+	// An integer followed by another one and R is a reference
+	save := p.pos
+	if gen := p.token(); gen != "" && n == float64(int(n)) {
+		if g, err := strconv.Atoi(gen); err == nil {
+			p.pos += len(gen)
+			if p.keyword("R") {
+				return pdfRef{num: int(n), gen: g}, nil
+			}
+		}
+	}
+	p.pos = save
+	return n, nil
+}
+
+// literalString parses a string in parentheses
+func (p *pdfParser) literalString() (string, error) {
+	p.pos++
+	var buf []byte
+	depth := 0
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return string(buf), nil
+			}
+			depth--
+		case '\\':
+			if p.pos >= len(p.data) {
+				return "", io.ErrUnexpectedEOF
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A line continuation
+				if c == '\r' && p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return "", io.ErrUnexpectedEOF
+}
+
+// hexString parses a string in angle brackets
+func (p *pdfParser) hexString() (string, error) {
+	p.pos++
+	var digits []byte
+	for p.pos < len(p.data) && p.data[p.pos] != '>' {
+		if !isSpace(p.data[p.pos]) {
+			digits = append(digits, p.data[p.pos])
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, len(digits)/2)
+	for i := range buf {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex string at offset %d", p.pos)
+		}
+		buf[i] = byte(v)
+	}
+	return string(buf), nil
+}
+
+// unescapeName decodes the #xx escapes of a name
+func unescapeName(name string) string {
+	if !bytes.Contains([]byte(name), []byte("#")) {
+		return name
+	}
+	var buf []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				buf = append(buf, byte(v))
+				i += 2
+				continue
+			}
+		}
+		buf = append(buf, name[i])
+	}
+	return string(buf)
+}
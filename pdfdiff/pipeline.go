@@ -0,0 +1,155 @@
+package pdfdiff
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Every page goes through the same stages: render → preprocess → compare → postprocess → encode. The zero Pipeline
+// runs the built-in stages; custom pipelines replace or extend some of them and keep the others, e.g. to remove a
+// watermark between render and compare:
+//
+//	opts.Pipeline.Preprocess = []pdfdiff.PreprocessFunc{func(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+//		return removeWatermark(img1), removeWatermark(img2), nil
+//	}}
+
+// RenderFunc rasterizes a page of a document at the given resolution, reporting whether the page is missing.
+// It is called for page numbers past the end of the document, or -1 for a page missing from an aligned comparison,
+// too, and must then return a blank page.
+type RenderFunc func(doc Document, page int, dpi float64) (img image.Image, missing bool, err error)
+
+// PreprocessFunc transforms the rendered pages of both documents before they are compared
+type PreprocessFunc func(page int, img1, img2 image.Image) (image.Image, image.Image, error)
+
+// CompareFunc compares the pages of both documents, page being the output page
+type CompareFunc func(page int, img1, img2 image.Image, opts Options) (Diff, error)
+
+// PostprocessFunc transforms the result of a comparison before its images are written
+type PostprocessFunc func(page int, diff *Diff) error
+
+// EncodeFunc writes an image, in the format given by the extension of the path
+type EncodeFunc func(img image.Image, path string) error
+
+// Pipeline holds the stages a page goes through. Nil stages run the built-in ones: RenderStage, CompareStage and
+// EncodeStage. Remote comparisons always use the built-in stages.
+type Pipeline struct {
+	Render      RenderFunc
+	Preprocess  []PreprocessFunc
+	Compare     CompareFunc
+	Postprocess []PostprocessFunc
+	Encode      EncodeFunc
+}
+
+// Diff is the result of the compare stage
+type Diff struct {
+	// Image highlights the changed pixels
+	Image         image.Image
+	ChangedPixels int
+	Regions       []Region
+	SSIM          float64
+	// Threshold is the threshold estimated for the page with Options.AdaptiveThreshold, 0 otherwise
+	Threshold int
+	// Content are the differences by type of content with Options.Segment, nil otherwise
+	Content *ContentDiffs
+	// Critical are the critical regions of Options.CriticalRegions that changed
+	Critical []CriticalChange
+}
+
+// RenderStage is the built-in render stage: it rasterizes the page, or returns a blank page if it does not exist
+func RenderStage(doc Document, page int, dpi float64) (image.Image, bool, error) {
+	return pageImage(doc, page, dpi)
+}
+
+// CompareStage is the built-in compare stage: it compares the pages pixel by pixel with the threshold, colors,
+// ignore regions, text to ignore, cropped margins and other comparison settings of the options
+func CompareStage(page int, img1, img2 image.Image, opts Options) (Diff, error) {
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = DefaultDPI
+	}
+	ignore := append(ignoreRects(opts.IgnoreRegions, page, img1.Bounds().Dy(), dpi), opts.masked...)
+	margins, err := marginRects(opts.Crop, img1.Bounds(), dpi)
+	if err != nil {
+		return Diff{}, err
+	}
+	ignore = append(ignore, margins...)
+	opts.critical = ignoreRects(opts.CriticalRegions, page, img1.Bounds().Dy(), dpi)
+	if opts.minRegionArea, err = regionPixels(opts.MinRegionSize, dpi); err != nil {
+		return Diff{}, err
+	}
+	// Pages rendered to the same pixels skip the comparison loop, which is most of the time spent on a page
+	if identicalImages(img1, img2) {
+		var content *ContentDiffs
+		if opts.zones != nil {
+			content = opts.zones.diffs([contentTypes + 1]int{})
+		}
+		if len(ignore) > 0 {
+			return Diff{Image: hatchRects(img1, ignore), SSIM: 1, Content: content}, nil
+		}
+		return Diff{Image: img1, SSIM: 1, Content: content}, nil
+	}
+	if opts.colorOld, opts.colorNew, err = highlightColors(opts); err != nil {
+		return Diff{}, err
+	}
+	diff := Diff{SSIM: ssim(img1, img2)}
+	if opts.AdaptiveThreshold {
+		if noise := estimateNoise(img1, img2); noise > opts.Threshold {
+			opts.Threshold = noise
+		}
+		diff.Threshold = opts.Threshold
+	}
+	diffImg, changedPixels, regions, content := diffImages(img1, img2, ignore, &opts)
+	if opts.HighlightStyle == HighlightBoxes {
+		drawBoxes(diffImg, regions, boxWidth(opts.BoxWidth, dpi), opts.colorOld, opts.colorNew)
+	}
+	diff.Image, diff.ChangedPixels, diff.Regions, diff.Content = diffImg, changedPixels, regions, content
+	diff.Critical = criticalChanges(img1, img2, opts.CriticalRegions, page, dpi)
+	classifyRegions(img1, img2, diff.Regions, dpi)
+	return diff, nil
+}
+
+// EncodeStage is the built-in encode stage
+func EncodeStage(img image.Image, path string) error {
+	return imaging.Save(img, path)
+}
+
+func (p Pipeline) render(doc Document, page int, dpi float64) (image.Image, bool, error) {
+	if p.Render != nil {
+		return p.Render(doc, page, dpi)
+	}
+	return RenderStage(doc, page, dpi)
+}
+
+func (p Pipeline) preprocess(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+	var err error
+	for _, stage := range p.Preprocess {
+		if img1, img2, err = stage(page, img1, img2); err != nil {
+			return nil, nil, err
+		}
+	}
+	return img1, img2, nil
+}
+
+func (p Pipeline) compare(page int, img1, img2 image.Image, opts Options) (Diff, error) {
+	if p.Compare != nil {
+		return p.Compare(page, img1, img2, opts)
+	}
+	return CompareStage(page, img1, img2, opts)
+}
+
+func (p Pipeline) postprocess(page int, diff *Diff) error {
+	for _, stage := range p.Postprocess {
+		if err := stage(page, diff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Pipeline) encode(img image.Image, path string) error {
+	if p.Encode != nil {
+		return p.Encode(img, path)
+	}
+	return EncodeStage(img, path)
+}
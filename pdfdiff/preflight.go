@@ -0,0 +1,40 @@
+package pdfdiff
+
+import "image"
+
+// deterministicRendering renders the first page of a document twice through the pipeline and reports whether both
+// renderings have the same pixels. Renderers that load missing fonts from the system, or fall back to another one
+// from time to time, draw the same page differently, which shows as sporadic differences between identical pages.
+func deterministicRendering(p Pipeline, doc Document, dpi float64) (bool, error) {
+	if doc.NumPage() == 0 {
+		return true, nil
+	}
+	img1, _, err := p.render(doc, 0, dpi)
+	if err != nil {
+		return false, err
+	}
+	img2, _, err := p.render(doc, 0, dpi)
+	if err != nil {
+		return false, err
+	}
+	return sameImages(img1, img2), nil
+}
+
+// sameImages reports whether two images have the same size and pixels
+func sameImages(img1, img2 image.Image) bool {
+	if identicalImages(img1, img2) {
+		return true
+	}
+	b := img1.Bounds()
+	if b != img2.Bounds() {
+		return false
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if pixelsDiffer(img1.At(x, y), img2.At(x, y), 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package pdfdiff
+
+import (
+	"image"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// prescoreDPI is the resolution of the quick render used to estimate how much a page has changed
+const prescoreDPI = 18.0
+
+// prioritizeJobs returns the pages of the first document ordered by the perceptual-hash distance between them and their
+// counterparts in the second document, so the pages that are most likely to have changed are compared first.
+// Pages with the same distance keep their order.
+func prioritizeJobs(doc1, doc2 Document, numJobs int, opts *Options) ([]int, error) {
+	jobs := make([]int, numJobs)
+	distance := make([]int, numJobs)
+	for j := range jobs {
+		jobs[j] = j
+		page1, pagToCompare := opts.sourcePages(j)
+		h1, err := pageHash(doc1, page1)
+		if err != nil {
+			return nil, err
+		}
+		h2, err := pageHash(doc2, pagToCompare)
+		if err != nil {
+			return nil, err
+		}
+		distance[j] = hammingDistance(h1, h2)
+	}
+	sort.SliceStable(jobs, func(a, b int) bool {
+		return distance[jobs[a]] > distance[jobs[b]]
+	})
+	return jobs, nil
+}
+
+// pageHash returns the difference hash of a page rendered at a low resolution. A missing page hashes like a blank page.
+func pageHash(doc Document, page int) (uint64, error) {
+	if page < 0 || page >= doc.NumPage() {
+		return 0, nil
+	}
+	img, err := RenderPage(doc, page, prescoreDPI)
+	if err == ErrPageMissing {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return dHash(img), nil
+}
+
+// dHash computes a 64 bit difference hash: every bit tells whether a cell of a 9x8 grayscale thumbnail is brighter than its right neighbour
+func dHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if brightness(small.At(x, y)) > brightness(small.At(x+1, y)) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// hammingDistance counts the bits that differ between two hashes
+func hammingDistance(a, b uint64) int {
+	n := 0
+	for x := a ^ b; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package pdfdiff
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ReadQuarantine reads a quarantine file, which lists the pages known to render nondeterministically, and returns
+// the pages (0-based) quarantined for the compared documents, for Options.Quarantine. Every line lists 1-based pages
+// and ranges, e.g. 3,5-7, optionally after the file name of the document they are quarantined in, e.g.
+// "invoice.pdf: 2", which applies when either compared document has that name. Lines starting with # are comments.
+func ReadQuarantine(path string, files ...string) ([]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pages []int
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.LastIndex(line, ":"); i >= 0 {
+			if !quarantinedIn(strings.TrimSpace(line[:i]), files) {
+				continue
+			}
+			line = line[i+1:]
+		}
+		more, err := parsePages(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		pages = append(pages, more...)
+	}
+	return pages, scanner.Err()
+}
+
+// quarantinedIn reports whether one of the documents has the file name of a line of a quarantine file
+func quarantinedIn(name string, files []string) bool {
+	for _, file := range files {
+		if filepath.Base(file) == filepath.Base(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePages parses a list of 1-based pages and ranges such as 3,5-7 into 0-based pages
+func parsePages(list string) ([]int, error) {
+	var pages []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		first, last := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			first, last = part[:i], part[i+1:]
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(first))
+		to, err2 := strconv.Atoi(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil || from < 1 || from > to {
+			return nil, fmt.Errorf("invalid pages %q, they should be like 3 or 5-7", part)
+		}
+		for p := from; p <= to; p++ {
+			pages = append(pages, p-1)
+		}
+	}
+	return pages, nil
+}
+
+// QuarantinedPages returns the quarantined pages of the report, changed or not
+func (r Report) QuarantinedPages() []PageResult {
+	var quarantined []PageResult
+	for _, page := range r.Pages {
+		if page.Quarantined {
+			quarantined = append(quarantined, page)
+		}
+	}
+	return quarantined
+}
+
+// quarantine flags the pages of the report listed in Options.Quarantine
+func quarantine(pages []PageResult, quarantined []int) {
+	for i := range pages {
+		for _, page := range quarantined {
+			if pages[i].Page == page {
+				pages[i].Quarantined = true
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package pdfdiff
+
+import "fmt"
+
+// The defaults of MuPDF, with which reflowable documents are laid out when they are opened
+const (
+	defaultReflowWidth    = 450.0
+	defaultReflowHeight   = 600.0
+	defaultReflowFontSize = 12.0
+)
+
+// ReflowLayout is the page size and the font size, in points, with which the reflowable documents, EPUB and FB2, are
+// paginated before rendering. The zero values keep the defaults of MuPDF: 450x600 points and a 12 point font.
+type ReflowLayout struct {
+	Width    float64 `json:"width,omitempty"`
+	Height   float64 `json:"height,omitempty"`
+	FontSize float64 `json:"font_size,omitempty"`
+}
+
+// check verifies that the layout is a page size, a font size or both
+func (l ReflowLayout) check() error {
+	if l.Width < 0 || l.Height < 0 || (l.Width == 0) != (l.Height == 0) {
+		return fmt.Errorf("the reflow size %gx%g should be a width and a height in points, e.g. 450x600", l.Width, l.Height)
+	}
+	if l.FontSize < 0 {
+		return fmt.Errorf("the reflow font size %g should be a size in points, e.g. 12", l.FontSize)
+	}
+	return nil
+}
+
+// Reflow paginates a reflowable document, such as an EPUB, again with the layout. The other documents are left as
+// they are.
+func Reflow(doc Document, layout ReflowLayout) error {
+	if layout == (ReflowLayout{}) {
+		return nil
+	}
+	if err := layout.check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOptions, err)
+	}
+	if layout.Width == 0 {
+		layout.Width, layout.Height = defaultReflowWidth, defaultReflowHeight
+	}
+	if layout.FontSize == 0 {
+		layout.FontSize = defaultReflowFontSize
+	}
+	return layoutFitz(doc, layout)
+}
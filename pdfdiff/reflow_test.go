@@ -0,0 +1,120 @@
+//go:build !js
+
+package pdfdiff
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// writeEPUB writes a minimal EPUB of a single chapter of the given paragraphs
+func writeEPUB(t *testing.T, paragraphs int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "book.epub")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	body := strings.Repeat("<p>The reflowed text of a chapter, long enough to fill several pages of a small layout.</p>\n", paragraphs)
+	files := []struct{ name, content string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+<rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Reflow</dc:title></metadata>
+<manifest><item id="ch1" href="ch1.xhtml" media-type="application/xhtml+xml"/></manifest>
+<spine><itemref idref="ch1"/></spine>
+</package>`},
+		{"ch1.xhtml", `<?xml version="1.0"?>
+<html xmlns="http://www.w3.org/1999/xhtml"><body>` + body + `</body></html>`},
+	}
+	for _, file := range files {
+		out, err := w.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write([]byte(file.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReflow(t *testing.T) {
+	book := writeEPUB(t, 40)
+	tests := []struct {
+		name       string
+		layout     ReflowLayout
+		wantWidth  int
+		wantHeight int
+		morePages  bool
+		wantErr    error
+	}{
+		{"default", ReflowLayout{}, 450, 600, false, nil},
+		{"smaller page", ReflowLayout{Width: 300, Height: 400}, 300, 400, true, nil},
+		{"larger font", ReflowLayout{FontSize: 24}, 450, 600, true, nil},
+		{"width only", ReflowLayout{Width: 300}, 0, 0, false, ErrInvalidOptions},
+		{"negative font", ReflowLayout{FontSize: -1}, 0, 0, false, ErrInvalidOptions},
+	}
+	defaultPages := 0
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := Open(book)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doc.Close()
+			if err := Reflow(doc, test.layout); test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("err = %v, want %v", err, test.wantErr)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			bounds, err := doc.(*fitz.Document).Bound(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bounds.Dx() != test.wantWidth || bounds.Dy() != test.wantHeight {
+				t.Errorf("page size = %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), test.wantWidth, test.wantHeight)
+			}
+			if defaultPages == 0 {
+				defaultPages = doc.NumPage()
+			} else if test.morePages && doc.NumPage() <= defaultPages {
+				t.Errorf("pages = %d, want more than the %d of the default layout", doc.NumPage(), defaultPages)
+			}
+		})
+	}
+}
+
+func TestReflowOtherDocuments(t *testing.T) {
+	// The documents that are not reflowable keep their pages
+	data, err := os.ReadFile(filepath.Join("testdata", "encrypted", "r6-aesv3.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openMemory(data, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	if err := Reflow(doc, ReflowLayout{Width: 300, Height: 400, FontSize: 24}); err != nil {
+		t.Fatal(err)
+	}
+	if bounds, _ := doc.(*fitz.Document).Bound(0); bounds.Dx() != 200 || bounds.Dy() != 100 {
+		t.Errorf("page size = %dx%d, want the 200x100 of the PDF", bounds.Dx(), bounds.Dy())
+	}
+}
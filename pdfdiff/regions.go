@@ -0,0 +1,214 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// regionCell is the size in pixels of the cells used to group changed pixels into regions.
+// Changed pixels in the same cell or in touching cells, diagonally too, belong to the same region.
+const regionCell = 32
+
+// Region is a connected component of the changed pixels of the difference image, with its bounding box in pixels. The
+// page is divided into cells of regionCell pixels, and the changed pixels of the same cell or of cells touching by a
+// side or a corner belong to the same region: two changes up to about two cells apart can merge, while changes just
+// over a cell apart stay separate when a cell without changes lies between them.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Area is the number of changed pixels of the region, and CentroidX and CentroidY their mean position
+	Area      int     `json:"area"`
+	CentroidX float64 `json:"centroid_x"`
+	CentroidY float64 `json:"centroid_y"`
+	// Kind is KindSignature or KindStamp when the region looks like a signature or a stamp of one document only
+	Kind string `json:"kind,omitempty"`
+	// In is the document (1 or 2) the signature or stamp is in: 2 when it was added, 1 when it was removed
+	In int `json:"in,omitempty"`
+	// Thumbnail is the region of the difference image as a PNG data URL, scaled down to Options.RegionThumbnails
+	Thumbnail string `json:"thumbnail,omitempty"`
+	// old is set when the first page is brighter at most of the changed pixels, which are colored with ColorOld
+	old bool
+	// cells are the cells of the regionGrid the changed pixels of the region are in, which no other region shares
+	cells []int
+}
+
+// Mark describes the signature or stamp of the region, e.g. "signature added", or returns "" for other regions
+func (r Region) Mark() string {
+	switch {
+	case r.Kind == "":
+		return ""
+	case r.In == 1:
+		return r.Kind + " removed"
+	default:
+		return r.Kind + " added"
+	}
+}
+
+// regionPixels returns the number of pixels of a minimum region size, in pixels, e.g. "20" or "20px", or in square
+// millimeters, e.g. "0.5mm2" or "0.5mm²", on a page rendered at dpi
+func regionPixels(size string, dpi float64) (int, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, nil
+	}
+	number, mm := size, false
+	for _, unit := range []string{"mm²", "mm2", "px"} {
+		if strings.HasSuffix(size, unit) {
+			number, mm = strings.TrimSpace(strings.TrimSuffix(size, unit)), unit != "px"
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid minimum region size %q, expected pixels (e.g. 20) or square millimeters (e.g. 0.5mm2)", size)
+	}
+	if mm {
+		v *= dpi / 25.4 * dpi / 25.4
+	}
+	return int(math.Ceil(v)), nil
+}
+
+// regionGrid records the bounding box, the number and the sum of the positions of the changed pixels of every cell of
+// a page
+type regionGrid struct {
+	bounds     image.Rectangle
+	cols, rows int
+	cells      []image.Rectangle // empty for cells without changed pixels
+	pixels     []int
+	sums       [][2]int
+	old        []int // the changed pixels where the first page is brighter
+}
+
+func newRegionGrid(bounds image.Rectangle) *regionGrid {
+	cols := (bounds.Dx() + regionCell - 1) / regionCell
+	rows := (bounds.Dy() + regionCell - 1) / regionCell
+	return &regionGrid{bounds: bounds, cols: cols, rows: rows, cells: make([]image.Rectangle, cols*rows), pixels: make([]int, cols*rows), sums: make([][2]int, cols*rows), old: make([]int, cols*rows)}
+}
+
+// add records a changed pixel, and whether the first page is brighter there
+func (g *regionGrid) add(x, y int, old bool) {
+	i := (y-g.bounds.Min.Y)/regionCell*g.cols + (x-g.bounds.Min.X)/regionCell
+	g.cells[i] = g.cells[i].Union(image.Rect(x, y, x+1, y+1))
+	g.pixels[i]++
+	g.sums[i][0] += x - g.bounds.Min.X
+	g.sums[i][1] += y - g.bounds.Min.Y
+	if old {
+		g.old[i]++
+	}
+}
+
+// merge adds the changed pixels recorded by another grid of the same page
+func (g *regionGrid) merge(o *regionGrid) {
+	for i, cell := range o.cells {
+		g.cells[i] = g.cells[i].Union(cell)
+		g.pixels[i] += o.pixels[i]
+		g.sums[i][0] += o.sums[i][0]
+		g.sums[i][1] += o.sums[i][1]
+		g.old[i] += o.old[i]
+	}
+}
+
+// regions returns the groups of touching cells with changed pixels, top to bottom
+func (g *regionGrid) regions() []Region {
+	var regions []Region
+	seen := make([]bool, len(g.cells))
+	for start := range g.cells {
+		if seen[start] || g.cells[start].Empty() {
+			continue
+		}
+		// Flood fill the cells connected to this one, including diagonally
+		box := image.Rectangle{}
+		area, sumX, sumY, old := 0, 0, 0, 0
+		var cells []int
+		stack := []int{start}
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			box = box.Union(g.cells[i])
+			cells = append(cells, i)
+			area += g.pixels[i]
+			sumX, sumY = sumX+g.sums[i][0], sumY+g.sums[i][1]
+			old += g.old[i]
+			col, row := i%g.cols, i/g.cols
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					c, r := col+dx, row+dy
+					if c < 0 || r < 0 || c >= g.cols || r >= g.rows {
+						continue
+					}
+					n := r*g.cols + c
+					if !seen[n] && !g.cells[n].Empty() {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+		box = box.Sub(g.bounds.Min)
+		// The centroid is the mean position of the pixels, at their centers
+		regions = append(regions, Region{X: box.Min.X, Y: box.Min.Y, Width: box.Dx(), Height: box.Dy(), Area: area,
+			CentroidX: math.Round((float64(sumX)/float64(area)+0.5)*10) / 10, CentroidY: math.Round((float64(sumY)/float64(area)+0.5)*10) / 10, old: 2*old > area, cells: cells})
+	}
+	return regions
+}
+
+// boxWidth returns the width in pixels of the strokes of the boxes of HighlightBoxes, a quarter of a millimeter on a
+// page rendered at dpi unless it is given
+func boxWidth(width int, dpi float64) int {
+	if width > 0 {
+		return width
+	}
+	return max(1, int(math.Round(dpi/100)))
+}
+
+// drawBoxes draws a rectangle of the given stroke width around every region, outside of its bounding box so the
+// changed content stays legible, with the opaque ColorOld or ColorNew of most of its changed pixels
+func drawBoxes(img *image.RGBA, regions []Region, width int, colorOld, colorNew color.RGBA) {
+	b := img.Bounds()
+	for _, r := range regions {
+		c := colorNew
+		if r.old {
+			c = colorOld
+		}
+		c.A = 255
+		inner := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height).Add(b.Min)
+		outer := inner.Inset(-width).Intersect(b)
+		for y := outer.Min.Y; y < outer.Max.Y; y++ {
+			for x := outer.Min.X; x < outer.Max.X; x++ {
+				if !image.Pt(x, y).In(inner) {
+					img.SetRGBA(x, y, c)
+				}
+			}
+		}
+	}
+}
+
+// addThumbnails sets the thumbnails of the regions, cut from the difference image with a margin of context and scaled
+// down so their longest side is at most size pixels
+func addThumbnails(img image.Image, regions []Region, size int) error {
+	for i, r := range regions {
+		area := image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+		thumb := imaging.Crop(img, area.Inset(-max(r.Width, r.Height)/10))
+		if thumb.Bounds().Dx() > size || thumb.Bounds().Dy() > size {
+			thumb = imaging.Fit(thumb, size, size, imaging.Lanczos)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, thumb); err != nil {
+			return err
+		}
+		regions[i].Thumbnail = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRegionPixels(t *testing.T) {
+	// At 254 dpi a millimeter is 10 pixels, so a square millimeter is 100 pixels
+	const dpi = 254
+	tests := []struct {
+		size    string
+		want    int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"20", 20, false},
+		{"20px", 20, false},
+		{" 20 px ", 20, false},
+		{"2.5", 3, false},
+		{"0", 0, false},
+		{"1mm2", 100, false},
+		{"0.5mm2", 50, false},
+		{"0.5 mm2", 50, false},
+		{"0.5mm²", 50, false},
+		{"0.011mm2", 2, false},
+		{"-1", 0, true},
+		{"-0.5mm2", 0, true},
+		{"twenty", 0, true},
+		{"px", 0, true},
+		{"mm2", 0, true},
+		{"20mm", 0, true},
+		{"20cm2", 0, true},
+	}
+	for _, test := range tests {
+		got, err := regionPixels(test.size, dpi)
+		if (err != nil) != test.wantErr {
+			t.Errorf("regionPixels(%q) err = %v, want error %v", test.size, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("regionPixels(%q) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
+
+func TestRegionsAdjacentCells(t *testing.T) {
+	tests := []struct {
+		name        string
+		changes     []image.Point
+		wantRegions int
+	}{
+		// Almost two cells apart, in touching cells
+		{"touching cells", []image.Point{{2, 5}, {62, 5}}, 1},
+		{"diagonal cells", []image.Point{{2, 2}, {62, 62}}, 1},
+		// Just over a cell apart, with a cell without changes between them
+		{"cell between", []image.Point{{30, 5}, {64, 5}}, 2},
+		{"same cell", []image.Point{{1, 1}, {30, 30}}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			img1 := whitePage(4*regionCell, 2*regionCell)
+			img2 := whitePage(4*regionCell, 2*regionCell)
+			for _, p := range test.changes {
+				img2.Set(p.X, p.Y, color.Black)
+			}
+			_, _, regions, _ := diffImages(img1, img2, nil, &Options{})
+			if len(regions) != test.wantRegions {
+				t.Errorf("regions = %d, want %d", len(regions), test.wantRegions)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultChunkSize is the number of pages sent to a server at a time
+const DefaultChunkSize = 16
+
+// Coordinator farms the page ranges of a comparison out to remote servers and merges their results.
+// A server that fails is dropped and its range is compared by the others.
+type Coordinator struct {
+	// Endpoints are the addresses of the servers, e.g. host:50051
+	Endpoints []string
+	// ChunkSize is the number of pages of the first document in every range (Default: DefaultChunkSize)
+	ChunkSize int
+	// TLS encrypts the connections to the servers when set, verifying their certificates with its RootCAs
+	TLS *tls.Config
+	// Token is sent to the servers started with a Token
+	Token string
+}
+
+// pageRange is a range of pages of the first document, [from, to)
+type pageRange struct {
+	from, to int
+}
+
+// rangeResult is sent by an endpoint when it has compared a range, or failed to
+type rangeResult struct {
+	endpoint string
+	r        pageRange
+	resp     *CompareResponse
+	err      error
+}
+
+// Compare compares two documents like pdfdiff.Compare, but on the remote servers. The images are written to
+// opts.OutputDir, or kept in the Images of the report with InMemory. Workers is left to each server, and Prioritize and Pipeline are ignored.
+func (c *Coordinator) Compare(ctx context.Context, file1, file2 string, opts pdfdiff.Options) (pdfdiff.Report, error) {
+	if opts.OutputDir == "" {
+		opts.OutputDir = "."
+	}
+	if opts.DPI == 0 {
+		opts.DPI = pdfdiff.DefaultDPI
+	}
+	report := pdfdiff.Report{SchemaVersion: pdfdiff.SchemaVersion, File1: file1, File2: file2, DPI: opts.DPI, Dir: opts.OutputDir, Config: pdfdiff.EffectiveConfig(opts)}
+	if len(c.Endpoints) == 0 {
+		return report, fmt.Errorf("%w: no remote endpoints", pdfdiff.ErrInvalidOptions)
+	}
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	// Count the pages locally to split the comparison, which also reports broken inputs before anything is sent
+	numJobs, err := countPages(&report, &opts)
+	if err != nil {
+		return report, err
+	}
+	doc1, err := readDocument(file1, opts.Password1)
+	if err != nil {
+		return report, err
+	}
+	doc2, err := readDocument(file2, opts.Password2)
+	if err != nil {
+		return report, err
+	}
+	if opts.InMemory {
+		report.Images = pdfdiff.NewMemoryImages()
+	} else if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return report, err
+	}
+
+	// Queue every range. A range is queued again when its endpoint fails, so the channel holds all of them.
+	var ranges []pageRange
+	for from := 0; from < numJobs; from += chunkSize {
+		ranges = append(ranges, pageRange{from: from, to: from + chunkSize})
+	}
+	queue := make(chan pageRange, len(ranges))
+	for _, r := range ranges {
+		queue <- r
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan rangeResult)
+	for _, endpoint := range c.Endpoints {
+		go c.runEndpoint(ctx, endpoint, doc1, doc2, opts, queue, results)
+	}
+
+	alive := len(c.Endpoints)
+	completed := 0
+	// A cancelled comparison still reports the ranges completed before it stopped
+	partial := func() (pdfdiff.Report, error) {
+		report.Partial = true
+		sortPages(&report)
+		return report, ctx.Err()
+	}
+	for done := 0; done < len(ranges); {
+		var res rangeResult
+		select {
+		case res = <-results:
+		case <-ctx.Done():
+			return partial()
+		}
+		if res.err != nil {
+			if ctx.Err() != nil {
+				return partial()
+			}
+			if isFatal(res.err) {
+				return report, fmt.Errorf("%s: %s", res.endpoint, status.Convert(res.err).Message())
+			}
+			// Give the range to the other endpoints
+			alive--
+			if alive == 0 {
+				return report, fmt.Errorf("every remote endpoint failed, the last one with %s: %v", res.endpoint, res.err)
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s failed, its pages are compared by the other endpoints: %v\n", res.endpoint, res.err)
+			queue <- res.r
+			continue
+		}
+
+		for name, data := range res.resp.Images {
+			if report.Images != nil {
+				report.Images.Add(name, data)
+				continue
+			}
+			// Never let a server write outside the artifacts directory
+			if err := os.WriteFile(filepath.Join(opts.OutputDir, filepath.Base(name)), data, 0644); err != nil {
+				return report, err
+			}
+		}
+		// Every server detects the same watermarks, since it has the whole documents
+		report.WatermarkIn = res.resp.Report.WatermarkIn
+		for _, page := range res.resp.Report.Pages {
+			report.Pages = append(report.Pages, page)
+			if opts.PageDone != nil {
+				opts.PageDone(page)
+			}
+			if opts.OnPageDone != nil {
+				if err := opts.OnPageDone(page); err != nil {
+					return report, err
+				}
+			}
+			for _, name := range []string{page.DiffImage, page.CombinedImage, page.Image1, page.Image2} {
+				if name == "" || opts.OnArtifact == nil {
+					continue
+				}
+				if err := opts.OnArtifact(page.Page, filepath.Join(opts.OutputDir, filepath.Base(name))); err != nil {
+					return report, err
+				}
+			}
+		}
+		done++
+		completed += min(res.r.to, numJobs) - res.r.from
+		if opts.Progress != nil {
+			opts.Progress(completed, numJobs)
+		}
+	}
+
+	sortPages(&report)
+	// The checks add up the fields of every range
+	report.Checks = pdfdiff.CheckFields(opts.Checks, opts.Fields, report.Pages)
+	report.Languages = pdfdiff.DocumentLanguages(report.Pages)
+	report.Glyphs = pdfdiff.GlyphTotals(report.Pages)
+	return report, nil
+}
+
+// sortPages puts the pages merged from the servers in page order and computes their mean similarity
+func sortPages(report *pdfdiff.Report) {
+	sort.Slice(report.Pages, func(i, j int) bool {
+		return report.Pages[i].Page < report.Pages[j].Page
+	})
+	report.SSIM = pdfdiff.MeanSSIM(report.Pages)
+}
+
+// countPages opens the documents to fill in their number of pages, checks the options and returns the number of jobs.
+// The pages of an aligned comparison are aligned here once, and every server compares the pairs it is sent.
+// The breaks of the text are found here too.
+func countPages(report *pdfdiff.Report, opts *pdfdiff.Options) (int, error) {
+	doc1, err := pdfdiff.OpenWithPassword(report.File1, opts.Password1)
+	if err != nil {
+		return 0, &pdfdiff.InputError{File: report.File1, Err: err}
+	}
+	defer doc1.Close()
+	doc2, err := pdfdiff.OpenWithPassword(report.File2, opts.Password2)
+	if err != nil {
+		return 0, &pdfdiff.InputError{File: report.File2, Err: err}
+	}
+	defer doc2.Close()
+	if err := pdfdiff.Reflow(doc1, opts.Reflow); err != nil {
+		return 0, &pdfdiff.InputError{File: report.File1, Err: err}
+	}
+	if err := pdfdiff.Reflow(doc2, opts.Reflow); err != nil {
+		return 0, &pdfdiff.InputError{File: report.File2, Err: err}
+	}
+
+	pdfdiff.MatchPages(doc1, doc2)
+	report.Pages1, report.Pages2 = doc1.NumPage(), doc2.NumPage()
+	if err := pdfdiff.CheckOptions(*opts, report.Pages1, report.Pages2); err != nil {
+		return 0, err
+	}
+	if opts.Align && opts.Alignment == nil {
+		if opts.Alignment, err = pdfdiff.AlignPages(doc1, doc2); err != nil {
+			return 0, err
+		}
+	}
+	// The breaks are found in the text of the whole documents, which the servers only see a range of
+	if opts.TextDiff && !opts.OCR {
+		if report.Breaks, err = pdfdiff.BreakChanges(doc1, doc2); err != nil {
+			return 0, err
+		}
+	}
+	if opts.Typography {
+		if report.Typography, err = pdfdiff.TypographyDefects(doc1, doc2); err != nil {
+			return 0, err
+		}
+	}
+	if opts.Alignment != nil {
+		return len(opts.Alignment), nil
+	}
+	if report.Pages1 > report.Pages2 {
+		return report.Pages1, nil
+	}
+	return report.Pages2, nil
+}
+
+// readDocument loads a document and computes its hash. An encrypted PDF is decrypted with its password first, so the
+// password never leaves this machine.
+func readDocument(file, password string) (Document, error) {
+	data, err := os.ReadFile(file)
+	if err == nil && password != "" && strings.EqualFold(filepath.Ext(file), ".pdf") {
+		data, err = pdfdiff.DecryptPDF(data, password)
+	}
+	if err != nil {
+		return Document{}, &pdfdiff.InputError{File: file, Err: err}
+	}
+	sum := sha256.Sum256(data)
+	return Document{Name: filepath.Base(file), Hash: hex.EncodeToString(sum[:]), Data: data}, nil
+}
+
+// runEndpoint compares the queued ranges on one server until the queue is done or the server fails
+func (c *Coordinator) runEndpoint(ctx context.Context, endpoint string, doc1, doc2 Document, opts pdfdiff.Options, queue chan pageRange, results chan<- rangeResult) {
+	send := func(res rangeResult) bool {
+		select {
+		case results <- res:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	creds := insecure.NewCredentials()
+	if c.TLS != nil {
+		creds = credentials.NewTLS(c.TLS)
+	}
+	dialOpts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultCallOptions(
+			grpc.ForceCodec(gobCodec{}),
+			grpc.MaxCallRecvMsgSize(maxMessageSize),
+			grpc.MaxCallSendMsgSize(maxMessageSize),
+		),
+	}
+	if c.Token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(TokenCredentials(c.Token)))
+	}
+	conn, err := grpc.Dial(endpoint, dialOpts...)
+	if err != nil {
+		select {
+		case r := <-queue:
+			send(rangeResult{endpoint: endpoint, r: r, err: err})
+		case <-ctx.Done():
+		}
+		return
+	}
+	defer conn.Close()
+
+	// The documents are only sent with the first request, or again if the server has lost them
+	uploaded := false
+	for {
+		var r pageRange
+		select {
+		case r = <-queue:
+		case <-ctx.Done():
+			return
+		}
+		if err := opts.Pauser.Wait(ctx); err != nil {
+			return
+		}
+
+		req := &CompareRequest{
+			File1:               doc1,
+			File2:               doc2,
+			Offset:              opts.Offset,
+			StartOffset:         opts.StartOffset,
+			Alignment:           opts.Alignment,
+			SideBySide:          opts.SideBySide,
+			VerticalAlign:       opts.VerticalAlign,
+			PageImages:          opts.PageImages,
+			Threshold:           opts.Threshold,
+			AdaptiveThreshold:   opts.AdaptiveThreshold,
+			SkipIdentical:       opts.SkipIdentical,
+			ColorOld:            opts.ColorOld,
+			ColorNew:            opts.ColorNew,
+			Heatmap:             opts.Heatmap,
+			HighlightStyle:      opts.HighlightStyle,
+			BoxWidth:            opts.BoxWidth,
+			DPI:                 opts.DPI,
+			MultiDPI:            opts.MultiDPI,
+			RemoveWatermarks:    opts.RemoveWatermarks,
+			NormalizeBackground: opts.NormalizeBackground,
+			Despeckle:           opts.Despeckle,
+			Segment:             opts.Segment,
+			Charts:              opts.Charts,
+			Quarantine:          opts.Quarantine,
+			RegionThumbnails:    opts.RegionThumbnails,
+			Equations:           opts.Equations,
+			Glyphs:              opts.Glyphs,
+			VectorOverlay:       opts.VectorOverlay,
+			Dimensions:          opts.Dimensions,
+			TextDiff:            opts.TextDiff,
+			OCR:                 opts.OCR,
+			OCRLanguage:         opts.OCRLanguage,
+			IgnoreText:          opts.IgnoreText,
+			Prefix:              opts.Prefix,
+			Descreen:            opts.Descreen,
+			IgnoreRegions:       opts.IgnoreRegions,
+			CriticalRegions:     opts.CriticalRegions,
+			Fields:              opts.Fields,
+			Language:            opts.Language,
+			Crop:                opts.Crop,
+			Reflow:              opts.Reflow,
+			IgnoreAntialiasing:  opts.IgnoreAntialiasing,
+			MinRegionSize:       opts.MinRegionSize,
+			From:                r.from,
+			To:                  r.to,
+		}
+		resp := &CompareResponse{}
+		if uploaded {
+			req.File1.Data, req.File2.Data = nil, nil
+			err = conn.Invoke(ctx, compareMethod, req, resp)
+			if status.Code(err) == codes.NotFound {
+				req.File1.Data, req.File2.Data = doc1.Data, doc2.Data
+				err = conn.Invoke(ctx, compareMethod, req, resp)
+			}
+		} else {
+			err = conn.Invoke(ctx, compareMethod, req, resp)
+		}
+		if err != nil {
+			send(rangeResult{endpoint: endpoint, r: r, err: err})
+			return
+		}
+		uploaded = true
+		if !send(rangeResult{endpoint: endpoint, r: r, resp: resp}) {
+			return
+		}
+	}
+}
+
+// isFatal reports whether a server error comes from the comparison itself, so another server would fail the same way
+func isFatal(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.Internal:
+		return true
+	}
+	return false
+}
+
+// min returns the smaller of two int numbers.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
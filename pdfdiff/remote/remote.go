@@ -0,0 +1,157 @@
+// Package remote distributes a comparison between several machines. A Server runs on every worker machine and
+// compares the page ranges it receives over gRPC; a Coordinator splits the documents into page ranges, farms them
+// out to the servers and merges their results into a single report and artifacts directory.
+//
+// The service of the workers is described in Go rather than protobuf: messages are encoded with encoding/gob, so no
+// generated code is needed and both ends only have to run the same version of the tool. Other services submit
+// comparisons to the public service of pdfdiff.proto (see Proto) instead, whose messages are protobuf.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+
+	"PdfDiff/pdfdiff"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultAddress is the address a Server listens on by default, which only accepts local coordinators
+const DefaultAddress = "localhost:50051"
+
+// maxMessageSize bounds the messages a coordinator exchanges with the servers, which carry whole documents and page
+// images
+const maxMessageSize = 1 << 30
+
+// DefaultMaxRequestSize bounds the requests a Server accepts by default, which carry both documents
+const DefaultMaxRequestSize = 256 << 20
+
+// DefaultCacheSize bounds the total size of the documents a Server caches by default
+const DefaultCacheSize = 2 << 30
+
+const compareMethod = "/pdfdiff.Worker/Compare"
+
+// Document is an input of a comparison, identified by the SHA-256 hash of its content.
+// Data is left empty when the server already has the document in its cache.
+type Document struct {
+	Name string
+	Hash string
+	Data []byte
+}
+
+// CompareRequest asks a server to compare a page range of two documents
+type CompareRequest struct {
+	File1, File2        Document
+	Offset              int
+	StartOffset         int
+	Alignment           []pdfdiff.PagePair
+	SideBySide          bool
+	VerticalAlign       bool
+	PageImages          bool
+	Threshold           int
+	AdaptiveThreshold   bool
+	SkipIdentical       bool
+	ColorOld, ColorNew  string
+	Heatmap             bool
+	HighlightStyle      string
+	BoxWidth            int
+	DPI                 float64
+	MultiDPI            []float64
+	RemoveWatermarks    bool
+	NormalizeBackground bool
+	Despeckle           bool
+	Segment             bool
+	Charts              bool
+	RegionThumbnails    int
+	Equations           bool
+	Glyphs              bool
+	VectorOverlay       string
+	Dimensions          bool
+	TextDiff            bool
+	OCR                 bool
+	OCRLanguage         string
+	IgnoreText          []string
+	Prefix              string
+	Descreen            bool
+	IgnoreAntialiasing  bool
+	MinRegionSize       string
+	From, To            int
+	IgnoreRegions       []pdfdiff.IgnoreRegion
+	CriticalRegions     []pdfdiff.IgnoreRegion
+	Fields              []pdfdiff.TemplateField
+	Language            string
+	Quarantine          []int
+	Crop                pdfdiff.Margins
+	Reflow              pdfdiff.ReflowLayout
+}
+
+// CompareResponse carries the result of a page range and the images produced for it, by file name
+type CompareResponse struct {
+	Report pdfdiff.Report
+	Images map[string][]byte
+}
+
+// gobCodec encodes the gRPC messages with encoding/gob
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+// workerService is implemented by Server
+type workerService interface {
+	compare(ctx context.Context, req *CompareRequest) (*CompareResponse, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pdfdiff.Worker",
+	HandlerType: (*workerService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Compare",
+		Handler:    compareHandler,
+	}},
+	Metadata: "pdfdiff/remote",
+}
+
+func compareHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(CompareRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(workerService).compare(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: compareMethod}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(workerService).compare(ctx, req.(*CompareRequest))
+	})
+}
+
+// tokenCredentials sends a bearer token with every call, which a Server with a Token checks
+type tokenCredentials string
+
+// TokenCredentials returns the credentials of the calls to a Server started with a Token, e.g. for
+// grpc.WithPerRPCCredentials. Without TLS the token is sent in clear.
+func TokenCredentials(token string) credentials.PerRPCCredentials {
+	return tokenCredentials(token)
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,282 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"PdfDiff/pdfdiff"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/tap"
+)
+
+// Server compares the page ranges sent by a Coordinator, and the comparisons submitted to the public service of
+// pdfdiff.proto. The documents are kept in a cache directory,
+// so they are only transferred once to every server.
+type Server struct {
+	// TLS encrypts the connections when set, with the certificate of the server
+	TLS *tls.Config
+	// Token, when set, is the bearer token every call must carry (see TokenCredentials)
+	Token string
+	// CacheSize bounds the total size of the cached documents in bytes, evicting the least recently used ones that no
+	// comparison in progress uses (Default: DefaultCacheSize)
+	CacheSize int64
+	// MaxRequestSize bounds the size of a request in bytes (Default: DefaultMaxRequestSize)
+	MaxRequestSize int
+
+	cacheDir string
+	workers  int
+	mu       sync.Mutex     // serializes the writes and evictions of the cache
+	inUse    map[string]int // the number of comparisons in progress using each cached document
+}
+
+// NewServer returns a Server that caches the documents in cacheDir and compares each range with the given
+// number of workers (Default: CPU count)
+func NewServer(cacheDir string, workers int) *Server {
+	return &Server{cacheDir: cacheDir, workers: workers, inUse: make(map[string]int)}
+}
+
+// Serve accepts coordinator connections on the listener until it fails
+func (s *Server) Serve(lis net.Listener) error {
+	if err := os.MkdirAll(s.cacheDir, 0755); err != nil {
+		return err
+	}
+	maxRequestSize := s.MaxRequestSize
+	if maxRequestSize <= 0 {
+		maxRequestSize = DefaultMaxRequestSize
+	}
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(serverCodec{}),
+		grpc.MaxRecvMsgSize(maxRequestSize),
+		grpc.MaxSendMsgSize(maxMessageSize),
+	}
+	if s.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.TLS)))
+	}
+	if s.Token != "" {
+		opts = append(opts, grpc.InTapHandle(s.authorize))
+	}
+	srv := grpc.NewServer(opts...)
+	srv.RegisterService(&serviceDesc, s)
+	srv.RegisterService(&publicServiceDesc, s)
+	return srv.Serve(lis)
+}
+
+// authorize rejects the calls without the token of the server, before their request is read
+func (s *Server) authorize(ctx context.Context, info *tap.Info) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(value), []byte("Bearer "+s.Token)) == 1 {
+			return ctx, nil
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+func (s *Server) compare(ctx context.Context, req *CompareRequest) (*CompareResponse, error) {
+	file1, err := s.document(req.File1)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(file1)
+	file2, err := s.document(req.File2)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(file2)
+
+	// Every range gets its own directory, so the images of concurrent requests never mix
+	dir, err := os.MkdirTemp("", "pdfdiff-remote-")
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	report, err := pdfdiff.Compare(ctx, file1, file2, pdfdiff.Options{
+		Offset:              req.Offset,
+		StartOffset:         req.StartOffset,
+		Alignment:           req.Alignment,
+		SideBySide:          req.SideBySide,
+		VerticalAlign:       req.VerticalAlign,
+		PageImages:          req.PageImages,
+		Threshold:           req.Threshold,
+		AdaptiveThreshold:   req.AdaptiveThreshold,
+		SkipIdentical:       req.SkipIdentical,
+		ColorOld:            req.ColorOld,
+		ColorNew:            req.ColorNew,
+		Heatmap:             req.Heatmap,
+		HighlightStyle:      req.HighlightStyle,
+		BoxWidth:            req.BoxWidth,
+		DPI:                 req.DPI,
+		MultiDPI:            req.MultiDPI,
+		RemoveWatermarks:    req.RemoveWatermarks,
+		NormalizeBackground: req.NormalizeBackground,
+		Despeckle:           req.Despeckle,
+		Segment:             req.Segment,
+		Charts:              req.Charts,
+		Quarantine:          req.Quarantine,
+		RegionThumbnails:    req.RegionThumbnails,
+		Equations:           req.Equations,
+		Glyphs:              req.Glyphs,
+		VectorOverlay:       req.VectorOverlay,
+		Dimensions:          req.Dimensions,
+		TextDiff:            req.TextDiff,
+		OCR:                 req.OCR,
+		OCRLanguage:         req.OCRLanguage,
+		IgnoreText:          req.IgnoreText,
+		Prefix:              req.Prefix,
+		Descreen:            req.Descreen,
+		IgnoreRegions:       req.IgnoreRegions,
+		CriticalRegions:     req.CriticalRegions,
+		Fields:              req.Fields,
+		Language:            req.Language,
+		Crop:                req.Crop,
+		Reflow:              req.Reflow,
+		IgnoreAntialiasing:  req.IgnoreAntialiasing,
+		MinRegionSize:       req.MinRegionSize,
+		From:                req.From,
+		To:                  req.To,
+		Workers:             s.workers,
+		OutputDir:           dir,
+	})
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	// The coordinator records the configuration of the whole comparison, and gob cannot encode the values of the map
+	report.Config = nil
+	resp := &CompareResponse{Report: report, Images: make(map[string][]byte)}
+	for _, page := range report.Pages {
+		names := []string{page.DiffImage, page.CombinedImage, page.Image1, page.Image2}
+		if page.Vectors != nil {
+			names = append(names, page.Vectors.Overlay)
+		}
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			resp.Images[name] = data
+		}
+	}
+	return resp, nil
+}
+
+// statusError returns the gRPC status of an error of a comparison
+func statusError(err error) error {
+	var inputErr *pdfdiff.InputError
+	switch {
+	case errors.Is(err, pdfdiff.ErrInvalidOptions):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &inputErr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// document returns the path of a document in the cache, saving it first if the request carries its content. The
+// document is kept in the cache until it is released.
+func (s *Server) document(doc Document) (string, error) {
+	if _, err := hex.DecodeString(doc.Hash); err != nil || len(doc.Hash) != sha256.Size*2 {
+		return "", status.Errorf(codes.InvalidArgument, "invalid hash for %s", doc.Name)
+	}
+	// The extension tells the backend how to open the document
+	path := filepath.Join(s.cacheDir, doc.Hash+strings.ToLower(filepath.Ext(doc.Name)))
+
+	if len(doc.Data) != 0 {
+		sum := sha256.Sum256(doc.Data)
+		if hex.EncodeToString(sum[:]) != doc.Hash {
+			return "", status.Errorf(codes.InvalidArgument, "the content of %s does not match its hash", doc.Name)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(path); err == nil {
+		// The modification time orders the documents from the least recently used
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		s.inUse[path]++
+		return path, nil
+	}
+	if len(doc.Data) == 0 {
+		return "", status.Errorf(codes.NotFound, "%s is not in the cache", doc.Name)
+	}
+	// Write to a temporary file first, so a failed transfer never leaves a truncated document in the cache
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, doc.Data, 0644); err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+	s.inUse[path]++
+	s.evict()
+	return path, nil
+}
+
+// release tells the cache that a comparison no longer uses a document
+func (s *Server) release(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inUse[path]--; s.inUse[path] <= 0 {
+		delete(s.inUse, path)
+	}
+}
+
+// evict removes the least recently used documents that are not in use until the cache fits in CacheSize. The caller
+// holds s.mu.
+func (s *Server) evict() {
+	limit := s.CacheSize
+	if limit <= 0 {
+		limit = DefaultCacheSize
+	}
+	entries, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return
+	}
+	var files []fs.FileInfo
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, info := range files {
+		if total <= limit {
+			break
+		}
+		path := filepath.Join(s.cacheDir, info.Name())
+		if s.inUse[path] > 0 {
+			continue
+		}
+		if os.Remove(path) == nil {
+			total -= info.Size()
+		}
+	}
+}
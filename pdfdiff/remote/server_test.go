@@ -0,0 +1,134 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"PdfDiff/pdfdiff"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// testDocument returns a document of the given size, with its hash
+func testDocument(name string, size int, fill byte) Document {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = fill
+	}
+	sum := sha256.Sum256(data)
+	return Document{Name: name, Hash: hex.EncodeToString(sum[:]), Data: data}
+}
+
+func TestServerToken(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(t.TempDir(), 1)
+	s.Token = "secret"
+	go s.Serve(lis)
+	defer lis.Close()
+
+	tests := []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{"no token", "", codes.Unauthenticated},
+		{"wrong token", "guess", codes.Unauthenticated},
+		// The request is read and rejected for its hash
+		{"token", "secret", codes.InvalidArgument},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+			if test.token != "" {
+				opts = append(opts, grpc.WithPerRPCCredentials(TokenCredentials(test.token)))
+			}
+			conn, err := grpc.Dial(lis.Addr().String(), opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			err = conn.Invoke(ctx, compareMethod, &CompareRequest{File1: Document{Name: "a.pdf", Hash: "bad"}}, new(CompareResponse), grpc.ForceCodec(gobCodec{}))
+			if got := status.Code(err); got != test.want {
+				t.Errorf("code = %v, want %v (%v)", got, test.want, err)
+			}
+		})
+	}
+}
+
+func TestServerCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	s := NewServer(dir, 1)
+	s.CacheSize = 250
+
+	// The oldest document is in use, the second oldest is not
+	inUse, err := s.document(testDocument("a.pdf", 100, 'a'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := s.document(testDocument("b.pdf", 100, 'b'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.release(old)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(inUse, past, past)
+	os.Chtimes(old, past.Add(time.Minute), past.Add(time.Minute))
+
+	latest, err := s.document(testDocument("c.pdf", 100, 'c'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{inUse: true, old: false, latest: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s cached = %v, want %v", filepath.Base(path), err == nil, want)
+		}
+	}
+
+	// Once released, the document is evicted by the next write
+	s.release(inUse)
+	s.release(latest)
+	if _, err := s.document(testDocument("d.pdf", 100, 'd')); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(inUse); err == nil {
+		t.Errorf("%s is still cached once released", filepath.Base(inUse))
+	}
+	// An evicted document is sent again
+	doc := testDocument("b.pdf", 100, 'b')
+	doc.Data = nil
+	if _, err := s.document(doc); status.Code(err) != codes.NotFound {
+		t.Errorf("evicted document: %v, want NotFound", err)
+	}
+}
+
+func TestCompareResponseGob(t *testing.T) {
+	images := pdfdiff.NewMemoryImages()
+	images.Add("differences_0.png", []byte("png"))
+	for _, report := range []pdfdiff.Report{{Pages: []pdfdiff.PageResult{{Page: 0}}}, {Images: images}} {
+		data, err := gobCodec{}.Marshal(&CompareResponse{Report: report})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp CompareResponse
+		if err := (gobCodec{}).Unmarshal(data, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if report.Images != nil && string(resp.Report.Images.Bytes("differences_0.png")) != "png" {
+			t.Errorf("images = %v, want the images sent", resp.Report.Images)
+		}
+	}
+}
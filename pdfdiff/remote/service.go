@@ -0,0 +1,725 @@
+package remote
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"PdfDiff/pdfdiff"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The public service of pdfdiff.proto lets other services submit comparisons and follow their progress. Unlike the
+// interface of the workers, its messages are encoded in the protobuf wire format, so clients generated from the
+// .proto file in any language can call it. The encoding is written by hand, so no generated code is needed here.
+
+// Proto is the protobuf definition of the public service
+//
+//go:embed pdfdiff.proto
+var Proto []byte
+
+const compareStreamMethod = "/pdfdiff.v1.PdfDiff/Compare"
+
+// InputDocument is a document to compare, whose name tells its format
+type InputDocument struct {
+	Name string
+	Data []byte
+}
+
+// ComparisonOptions are the options of a comparison of the public service, named after the Options of pdfdiff
+type ComparisonOptions struct {
+	DPI                float64
+	Threshold          int
+	AdaptiveThreshold  bool
+	IgnoreAntialiasing bool
+	Offset             int
+	StartOffset        int
+	Align              bool
+	SkipIdentical      bool
+	ColorOld, ColorNew string
+	Heatmap            bool
+	IgnoreText         []string
+	TextDiff           bool
+	SideBySide         bool
+	MinRegionSize      string
+	HighlightStyle     string
+	BoxWidth           int
+}
+
+// ComparisonRequest submits a comparison to the public service
+type ComparisonRequest struct {
+	File1, File2 InputDocument
+	Options      ComparisonOptions
+	// Images streams the images written for every page
+	Images bool
+}
+
+// ComparisonEvent is an event of the stream of a comparison, of which exactly one field is set
+type ComparisonEvent struct {
+	Progress *ComparisonProgress
+	Page     *PageDiff
+	Image    *PageImage
+	Report   *ComparisonReport
+}
+
+// ComparisonProgress is the number of pages compared so far, out of the total
+type ComparisonProgress struct {
+	Completed, Total int
+}
+
+// PageDiff is the result of a page, like the pages of the JSON report
+type PageDiff struct {
+	Page           int
+	Changed        bool
+	ChangedPixels  int
+	PercentChanged float64
+	SSIM           float64
+	Width, Height  int
+	Regions        []ChangedRegion
+	MissingIn      int
+}
+
+// ChangedRegion is a bounding box of changed pixels, in pixels of the difference image, with the number of changed
+// pixels and their centroid
+type ChangedRegion struct {
+	X, Y, Width, Height  int
+	Kind                 string
+	Area                 int
+	CentroidX, CentroidY float64
+}
+
+// PageImage is an image written for a page
+type PageImage struct {
+	Page int
+	Name string
+	Data []byte
+}
+
+// ComparisonReport ends the stream of a comparison, with its JSON report
+type ComparisonReport struct {
+	Pages1, Pages2 int
+	SSIM           float64
+	ChangedPages   []int
+	JSON           string
+}
+
+// options returns the options of pdfdiff
+func (o ComparisonOptions) options() pdfdiff.Options {
+	return pdfdiff.Options{
+		DPI:                o.DPI,
+		Threshold:          o.Threshold,
+		AdaptiveThreshold:  o.AdaptiveThreshold,
+		IgnoreAntialiasing: o.IgnoreAntialiasing,
+		MinRegionSize:      o.MinRegionSize,
+		Offset:             o.Offset,
+		StartOffset:        o.StartOffset,
+		Align:              o.Align,
+		SkipIdentical:      o.SkipIdentical,
+		ColorOld:           o.ColorOld,
+		ColorNew:           o.ColorNew,
+		Heatmap:            o.Heatmap,
+		HighlightStyle:     o.HighlightStyle,
+		BoxWidth:           o.BoxWidth,
+		IgnoreText:         o.IgnoreText,
+		TextDiff:           o.TextDiff,
+		SideBySide:         o.SideBySide,
+	}
+}
+
+// newPageDiff converts the result of a page
+func newPageDiff(page pdfdiff.PageResult) *PageDiff {
+	diff := &PageDiff{
+		Page:           page.Page,
+		Changed:        page.Changed,
+		ChangedPixels:  page.ChangedPixels,
+		PercentChanged: page.PercentChanged,
+		SSIM:           page.SSIM,
+		Width:          page.Width,
+		Height:         page.Height,
+		MissingIn:      page.MissingIn,
+	}
+	for _, r := range page.Regions {
+		diff.Regions = append(diff.Regions, ChangedRegion{X: r.X, Y: r.Y, Width: r.Width, Height: r.Height, Kind: r.Kind,
+			Area: r.Area, CentroidX: r.CentroidX, CentroidY: r.CentroidY})
+	}
+	return diff
+}
+
+// comparisonService is implemented by Server
+type comparisonService interface {
+	compareStream(req *ComparisonRequest, stream grpc.ServerStream) error
+}
+
+var publicServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pdfdiff.v1.PdfDiff",
+	HandlerType: (*comparisonService)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Compare",
+		Handler:       compareStreamHandler,
+		ServerStreams: true,
+	}},
+	Metadata: "pdfdiff.proto",
+}
+
+func compareStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(ComparisonRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(comparisonService).compareStream(req, stream)
+}
+
+func (s *Server) compareStream(req *ComparisonRequest, stream grpc.ServerStream) error {
+	for _, doc := range []InputDocument{req.File1, req.File2} {
+		if doc.Name == "" || len(doc.Data) == 0 {
+			return status.Error(codes.InvalidArgument, "both documents need a name and their content")
+		}
+	}
+	dir, err := os.MkdirTemp("", "pdfdiff-service-")
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	// The callbacks are called by several goroutines, and a stream sends one message at a time
+	var mu sync.Mutex
+	send := func(event *ComparisonEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.SendMsg(event)
+	}
+	opts := req.Options.options()
+	opts.Workers = s.workers
+	opts.OutputDir = dir
+	opts.Progress = func(completed, total int) {
+		// A failed send means the client is gone, which cancels the comparison with the context of the stream
+		send(&ComparisonEvent{Progress: &ComparisonProgress{Completed: completed, Total: total}})
+	}
+	opts.OnPageDone = func(page pdfdiff.PageResult) error {
+		return send(&ComparisonEvent{Page: newPageDiff(page)})
+	}
+	if req.Images {
+		opts.OnArtifact = func(page int, path string) error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			return send(&ComparisonEvent{Image: &PageImage{Page: page, Name: filepath.Base(path), Data: data}})
+		}
+	}
+
+	report, err := pdfdiff.CompareInputs(stream.Context(), pdfdiff.BytesInput(req.File1.Name, req.File1.Data),
+		pdfdiff.BytesInput(req.File2.Name, req.File2.Data), opts)
+	if err != nil {
+		return statusError(err)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	result := &ComparisonReport{Pages1: report.Pages1, Pages2: report.Pages2, SSIM: report.SSIM, JSON: string(data)}
+	for _, page := range report.ChangedPages() {
+		result.ChangedPages = append(result.ChangedPages, page.Page)
+	}
+	return send(&ComparisonEvent{Report: result})
+}
+
+// ServiceClient calls the public service of a server from Go
+type ServiceClient struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewServiceClient returns a client of the public service of the server of conn
+func NewServiceClient(conn grpc.ClientConnInterface) *ServiceClient {
+	return &ServiceClient{conn: conn}
+}
+
+// Compare submits a comparison and calls event with every event of its stream, until the report. An error returned
+// by event cancels the comparison.
+func (c *ServiceClient) Compare(ctx context.Context, req *ComparisonRequest, event func(*ComparisonEvent) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.conn.NewStream(ctx, &publicServiceDesc.Streams[0], compareStreamMethod,
+		grpc.ForceCodec(protoCodec{}), grpc.MaxCallRecvMsgSize(maxMessageSize), grpc.MaxCallSendMsgSize(maxMessageSize))
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		ev := new(ComparisonEvent)
+		if err := stream.RecvMsg(ev); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := event(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// protoMessage is a message of the public service
+type protoMessage interface {
+	marshalProto() []byte
+	unmarshalProto(data []byte) error
+}
+
+// protoCodec encodes the messages of the public service in the protobuf wire format
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "%T is not a message of pdfdiff.proto", v)
+	}
+	return m.marshalProto(), nil
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(protoMessage)
+	if !ok {
+		return status.Errorf(codes.Internal, "%T is not a message of pdfdiff.proto", v)
+	}
+	return m.unmarshalProto(data)
+}
+
+func (protoCodec) Name() string {
+	return "proto"
+}
+
+// serverCodec encodes the messages of the public service like protoCodec, and the ones of the workers like gobCodec
+type serverCodec struct{}
+
+func (serverCodec) Marshal(v interface{}) ([]byte, error) {
+	if _, ok := v.(protoMessage); ok {
+		return protoCodec{}.Marshal(v)
+	}
+	return gobCodec{}.Marshal(v)
+}
+
+func (serverCodec) Unmarshal(data []byte, v interface{}) error {
+	if _, ok := v.(protoMessage); ok {
+		return protoCodec{}.Unmarshal(data, v)
+	}
+	return gobCodec{}.Unmarshal(data, v)
+}
+
+func (serverCodec) Name() string {
+	return "proto"
+}
+
+// protoEncoder appends fields in the protobuf wire format, leaving out the zero values like proto3
+type protoEncoder []byte
+
+func (e *protoEncoder) varint(num protowire.Number, v uint64) {
+	if v != 0 {
+		*e = protowire.AppendTag(*e, num, protowire.VarintType)
+		*e = protowire.AppendVarint(*e, v)
+	}
+}
+
+func (e *protoEncoder) int(num protowire.Number, v int) {
+	e.varint(num, uint64(int64(v)))
+}
+
+func (e *protoEncoder) bool(num protowire.Number, v bool) {
+	if v {
+		e.varint(num, 1)
+	}
+}
+
+func (e *protoEncoder) double(num protowire.Number, v float64) {
+	if v != 0 {
+		*e = protowire.AppendTag(*e, num, protowire.Fixed64Type)
+		*e = protowire.AppendFixed64(*e, math.Float64bits(v))
+	}
+}
+
+func (e *protoEncoder) bytes(num protowire.Number, v []byte) {
+	if len(v) > 0 {
+		*e = protowire.AppendTag(*e, num, protowire.BytesType)
+		*e = protowire.AppendBytes(*e, v)
+	}
+}
+
+func (e *protoEncoder) string(num protowire.Number, v string) {
+	e.bytes(num, []byte(v))
+}
+
+// message appends an embedded message, even an empty one, so a field of a oneof is always present
+func (e *protoEncoder) message(num protowire.Number, m protoMessage) {
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendBytes(*e, m.marshalProto())
+}
+
+// decodeProto calls field with every field of a message in the protobuf wire format: v is the value of a varint or
+// fixed field, and data the content of a length-delimited one. The callers ignore the fields they do not know.
+func decodeProto(b []byte, field func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(b)
+			v = uint64(v32)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := field(num, typ, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *InputDocument) marshalProto() []byte {
+	var e protoEncoder
+	e.string(1, m.Name)
+	e.bytes(2, m.Data)
+	return e
+}
+
+func (m *InputDocument) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Name = string(data)
+		case 2:
+			m.Data = append([]byte(nil), data...)
+		}
+		return nil
+	})
+}
+
+func (m *ComparisonOptions) marshalProto() []byte {
+	var e protoEncoder
+	e.double(1, m.DPI)
+	e.int(2, m.Threshold)
+	e.bool(3, m.AdaptiveThreshold)
+	e.bool(4, m.IgnoreAntialiasing)
+	e.int(5, m.Offset)
+	e.int(6, m.StartOffset)
+	e.bool(7, m.Align)
+	e.bool(8, m.SkipIdentical)
+	e.string(9, m.ColorOld)
+	e.string(10, m.ColorNew)
+	e.bool(11, m.Heatmap)
+	for _, pattern := range m.IgnoreText {
+		// Every element of a repeated field is kept, even an empty one
+		e = protowire.AppendTag(e, 12, protowire.BytesType)
+		e = protowire.AppendString(e, pattern)
+	}
+	e.bool(13, m.TextDiff)
+	e.bool(14, m.SideBySide)
+	e.string(15, m.MinRegionSize)
+	e.string(16, m.HighlightStyle)
+	e.int(17, m.BoxWidth)
+	return e
+}
+
+func (m *ComparisonOptions) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.DPI = math.Float64frombits(v)
+		case 2:
+			m.Threshold = int(int32(v))
+		case 3:
+			m.AdaptiveThreshold = v != 0
+		case 4:
+			m.IgnoreAntialiasing = v != 0
+		case 5:
+			m.Offset = int(int32(v))
+		case 6:
+			m.StartOffset = int(int32(v))
+		case 7:
+			m.Align = v != 0
+		case 8:
+			m.SkipIdentical = v != 0
+		case 9:
+			m.ColorOld = string(data)
+		case 10:
+			m.ColorNew = string(data)
+		case 11:
+			m.Heatmap = v != 0
+		case 12:
+			m.IgnoreText = append(m.IgnoreText, string(data))
+		case 13:
+			m.TextDiff = v != 0
+		case 14:
+			m.SideBySide = v != 0
+		case 15:
+			m.MinRegionSize = string(data)
+		case 16:
+			m.HighlightStyle = string(data)
+		case 17:
+			m.BoxWidth = int(int32(v))
+		}
+		return nil
+	})
+}
+
+func (m *ComparisonRequest) marshalProto() []byte {
+	var e protoEncoder
+	e.message(1, &m.File1)
+	e.message(2, &m.File2)
+	e.message(3, &m.Options)
+	e.bool(4, m.Images)
+	return e
+}
+
+func (m *ComparisonRequest) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			return m.File1.unmarshalProto(data)
+		case 2:
+			return m.File2.unmarshalProto(data)
+		case 3:
+			return m.Options.unmarshalProto(data)
+		case 4:
+			m.Images = v != 0
+		}
+		return nil
+	})
+}
+
+func (m *ComparisonEvent) marshalProto() []byte {
+	var e protoEncoder
+	switch {
+	case m.Progress != nil:
+		e.message(1, m.Progress)
+	case m.Page != nil:
+		e.message(2, m.Page)
+	case m.Image != nil:
+		e.message(3, m.Image)
+	case m.Report != nil:
+		e.message(4, m.Report)
+	}
+	return e
+}
+
+func (m *ComparisonEvent) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		// The last field of the oneof wins
+		var field protoMessage
+		switch num {
+		case 1:
+			*m = ComparisonEvent{Progress: new(ComparisonProgress)}
+			field = m.Progress
+		case 2:
+			*m = ComparisonEvent{Page: new(PageDiff)}
+			field = m.Page
+		case 3:
+			*m = ComparisonEvent{Image: new(PageImage)}
+			field = m.Image
+		case 4:
+			*m = ComparisonEvent{Report: new(ComparisonReport)}
+			field = m.Report
+		default:
+			return nil
+		}
+		return field.unmarshalProto(data)
+	})
+}
+
+func (m *ComparisonProgress) marshalProto() []byte {
+	var e protoEncoder
+	e.int(1, m.Completed)
+	e.int(2, m.Total)
+	return e
+}
+
+func (m *ComparisonProgress) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Completed = int(int32(v))
+		case 2:
+			m.Total = int(int32(v))
+		}
+		return nil
+	})
+}
+
+func (m *PageDiff) marshalProto() []byte {
+	var e protoEncoder
+	e.int(1, m.Page)
+	e.bool(2, m.Changed)
+	e.int(3, m.ChangedPixels)
+	e.double(4, m.PercentChanged)
+	e.double(5, m.SSIM)
+	e.int(6, m.Width)
+	e.int(7, m.Height)
+	for i := range m.Regions {
+		e.message(8, &m.Regions[i])
+	}
+	e.int(9, m.MissingIn)
+	return e
+}
+
+func (m *PageDiff) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Page = int(int32(v))
+		case 2:
+			m.Changed = v != 0
+		case 3:
+			m.ChangedPixels = int(int64(v))
+		case 4:
+			m.PercentChanged = math.Float64frombits(v)
+		case 5:
+			m.SSIM = math.Float64frombits(v)
+		case 6:
+			m.Width = int(int32(v))
+		case 7:
+			m.Height = int(int32(v))
+		case 8:
+			var r ChangedRegion
+			if err := r.unmarshalProto(data); err != nil {
+				return err
+			}
+			m.Regions = append(m.Regions, r)
+		case 9:
+			m.MissingIn = int(int32(v))
+		}
+		return nil
+	})
+}
+
+func (m *ChangedRegion) marshalProto() []byte {
+	var e protoEncoder
+	e.int(1, m.X)
+	e.int(2, m.Y)
+	e.int(3, m.Width)
+	e.int(4, m.Height)
+	e.string(5, m.Kind)
+	e.int(6, m.Area)
+	e.double(7, m.CentroidX)
+	e.double(8, m.CentroidY)
+	return e
+}
+
+func (m *ChangedRegion) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.X = int(int32(v))
+		case 2:
+			m.Y = int(int32(v))
+		case 3:
+			m.Width = int(int32(v))
+		case 4:
+			m.Height = int(int32(v))
+		case 5:
+			m.Kind = string(data)
+		case 6:
+			m.Area = int(int64(v))
+		case 7:
+			m.CentroidX = math.Float64frombits(v)
+		case 8:
+			m.CentroidY = math.Float64frombits(v)
+		}
+		return nil
+	})
+}
+
+func (m *PageImage) marshalProto() []byte {
+	var e protoEncoder
+	e.int(1, m.Page)
+	e.string(2, m.Name)
+	e.bytes(3, m.Data)
+	return e
+}
+
+func (m *PageImage) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Page = int(int32(v))
+		case 2:
+			m.Name = string(data)
+		case 3:
+			m.Data = append([]byte(nil), data...)
+		}
+		return nil
+	})
+}
+
+func (m *ComparisonReport) marshalProto() []byte {
+	var e protoEncoder
+	e.int(1, m.Pages1)
+	e.int(2, m.Pages2)
+	e.double(3, m.SSIM)
+	if len(m.ChangedPages) > 0 {
+		// Repeated numbers are packed, like protoc does for proto3
+		var packed []byte
+		for _, page := range m.ChangedPages {
+			packed = protowire.AppendVarint(packed, uint64(int64(page)))
+		}
+		e.bytes(4, packed)
+	}
+	e.string(5, m.JSON)
+	return e
+}
+
+func (m *ComparisonReport) unmarshalProto(b []byte) error {
+	return decodeProto(b, func(num protowire.Number, typ protowire.Type, v uint64, data []byte) error {
+		switch num {
+		case 1:
+			m.Pages1 = int(int32(v))
+		case 2:
+			m.Pages2 = int(int32(v))
+		case 3:
+			m.SSIM = math.Float64frombits(v)
+		case 4:
+			if typ != protowire.BytesType {
+				m.ChangedPages = append(m.ChangedPages, int(int32(v)))
+				break
+			}
+			for len(data) > 0 {
+				page, n := protowire.ConsumeVarint(data)
+				if n < 0 {
+					return protowire.ParseError(n)
+				}
+				m.ChangedPages = append(m.ChangedPages, int(int32(page)))
+				data = data[n:]
+			}
+		case 5:
+			m.JSON = string(data)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,280 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// The messages of the public service are checked against pdfdiff.proto itself: it is parsed into descriptors, and
+// every message with all its fields set is encoded by hand and decoded by the protobuf runtime, then the other way
+// around, so a field number or a type that does not match the definition fails the test.
+
+var (
+	protoMessageLine = regexp.MustCompile(`^message (\w+) \{$`)
+	protoOneofLine   = regexp.MustCompile(`^oneof (\w+) \{$`)
+	protoFieldLine   = regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);$`)
+)
+
+var protoScalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// parseProto returns the descriptor of the messages of pdfdiff.proto, which only uses scalars, messages, repeated
+// fields and a oneof
+func parseProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	file := &descriptorpb.FileDescriptorProto{Name: proto.String("pdfdiff.proto"), Package: proto.String("pdfdiff.v1"), Syntax: proto.String("proto3")}
+	var msg *descriptorpb.DescriptorProto
+	var oneof *int32
+	scanner := bufio.NewScanner(bytes.NewReader(Proto))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case protoMessageLine.MatchString(line):
+			msg = &descriptorpb.DescriptorProto{Name: proto.String(protoMessageLine.FindStringSubmatch(line)[1])}
+			file.MessageType = append(file.MessageType, msg)
+		case protoOneofLine.MatchString(line):
+			msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String(protoOneofLine.FindStringSubmatch(line)[1])})
+			oneof = proto.Int32(int32(len(msg.OneofDecl) - 1))
+		case line == "}" && oneof != nil:
+			oneof = nil
+		case line == "}":
+			msg = nil
+		case msg != nil && protoFieldLine.MatchString(line):
+			m := protoFieldLine.FindStringSubmatch(line)
+			num, _ := strconv.Atoi(m[4])
+			field := &descriptorpb.FieldDescriptorProto{Name: proto.String(m[3]), Number: proto.Int32(int32(num)), JsonName: proto.String(m[3]),
+				Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), OneofIndex: oneof}
+			if m[1] != "" {
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+			}
+			if typ, ok := protoScalarTypes[m[2]]; ok {
+				field.Type = typ.Enum()
+			} else {
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String(".pdfdiff.v1." + m[2])
+			}
+			msg.Field = append(msg.Field, field)
+		}
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+// goField returns the field of a message struct named after a protobuf field, e.g. StartOffset for start_offset
+func goField(v reflect.Value, fd protoreflect.FieldDescriptor) reflect.Value {
+	name := strings.ReplaceAll(string(fd.Name()), "_", "")
+	return v.FieldByNameFunc(func(field string) bool { return strings.EqualFold(field, name) })
+}
+
+// toDynamic sets the fields of a dynamic message from a message struct
+func toDynamic(t *testing.T, v reflect.Value, m protoreflect.Message) {
+	t.Helper()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f := goField(v, fd)
+		if !f.IsValid() {
+			t.Fatalf("%s has no field for %s", v.Type(), fd.FullName())
+		}
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for j := 0; j < f.Len(); j++ {
+				if fd.Kind() == protoreflect.MessageKind {
+					item := list.NewElement()
+					toDynamic(t, f.Index(j), item.Message())
+					list.Append(item)
+				} else {
+					list.Append(scalarValue(fd, f.Index(j)))
+				}
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					continue
+				}
+				f = f.Elem()
+			}
+			toDynamic(t, f, m.Mutable(fd).Message())
+		default:
+			if !f.IsZero() {
+				m.Set(fd, scalarValue(fd, f))
+			}
+		}
+	}
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, f reflect.Value) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind:
+		return protoreflect.ValueOfInt32(int32(f.Int()))
+	case protoreflect.Int64Kind:
+		return protoreflect.ValueOfInt64(f.Int())
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(f.Float())
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(f.Bool())
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(f.String())
+	default:
+		return protoreflect.ValueOfBytes(f.Bytes())
+	}
+}
+
+// fromDynamic sets the fields of a message struct from a dynamic message
+func fromDynamic(t *testing.T, m protoreflect.Message, v reflect.Value) {
+	t.Helper()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !m.Has(fd) {
+			continue
+		}
+		f := goField(v, fd)
+		switch {
+		case fd.IsList():
+			list := m.Get(fd).List()
+			for j := 0; j < list.Len(); j++ {
+				item := reflect.New(f.Type().Elem()).Elem()
+				if fd.Kind() == protoreflect.MessageKind {
+					fromDynamic(t, list.Get(j).Message(), item)
+				} else {
+					setScalar(item, list.Get(j))
+				}
+				f.Set(reflect.Append(f, item))
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if f.Kind() == reflect.Ptr {
+				f.Set(reflect.New(f.Type().Elem()))
+				f = f.Elem()
+			}
+			fromDynamic(t, m.Get(fd).Message(), f)
+		default:
+			setScalar(f, m.Get(fd))
+		}
+	}
+}
+
+func setScalar(f reflect.Value, value protoreflect.Value) {
+	switch v := value.Interface().(type) {
+	case int32:
+		f.SetInt(int64(v))
+	case int64:
+		f.SetInt(v)
+	case float64:
+		f.SetFloat(v)
+	case bool:
+		f.SetBool(v)
+	case string:
+		f.SetString(v)
+	case []byte:
+		f.SetBytes(append([]byte(nil), v...))
+	}
+}
+
+func TestProtoMessages(t *testing.T) {
+	file := parseProto(t)
+	region := ChangedRegion{X: 10, Y: 20, Width: 30, Height: 40, Kind: "stamp", Area: 1 << 40, CentroidX: 25.5, CentroidY: 40.25}
+	// Every field is set, to a value of its own
+	messages := []protoMessage{
+		&InputDocument{Name: "a.pdf", Data: []byte("%PDF")},
+		&ComparisonOptions{DPI: 150, Threshold: 12, AdaptiveThreshold: true, IgnoreAntialiasing: true, Offset: -2, StartOffset: 3,
+			Align: true, SkipIdentical: true, ColorOld: "#FF0000", ColorNew: "#0000FF", Heatmap: true, IgnoreText: []string{"Date: .*", ""},
+			TextDiff: true, SideBySide: true, MinRegionSize: "2mm2", HighlightStyle: "boxes", BoxWidth: 4},
+		&ComparisonRequest{File1: InputDocument{Name: "a.pdf", Data: []byte{1}}, File2: InputDocument{Name: "b.png", Data: []byte{2}},
+			Options: ComparisonOptions{DPI: 72, Offset: 1}, Images: true},
+		&ComparisonProgress{Completed: 3, Total: 7},
+		&PageDiff{Page: 4, Changed: true, ChangedPixels: 1 << 33, PercentChanged: 1.5, SSIM: 0.97, Width: 2480, Height: 3508,
+			Regions: []ChangedRegion{region, {X: 1, Kind: "signature"}}, MissingIn: 2},
+		&region,
+		&PageImage{Page: 5, Name: "differences_5.png", Data: []byte("png")},
+		&ComparisonReport{Pages1: 10, Pages2: 11, SSIM: 0.9, ChangedPages: []int{0, 3, 9}, JSON: `{"schemaVersion":1}`},
+		&ComparisonEvent{Progress: &ComparisonProgress{Completed: 1, Total: 2}},
+		&ComparisonEvent{Page: &PageDiff{Page: 1, Regions: []ChangedRegion{region}}},
+		&ComparisonEvent{Image: &PageImage{Page: 1, Name: "combined_1.png"}},
+		&ComparisonEvent{Report: &ComparisonReport{ChangedPages: []int{1}}},
+		// An empty message of a oneof is still sent
+		&ComparisonEvent{Progress: &ComparisonProgress{}},
+	}
+	for _, msg := range messages {
+		v := reflect.ValueOf(msg).Elem()
+		name := v.Type().Name()
+		t.Run(name, func(t *testing.T) {
+			desc := file.Messages().ByName(protoreflect.Name(name))
+			if desc == nil {
+				t.Fatalf("pdfdiff.proto has no message %s", name)
+			}
+
+			// Encoded here, decoded by the protobuf runtime
+			decoded := dynamicpb.NewMessage(desc)
+			if err := proto.Unmarshal(msg.marshalProto(), decoded); err != nil {
+				t.Fatal(err)
+			}
+			if unknown := decoded.GetUnknown(); len(unknown) > 0 {
+				t.Errorf("the encoding has fields that are not in pdfdiff.proto: %x", unknown)
+			}
+			got := reflect.New(v.Type())
+			fromDynamic(t, decoded, got.Elem())
+			if !reflect.DeepEqual(got.Interface(), msg) {
+				t.Errorf("decoded by the protobuf runtime:\n%+v\nwant\n%+v", got.Interface(), msg)
+			}
+
+			// Encoded by the protobuf runtime, decoded here
+			encoded := dynamicpb.NewMessage(desc)
+			toDynamic(t, v, encoded)
+			data, err := proto.Marshal(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back := reflect.New(v.Type()).Interface().(protoMessage)
+			if err := back.unmarshalProto(data); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(back, msg) {
+				t.Errorf("decoded from the protobuf runtime:\n%+v\nwant\n%+v", back, msg)
+			}
+		})
+	}
+}
+
+func TestProtoUnknownFields(t *testing.T) {
+	// A field added by a newer client is skipped, whatever its type
+	var e protoEncoder
+	e.int(99, 7)
+	e.double(98, 1.5)
+	e.string(97, "new")
+	e.string(1, "a.pdf")
+	var doc InputDocument
+	if err := doc.unmarshalProto(e); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "a.pdf" {
+		t.Errorf("name = %q, want a.pdf", doc.Name)
+	}
+	if err := doc.unmarshalProto([]byte{0x0a, 0x05, 'a'}); err == nil {
+		t.Error("a truncated message was decoded")
+	}
+}
@@ -0,0 +1,203 @@
+package pdfdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sarifSchema is the schema of the SARIF 2.1.0 logs written by WriteSARIF
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// The rules of the SARIF results
+var sarifRules = []sarifRule{
+	{ID: "changed-region", Description: "A region of the page changed between the documents", Level: "warning"},
+	{ID: "critical-region", Description: "A critical region of the page changed between the documents", Level: "error"},
+	{ID: "missing-page", Description: "The page is only in one of the documents", Level: "error"},
+	{ID: "failed-check", Description: "The template fields of the second document fail a check", Level: "error"},
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string          `json:"name"`
+		InformationURI string          `json:"informationUri"`
+		Rules          []sarifRuleJSON `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID, Description, Level string
+}
+
+type sarifRuleJSON struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string                 `json:"ruleId"`
+	Level        string                 `json:"level"`
+	Message      sarifMessage           `json:"message"`
+	Locations    []sarifLocation        `json:"locations"`
+	Attachments  []sarifAttachment      `json:"attachments,omitempty"`
+	Suppressions []sarifSuppression     `json:"suppressions,omitempty"`
+	Properties   map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		// The line of the region is the page, for the platforms that need a line to place a result
+		Region struct {
+			StartLine int `json:"startLine"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifAttachment struct {
+	Description      sarifMessage     `json:"description"`
+	ArtifactLocation sarifArtifact    `json:"artifactLocation"`
+	Rectangles       []sarifRectangle `json:"rectangles,omitempty"`
+}
+
+type sarifRectangle struct {
+	Top    int `json:"top"`
+	Left   int `json:"left"`
+	Bottom int `json:"bottom"`
+	Right  int `json:"right"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification"`
+}
+
+// WriteSARIF saves the report as a SARIF 2.1.0 log, for the platforms that ingest the results of code scanning:
+// every changed region is a result located on its page of the second document, with its bounding box in the
+// properties and as a rectangle of the attached difference image. The changed critical regions, the missing pages
+// and the failed checks are results too, and the results of the quarantined pages are suppressed.
+func WriteSARIF(report Report, output string) error {
+	var run sarifRun
+	run.Tool.Driver.Name = "PdfDiffGo"
+	run.Tool.Driver.InformationURI = "https://github.com/jackyes/PdfDiffGo"
+	for _, r := range sarifRules {
+		rule := sarifRuleJSON{ID: r.ID, ShortDescription: sarifMessage{Text: r.Description}}
+		rule.DefaultConfiguration.Level = r.Level
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+	}
+	run.Results = []sarifResult{}
+
+	document := sarifURI(report.File2)
+	for _, page := range report.Pages {
+		// The critical regions are checked whatever the threshold, so they may change on a page that did not
+		if !page.Changed && len(page.Critical) == 0 {
+			continue
+		}
+		diffImage := ""
+		if page.DiffImage != "" && report.Images == nil {
+			diffImage = sarifURI(filepath.Join(report.Dir, page.DiffImage))
+		}
+		add := func(rule, text string, r image.Rectangle, hasBox bool) {
+			result := sarifResult{RuleID: rule, Level: ruleLevel(rule), Message: sarifMessage{Text: text}, Locations: []sarifLocation{sarifPage(document, page.Page)}}
+			result.Properties = map[string]interface{}{"page": page.Page + 1}
+			if hasBox {
+				result.Properties["x"], result.Properties["y"] = r.Min.X, r.Min.Y
+				result.Properties["width"], result.Properties["height"] = r.Dx(), r.Dy()
+			}
+			if diffImage != "" {
+				a := sarifAttachment{Description: sarifMessage{Text: "difference image of the page"}, ArtifactLocation: sarifArtifact{URI: diffImage}}
+				if hasBox {
+					a.Rectangles = []sarifRectangle{{Top: r.Min.Y, Left: r.Min.X, Bottom: r.Max.Y, Right: r.Max.X}}
+				}
+				result.Attachments = []sarifAttachment{a}
+			}
+			if page.Quarantined {
+				result.Suppressions = []sarifSuppression{{Kind: "external", Justification: "the page is quarantined"}}
+			}
+			run.Results = append(run.Results, result)
+		}
+
+		if page.MissingIn != 0 {
+			add("missing-page", fmt.Sprintf("Page %d is missing in document %d", page.Page+1, page.MissingIn), image.Rectangle{}, false)
+			continue
+		}
+		for n, r := range page.Regions {
+			add("changed-region", fmt.Sprintf("Difference %d of %d on page %d, %dx%d pixels at %d,%d (%.2f%% of the page changed)",
+				n+1, len(page.Regions), page.Page+1, r.Width, r.Height, r.X, r.Y, page.PercentChanged), image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), true)
+		}
+		for _, c := range page.Critical {
+			text := fmt.Sprintf("The critical region at %d,%d of page %d changed, %d pixels", c.X, c.Y, page.Page+1, c.ChangedPixels)
+			if c.Reason != "" {
+				text = fmt.Sprintf("The critical region %q of page %d changed, %d pixels", c.Reason, page.Page+1, c.ChangedPixels)
+			}
+			add("critical-region", text, image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height), true)
+		}
+	}
+	for _, c := range report.FailedChecks() {
+		result := sarifResult{RuleID: "failed-check", Level: ruleLevel("failed-check"), Message: sarifMessage{Text: fmt.Sprintf("The check %s fails: %s", c.Name, c.Failure2)}}
+		result.Locations = []sarifLocation{sarifPage(document, 0)}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append(data, '\n'), 0644)
+}
+
+// ruleLevel returns the level of the results of a rule
+func ruleLevel(id string) string {
+	for _, r := range sarifRules {
+		if r.ID == id {
+			return r.Level
+		}
+	}
+	return "warning"
+}
+
+// sarifPage locates a result on a page of a document
+func sarifPage(document string, page int) sarifLocation {
+	var l sarifLocation
+	l.PhysicalLocation.ArtifactLocation.URI = document
+	l.PhysicalLocation.Region.StartLine = page + 1
+	return l
+}
+
+// sarifURI returns the URI of a file: relative paths stay relative, for the platforms resolving them against the
+// checkout, absolute paths are file URIs, and the URLs of remote documents are kept
+func sarifURI(file string) string {
+	if strings.Contains(file, "://") {
+		return file
+	}
+	if filepath.IsAbs(file) {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(file)}).String()
+	}
+	return (&url.URL{Path: filepath.ToSlash(file)}).String()
+}
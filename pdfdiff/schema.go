@@ -0,0 +1,28 @@
+package pdfdiff
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON schema of the reports, MAJOR.MINOR.
+// The minor version is increased when fields are added and the major version when fields are removed or change meaning.
+const SchemaVersion = "1.34"
+
+// Schema is the JSON schema that describes every machine-readable output of a comparison
+//
+//go:embed schema.json
+var Schema []byte
+
+// CheckSchemaVersion returns an error if a report written with the given schema version cannot be read by this version
+func CheckSchemaVersion(version string) error {
+	if version == "" {
+		return fmt.Errorf("the report does not have a schema_version")
+	}
+	major := strings.SplitN(version, ".", 2)[0]
+	if major != strings.SplitN(SchemaVersion, ".", 2)[0] {
+		return fmt.Errorf("the report uses schema version %s, which is not compatible with schema version %s", version, SchemaVersion)
+	}
+	return nil
+}
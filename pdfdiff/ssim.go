@@ -0,0 +1,66 @@
+package pdfdiff
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ssimWindow is the size in pixels of the square windows the structural similarity is computed on
+const ssimWindow = 8
+
+// Stabilizing constants of SSIM for 8 bit luminance
+const (
+	ssimC1 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 = (0.03 * 255) * (0.03 * 255)
+)
+
+// ssim computes the structural similarity of the luminance of two pages, from 1 for identical pages down to about 0
+// for unrelated ones. It is the mean SSIM of non-overlapping windows over the area the pages have in common.
+func ssim(img1, img2 image.Image) float64 {
+	g1, g2 := imaging.Grayscale(img1), imaging.Grayscale(img2)
+	area := g1.Bounds().Intersect(g2.Bounds())
+
+	var total float64
+	windows := 0
+	for y0 := 0; y0+ssimWindow <= area.Dy(); y0 += ssimWindow {
+		for x0 := 0; x0+ssimWindow <= area.Dx(); x0 += ssimWindow {
+			var sum1, sum2, sq1, sq2, cross float64
+			for y := y0; y < y0+ssimWindow; y++ {
+				// Grayscale images have the same value in the red, green and blue channels
+				row1 := g1.Pix[y*g1.Stride:]
+				row2 := g2.Pix[y*g2.Stride:]
+				for x := x0; x < x0+ssimWindow; x++ {
+					v1, v2 := float64(row1[x*4]), float64(row2[x*4])
+					sum1 += v1
+					sum2 += v2
+					sq1 += v1 * v1
+					sq2 += v2 * v2
+					cross += v1 * v2
+				}
+			}
+			n := float64(ssimWindow * ssimWindow)
+			mean1, mean2 := sum1/n, sum2/n
+			var1, var2 := sq1/n-mean1*mean1, sq2/n-mean2*mean2
+			cov := cross/n - mean1*mean2
+			total += (2*mean1*mean2 + ssimC1) * (2*cov + ssimC2) / ((mean1*mean1 + mean2*mean2 + ssimC1) * (var1 + var2 + ssimC2))
+			windows++
+		}
+	}
+	if windows == 0 {
+		return 1
+	}
+	return total / float64(windows)
+}
+
+// MeanSSIM returns the mean structural similarity of the pages, the SSIM of the whole document
+func MeanSSIM(pages []PageResult) float64 {
+	if len(pages) == 0 {
+		return 1
+	}
+	var total float64
+	for _, page := range pages {
+		total += page.SSIM
+	}
+	return total / float64(len(pages))
+}
@@ -0,0 +1,52 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func TestSSIM(t *testing.T) {
+	white := whitePage(32, 32)
+	// A black square in a single window of the 16 of the page
+	square := whitePage(32, 32)
+	draw.Draw(square, image.Rect(10, 10, 14, 14), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	black := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(black, black.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	tests := []struct {
+		name       string
+		img1, img2 image.Image
+		min, max   float64
+	}{
+		{"identical", white, white, 1, 1},
+		{"identical with a square", square, square, 1, 1},
+		{"one window changed", white, square, 15.0 / 16, 0.999},
+		{"one window changed, swapped", square, white, 15.0 / 16, 0.999},
+		{"inverted", white, black, 0, 0.001},
+		// Only the area the pages have in common is compared
+		{"larger second page", white, whitePage(40, 48), 1, 1},
+		{"smaller than a window", whitePage(4, 4), whitePage(4, 4), 1, 1},
+	}
+	for _, test := range tests {
+		got := ssim(test.img1, test.img2)
+		if got < test.min-1e-9 || got > test.max+1e-9 {
+			t.Errorf("%s: ssim = %.6f, want between %.6f and %.6f", test.name, got, test.min, test.max)
+		}
+	}
+	if a, b := ssim(white, square), ssim(square, white); math.Abs(a-b) > 1e-12 {
+		t.Errorf("ssim is not symmetric: %.12f and %.12f", a, b)
+	}
+}
+
+func TestMeanSSIM(t *testing.T) {
+	if got := MeanSSIM(nil); got != 1 {
+		t.Errorf("MeanSSIM of no pages = %g, want 1", got)
+	}
+	pages := []PageResult{{SSIM: 1}, {SSIM: 0.5}, {SSIM: 0.75}}
+	if got := MeanSSIM(pages); got != 0.75 {
+		t.Errorf("MeanSSIM = %g, want 0.75", got)
+	}
+}
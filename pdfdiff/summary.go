@@ -0,0 +1,181 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/phpdave11/gofpdf"
+)
+
+// DefaultSummaryTop is the number of pages listed by an executive summary by default
+const DefaultSummaryTop = 10
+
+// summaryRowsPerPage is the number of pages that fit in the one-page PDF summary
+const summaryRowsPerPage = 10
+
+// summaryThumbnailWidth is the width in pixels of the thumbnails of a summary
+const summaryThumbnailWidth = 240
+
+// summaryEntry is a changed page listed in an executive summary
+type summaryEntry struct {
+	report Report
+	page   PageResult
+}
+
+// WriteSummary writes an executive summary of one or more comparisons, listing the top most changed pages with a
+// thumbnail of their changed area. The format depends on the extension of the output: .md for Markdown (the thumbnails
+// are written to a directory next to it), .pdf for a single PDF page, or plain text otherwise.
+func WriteSummary(reports []Report, output string, top int) error {
+	if top <= 0 {
+		top = DefaultSummaryTop
+	}
+	entries, pages, changed := topChanges(reports, top)
+	withFiles := len(reports) > 1
+	title := fmt.Sprintf("%d of %d pages changed", changed, pages)
+	if !withFiles {
+		title = fmt.Sprintf("%s vs %s: %s", reports[0].File1, reports[0].File2, title)
+	} else {
+		title = fmt.Sprintf("%d comparisons: %s", len(reports), title)
+	}
+
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".md":
+		return writeMarkdownSummary(title, entries, withFiles, output)
+	case ".pdf":
+		if len(entries) > summaryRowsPerPage {
+			entries = entries[:summaryRowsPerPage]
+		}
+		return writePDFSummary(title, entries, withFiles, output)
+	default:
+		var buf bytes.Buffer
+		fmt.Fprintln(&buf, title)
+		for n, e := range entries {
+			fmt.Fprintf(&buf, "%2d. %s\n", n+1, e.describe(withFiles))
+		}
+		return os.WriteFile(output, buf.Bytes(), 0644)
+	}
+}
+
+// topChanges returns the most changed pages of the reports, by percentage of changed pixels and then by similarity,
+// along with the number of pages and changed pages
+func topChanges(reports []Report, top int) ([]summaryEntry, int, int) {
+	var entries []summaryEntry
+	pages := 0
+	for _, report := range reports {
+		pages += len(report.Pages)
+		for _, page := range report.ChangedPages() {
+			entries = append(entries, summaryEntry{report: report, page: page})
+		}
+	}
+	changed := len(entries)
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].page, entries[j].page
+		if a.PercentChanged != b.PercentChanged {
+			return a.PercentChanged > b.PercentChanged
+		}
+		return a.SSIM < b.SSIM
+	})
+	if len(entries) > top {
+		entries = entries[:top]
+	}
+	return entries, pages, changed
+}
+
+// describe returns a one-line description of the changed page, naming the documents if the summary covers several
+func (e summaryEntry) describe(withFiles bool) string {
+	s := fmt.Sprintf("Page %d: ", e.page.Page+1)
+	if withFiles {
+		s = fmt.Sprintf("%s page %d: ", e.report.File2, e.page.Page+1)
+	}
+	if e.page.MissingIn != 0 {
+		return s + fmt.Sprintf("missing in document %d", e.page.MissingIn)
+	}
+	s += fmt.Sprintf("%.2f%% changed, SSIM %.4f, %d changed regions", e.page.PercentChanged, e.page.SSIM, len(e.page.Regions))
+	for _, r := range e.page.Regions {
+		if mark := r.Mark(); mark != "" {
+			s += ", " + mark
+		}
+	}
+	return s
+}
+
+// thumbnail returns the changed area of the difference image scaled down, or nil if the image is not available
+func (e summaryEntry) thumbnail() image.Image {
+	if e.page.DiffImage == "" {
+		return nil
+	}
+	img, err := openImage(e.report.Dir, e.report.Images, e.page.DiffImage)
+	if err != nil {
+		return nil
+	}
+	if len(e.page.Regions) > 0 {
+		area := image.Rectangle{}
+		for _, r := range e.page.Regions {
+			area = area.Union(image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height))
+		}
+		// Keep some context around the changes
+		margin := max(area.Dx(), area.Dy()) / 10
+		img = imaging.Crop(img, area.Inset(-margin))
+	}
+	return imaging.Resize(img, summaryThumbnailWidth, 0, imaging.Lanczos)
+}
+
+// writeMarkdownSummary writes the summary as Markdown, with the thumbnails in a directory named after the output
+func writeMarkdownSummary(title string, entries []summaryEntry, withFiles bool, output string) error {
+	thumbDir := strings.TrimSuffix(output, filepath.Ext(output)) + "_thumbnails"
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", title)
+	for n, e := range entries {
+		fmt.Fprintf(&buf, "%d. %s\n", n+1, e.describe(withFiles))
+		if thumb := e.thumbnail(); thumb != nil {
+			if err := os.MkdirAll(thumbDir, 0755); err != nil {
+				return err
+			}
+			name := fmt.Sprintf("%d.png", n+1)
+			if err := imaging.Save(thumb, filepath.Join(thumbDir, name)); err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "\n   ![Page %d](%s)\n\n", e.page.Page+1, filepath.ToSlash(filepath.Join(filepath.Base(thumbDir), name)))
+		}
+	}
+	return os.WriteFile(output, buf.Bytes(), 0644)
+}
+
+// writePDFSummary writes the summary on a single A4 page, one row per changed page
+func writePDFSummary(title string, entries []summaryEntry, withFiles bool, output string) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.MultiCell(0, 7, title, "", "L", false)
+	pdf.Ln(4)
+
+	const rowHeight, thumbWidth = 25.0, 45.0
+	pdf.SetFont("Helvetica", "", 10)
+	for n, e := range entries {
+		y := pdf.GetY()
+		if thumb := e.thumbnail(); thumb != nil {
+			var img bytes.Buffer
+			if err := png.Encode(&img, thumb); err != nil {
+				return err
+			}
+			name := fmt.Sprintf("thumbnail%d", n)
+			info := pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, &img)
+			w, h := info.Extent()
+			scale := min(thumbWidth/w, (rowHeight-3)/h)
+			pdf.ImageOptions(name, 15, y, w*scale, h*scale, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		}
+		pdf.SetXY(15+thumbWidth+5, y)
+		pdf.MultiCell(0, 5, fmt.Sprintf("%d. %s", n+1, e.describe(withFiles)), "", "L", false)
+		pdf.SetY(y + rowHeight)
+	}
+	return pdf.OutputFileAndClose(output)
+}
@@ -0,0 +1,106 @@
+//go:build ignore
+
+// gen writes the encrypted fixtures of decrypt_test.go with MuPDF, the library of go-fitz, whose security handler
+// was written independently of the one of decrypt.go. Run it from this directory with the headers of go-fitz:
+//
+//	CGO_CFLAGS="-I$(go env GOMODCACHE)/github.com/gen2brain/go-fitz@v1.22.2/include" go run gen.go
+//
+// MuPDF cannot write revision 4 with RC4 nor revision 5, whose fixtures decrypt_test.go encrypts itself.
+package main
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <mupdf/fitz.h>
+#include <mupdf/pdf.h>
+
+static const char *encrypt(const char *in, const char *out, int method, const char *user, const char *owner) {
+	fz_context *ctx = fz_new_context(NULL, NULL, FZ_STORE_DEFAULT);
+	pdf_document *doc = NULL;
+	const char *failed = NULL;
+	fz_try(ctx) {
+		doc = pdf_open_document(ctx, in);
+		pdf_write_options opts = pdf_default_write_options;
+		opts.do_encrypt = method;
+		opts.permissions = -4;
+		strncpy(opts.upwd_utf8, user, sizeof(opts.upwd_utf8) - 1);
+		strncpy(opts.opwd_utf8, owner, sizeof(opts.opwd_utf8) - 1);
+		pdf_save_document(ctx, doc, out, &opts);
+	}
+	fz_catch(ctx) {
+		failed = "MuPDF failed to write the fixture";
+	}
+	pdf_drop_document(ctx, doc);
+	fz_drop_context(ctx);
+	return failed;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"unsafe"
+
+	_ "github.com/gen2brain/go-fitz" // links libmupdf
+)
+
+// plainObjects are the objects of the document encrypted by every fixture, with a string in its Info dictionary and a
+// content stream
+var plainObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>",
+	"<< /Length 44 >>\nstream\nBT /F1 24 Tf 10 40 Td (Encrypted page) Tj ET\nendstream",
+	"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	"<< /Title (Encrypted fixture) >>",
+}
+
+// plain returns the document encrypted by every fixture
+func plain() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(plainObjects))
+	for i, obj := range plainObjects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(plainObjects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Root 1 0 R /Info 6 0 R /Size %d >>\nstartxref\n%d\n%%%%EOF\n", len(plainObjects)+1, xref)
+	return buf.Bytes()
+}
+
+func main() {
+	if err := os.WriteFile("plain.tmp.pdf", plain(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove("plain.tmp.pdf")
+	fixtures := []struct {
+		name        string
+		method      C.int
+		user, owner string
+	}{
+		{"r2-rc4-40.pdf", C.PDF_ENCRYPT_RC4_40, "user", "owner"},
+		{"r3-rc4-128.pdf", C.PDF_ENCRYPT_RC4_128, "user", "owner"},
+		{"r4-aesv2.pdf", C.PDF_ENCRYPT_AES_128, "user", "owner"},
+		{"r6-aesv3.pdf", C.PDF_ENCRYPT_AES_256, "user", "owner"},
+		// MuPDF hashes the password as it is given, normalized already
+		{"r6-aesv3-unicode.pdf", C.PDF_ENCRYPT_AES_256, "caf\u00e9 secret", "owner"},
+	}
+	for _, f := range fixtures {
+		in, out, user, owner := C.CString("plain.tmp.pdf"), C.CString(f.name), C.CString(f.user), C.CString(f.owner)
+		if failed := C.encrypt(in, out, f.method, user, owner); failed != nil {
+			log.Fatalf("%s: %s", f.name, C.GoString(failed))
+		}
+		for _, s := range []*C.char{in, out, user, owner} {
+			C.free(unsafe.Pointer(s))
+		}
+		fmt.Println("wrote", f.name)
+	}
+}
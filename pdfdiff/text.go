@@ -0,0 +1,127 @@
+package pdfdiff
+
+// maxTextDiffCells bounds the table of the word diff of a page, words of the first page times words of the second
+// one, so a page of a dictionary does not take gigabytes: past it, the changed part is reported as replaced at once
+const maxTextDiffCells = 4 << 20
+
+// TextChange is a run of words inserted in or deleted from the text of a page
+type TextChange struct {
+	// Op is "insert" for words of the second document only and "delete" for words of the first document only
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// textExtractor is implemented by documents that can extract the text of a page, such as *fitz.Document
+type textExtractor interface {
+	Text(pageNumber int) (string, error)
+}
+
+// pageText returns the text of a page, or "" for documents without text and for missing pages
+func pageText(doc Document, page int) (string, error) {
+	t, ok := doc.(textExtractor)
+	if !ok || page < 0 || page >= doc.NumPage() {
+		return "", nil
+	}
+	defer lockDocument(doc)()
+	return t.Text(page)
+}
+
+// diffWords returns the words inserted and deleted between the texts of two pages, in reading order. Spacing and
+// line breaks are ignored, so text that only reflowed has no changes. The texts are split in the languages of the
+// pages, see textWords.
+func diffWords(lang1, lang2, text1, text2 string) []TextChange {
+	words1, words2 := textWords(lang1, text1), textWords(lang2, text2)
+	return textChanges(wordEdits(words1, words2), words1, words2)
+}
+
+// wordEdit is a step of the diff of two lists of words: word i of the first list and word j of the second one are
+// "equal", or word i is a "delete" or word j an "insert"
+type wordEdit struct {
+	op   string
+	i, j int
+}
+
+// wordEdits returns the steps turning the first list of words into the second one, in order
+func wordEdits(a, b []string) []wordEdit {
+	var edits []wordEdit
+	// The common start and end of the pages are left out of the table
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		edits = append(edits, wordEdit{op: "equal", i: start, j: start})
+		start++
+	}
+	end1, end2 := len(a), len(b)
+	for end1 > start && end2 > start && a[end1-1] == b[end2-1] {
+		end1--
+		end2--
+	}
+	end := func(edits []wordEdit) []wordEdit {
+		for k := 0; end1+k < len(a); k++ {
+			edits = append(edits, wordEdit{op: "equal", i: end1 + k, j: end2 + k})
+		}
+		return edits
+	}
+
+	if (end1-start+1)*(end2-start+1) > maxTextDiffCells {
+		for i := start; i < end1; i++ {
+			edits = append(edits, wordEdit{op: "delete", i: i})
+		}
+		for j := start; j < end2; j++ {
+			edits = append(edits, wordEdit{op: "insert", j: j})
+		}
+		return end(edits)
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[start+i:end1] and b[start+j:end2]
+	n, m := end1-start, end2-start
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[start+i] == b[start+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && a[start+i] == b[start+j]:
+			edits = append(edits, wordEdit{op: "equal", i: start + i, j: start + j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, wordEdit{op: "insert", j: start + j})
+			j++
+		default:
+			edits = append(edits, wordEdit{op: "delete", i: start + i})
+			i++
+		}
+	}
+	return end(edits)
+}
+
+// textChanges merges the inserted and deleted words of a diff into runs of words
+func textChanges(edits []wordEdit, a, b []string) []TextChange {
+	var changes []TextChange
+	add := func(op, word string) {
+		if n := len(changes); n > 0 && changes[n-1].Op == op {
+			changes[n-1].Text += " " + word
+			return
+		}
+		changes = append(changes, TextChange{Op: op, Text: word})
+	}
+	for _, e := range edits {
+		switch e.op {
+		case "delete":
+			add("delete", a[e.i])
+		case "insert":
+			add("insert", b[e.j])
+		}
+	}
+	return changes
+}
@@ -0,0 +1,87 @@
+package pdfdiff
+
+import (
+	"image"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// textLine is a line of the text drawn by a page, with the box of the glyph every byte of its text belongs to.
+// The spaces between the words, which are not drawn, have the boxes of the glyphs around them.
+type textLine struct {
+	text  string
+	boxes [][4]float64
+}
+
+// svgTextLines rebuilds the lines of text of an SVG page from its glyphs: the glyphs sharing a baseline, in order
+// from left to right. The spaces are drawn as glyphs by most documents, and added wherever the gap between two
+// glyphs is wider than a third of their size otherwise.
+func svgTextLines(svg string) []textLine {
+	var lines []textLine
+	for _, line := range glyphLines(svg) {
+		var l textLine
+		for i, g := range line {
+			if i > 0 && g.text != " " && line[i-1].text != " " && g.box[0]-line[i-1].box[2] > 0.33*g.size {
+				l.text += " "
+				l.boxes = append(l.boxes, line[i-1].box)
+			}
+			l.text += g.text
+			for range g.text {
+				l.boxes = append(l.boxes, g.box)
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// glyphLines returns the glyphs of the text of an SVG page by line: the glyphs sharing a baseline, from top to
+// bottom, and in order from left to right
+func glyphLines(svg string) [][]svgGlyph {
+	var glyphs []svgGlyph
+	for _, g := range svgGlyphs(svg) {
+		if g.size > 0 && g.text != "" {
+			glyphs = append(glyphs, g)
+		}
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i].origin[1] < glyphs[j].origin[1] })
+	var lines [][]svgGlyph
+	for start := 0; start < len(glyphs); {
+		end := start + 1
+		for end < len(glyphs) && glyphs[end].origin[1]-glyphs[start].origin[1] <= 0.3*glyphs[start].size {
+			end++
+		}
+		line := glyphs[start:end]
+		sort.Slice(line, func(i, j int) bool { return line[i].origin[0] < line[j].origin[0] })
+		lines = append(lines, line)
+		start = end
+	}
+	return lines
+}
+
+// maskText returns the areas, in pixels of the page rendered at dpi, of the text of an SVG page matched by one
+// of the patterns, and the matched text
+func maskText(svg string, patterns []*regexp.Regexp, dpi float64) ([]image.Rectangle, []string) {
+	var rects []image.Rectangle
+	var matches []string
+	scale := dpi / 72
+	for _, line := range svgTextLines(svg) {
+		for _, re := range patterns {
+			for _, m := range re.FindAllStringIndex(line.text, -1) {
+				if m[0] == m[1] {
+					continue
+				}
+				box := line.boxes[m[0]]
+				for _, b := range line.boxes[m[0]:m[1]] {
+					box = [4]float64{math.Min(box[0], b[0]), math.Min(box[1], b[1]), math.Max(box[2], b[2]), math.Max(box[3], b[3])}
+				}
+				// Round outwards and cover the anti-aliased edges of the glyphs
+				rects = append(rects, image.Rect(int(math.Floor(box[0]*scale))-1, int(math.Floor(box[1]*scale))-1,
+					int(math.Ceil(box[2]*scale))+1, int(math.Ceil(box[3]*scale))+1))
+				matches = append(matches, line.text[m[0]:m[1]])
+			}
+		}
+	}
+	return rects, matches
+}
@@ -0,0 +1,176 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// thumbnailDPI is the resolution the pages are rendered at before being scaled to the size of their thumbnail
+const thumbnailDPI = 72.0
+
+// staleThumbnailSSIM is the structural similarity below which a thumbnail no longer shows its page. Thumbnails are
+// small and often JPEG compressed, so the bar is much lower than for rendered pages.
+const staleThumbnailSSIM = 0.9
+
+// ThumbnailResult describes the embedded thumbnail of a page
+type ThumbnailResult struct {
+	// Document is the document (1 or 2) the thumbnail is embedded in
+	Document int `json:"document"`
+	// Page is the page of the document, counted from 0
+	Page int `json:"page"`
+	// SSIM is the structural similarity of the thumbnail with the page rendered at the same size
+	SSIM float64 `json:"ssim"`
+	// Stale reports that the thumbnail does not show the page any more
+	Stale bool `json:"stale"`
+	// Copied reports that the thumbnail of the second document is the one of the first document although the page changed
+	Copied bool `json:"copied,omitempty"`
+}
+
+// CheckThumbnails compares the thumbnails embedded in the pages of two PDF documents with freshly rendered pages
+// and with each other, to find the thumbnails a tool left behind when it updated the pages. Documents that are not PDFs
+// or have no thumbnails are skipped.
+func CheckThumbnails(file1, file2 string) ([]ThumbnailResult, error) {
+	var results []ThumbnailResult
+	var thumbs [2]map[int]image.Image
+	var renders [2]map[int]image.Image
+	for n, file := range []string{file1, file2} {
+		if strings.ToLower(filepath.Ext(file)) != ".pdf" {
+			continue
+		}
+		var err error
+		thumbs[n], err = embeddedThumbnails(file)
+		if err != nil {
+			return nil, err
+		}
+		if len(thumbs[n]) == 0 {
+			continue
+		}
+		doc, err := Open(file)
+		if err != nil {
+			return nil, &InputError{File: file, Err: err}
+		}
+		renders[n] = make(map[int]image.Image)
+		for page := 0; page < doc.NumPage(); page++ {
+			thumb, ok := thumbs[n][page]
+			if !ok {
+				continue
+			}
+			img, err := RenderPage(doc, page, thumbnailDPI)
+			if err != nil {
+				doc.Close()
+				return nil, &PageError{Page: page, Err: err}
+			}
+			img = imaging.Resize(img, thumb.Bounds().Dx(), thumb.Bounds().Dy(), imaging.Lanczos)
+			renders[n][page] = img
+			similarity := ssim(thumb, img)
+			results = append(results, ThumbnailResult{Document: n + 1, Page: page, SSIM: similarity, Stale: similarity < staleThumbnailSSIM})
+		}
+		doc.Close()
+	}
+
+	// A page that changed while keeping the thumbnail of the first document has a copied thumbnail
+	for i, result := range results {
+		if result.Document != 2 {
+			continue
+		}
+		thumb1, ok1 := thumbs[0][result.Page]
+		render1, ok2 := renders[0][result.Page]
+		thumb2, render2 := thumbs[1][result.Page], renders[1][result.Page]
+		if !ok1 || !ok2 || thumb1.Bounds().Size() != thumb2.Bounds().Size() {
+			continue
+		}
+		results[i].Copied = ssim(thumb1, thumb2) >= staleThumbnailSSIM && ssim(render1, render2) < staleThumbnailSSIM
+	}
+	return results, nil
+}
+
+// embeddedThumbnails returns the thumbnails of the pages of a PDF that have one, by page
+func embeddedThumbnails(file string) (map[int]image.Image, error) {
+	f, err := readPDF(file)
+	if err != nil {
+		return nil, &InputError{File: file, Err: err}
+	}
+	thumbs := make(map[int]image.Image)
+	for n, page := range f.pages() {
+		stream, ok := f.resolve(page["Thumb"]).(pdfStream)
+		if !ok {
+			continue
+		}
+		// Thumbnails in a format this reader does not decode are not checked
+		if img, err := f.decodeImage(stream); err == nil {
+			thumbs[n] = img
+		}
+	}
+	return thumbs, nil
+}
+
+// decodeImage decodes an image XObject with 8 bits per component in the gray, RGB or indexed RGB color spaces,
+// Flate or JPEG encoded
+func (f *pdfFile) decodeImage(stream pdfStream) (image.Image, error) {
+	for _, filter := range append(f.array(stream.dict["Filter"]), f.resolve(stream.dict["Filter"])) {
+		if f.resolve(filter) == pdfName("DCTDecode") {
+			return jpeg.Decode(bytes.NewReader(stream.data))
+		}
+	}
+	width, _ := f.resolve(stream.dict["Width"]).(float64)
+	height, _ := f.resolve(stream.dict["Height"]).(float64)
+	if bpc, _ := f.resolve(stream.dict["BitsPerComponent"]).(float64); bpc != 8 || width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("unsupported image")
+	}
+	data, err := f.decode(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	// Thumbnails either name their color space or describe an indexed one
+	var palette []byte
+	components := 0
+	switch cs := f.resolve(stream.dict["ColorSpace"]).(type) {
+	case pdfName:
+		components = map[pdfName]int{"DeviceGray": 1, "DeviceRGB": 3}[cs]
+	case []interface{}:
+		if len(cs) == 4 && f.resolve(cs[0]) == pdfName("Indexed") && f.resolve(cs[1]) == pdfName("DeviceRGB") {
+			components = 1
+			switch lookup := f.resolve(cs[3]).(type) {
+			case string:
+				palette = []byte(lookup)
+			case pdfStream:
+				palette, err = f.decode(lookup)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w, h := int(width), int(height)
+	if components == 0 || len(data) < w*h*components {
+		return nil, fmt.Errorf("unsupported image")
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (y*w + x) * components
+			var c color.RGBA
+			switch {
+			case palette != nil:
+				if j := int(data[i]) * 3; j+2 < len(palette) {
+					c = color.RGBA{palette[j], palette[j+1], palette[j+2], 255}
+				}
+			case components == 1:
+				c = color.RGBA{data[i], data[i], data[i], 255}
+			default:
+				c = color.RGBA{data[i], data[i+1], data[i+2], 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,127 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/phpdave11/gofpdf"
+)
+
+const (
+	// tileMargin is the margin of the tiles in mm, outside of the printable area of most printers, which holds
+	// the assembly marks
+	tileMargin = 10.0
+	// tileOverlap is how much of the page, in mm, two neighbouring tiles both print, to glue them together
+	tileOverlap = 10.0
+	// tileMarkLength is the length of the crop marks in mm
+	tileMarkLength = 5.0
+)
+
+// formatSize returns the size in mm of an A format of printSizes, portrait
+func formatSize(name string) (float64, float64, bool) {
+	for _, format := range printSizes {
+		if format.name == name {
+			return format.width, format.height, true
+		}
+	}
+	return 0, 0, false
+}
+
+// tileGrid is the tiling of a page of the given size in mm on tiles of a format, in the orientation that takes the
+// fewest tiles
+type tileGrid struct {
+	tileW, tileH float64
+	cols, rows   int
+}
+
+// newTileGrid returns the tiling of a page of width by height mm on tiles of the TilePrint format, or nil if the
+// page fits on one sheet of that format, in either orientation
+func (layout Layout) newTileGrid(width, height float64) *tileGrid {
+	w, h, ok := formatSize(layout.TilePrint)
+	if !ok {
+		return nil
+	}
+	// A page of the format itself, rendered with rounding, still fits
+	const tolerance = 1.0
+	if width <= w+tolerance && height <= h+tolerance || width <= h+tolerance && height <= w+tolerance {
+		return nil
+	}
+	count := func(length, tile float64) int {
+		step := tile - 2*tileMargin - tileOverlap
+		return max(1, int(math.Ceil((length-tileOverlap)/step)))
+	}
+	portrait := &tileGrid{tileW: w, tileH: h, cols: count(width, w), rows: count(height, h)}
+	landscape := &tileGrid{tileW: h, tileH: w, cols: count(width, h), rows: count(height, w)}
+	if landscape.cols*landscape.rows < portrait.cols*portrait.rows {
+		return landscape
+	}
+	return portrait
+}
+
+// addTiles adds the image of a page of width by height mm to the PDF at its actual size, split on the pages of the
+// grid: every tile prints a part of the image overlapping its neighbours by tileOverlap, within crop marks, with
+// dashed lines where the next tiles begin and a label naming the tile, e.g. 2-3 for the second row and the third
+// column, so the tiles can be printed on an office printer and assembled
+func addTiles(pdf *gofpdf.Fpdf, name string, width, height float64, options gofpdf.ImageOptions, grid *tileGrid, page PageResult) {
+	areaW, areaH := grid.tileW-2*tileMargin, grid.tileH-2*tileMargin
+	stepX, stepY := areaW-tileOverlap, areaH-tileOverlap
+	for row := 0; row < grid.rows; row++ {
+		for col := 0; col < grid.cols; col++ {
+			pdf.AddPageFormat("P", gofpdf.SizeType{Wd: grid.tileW, Ht: grid.tileH})
+			if row == 0 && col == 0 {
+				bookmarkPage(pdf, page)
+			}
+			pdf.ClipRect(tileMargin, tileMargin, areaW, areaH, false)
+			pdf.ImageOptions(name, tileMargin-float64(col)*stepX, tileMargin-float64(row)*stepY, width, height, false, options, 0, "")
+			pdf.ClipEnd()
+			drawTileMarks(pdf, grid, row, col)
+
+			label := fmt.Sprintf("Page %d, tile %s (row %d of %d, column %d of %d), overlap %.0f mm", page.Page+1, tileName(row, col), row+1, grid.rows, col+1, grid.cols, tileOverlap)
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Text(tileMargin, tileMargin-2, label)
+		}
+	}
+}
+
+// drawTileMarks draws the crop marks at the corners of the printed area of a tile, and dashed lines where the
+// tiles to its right and below begin, which the next tiles are glued on
+func drawTileMarks(pdf *gofpdf.Fpdf, grid *tileGrid, row, col int) {
+	left, top := tileMargin, tileMargin
+	right, bottom := grid.tileW-tileMargin, grid.tileH-tileMargin
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(0.2)
+	for _, x := range []float64{left, right} {
+		for _, y := range []float64{top, bottom} {
+			dx, dy := tileMarkLength, tileMarkLength
+			if x == left {
+				dx = -dx
+			}
+			if y == top {
+				dy = -dy
+			}
+			pdf.Line(x, y, x+dx, y)
+			pdf.Line(x, y, x, y+dy)
+		}
+	}
+	pdf.SetDashPattern([]float64{2, 1}, 0)
+	if col < grid.cols-1 {
+		pdf.Line(right-tileOverlap, top-tileMarkLength, right-tileOverlap, bottom+tileMarkLength)
+		pdf.SetFont("Helvetica", "", 7)
+		pdf.TransformBegin()
+		pdf.TransformRotate(-90, right+2, top)
+		pdf.Text(right+2, top, "glue "+tileName(row, col+1)+" here")
+		pdf.TransformEnd()
+	}
+	if row < grid.rows-1 {
+		pdf.Line(left-tileMarkLength, bottom-tileOverlap, right+tileMarkLength, bottom-tileOverlap)
+		pdf.SetFont("Helvetica", "", 7)
+		pdf.Text(left, bottom+4, "glue "+tileName(row+1, col)+" here")
+	}
+	pdf.SetDashPattern([]float64{}, 0)
+}
+
+// tileName names a tile by its row and its column, from 1, e.g. 2-3, apart from the names of the formats
+func tileName(row, col int) string {
+	return fmt.Sprintf("%d-%d", row+1, col+1)
+}
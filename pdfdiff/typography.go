@@ -0,0 +1,224 @@
+package pdfdiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The kinds of the typographic defects found with Typography
+const (
+	DefectWidow    = "widow"
+	DefectOrphan   = "orphan"
+	DefectOverflow = "overflow"
+)
+
+// overflowTolerance is how far, in points, a glyph may reach beyond the page box before its line overflows, for
+// the glyphs whose outline touches the edge
+const overflowTolerance = 0.5
+
+// TypographyDefect is a typographic defect of a page of one of the documents, found with Typography
+type TypographyDefect struct {
+	// Document is the document of the page, 1 or 2, and Page the page of that document, from 0
+	Document int `json:"document"`
+	Page     int `json:"page"`
+	// Kind is widow, for the last line of a paragraph alone at the top of the page, orphan, for the first line of a
+	// paragraph alone at the bottom of the page, or overflow, for a line reaching beyond the page box
+	Kind        string `json:"kind"`
+	Text        string `json:"text"`
+	Description string `json:"description"`
+	// New is set for the defects of the second document the first one does not have
+	New bool `json:"new,omitempty"`
+}
+
+// NewDefects returns the typographic defects the second document introduced
+func (r Report) NewDefects() []TypographyDefect {
+	var defects []TypographyDefect
+	for _, d := range r.Typography {
+		if d.New {
+			defects = append(defects, d)
+		}
+	}
+	return defects
+}
+
+// TypographyDefects finds the widows, the orphans and the lines overflowing the page box of both documents, and
+// sets New on the defects of the second document that the first one does not have, the same kind of defect on the
+// same line of text, wherever its page moved
+func TypographyDefects(doc1, doc2 Document) ([]TypographyDefect, error) {
+	defects1, err := documentDefects(doc1, 1)
+	if err != nil {
+		return nil, err
+	}
+	defects2, err := documentDefects(doc2, 2)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]int)
+	for _, d := range defects1 {
+		known[d.Kind+"\x00"+d.Text]++
+	}
+	for i, d := range defects2 {
+		key := d.Kind + "\x00" + d.Text
+		if known[key] > 0 {
+			known[key]--
+			continue
+		}
+		defects2[i].New = true
+	}
+	return append(defects1, defects2...), nil
+}
+
+// documentDefects returns the typographic defects of the pages of a document, in page order
+func documentDefects(doc Document, document int) ([]TypographyDefect, error) {
+	pages := make([][][]string, doc.NumPage())
+	for page := range pages {
+		text, err := pageText(doc, page)
+		if err != nil {
+			return nil, err
+		}
+		pages[page] = textBlocks(text)
+	}
+	stripRunningLines(pages)
+
+	var defects []TypographyDefect
+	add := func(page int, kind, text, description string) {
+		defects = append(defects, TypographyDefect{Document: document, Page: page, Kind: kind, Text: text, Description: description})
+	}
+	for page := range pages {
+		svg, err := pageSVG(doc, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range overflowingLines(svg) {
+			add(page, DefectOverflow, o.text, fmt.Sprintf("the line %q overflows the %s edge of the page box by %.1f pt", o.text, o.edge, o.by))
+		}
+		// A paragraph continues on the next page when its last line does not end a sentence
+		if page+1 >= len(pages) || len(pages[page]) == 0 || len(pages[page+1]) == 0 {
+			continue
+		}
+		last, next := pages[page][len(pages[page])-1], pages[page+1][0]
+		if endsSentence(last[len(last)-1]) {
+			continue
+		}
+		if len(last) == 1 {
+			add(page, DefectOrphan, last[0], fmt.Sprintf("the first line of a paragraph (%q) is alone at the bottom of the page", last[0]))
+		}
+		if len(next) == 1 {
+			add(page+1, DefectWidow, next[0], fmt.Sprintf("the last line of a paragraph (%q) is alone at the top of the page", next[0]))
+		}
+	}
+	return defects, nil
+}
+
+// textBlocks splits the text of a page into its blocks, separated by empty lines, and the blocks into their lines
+func textBlocks(text string) [][]string {
+	var blocks [][]string
+	for _, block := range strings.Split(text, "\n\n") {
+		var lines []string
+		for _, l := range strings.Split(block, "\n") {
+			if l = strings.Join(strings.Fields(l), " "); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, lines)
+		}
+	}
+	return blocks
+}
+
+// stripRunningLines removes the running headers and footers from the blocks of the pages: the lines alone in a
+// block at the top or the bottom of several pages, the same but for their numbers, e.g. "Page 3"
+func stripRunningLines(pages [][][]string) {
+	running := func(block []string) string {
+		if len(block) != 1 {
+			return ""
+		}
+		return strings.Map(func(r rune) rune {
+			if unicode.IsDigit(r) {
+				return '#'
+			}
+			return r
+		}, block[0])
+	}
+	edges := make(map[string]int)
+	for _, blocks := range pages {
+		if len(blocks) == 0 {
+			continue
+		}
+		first, last := running(blocks[0]), running(blocks[len(blocks)-1])
+		if first != "" {
+			edges[first]++
+		}
+		if last != "" && last != first {
+			edges[last]++
+		}
+	}
+	for i, blocks := range pages {
+		for len(blocks) > 0 && edges[running(blocks[0])] > 1 {
+			blocks = blocks[1:]
+		}
+		for len(blocks) > 0 && edges[running(blocks[len(blocks)-1])] > 1 {
+			blocks = blocks[:len(blocks)-1]
+		}
+		pages[i] = blocks
+	}
+}
+
+// endsSentence reports whether a line ends a sentence, and so likely its paragraph, leaving out the closing quotes
+// and brackets
+func endsSentence(line string) bool {
+	line = strings.TrimRight(line, "\"'”’»)]")
+	return strings.HasSuffix(line, ".") || strings.HasSuffix(line, "!") || strings.HasSuffix(line, "?") || strings.HasSuffix(line, ":")
+}
+
+// overflow is a line of text of a page reaching beyond an edge of the page box, by some points
+type overflow struct {
+	text, edge string
+	by         float64
+}
+
+// overflowingLines returns the lines of text of an SVG page reaching beyond the page box, past the edge they
+// overflow the most
+func overflowingLines(svg string) []overflow {
+	width, height, ok := svgPageSize(svg)
+	if !ok {
+		return nil
+	}
+	var lines []overflow
+	for _, line := range svgTextLines(svg) {
+		o := overflow{text: strings.TrimSpace(line.text)}
+		for _, b := range line.boxes {
+			for _, e := range []struct {
+				edge string
+				by   float64
+			}{{"left", -b[0]}, {"top", -b[1]}, {"right", b[2] - width}, {"bottom", b[3] - height}} {
+				if e.by > o.by {
+					o.edge, o.by = e.edge, e.by
+				}
+			}
+		}
+		if o.by > overflowTolerance && o.text != "" {
+			lines = append(lines, o)
+		}
+	}
+	return lines
+}
+
+// svgPageSize returns the size of an SVG page in points, from the view box of its root element
+func svgPageSize(svg string) (float64, float64, bool) {
+	var box []string
+	walkSVG(svg, func(name string, attrs map[string]string, m svgMatrix, hidden bool) {
+		if name == "svg" && box == nil {
+			box = strings.Fields(attrs["viewBox"])
+		}
+	})
+	if len(box) != 4 {
+		return 0, 0, false
+	}
+	width, err1 := strconv.ParseFloat(box[2], 64)
+	height, err2 := strconv.ParseFloat(box[3], 64)
+	return width, height, err1 == nil && err2 == nil
+}
@@ -0,0 +1,236 @@
+package pdfdiff
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The formats of the vector overlays written with VectorOverlay
+const (
+	VectorSVG = "svg"
+	VectorDXF = "dxf"
+)
+
+const (
+	// vectorPrecision is the grid, in points, the vertices of the paths are snapped to before they are paired, so the
+	// rounding of the coordinates by the producers of the documents does not change a path
+	vectorPrecision = 0.05
+	// curveSteps is the number of lines a curve is flattened into
+	curveSteps = 8
+)
+
+// VectorChanges are the vector paths of the drawing of a page added and removed between the documents, found with
+// VectorOverlay
+type VectorChanges struct {
+	// Added and Removed are the subpaths drawn by the second page only, and by the first page only
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	// Overlay is the name of the file of the overlay of the changed paths
+	Overlay string `json:"overlay,omitempty"`
+}
+
+// vectorPath is a subpath drawn by an SVG page, flattened into lines, in points from the top left corner
+type vectorPath struct {
+	points [][2]float64
+	closed bool
+	filled bool
+}
+
+// key identifies a path by its vertices snapped to the grid, so the same path is found on both pages
+func (p vectorPath) key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%t %t", p.closed, p.filled)
+	for _, pt := range p.points {
+		fmt.Fprintf(&b, " %.0f,%.0f", pt[0]/vectorPrecision, pt[1]/vectorPrecision)
+	}
+	return b.String()
+}
+
+// vectorPaths returns the visible paths drawn by an SVG page written by MuPDF, apart from its text: the strokes and
+// fills of its drawings, one by subpath
+func vectorPaths(svg string) []vectorPath {
+	var paths []vectorPath
+	walkSVG(svg, func(name string, attrs map[string]string, m svgMatrix, hidden bool) {
+		if name != "path" || hidden {
+			return
+		}
+		isStroked, _ := stroked(attrs)
+		filled := attrs["fill"] != "none"
+		if !isStroked && !filled {
+			return
+		}
+		for _, sub := range parsePath(attrs["d"]) {
+			p := vectorPath{closed: sub.closed, filled: filled && !isStroked}
+			for i, pt := range sub.points {
+				if i > 0 {
+					p.points = append(p.points, flattenCurve(sub.points[i-1], sub.curves[i], pt)...)
+				}
+				p.points = append(p.points, pt)
+			}
+			for i, pt := range p.points {
+				p.points[i] = [2]float64{m[0]*pt[0] + m[2]*pt[1] + m[4], m[1]*pt[0] + m[3]*pt[1] + m[5]}
+			}
+			if len(p.points) > 1 {
+				paths = append(paths, p)
+			}
+		}
+	})
+	return paths
+}
+
+// flattenCurve returns the points between from and to of the quadratic or cubic curve with these control points, or
+// none for a line
+func flattenCurve(from [2]float64, controls [][2]float64, to [2]float64) [][2]float64 {
+	if len(controls) == 0 || len(controls) > 2 {
+		return nil
+	}
+	var points [][2]float64
+	for step := 1; step < curveSteps; step++ {
+		t := float64(step) / curveSteps
+		var pt [2]float64
+		for k := 0; k < 2; k++ {
+			if len(controls) == 1 {
+				pt[k] = (1-t)*(1-t)*from[k] + 2*(1-t)*t*controls[0][k] + t*t*to[k]
+			} else {
+				pt[k] = math.Pow(1-t, 3)*from[k] + 3*(1-t)*(1-t)*t*controls[0][k] + 3*(1-t)*t*t*controls[1][k] + t*t*t*to[k]
+			}
+		}
+		points = append(points, pt)
+	}
+	return points
+}
+
+// compareVectors pairs the paths drawn by both SVG pages and returns the ones only the second page draws, and the
+// ones only the first page draws. A path that moved is both removed and added.
+func compareVectors(svg1, svg2 string) (added, removed []vectorPath) {
+	paths1, paths2 := vectorPaths(svg1), vectorPaths(svg2)
+	count := make(map[string]int)
+	for _, p := range paths1 {
+		count[p.key()]++
+	}
+	for _, p := range paths2 {
+		if k := p.key(); count[k] > 0 {
+			count[k]--
+		} else {
+			added = append(added, p)
+		}
+	}
+	for _, p := range paths1 {
+		if k := p.key(); count[k] > 0 {
+			count[k]--
+			removed = append(removed, p)
+		}
+	}
+	return added, removed
+}
+
+// vectorOverlayName returns the name of the file of the vector overlay of an output page
+func vectorOverlayName(prefix string, page int, format string) string {
+	return fmt.Sprintf("%svectors_%d.%s", prefix, page, format)
+}
+
+// writeVectorOverlay compares the paths of both SVG pages and, if any changed, writes their overlay in the format
+// of VectorOverlay, the removed paths in red and the added ones in blue, for the CAD tools of the reviewers
+func (opts *Options) writeVectorOverlay(page int, svg1, svg2, path string) (*VectorChanges, error) {
+	added, removed := compareVectors(svg1, svg2)
+	changes := &VectorChanges{Added: len(added), Removed: len(removed)}
+	if len(added) == 0 && len(removed) == 0 {
+		// Do not leave the overlay of a previous run behind
+		if opts.images == nil {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+		return changes, nil
+	}
+	width, height, _ := svgPageSize(svg2)
+	var data []byte
+	if opts.VectorOverlay == VectorDXF {
+		data = dxfOverlay(added, removed, height)
+	} else {
+		data = svgOverlay(added, removed, width, height)
+	}
+	changes.Overlay = filepath.Base(path)
+	if opts.images != nil {
+		opts.images.Add(path, data)
+	} else if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	if opts.OnArtifact != nil {
+		return changes, opts.OnArtifact(page, path)
+	}
+	return changes, nil
+}
+
+// svgOverlay draws the removed and added paths on a transparent SVG page of the size of the second page, in the
+// layers removed and added
+func svgOverlay(added, removed []vectorPath, width, height float64) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\" width=\"%gpt\" height=\"%gpt\" viewBox=\"0 0 %g %g\">\n", width, height, width, height)
+	for _, layer := range []struct {
+		id, color string
+		paths     []vectorPath
+	}{{"removed", "#FF0000", removed}, {"added", "#0000FF", added}} {
+		fmt.Fprintf(&b, "<g id=\"%s\" fill=\"none\" stroke=\"%s\" stroke-width=\"0.5\">\n", layer.id, layer.color)
+		for _, p := range layer.paths {
+			element := "polyline"
+			if p.closed {
+				element = "polygon"
+			}
+			points := make([]string, len(p.points))
+			for i, pt := range p.points {
+				points[i] = fmt.Sprintf("%.2f,%.2f", pt[0], pt[1])
+			}
+			fmt.Fprintf(&b, "<%s points=\"%s\"/>\n", element, strings.Join(points, " "))
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+// dxfOverlay draws the removed and added paths as the polylines of an AutoCAD R12 DXF drawing, in the layers
+// REMOVED (red) and ADDED (blue), in points from the bottom left corner of the page of the given height
+func dxfOverlay(added, removed []vectorPath, height float64) []byte {
+	var b bytes.Buffer
+	group := func(code int, value interface{}) {
+		fmt.Fprintf(&b, "%d\n%v\n", code, value)
+	}
+	group(0, "SECTION")
+	group(2, "ENTITIES")
+	for _, layer := range []struct {
+		name  string
+		color int
+		paths []vectorPath
+	}{{"REMOVED", 1, removed}, {"ADDED", 5, added}} {
+		for _, p := range layer.paths {
+			group(0, "POLYLINE")
+			group(8, layer.name)
+			group(62, layer.color)
+			group(66, 1)
+			group(10, 0)
+			group(20, 0)
+			group(30, 0)
+			closed := 0
+			if p.closed {
+				closed = 1
+			}
+			group(70, closed)
+			for _, pt := range p.points {
+				group(0, "VERTEX")
+				group(8, layer.name)
+				group(10, fmt.Sprintf("%.3f", pt[0]))
+				group(20, fmt.Sprintf("%.3f", height-pt[1]))
+			}
+			group(0, "SEQEND")
+			group(8, layer.name)
+		}
+	}
+	group(0, "ENDSEC")
+	group(0, "EOF")
+	return b.Bytes()
+}
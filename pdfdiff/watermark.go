@@ -0,0 +1,236 @@
+package pdfdiff
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// watermarkDPI is the resolution of the pass that looks for watermarks, enough for the large text they are made of
+const watermarkDPI = 24.0
+
+// A pixel belongs to a watermark when it has the same light tint on at least watermarkPageRatio of the pages of a
+// document, within watermarkTolerance in every color channel
+const (
+	watermarkPageRatio = 0.9
+	watermarkTolerance = 24
+)
+
+// watermarkColorDPI is the resolution the color of the watermark is measured at, where the inside of its strokes
+// outnumbers the edges blended with the paper
+const watermarkColorDPI = 96.0
+
+// watermarkMinArea is the smallest part of the page a watermark covers, so small repeated marks are still compared
+const watermarkMinArea = 0.01
+
+// watermark is the area of the pages of a document covered by a watermark, at watermarkDPI
+type watermark struct {
+	doc           int // document (1 or 2) with the watermark
+	mask          []bool
+	width, height int
+	color         [3]uint8 // color of the watermark on blank paper
+}
+
+// tintStats accumulates the light tints of the pixels of the pages of a document
+type tintStats struct {
+	width, height int
+	pages         int // pages of the size of the first one
+	counts        []int
+	low, high     [][3]uint8
+}
+
+// tinted reports whether a pixel is light enough to be part of a semi-transparent watermark, but not blank paper
+func tinted(c color.Color) bool {
+	b := brightness(c)
+	return b >= 128 && b < 250
+}
+
+// detectWatermarks looks for a watermark repeated on every page of one document and not the other, such as a
+// "DRAFT" stamp. Marks shared by both documents, like a letterhead, are left alone.
+func detectWatermarks(doc1, doc2 Document) ([]*watermark, error) {
+	var stats [2]*tintStats
+	docs := []Document{doc1, doc2}
+	for n, doc := range docs {
+		var err error
+		if stats[n], err = pageTints(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var watermarks []*watermark
+	for n := range stats {
+		s, other := stats[n], stats[1-n]
+		if s == nil {
+			continue
+		}
+		w := &watermark{doc: n + 1, width: s.width, height: s.height, mask: make([]bool, s.width*s.height)}
+		var area int
+		for i := range w.mask {
+			if s.repeated(i) && !(other != nil && other.width == s.width && other.height == s.height && other.repeated(i)) {
+				w.mask[i] = true
+				area++
+			}
+		}
+		if float64(area) < watermarkMinArea*float64(len(w.mask)) {
+			continue
+		}
+		w.dilate()
+		var err error
+		if w.color, err = w.strokeColor(docs[n]); err != nil {
+			return nil, err
+		}
+		watermarks = append(watermarks, w)
+	}
+	return watermarks, nil
+}
+
+// pageTints renders the pages of a document at low resolution and records the light tints of every pixel,
+// or returns nil if the document has too few pages to tell a watermark from content
+func pageTints(doc Document) (*tintStats, error) {
+	if doc.NumPage() < 2 {
+		return nil, nil
+	}
+	var s *tintStats
+	for page := 0; page < doc.NumPage(); page++ {
+		img, err := RenderPage(doc, page, watermarkDPI)
+		if err != nil {
+			return nil, &PageError{Page: page, Err: err}
+		}
+		b := img.Bounds()
+		if s == nil {
+			s = &tintStats{width: b.Dx(), height: b.Dy(), counts: make([]int, b.Dx()*b.Dy()), low: make([][3]uint8, b.Dx()*b.Dy()), high: make([][3]uint8, b.Dx()*b.Dy())}
+			for i := range s.low {
+				s.low[i] = [3]uint8{255, 255, 255}
+			}
+		}
+		// Pages of another format cannot share a watermark position with the first one
+		if b.Dx() != s.width || b.Dy() != s.height {
+			continue
+		}
+		s.pages++
+		for y := 0; y < s.height; y++ {
+			for x := 0; x < s.width; x++ {
+				c := img.At(b.Min.X+x, b.Min.Y+y)
+				if !tinted(c) {
+					continue
+				}
+				i := y*s.width + x
+				s.counts[i]++
+				r, g, bl, _ := c.RGBA()
+				for ch, v := range [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)} {
+					if v < s.low[i][ch] {
+						s.low[i][ch] = v
+					}
+					if v > s.high[i][ch] {
+						s.high[i][ch] = v
+					}
+				}
+			}
+		}
+	}
+	if s.pages < 2 {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// repeated reports whether pixel i has the same tint on nearly every page
+func (s *tintStats) repeated(i int) bool {
+	if float64(s.counts[i]) < watermarkPageRatio*float64(s.pages) {
+		return false
+	}
+	for ch := 0; ch < 3; ch++ {
+		if int(s.high[i][ch])-int(s.low[i][ch]) > 2*watermarkTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// strokeColor returns the most common light tint of the area on the first page, the color of the watermark on paper
+func (w *watermark) strokeColor(doc Document) ([3]uint8, error) {
+	img, err := RenderPage(doc, 0, watermarkColorDPI)
+	if err != nil {
+		return [3]uint8{}, &PageError{Page: 0, Err: err}
+	}
+	b := img.Bounds()
+	tints := make(map[[3]uint8]int)
+	best := [3]uint8{255, 255, 255}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if !w.mask[(y*w.height/b.Dy())*w.width+x*w.width/b.Dx()] {
+				continue
+			}
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			if !tinted(c) {
+				continue
+			}
+			r, g, bl, _ := c.RGBA()
+			tint := [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)}
+			tints[tint]++
+			if tints[tint] > tints[best] {
+				best = tint
+			}
+		}
+	}
+	return best, nil
+}
+
+// dilate grows the area by one pixel, to cover the edges of the watermark lost at low resolution. Dividing the
+// paper or solid content next to the watermark by its color leaves them unchanged, so a generous area is harmless.
+func (w *watermark) dilate() {
+	grown := make([]bool, len(w.mask))
+	for y := 0; y < w.height; y++ {
+		for x := 0; x < w.width; x++ {
+			if !w.mask[y*w.width+x] {
+				continue
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if nx, ny := x+dx, y+dy; nx >= 0 && ny >= 0 && nx < w.width && ny < w.height {
+						grown[ny*w.width+nx] = true
+					}
+				}
+			}
+		}
+	}
+	w.mask = grown
+}
+
+// remove takes the watermark out of a page by dividing the pixels of its area by the color of the watermark: the
+// watermark itself becomes white paper, and dark content drawn over it or blended with it gets its own color back
+func (w *watermark) remove(img image.Image) image.Image {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	for y := 0; y < b.Dy(); y++ {
+		my := y * w.height / b.Dy()
+		for x := 0; x < b.Dx(); x++ {
+			mx := x * w.width / b.Dx()
+			if !w.mask[my*w.width+mx] {
+				continue
+			}
+			i := out.PixOffset(b.Min.X+x, b.Min.Y+y)
+			for ch := 0; ch < 3; ch++ {
+				if w.color[ch] > 0 {
+					out.Pix[i+ch] = uint8(min(255, float64(out.Pix[i+ch])*255/float64(w.color[ch])))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// removeWatermarks returns the preprocess stage that removes the detected watermarks from the pages of their document
+func removeWatermarks(watermarks []*watermark) PreprocessFunc {
+	return func(page int, img1, img2 image.Image) (image.Image, image.Image, error) {
+		for _, w := range watermarks {
+			if w.doc == 1 {
+				img1 = w.remove(img1)
+			} else {
+				img2 = w.remove(img2)
+			}
+		}
+		return img1, img2, nil
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"PdfDiff/pdfdiff"
+)
+
+// quarantineFlags adds the flags of the pages known to render nondeterministically
+func quarantineFlags(flags *flag.FlagSet) (*string, *bool) {
+	path := flags.String("quarantine", "", "a file listing the pages known to render nondeterministically, e.g. 3,5-7 or invoice.pdf: 2 per line, which are compared and reported but never fail the run")
+	report := flags.Bool("report-quarantine", false, "print every quarantined page and whether it changed, so the pages that became stable are taken out of the quarantine")
+	return path, report
+}
+
+// loadQuarantine reads the quarantine file given with -quarantine, if any, and exits if it cannot be read
+func loadQuarantine(path string, files []string) []int {
+	if path == "" {
+		return nil
+	}
+	pages, err := pdfdiff.ReadQuarantine(path, files...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	return pages
+}
+
+// changedQuarantined returns the number of quarantined pages with differences, which do not fail the run
+func changedQuarantined(report pdfdiff.Report) int {
+	n := 0
+	for _, page := range report.QuarantinedPages() {
+		if page.Changed {
+			n++
+		}
+	}
+	return n
+}
+
+// printQuarantine prints the summary of the quarantined pages of a comparison
+func printQuarantine(report pdfdiff.Report, path string) {
+	pages := report.QuarantinedPages()
+	fmt.Printf("%d pages are quarantined by %s:\n", len(pages), path)
+	stable := 0
+	for _, page := range pages {
+		if page.Changed {
+			fmt.Printf("  Page %d: %.2f%% changed, SSIM %.4f\n", page.Page+1, page.PercentChanged, page.SSIM)
+		} else {
+			fmt.Printf("  Page %d: identical\n", page.Page+1)
+			stable++
+		}
+	}
+	if stable > 0 {
+		fmt.Printf("%d quarantined pages were identical: take them out of %s once they stay stable\n", stable, path)
+	}
+}
+
+// containsPage reports whether a list of pages, such as the quarantined ones, holds a page
+func containsPage(pages []int, page int) bool {
+	for _, p := range pages {
+		if p == page {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+
+	"github.com/disintegration/imaging"
+)
+
+// runRender rasterizes the pages of a single document with the same pipeline used for comparisons,
+// so baselines and ad-hoc renders match what a comparison would see
+func runRender(args []string) {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	pagesFlag := flags.String("pages", "", "the pages to render, e.g. 1-10 or 1,3,5-7 (Default: all pages)")
+	dpiFlag := flags.Float64("dpi", pdfdiff.DefaultDPI, "the resolution used to rasterize the pages")
+	outdirFlag := flags.String("outdir", ".", "the directory where the page images are written")
+	reflowFlag := reflowFlags(flags)
+
+	files := parseArgs(flags, args)
+	if len(files) != 1 {
+		fmt.Println("Usage: render [-pages 1-10] [-dpi 300] [-outdir pages/] [-reflow-size 450x600] [-reflow-font-size 12] <file>")
+		os.Exit(exitUsage)
+	}
+	file := files[0]
+
+	if *dpiFlag <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: The dpi is invalid. It should be greater than 0.\n")
+		os.Exit(exitUsage)
+	}
+	if err := pdfdiff.CheckInput(file); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+
+	doc, err := pdfdiff.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	// Ensure the document is closed after use
+	defer doc.Close()
+	if err := pdfdiff.Reflow(doc, *reflowFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	pages, err := parsePageRange(*pagesFlag, doc.NumPage())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	renderPages(doc, pages, *dpiFlag, *outdirFlag)
+	fmt.Printf("%d pages have been rendered into %s\n", len(pages), *outdirFlag)
+}
+
+// renderPages writes the images of the pages of a document to page_001.png, page_002.png... in outdir
+func renderPages(doc pdfdiff.Document, pages []int, dpi float64, outdir string) {
+	if err := os.MkdirAll(outdir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+
+	for n, page := range pages {
+		img, err := pdfdiff.RenderPage(doc, page, dpi)
+		if checkError(err) != nil {
+			os.Exit(exitRender)
+		}
+		// Pages are numbered from 1 in the file names, matching the page numbers passed to -pages
+		imgPath := filepath.Join(outdir, fmt.Sprintf("page_%03d.png", page+1))
+		if checkError(imaging.Save(img, imgPath)) != nil {
+			os.Exit(exitOutput)
+		}
+		fmt.Printf("%.2f%% completed\n", float64(n+1)/float64(len(pages))*100)
+	}
+}
+
+// parsePageRange parses a list of 1-based pages and ranges such as "1-10" or "1,3,5-7" into 0-based page indexes.
+// An empty specification selects every page of the document.
+func parsePageRange(spec string, numPages int) ([]int, error) {
+	var pages []int
+	if strings.TrimSpace(spec) == "" {
+		for i := 0; i < numPages; i++ {
+			pages = append(pages, i)
+		}
+		return pages, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		first, last := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			first, last = part[:i], part[i+1:]
+		}
+		from, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		to, err := strconv.Atoi(strings.TrimSpace(last))
+		if err != nil {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		if from < 1 || to > numPages || from > to {
+			return nil, fmt.Errorf("invalid page range %q. Pages should be between 1 and %d", part, numPages)
+		}
+		for p := from; p <= to; p++ {
+			pages = append(pages, p-1)
+		}
+	}
+	return pages, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"PdfDiff/pdfdiff"
+)
+
+// manifestName is the file, written next to the page images, that describes the artifacts of a comparison
+const manifestName = "pdfdiff_manifest.json"
+
+// runManifest describes the artifacts produced by a comparison, so the reports can be regenerated later
+// without comparing the documents again. Image paths are relative to the directory holding the manifest.
+type runManifest struct {
+	pdfdiff.Report
+	Orientation string `json:"orientation"`
+	PrintSize   string `json:"print_size"`
+	TilePrint   string `json:"tile_print,omitempty"`
+	Output      string `json:"output"`
+	Merge       bool   `json:"merge"`
+	SideBySide  bool   `json:"side_by_side"`
+	HTML        string `json:"html,omitempty"`
+	ChangedOnly bool   `json:"changed_only,omitempty"`
+	SummaryPage bool   `json:"summary_page,omitempty"`
+	JSON        string `json:"json,omitempty"`
+	CSV         string `json:"csv,omitempty"`
+	SARIF       string `json:"sarif,omitempty"`
+	JUnit       string `json:"junit,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	SummaryTop  int    `json:"summary_top,omitempty"`
+	Overview    string `json:"overview,omitempty"`
+	Annotations string `json:"annotations,omitempty"`
+	// The thresholds the JUnit test cases fail on
+	MinSSIM            float64 `json:"min_ssim,omitempty"`
+	MaxPageDiffPercent float64 `json:"max_page_diff_percent,omitempty"`
+}
+
+// reports returns the paths of the reports of the comparison, empty for those it did not write
+func (m *runManifest) reports() []string {
+	return []string{m.HTML, m.JSON, m.JUnit, m.SARIF, m.CSV, m.Summary, m.Overview, m.Annotations}
+}
+
+// writeReports writes the reports of the manifest other than the merged PDFs
+func writeReports(m *runManifest) error {
+	junit := func(report pdfdiff.Report, output string) error {
+		return pdfdiff.WriteJUnit(report, output, m.MinSSIM, m.MaxPageDiffPercent)
+	}
+	summary := func(report pdfdiff.Report, output string) error {
+		return pdfdiff.WriteSummary([]pdfdiff.Report{report}, output, m.SummaryTop)
+	}
+	writers := []struct {
+		output  string
+		write   func(pdfdiff.Report, string) error
+		message string
+	}{
+		{m.HTML, pdfdiff.WriteHTMLReport, "The HTML report has been written to %s\n"},
+		{m.JSON, pdfdiff.WriteJSONReport, "The JSON report has been written to %s\n"},
+		{m.CSV, pdfdiff.WriteCSV, "The page metrics have been written to %s\n"},
+		{m.SARIF, pdfdiff.WriteSARIF, "The SARIF log has been written to %s\n"},
+		{m.JUnit, junit, "The JUnit report has been written to %s\n"},
+		{m.Summary, summary, "The executive summary has been written to %s\n"},
+		{m.Overview, pdfdiff.WriteOverview, "The overview has been written to %s\n"},
+		{m.Annotations, pdfdiff.WriteAnnotations, "The annotations have been written to %s\n"},
+	}
+	for _, w := range writers {
+		if w.output == "" {
+			continue
+		}
+		if err := w.write(m.Report, w.output); err != nil {
+			return err
+		}
+		fmt.Printf(w.message, w.output)
+	}
+	return nil
+}
+
+// reportFlags adds the flags of the layout and the reports rebuilt by the report subcommand, bound to the fields of m
+func reportFlags(flags *flag.FlagSet, m *runManifest) {
+	flags.StringVar(&m.Output, "output", m.Output, "the name of the output PDF file (Default: the output of the original comparison)")
+	flags.StringVar(&m.Orientation, "orientation", m.Orientation, "the orientation of the PDF (P for portrait, L for landscape, first for the orientation of the first page, auto to turn every page like its image) (Default: the orientation of the original comparison)")
+	flags.StringVar(&m.PrintSize, "printsize", m.PrintSize, "Size of printed PDF A4,A3,A2..., or auto for the A size closest to most pages (Default: the print size of the original comparison)")
+	flags.StringVar(&m.TilePrint, "tile-print", m.TilePrint, "split the difference pages larger than this A format, e.g. A4, into overlapping tiles with assembly marks (Default: the tiles of the original comparison)")
+	flags.BoolVar(&m.SideBySide, "sidebyside", m.SideBySide, "also merge the side-by-side images into a PDF (Default: as the original comparison)")
+	flags.BoolVar(&m.ChangedOnly, "changed-only", m.ChangedOnly, "only merge the pages that have differences (Default: as the original comparison)")
+	flags.BoolVar(&m.SummaryPage, "summary-page", m.SummaryPage, "begin the merged PDFs with a page describing the comparison (Default: as the original comparison)")
+	flags.StringVar(&m.HTML, "html", m.HTML, "write the HTML report, e.g. report.html, or none with an empty name (Default: the HTML report of the original comparison)")
+	flags.StringVar(&m.JSON, "json", m.JSON, "write the JSON report, e.g. report.json (Default: the JSON report of the original comparison)")
+	flags.StringVar(&m.CSV, "csv", m.CSV, "write the metrics of every page to a CSV file, e.g. metrics.csv (Default: the CSV file of the original comparison)")
+	flags.StringVar(&m.SARIF, "sarif", m.SARIF, "write the SARIF log, e.g. pdfdiff.sarif (Default: the SARIF log of the original comparison)")
+	flags.StringVar(&m.JUnit, "junit", m.JUnit, "write the JUnit XML report, e.g. junit.xml (Default: the JUnit report of the original comparison)")
+	flags.StringVar(&m.Summary, "summary", m.Summary, "write the executive summary, as Markdown (.md), a one-page PDF (.pdf) or text (Default: the summary of the original comparison)")
+	flags.IntVar(&m.SummaryTop, "summary-top", m.SummaryTop, "the number of pages listed in the executive summary (Default: as the original comparison)")
+	flags.StringVar(&m.Overview, "overview", m.Overview, "write all the pages on a single A0 sheet, as a PDF (.pdf) or an image (Default: the overview of the original comparison)")
+	flags.StringVar(&m.Annotations, "annotations", m.Annotations, "export the changed regions as XFDF (.xfdf) or FDF (.fdf) annotations (Default: the annotations of the original comparison)")
+}
+
+// writeManifest saves the manifest in the artifacts directory
+func writeManifest(dir string, m *runManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestName), data, 0644)
+}
+
+// readManifest loads the manifest from the artifacts directory
+func readManifest(dir string) (*runManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s does not contain a %s file. Run a comparison first", dir, manifestName)
+		}
+		return nil, err
+	}
+	m := &runManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", manifestName, err)
+	}
+	if err := pdfdiff.CheckSchemaVersion(m.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", manifestName, err)
+	}
+	// The images are looked up next to the manifest, wherever the directory has been moved
+	m.Dir = dir
+	return m, nil
+}
+
+// combinedOutputPath returns the name of the side-by-side PDF that accompanies the differences PDF
+func combinedOutputPath(output string) string {
+	return filepath.Join(filepath.Dir(output), "combined_"+filepath.Base(output))
+}
+
+// runReport regenerates the reports of a previous comparison from its page images and manifest, with the layout and
+// the report formats of the comparison unless flags change them
+func runReport(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	fromArtifactsFlag := flags.String("from-artifacts", "", "the directory holding the page images and manifest of a previous comparison")
+	reportFlags(flags, &runManifest{})
+	heartbeatFlag, heartbeatTextFlag := heartbeatFlags(flags)
+
+	rest := parseArgs(flags, args)
+	if *fromArtifactsFlag == "" && len(rest) == 1 {
+		*fromArtifactsFlag = rest[0]
+	} else if *fromArtifactsFlag == "" || len(rest) != 0 {
+		fmt.Println("Usage: report --from-artifacts <dir> [-output output.pdf] [-printsize auto|A4|A3|A2|A1|A0] [-tile-print A4] [-orientation auto|first|P|L] [-sidebyside] [-changed-only] [-summary-page] [-html report.html] [-json report.json] [-csv metrics.csv] [-sarif pdfdiff.sarif] [-junit junit.xml] [-summary summary.md] [-summary-top 10] [-overview overview.png] [-annotations changes.xfdf]")
+		os.Exit(exitUsage)
+	}
+	if problems := flagProblems(flags); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		}
+		os.Exit(exitUsage)
+	}
+	dir := *fromArtifactsFlag
+
+	m, err := readManifest(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	if len(m.Pages) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s does not list any page images\n", manifestName)
+		os.Exit(exitInput)
+	}
+
+	// The flags given replace the layout and the reports of the comparison, the others keep them
+	recorded := flag.NewFlagSet("report", flag.ContinueOnError)
+	reportFlags(recorded, m)
+	flags.Visit(func(f *flag.Flag) {
+		if recorded.Lookup(f.Name) != nil {
+			recorded.Set(f.Name, f.Value.String())
+		}
+	})
+	if m.Output == "" {
+		fmt.Fprintf(os.Stderr, "Error: The output PDF file should have a name.\n")
+		os.Exit(exitUsage)
+	}
+	output := m.Output
+
+	hb := startHeartbeat(*heartbeatFlag, *heartbeatTextFlag)
+	defer hb.Stop()
+	layout := pdfdiff.Layout{Orientation: resolveOrientation(m.Orientation, m.Pages), PrintSize: m.PrintSize, TilePrint: m.TilePrint, DPI: m.DPI, Progress: hb.wrap(printMergeProgress), PageNumbers: m.ChangedOnly}
+	if m.SummaryPage {
+		layout.Summary = &m.Report
+	}
+	pages := m.Pages
+	if m.ChangedOnly {
+		pages = m.ChangedPages()
+	}
+
+	fmt.Printf("Merging difference images...")
+	err = pdfdiff.WriteDiffPDF(m.Dir, pages, output, layout)
+	fmt.Println()
+	if checkError(err) != nil {
+		os.Exit(exitOutput)
+	}
+	fmt.Printf("The difference images have been merged into %s\n", output)
+
+	if m.SideBySide {
+		outputCombinedPDF := combinedOutputPath(output)
+		if checkError(pdfdiff.WriteCombinedPDF(m.Dir, pages, outputCombinedPDF, layout)) != nil {
+			os.Exit(exitOutput)
+		}
+		fmt.Printf("The combined images have been merged into %s\n", outputCombinedPDF)
+	}
+
+	if checkError(writeReports(m)) != nil {
+		os.Exit(exitOutput)
+	}
+}
+
+// runMerge re-runs the merge stage of a previous comparison with a different layout, reusing its cached page images
+func runMerge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	orientationFlag := flags.String("orientation", "", "the orientation of the PDF (P for portrait, L for landscape, first for the orientation of the first page, auto to turn every page like its image) (Default: the orientation of the original comparison)")
+	printSizeFlag := flags.String("printsize", "", "Size of printed PDF A4,A3,A2..., or auto for the A size closest to most pages (Default: the print size of the original comparison)")
+	tilePrintFlag := flags.String("tile-print", "", "split the difference pages larger than this A format, e.g. A4, into overlapping tiles with assembly marks (Default: the tiles of the original comparison)")
+	outputFlag := flags.String("output", "", "the name of the output PDF file (Default: the output of the original comparison)")
+	changedOnlyFlag := flags.Bool("changed-only", false, "only merge the pages that have differences")
+	summaryPageFlag := flags.Bool("summary-page", false, "begin the merged PDF with a page describing the comparison")
+	heartbeatFlag, heartbeatTextFlag := heartbeatFlags(flags)
+
+	dirs := parseArgs(flags, args)
+	if len(dirs) != 1 {
+		fmt.Println("Usage: merge [-printsize auto|A4|A3|A2|A1|A0] [-tile-print A4] [-orientation auto|first|P|L] [-output output.pdf] [-changed-only] [-summary-page] <dir>")
+		os.Exit(exitUsage)
+	}
+	if problems := flagProblems(flags); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", problem)
+		}
+		os.Exit(exitUsage)
+	}
+	dir := dirs[0]
+
+	m, err := readManifest(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitInput)
+	}
+	orientation, printSize, tilePrint, output := m.Orientation, m.PrintSize, m.TilePrint, m.Output
+	if *orientationFlag != "" {
+		orientation = *orientationFlag
+	}
+	if *printSizeFlag != "" {
+		printSize = *printSizeFlag
+	}
+	if *tilePrintFlag != "" {
+		tilePrint = *tilePrintFlag
+	}
+	if *outputFlag != "" {
+		output = *outputFlag
+	}
+
+	// Check that the orientation and print size are valid
+	if !validOrientation(orientation) {
+		fmt.Fprintf(os.Stderr, "Error: The orientation is invalid. It should be 'P', 'L', 'first' or 'auto'.\n")
+		os.Exit(exitUsage)
+	}
+	if !validPrintSize(printSize) {
+		fmt.Fprintf(os.Stderr, "Error: Invalid print size. It should be one of 'auto', 'A4', 'A3', 'A2', 'A1', or 'A0'.\n")
+		os.Exit(exitUsage)
+	}
+	if tilePrint != "" && !validTileSize(tilePrint) {
+		fmt.Fprintf(os.Stderr, "Error: Invalid tile size. It should be one of 'A4', 'A3', 'A2', 'A1', or 'A0'.\n")
+		os.Exit(exitUsage)
+	}
+	orientation = resolveOrientation(orientation, m.Pages)
+
+	pages := m.Pages
+	if *changedOnlyFlag {
+		pages = m.ChangedPages()
+	}
+	if len(pages) == 0 {
+		fmt.Println("There are no pages to merge")
+		return
+	}
+
+	hb := startHeartbeat(*heartbeatFlag, *heartbeatTextFlag)
+	defer hb.Stop()
+
+	layout := pdfdiff.Layout{Orientation: orientation, PrintSize: printSize, TilePrint: tilePrint, DPI: m.DPI, Progress: hb.wrap(printMergeProgress), PageNumbers: *changedOnlyFlag}
+	if *summaryPageFlag {
+		layout.Summary = &m.Report
+	}
+	fmt.Printf("Merging difference images...")
+	err = pdfdiff.WriteDiffPDF(m.Dir, pages, output, layout)
+	fmt.Println()
+	if checkError(err) != nil {
+		os.Exit(exitOutput)
+	}
+	fmt.Printf("The difference images have been merged into %s\n", output)
+}
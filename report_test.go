@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestReportFormats(t *testing.T) {
+	dir := t.TempDir()
+	writePage(t, filepath.Join(dir, "a.png"), false)
+	writePage(t, filepath.Join(dir, "b.png"), true)
+	if got := runTool(t, dir, "-workdir", "artifacts", "-merge", "-printsize", "A4", "-orientation", "P", "-json", "report.json", "-csv", "metrics.csv", "a.png", "b.png"); got != exitDifferent {
+		t.Fatalf("exit code of the comparison = %d, want %d", got, exitDifferent)
+	}
+	m, err := readManifest(filepath.Join(dir, "artifacts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.JSON != "report.json" || m.CSV != "metrics.csv" {
+		t.Fatalf("the manifest records the JSON report %q and the CSV file %q, want report.json and metrics.csv", m.JSON, m.CSV)
+	}
+
+	// A4 in points, portrait then landscape
+	portrait, landscape := []byte("/MediaBox [0 0 595.28 841.89]"), []byte("/MediaBox [0 0 841.89 595.28]")
+	tests := []struct {
+		name     string
+		args     []string
+		want     []string
+		wantNot  []string
+		mediaBox []byte
+		wantExit int
+	}{
+		{"formats of the comparison", nil, []string{"differences.pdf", "report.json", "metrics.csv"}, nil, portrait, exitIdentical},
+		{"other formats", []string{"-orientation", "L", "-json", "other.json", "-csv", "", "-sarif", "pdfdiff.sarif"},
+			[]string{"differences.pdf", "other.json", "pdfdiff.sarif"}, []string{"report.json", "metrics.csv"}, landscape, exitIdentical},
+		{"other output", []string{"-output", "review.pdf", "-json", ""}, []string{"review.pdf", "metrics.csv"}, []string{"differences.pdf", "report.json"}, nil, exitIdentical},
+		{"invalid orientation", []string{"-orientation", "sideways"}, nil, []string{"differences.pdf", "report.json", "metrics.csv"}, nil, exitUsage},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{"differences.pdf", "review.pdf", "report.json", "other.json", "metrics.csv", "pdfdiff.sarif"} {
+				os.Remove(filepath.Join(dir, name))
+			}
+			args := append([]string{"report", "-from-artifacts", "artifacts"}, test.args...)
+			if got := runTool(t, dir, args...); got != test.wantExit {
+				t.Fatalf("exit code of %q = %d, want %d", test.args, got, test.wantExit)
+			}
+			for _, name := range test.want {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s has not been written: %v", name, err)
+				}
+			}
+			for _, name := range test.wantNot {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					t.Errorf("%s has been written", name)
+				}
+			}
+			if test.mediaBox != nil {
+				data, err := os.ReadFile(filepath.Join(dir, "differences.pdf"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Contains(data, test.mediaBox) {
+					t.Errorf("differences.pdf is not laid out on %s", test.mediaBox)
+				}
+			}
+		})
+	}
+
+	// The JSON report rebuilt from the artifacts describes the same comparison
+	if got := runTool(t, dir, "report", "-from-artifacts", "artifacts", "-json", "other.json"); got != exitIdentical {
+		t.Fatalf("exit code of report = %d", got)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "other.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report pdfdiff.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Pages) != 1 || !report.Pages[0].Changed || report.Pages[0].PercentChanged != m.Pages[0].PercentChanged {
+		t.Errorf("the JSON report lists %+v, want the changed page of the comparison", report.Pages)
+	}
+}
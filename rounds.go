@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+)
+
+// twoDocumentFlags are the flags of a comparison that only apply to two documents
+var twoDocumentFlags = []string{"password1", "password2", "page1", "page2", "offset", "startoffset", "remote", "in-memory",
+	"html", "annotations", "summary", "overview", "summary-page", "thumbnails", "embedded-fonts-only", "sidebyside", "clean", "store", "deadline", "tui", "upload", "archive", "junit", "sarif", "csv"}
+
+// roundsProblems lists the flags set for a comparison of more than two documents that only apply to two of them
+func roundsProblems(flags *flag.FlagSet) []string {
+	var problems []string
+	flags.Visit(func(f *flag.Flag) {
+		for _, name := range twoDocumentFlags {
+			if f.Name == name {
+				problems = append(problems, fmt.Sprintf("-%s can only be used to compare two documents: remove it, or compare the documents two at a time.", name))
+			}
+		}
+	})
+	return problems
+}
+
+// runRounds compares every pair of several documents, e.g. the original, the counter-proposal and the final version
+// of a contract, prints the matrix of their differences and draws the changes of every round in its own color
+func runRounds(files []string, opts pdfdiff.Options, layout pdfdiff.Layout, merge bool, output, jsonOutput string) {
+	report, err := pdfdiff.CompareDocuments(context.Background(), files, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	// Every cell is the number of changed pages between the documents of its row and column, and their similarity
+	fmt.Println("Changed pages and structural similarity (SSIM) of every pair of documents:")
+	for i := range files {
+		cells := make([]string, len(files))
+		for j := range files {
+			cells[j] = fmt.Sprintf("%16s", "-")
+			if pair, ok := report.Pair(i, j); ok {
+				cells[j] = fmt.Sprintf("%6d (%.4f)", len(pair.Report.ChangedPages()), pair.Report.SSIM)
+			}
+		}
+		fmt.Printf("  %d %s\n", i+1, strings.Join(cells, " "))
+	}
+	for i, file := range files {
+		fmt.Printf("  %d: %s\n", i+1, file)
+	}
+	fmt.Println("Colors of the changes of every round:")
+	for k := 1; k < len(files); k++ {
+		fmt.Printf("  %s: document %d to document %d\n", pdfdiff.RoundColors[(k-1)%len(pdfdiff.RoundColors)], k, k+1)
+	}
+
+	if jsonOutput != "" {
+		if checkError(pdfdiff.WriteMultiReport(report, jsonOutput)) != nil {
+			os.Exit(exitOutput)
+		}
+		fmt.Printf("The JSON report has been written to %s\n", jsonOutput)
+	}
+
+	var changed []pdfdiff.PageResult
+	for _, page := range report.Rounds {
+		if page.DiffImage != "" {
+			changed = append(changed, page)
+		}
+	}
+	if merge && len(changed) == 0 {
+		fmt.Println("There are no changed pages to merge")
+	} else if merge {
+		layout.Orientation = resolveOrientation(layout.Orientation, report.Rounds)
+		fmt.Printf("Merging the images of the rounds...")
+		err := pdfdiff.WriteDiffPDF(report.Dir, changed, output, layout)
+		fmt.Println()
+		if checkError(err) != nil {
+			os.Exit(exitOutput)
+		}
+		fmt.Printf("The images of the rounds have been merged into %s\n", output)
+	}
+	fmt.Printf("The page images have been written to %s\n", report.Dir)
+
+	if report.Changed() {
+		os.Exit(exitDifferent)
+	}
+	os.Exit(exitIdentical)
+}
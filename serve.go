@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"PdfDiff/pdfdiff/remote"
+)
+
+// runServe runs a remote worker that compares the page ranges sent by a coordinator (compare -remote)
+func runServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listenFlag := flags.String("listen", remote.DefaultAddress, "the address to listen on for coordinators")
+	cacheFlag := flags.String("cache", filepath.Join(os.TempDir(), "pdfdiff-cache"), "the directory where the received documents are cached")
+	workersFlag := flags.Int("workers", 0, "the number of workers to use. (Default: CPU Count)")
+	uiFlag := flags.String("ui", "", "also serve a web UI to browse the comparisons below -results on this address, e.g. 127.0.0.1:8080")
+	resultsFlag := flags.String("results", ".", "the directory whose comparisons the web UI lists, e.g. the -workdir of the comparisons or a batch -outdir")
+	tlsCertFlag := flags.String("tls-cert", "", "serve over TLS with the certificate of this PEM file, with -tls-key")
+	tlsKeyFlag := flags.String("tls-key", "", "the PEM file of the private key of -tls-cert")
+	tokenFlag := flags.String("token-file", "", "only accept the coordinators that send the bearer token of this file (compare -remote-token-file)")
+	cacheSizeFlag := flags.Int64("cache-size", remote.DefaultCacheSize>>20, "the total size of the cached documents in MiB, beyond which the least recently used ones are removed")
+	maxRequestFlag := flags.Int("max-request-size", remote.DefaultMaxRequestSize>>20, "the largest request accepted in MiB, which carries both documents")
+
+	if rest := parseArgs(flags, args); len(rest) != 0 {
+		fmt.Println("Usage: serve [-listen localhost:50051] [-cache dir] [-cache-size MiB] [-max-request-size MiB] [-tls-cert cert.pem -tls-key key.pem] [-token-file token.txt] [-workers n] [-ui 127.0.0.1:8080] [-results dir]")
+		os.Exit(exitUsage)
+	}
+	if (*tlsCertFlag == "") != (*tlsKeyFlag == "") {
+		fmt.Fprintln(os.Stderr, "Error: -tls-cert and -tls-key go together: give both, or neither to serve without TLS.")
+		os.Exit(exitUsage)
+	}
+	if *cacheSizeFlag <= 0 || *maxRequestFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -cache-size and -max-request-size are sizes in MiB, e.g. 2048: give a positive number.")
+		os.Exit(exitUsage)
+	}
+	server := remote.NewServer(*cacheFlag, *workersFlag)
+	server.CacheSize = *cacheSizeFlag << 20
+	server.MaxRequestSize = *maxRequestFlag << 20
+	if *tlsCertFlag != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCertFlag, *tlsKeyFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	if *tokenFlag != "" {
+		token, err := readToken(*tokenFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		server.Token = token
+	}
+
+	if *uiFlag != "" {
+		ui, err := net.Listen("tcp", *uiFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		fmt.Printf("Serving the web UI of the comparisons in %s on http://%s/\n", *resultsFlag, ui.Addr())
+		if !loopback(ui.Addr()) {
+			fmt.Fprintf(os.Stderr, "Warning: the web UI is not authenticated and %s is reachable from other machines, which can read every comparison below %s: listen on 127.0.0.1 instead\n", ui.Addr(), *resultsFlag)
+		}
+		// An empty -listen only serves the web UI
+		if *listenFlag == "" {
+			checkError(http.Serve(ui, newResultsUI(*resultsFlag)))
+			os.Exit(exitOutput)
+		}
+		go func() {
+			checkError(http.Serve(ui, newResultsUI(*resultsFlag)))
+			os.Exit(exitOutput)
+		}()
+	}
+
+	lis, err := net.Listen("tcp", *listenFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	fmt.Printf("Waiting for coordinators on %s\n", lis.Addr())
+	if err := server.Serve(lis); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitOutput)
+	}
+}
+
+// loopback reports whether a listener only accepts the connections of this machine
+func loopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// readToken reads the bearer token of a worker from a file, without the spaces and newlines around it
+func readToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("the token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// clientTLS returns the TLS configuration of the connections to the remote workers: nil without TLS, the system
+// roots with -remote-tls, or the CA certificates of a PEM file
+func clientTLS(system bool, caFile string) (*tls.Config, error) {
+	if caFile == "" {
+		if !system {
+			return nil, nil
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s has no PEM certificates", caFile)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestLoopback(t *testing.T) {
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"127.0.0.1:0", true},
+		{"localhost:0", true},
+		{"[::1]:0", true},
+		{":0", false},
+		{"0.0.0.0:0", false},
+	}
+	for _, test := range tests {
+		lis, err := net.Listen("tcp", test.address)
+		if err != nil {
+			t.Logf("%s: %v", test.address, err)
+			continue
+		}
+		if got := loopback(lis.Addr()); got != test.want {
+			t.Errorf("loopback(%s) = %v, want %v", lis.Addr(), got, test.want)
+		}
+		lis.Close()
+	}
+}
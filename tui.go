@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"PdfDiff/pdfdiff"
+
+	"github.com/disintegration/imaging"
+)
+
+// Graphics protocols of the terminals, to preview the difference images
+const (
+	graphicsKitty  = "kitty"
+	graphicsSixel  = "sixel"
+	graphicsBlocks = "blocks" // colored half blocks, which every terminal with 24-bit colors can show
+)
+
+// tui is the terminal interface of -tui, which lists the pages of a comparison and previews their difference images,
+// so the results can be triaged over SSH
+type tui struct {
+	report      pdfdiff.Report
+	pages       []pdfdiff.PageResult // the pages listed
+	changedOnly bool
+	cursor, top int
+	height      int // rows of the terminal, read again before every screen is drawn
+	width       int
+	graphics    string
+	in          *bufio.Reader
+	out         *bufio.Writer
+}
+
+// runTUI browses the pages of a comparison on the terminal until q is pressed
+func runTUI(report pdfdiff.Report) error {
+	restore, err := rawTerminal()
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	t := &tui{report: report, graphics: detectGraphics(), in: stdin, out: bufio.NewWriter(os.Stdout)}
+	t.filter()
+	// The alternate screen leaves the output of the comparison as it was
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+		t.out.Flush()
+	}()
+
+	for {
+		t.drawList()
+		switch t.key() {
+		case "q", "esc":
+			return nil
+		case "up", "k":
+			t.move(-1)
+		case "down", "j":
+			t.move(1)
+		case "pgup":
+			t.move(-t.rows())
+		case "pgdown", " ":
+			t.move(t.rows())
+		case "c":
+			t.changedOnly = !t.changedOnly
+			t.filter()
+		case "enter", "d":
+			t.preview(func(page pdfdiff.PageResult) string { return page.DiffImage })
+		case "a":
+			t.preview(func(page pdfdiff.PageResult) string { return page.Image1 })
+		case "b":
+			t.preview(func(page pdfdiff.PageResult) string { return page.Image2 })
+		}
+	}
+}
+
+// filter lists all the pages, or only the changed ones
+func (t *tui) filter() {
+	t.pages = t.report.Pages
+	if t.changedOnly {
+		t.pages = t.report.ChangedPages()
+	}
+	t.cursor, t.top = 0, 0
+}
+
+// rows returns the number of pages the list shows at a time, below the header and above the help line
+func (t *tui) rows() int {
+	if t.height < 4 {
+		return 1
+	}
+	return t.height - 3
+}
+
+// move moves the cursor by n pages, scrolling the list with it
+func (t *tui) move(n int) {
+	t.cursor += n
+	if t.cursor >= len(t.pages) {
+		t.cursor = len(t.pages) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+t.rows() {
+		t.top = t.cursor - t.rows() + 1
+	}
+}
+
+func (t *tui) drawList() {
+	t.height, t.width = terminalSize()
+	cols := t.width
+	fmt.Fprint(t.out, "\x1b[H\x1b[2J")
+	fmt.Fprintf(t.out, "\x1b[1m%s\x1b[0m\r\n", clip(fmt.Sprintf("%s vs %s: %d pages, %d with differences, SSIM %.4f",
+		filepath.Base(t.report.File1), filepath.Base(t.report.File2), len(t.report.Pages), len(t.report.ChangedPages()), t.report.SSIM), cols))
+	if len(t.pages) == 0 {
+		fmt.Fprint(t.out, "No page has differences\r\n")
+	}
+	for i := t.top; i < len(t.pages) && i < t.top+t.rows(); i++ {
+		line := clip(describePage(t.pages[i]), cols)
+		switch {
+		case i == t.cursor:
+			line = "\x1b[7m" + line + "\x1b[0m"
+		case t.pages[i].Changed:
+			line = "\x1b[31m" + line + "\x1b[0m"
+		}
+		fmt.Fprint(t.out, line, "\r\n")
+	}
+	fmt.Fprintf(t.out, "\x1b[%d;1H\x1b[2m%s\x1b[0m", t.height, clip("up/down move  enter preview  a/b documents  c changed only  q quit", cols))
+	t.out.Flush()
+}
+
+// describePage returns the line of a page in the list
+func describePage(page pdfdiff.PageResult) string {
+	line := fmt.Sprintf("Page %4d  %7.2f%% changed  SSIM %.4f", page.Page+1, page.PercentChanged, page.SSIM)
+	if page.MissingIn != 0 {
+		line += fmt.Sprintf("  missing in document %d", page.MissingIn)
+	}
+	if len(page.Critical) > 0 {
+		line += "  CRITICAL"
+	}
+	if page.Quarantined {
+		line += "  quarantined"
+	}
+	return line
+}
+
+// clip cuts a line to the width of the terminal
+func clip(line string, cols int) string {
+	if r := []rune(line); cols > 0 && len(r) > cols {
+		return string(r[:cols])
+	}
+	return line
+}
+
+// preview shows an image of the page under the cursor until a key is pressed
+func (t *tui) preview(name func(pdfdiff.PageResult) string) {
+	if len(t.pages) == 0 {
+		return
+	}
+	page := t.pages[t.cursor]
+	rows, cols := terminalSize()
+	fmt.Fprint(t.out, "\x1b[H\x1b[2J")
+	fmt.Fprintf(t.out, "\x1b[1m%s\x1b[0m\r\n", clip(describePage(page), cols))
+	img, err := t.image(name(page))
+	if err != nil {
+		fmt.Fprintf(t.out, "%v\r\n", err)
+	} else {
+		drawImage(t.out, img, t.graphics, cols, rows-2)
+	}
+	fmt.Fprintf(t.out, "\x1b[%d;1H\x1b[2m%s\x1b[0m", rows, clip("press any key to go back", cols))
+	t.out.Flush()
+	t.key()
+	if t.graphics == graphicsKitty {
+		// Remove the image, which the terminal keeps otherwise
+		fmt.Fprint(t.out, "\x1b_Ga=d\x1b\\")
+	}
+}
+
+// image opens an image of the comparison, from memory with -in-memory
+func (t *tui) image(name string) (image.Image, error) {
+	switch {
+	case name == "":
+		return nil, fmt.Errorf("this image was not written: the pages of the documents are only written with -html")
+	case t.report.Images != nil:
+		return t.report.Images.Open(name)
+	}
+	return imaging.Open(filepath.Join(t.report.Dir, name))
+}
+
+// key reads a key press, naming the arrows, page keys, enter and escape
+func (t *tui) key() string {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return "q"
+	}
+	switch b {
+	case '\r', '\n':
+		return "enter"
+	case 3: // Ctrl+C, which raw mode does not turn into a signal
+		return "q"
+	case 0x1b:
+		if t.in.Buffered() == 0 {
+			return "esc"
+		}
+		seq := make([]byte, 0, 4)
+		for t.in.Buffered() > 0 && len(seq) < 4 {
+			c, _ := t.in.ReadByte()
+			seq = append(seq, c)
+			if c >= 'A' && c <= 'Z' || c == '~' {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return "up"
+		case "[B", "OB":
+			return "down"
+		case "[5~":
+			return "pgup"
+		case "[6~":
+			return "pgdown"
+		}
+		return ""
+	}
+	return string(b)
+}
+
+// rawTerminal puts the terminal in raw mode with stty, so the keys are read as they are pressed, and returns the
+// function restoring it
+func rawTerminal() (func(), error) {
+	state, err := sttyOutput("-g")
+	if err == nil {
+		_, err = sttyOutput("raw", "-echo")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-tui needs a terminal with stty: %v", err)
+	}
+	return func() { sttyOutput(strings.TrimSpace(state)) }, nil
+}
+
+// terminalSize returns the rows and columns of the terminal, 24x80 if it does not tell
+func terminalSize() (int, int) {
+	rows, cols := 24, 80
+	if out, err := sttyOutput("size"); err == nil {
+		fmt.Sscan(out, &rows, &cols)
+	}
+	return rows, cols
+}
+
+func sttyOutput(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// detectGraphics chooses how to preview the images from the environment of the terminal, which $PDFDIFF_GRAPHICS
+// (kitty, sixel or blocks) overrides
+func detectGraphics() string {
+	switch env := os.Getenv("PDFDIFF_GRAPHICS"); env {
+	case graphicsKitty, graphicsSixel, graphicsBlocks:
+		return env
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty") || program == "WezTerm" || program == "ghostty":
+		return graphicsKitty
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || strings.HasPrefix(term, "mlterm") || program == "iTerm.app":
+		return graphicsSixel
+	}
+	return graphicsBlocks
+}
+
+// Terminals do not tell the size of their cells, so sixel images are sized for cells of this many pixels
+const (
+	sixelCellWidth  = 8
+	sixelCellHeight = 16
+)
+
+// drawImage draws an image fitting cols x rows cells at the cursor
+func drawImage(out *bufio.Writer, img image.Image, graphics string, cols, rows int) {
+	if cols < 1 || rows < 1 {
+		return
+	}
+	switch graphics {
+	case graphicsKitty:
+		// The terminal scales the image to the cells, so it is only scaled down to spare the bandwidth over SSH
+		img = imaging.Fit(img, 1600, 1600, imaging.Box)
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		data := base64.StdEncoding.EncodeToString(buf.Bytes())
+		for first := true; data != ""; first = false {
+			chunk := data
+			if len(chunk) > 4096 {
+				chunk = chunk[:4096]
+			}
+			data = data[len(chunk):]
+			more := 0
+			if data != "" {
+				more = 1
+			}
+			if first {
+				// The image fills the rows, since pages are taller than wide
+				fmt.Fprintf(out, "\x1b_Ga=T,f=100,r=%d,m=%d;%s\x1b\\", rows, more, chunk)
+			} else {
+				fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+			}
+		}
+	case graphicsSixel:
+		writeSixel(out, imaging.Fit(img, cols*sixelCellWidth, rows*sixelCellHeight, imaging.Box))
+	default:
+		// Every cell shows two pixels, the upper half block in the foreground color and the lower one in the background
+		img = imaging.Fit(img, cols, rows*2, imaging.Box)
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				top := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+				bottom := color.RGBA{255, 255, 255, 255}
+				if y+1 < bounds.Max.Y {
+					bottom = color.RGBAModel.Convert(img.At(x, y+1)).(color.RGBA)
+				}
+				fmt.Fprintf(out, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+			}
+			fmt.Fprint(out, "\x1b[0m\r\n")
+		}
+	}
+}
+
+// writeSixel draws an image with the sixel graphics protocol, in the 216 colors of a 6x6x6 color cube
+func writeSixel(out *bufio.Writer, img image.Image) {
+	bounds := img.Bounds()
+	index := func(x, y int) int {
+		c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+		return int(c.R)*5/255*36 + int(c.G)*5/255*6 + int(c.B)*5/255
+	}
+	fmt.Fprint(out, "\x1bPq")
+	for i := 0; i < 216; i++ {
+		// The colors of sixel are given in percent
+		fmt.Fprintf(out, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	// Every line of sixels is 6 pixels high, drawn once per color it uses, each a run-length encoded line of sixels
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 6 {
+		bands := make(map[int][]byte)
+		var colors []int
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			for dy := 0; dy < 6 && y+dy < bounds.Max.Y; dy++ {
+				c := index(x, y+dy)
+				band, ok := bands[c]
+				if !ok {
+					band = make([]byte, bounds.Dx())
+					colors = append(colors, c)
+				}
+				band[x-bounds.Min.X] |= 1 << dy
+				bands[c] = band
+			}
+		}
+		for i, c := range colors {
+			if i > 0 {
+				out.WriteByte('$')
+			}
+			fmt.Fprintf(out, "#%d", c)
+			band := bands[c]
+			for x := 0; x < len(band); {
+				run := 1
+				for x+run < len(band) && band[x+run] == band[x] {
+					run++
+				}
+				if run > 3 {
+					fmt.Fprintf(out, "!%d%c", run, 63+band[x])
+				} else {
+					out.WriteString(strings.Repeat(string(rune(63+band[x])), run))
+				}
+				x += run
+			}
+		}
+		out.WriteByte('-')
+	}
+	fmt.Fprint(out, "\x1b\\")
+}
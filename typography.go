@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"PdfDiff/pdfdiff"
+)
+
+// printTypography prints the typographic defects the second document introduced, and counts the ones both documents
+// have, which do not fail the run
+func printTypography(report pdfdiff.Report) {
+	kept := 0
+	for _, d := range report.Typography {
+		switch {
+		case d.New:
+			fmt.Printf("NEW %s: page %d of document 2: %s\n", d.Kind, d.Page+1, d.Description)
+		case d.Document == 2:
+			kept++
+		}
+	}
+	if kept > 0 {
+		fmt.Printf("%d typographic defects of the first document are still in the second one\n", kept)
+	}
+	if len(report.NewDefects()) == 0 {
+		fmt.Println("No new typographic defects")
+	}
+}
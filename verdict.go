@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+
+	"PdfDiff/pdfdiff"
+)
+
+// verdictInput is what the exit code of a comparison depends on: the report, how the comparison ended, the findings
+// of the checks that always fail it and the thresholds of the flags
+type verdictInput struct {
+	report pdfdiff.Report
+	// stopped is set when -fail-fast stopped the comparison, and incomplete when its -deadline passed
+	stopped, incomplete bool
+	// failures are the findings of the checks that no threshold excuses, such as the stale thumbnails
+	failures           int
+	minSSIM            float64
+	maxDiffPercent     float64
+	maxPageDiffPercent float64
+	allowChangedPages  int
+}
+
+// verdict prints why the changes of a comparison pass or fail it and returns its exit code: exitIncomplete if the
+// deadline passed, exitDifferent if the changes fail it and exitIdentical otherwise. -min-ssim, or else the budgets
+// of -max-diff-percent and -max-page-diff-percent, decide which pages fail, and -allow-changed-pages how many of them
+// may.
+func verdict(v verdictInput) int {
+	report := v.report
+	if v.stopped {
+		// The pages left cannot change the verdict
+		return exitDifferent
+	}
+	if v.incomplete {
+		// The verdict of the pages left is unknown, whatever the ones compared show
+		fmt.Printf("The comparison is incomplete: %d pages compared, %d with differences\n", len(report.Pages), len(report.ChangedPages()))
+		return exitIncomplete
+	}
+	if v.failures > 0 || len(report.CriticalPages()) > 0 || len(report.FailedChecks()) > 0 || len(report.NewDefects()) > 0 {
+		// Neither -min-ssim, the budgets nor -allow-changed-pages excuse them
+		return exitDifferent
+	}
+	if v.minSSIM > 0 {
+		// Only the perceptually significant changes fail the comparison
+		below := 0
+		for _, page := range report.Pages {
+			if page.SSIM < v.minSSIM && !page.Quarantined {
+				fmt.Printf("Page %d has a structural similarity of %.4f, below %.4f\n", page.Page+1, page.SSIM, v.minSSIM)
+				below++
+			}
+		}
+		if below > v.allowChangedPages {
+			return exitDifferent
+		}
+		return exitIdentical
+	}
+	if v.maxDiffPercent > 0 || v.maxPageDiffPercent > 0 {
+		// Only the changes beyond the budget fail the comparison
+		pages, document := overBudget(report, v.maxDiffPercent, v.maxPageDiffPercent)
+		if document || pages > v.allowChangedPages {
+			return exitDifferent
+		}
+		return exitIdentical
+	}
+	if changed := len(report.ChangedPages()) - changedQuarantined(report); changed > 0 && changed <= v.allowChangedPages {
+		fmt.Printf("%d pages have differences, within the %d allowed by -allow-changed-pages\n", changed, v.allowChangedPages)
+		return exitIdentical
+	}
+	if report.Changed() {
+		return exitDifferent
+	}
+	return exitIdentical
+}
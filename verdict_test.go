@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestVerdict(t *testing.T) {
+	identical := pdfdiff.PageResult{Page: 0, SSIM: 1}
+	small := pdfdiff.PageResult{Page: 1, Changed: true, PercentChanged: 0.2, SSIM: 0.99}
+	large := pdfdiff.PageResult{Page: 2, Changed: true, PercentChanged: 5, SSIM: 0.8}
+	quarantined := pdfdiff.PageResult{Page: 3, Changed: true, PercentChanged: 50, SSIM: 0.5, Quarantined: true}
+	critical := pdfdiff.PageResult{Page: 4, Changed: true, PercentChanged: 0.1, SSIM: 0.99, Critical: []pdfdiff.CriticalChange{{}}}
+	pages := func(pages ...pdfdiff.PageResult) pdfdiff.Report {
+		return pdfdiff.Report{Pages: pages}
+	}
+
+	tests := []struct {
+		name  string
+		input verdictInput
+		want  int
+	}{
+		{"identical", verdictInput{report: pages(identical)}, exitIdentical},
+		{"changed", verdictInput{report: pages(identical, small)}, exitDifferent},
+		{"quarantined only", verdictInput{report: pages(identical, quarantined)}, exitIdentical},
+		{"stopped by -fail-fast", verdictInput{report: pages(identical), stopped: true}, exitDifferent},
+		{"deadline passed", verdictInput{report: pages(small), incomplete: true}, exitIncomplete},
+		{"stale thumbnail", verdictInput{report: pages(identical), failures: 1}, exitDifferent},
+		{"critical region excused by nothing", verdictInput{report: pages(critical), maxPageDiffPercent: 1, allowChangedPages: 1}, exitDifferent},
+		{"failed check", verdictInput{report: pdfdiff.Report{Pages: []pdfdiff.PageResult{identical}, Checks: []pdfdiff.CheckResult{{Passed2: false}}}}, exitDifferent},
+		{"above -min-ssim", verdictInput{report: pages(small), minSSIM: 0.95}, exitIdentical},
+		{"below -min-ssim", verdictInput{report: pages(small, large), minSSIM: 0.95}, exitDifferent},
+		{"below -min-ssim, allowed", verdictInput{report: pages(small, large), minSSIM: 0.95, allowChangedPages: 1}, exitIdentical},
+		{"quarantined below -min-ssim", verdictInput{report: pages(quarantined), minSSIM: 0.95}, exitIdentical},
+		{"within -max-page-diff-percent", verdictInput{report: pages(small), maxPageDiffPercent: 1}, exitIdentical},
+		{"above -max-page-diff-percent", verdictInput{report: pages(small, large), maxPageDiffPercent: 1}, exitDifferent},
+		{"above -max-page-diff-percent, allowed", verdictInput{report: pages(small, large), maxPageDiffPercent: 1, allowChangedPages: 1}, exitIdentical},
+		{"within -max-diff-percent", verdictInput{report: pages(identical, identical, small), maxDiffPercent: 0.5}, exitIdentical},
+		{"above -max-diff-percent", verdictInput{report: pages(identical, large), maxDiffPercent: 0.5}, exitDifferent},
+		{"above -max-diff-percent, pages allowed", verdictInput{report: pages(identical, large), maxDiffPercent: 0.5, allowChangedPages: 5}, exitDifferent},
+		{"within -allow-changed-pages", verdictInput{report: pages(small, large), allowChangedPages: 2}, exitIdentical},
+		{"above -allow-changed-pages", verdictInput{report: pages(small, large), allowChangedPages: 1}, exitDifferent},
+		{"quarantined pages not counted", verdictInput{report: pages(small, quarantined), allowChangedPages: 1}, exitIdentical},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := verdict(test.input); got != test.want {
+				t.Errorf("verdict = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
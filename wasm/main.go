@@ -0,0 +1,181 @@
+//go:build js && wasm
+
+// Command wasm is the WebAssembly build of the comparison, for browsers that compare documents without sending
+// them anywhere. It exposes the global pdfdiff object used by pdfdiff.js:
+//
+//	pdfdiff.compare(doc1, doc2, options) // a Promise of {report, images}
+//
+// A document is {name, data} for a page image (PNG, JPEG, GIF, BMP, TIFF) or an archive of page images (CBZ, ZIP),
+// or {name, pages} with the images of its pages, e.g. the PNG renders of pdf.js. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o pdfdiff.wasm ./wasm
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall/js"
+
+	"PdfDiff/pdfdiff"
+)
+
+// options are the options of a comparison given by JavaScript, named after the Options of pdfdiff
+type options struct {
+	Threshold          int    `json:"threshold"`
+	AdaptiveThreshold  bool   `json:"adaptiveThreshold"`
+	IgnoreAntialiasing bool   `json:"ignoreAntialiasing"`
+	Offset             int    `json:"offset"`
+	StartOffset        int    `json:"startOffset"`
+	Align              bool   `json:"align"`
+	SkipIdentical      bool   `json:"skipIdentical"`
+	ColorOld           string `json:"colorOld"`
+	ColorNew           string `json:"colorNew"`
+	Heatmap            bool   `json:"heatmap"`
+	SideBySide         bool   `json:"sideBySide"`
+	Despeckle          bool   `json:"despeckle"`
+	MinRegionSize      string `json:"minRegionSize"`
+	HighlightStyle     string `json:"highlightStyle"`
+	BoxWidth           int    `json:"boxWidth"`
+}
+
+func main() {
+	js.Global().Set("pdfdiff", js.ValueOf(map[string]interface{}{
+		"version": pdfdiff.SchemaVersion,
+		"compare": js.FuncOf(compare),
+	}))
+	// The functions are called from JavaScript for as long as the page lives
+	select {}
+}
+
+// compare returns a Promise of the comparison of two documents, which runs in a goroutine so the caller's event loop
+// is not blocked by the call
+func compare(this js.Value, args []js.Value) interface{} {
+	var resolve, reject js.Value
+	executor := js.FuncOf(func(this js.Value, promise []js.Value) interface{} {
+		resolve, reject = promise[0], promise[1]
+		return nil
+	})
+	defer executor.Release()
+	promise := js.Global().Get("Promise").New(executor)
+
+	go func() {
+		result, err := run(args)
+		if err != nil {
+			reject.Invoke(js.Global().Get("Error").New(err.Error()))
+			return
+		}
+		resolve.Invoke(result)
+	}()
+	return promise
+}
+
+// run compares the documents and returns the report, as parsed JSON, and the images of the pages by name
+func run(args []js.Value) (js.Value, error) {
+	if len(args) < 2 {
+		return js.Undefined(), fmt.Errorf("compare needs two documents and optional options")
+	}
+	in1, err := input(args[0])
+	if err != nil {
+		return js.Undefined(), err
+	}
+	in2, err := input(args[1])
+	if err != nil {
+		return js.Undefined(), err
+	}
+	var o options
+	if len(args) > 2 && args[2].Truthy() {
+		if err := json.Unmarshal([]byte(js.Global().Get("JSON").Call("stringify", args[2]).String()), &o); err != nil {
+			return js.Undefined(), fmt.Errorf("invalid options: %v", err)
+		}
+	}
+
+	report, err := pdfdiff.CompareInputs(context.Background(), in1, in2, pdfdiff.Options{
+		Threshold:          o.Threshold,
+		AdaptiveThreshold:  o.AdaptiveThreshold,
+		IgnoreAntialiasing: o.IgnoreAntialiasing,
+		Offset:             o.Offset,
+		StartOffset:        o.StartOffset,
+		Align:              o.Align,
+		SkipIdentical:      o.SkipIdentical,
+		ColorOld:           o.ColorOld,
+		ColorNew:           o.ColorNew,
+		Heatmap:            o.Heatmap,
+		SideBySide:         o.SideBySide,
+		Despeckle:          o.Despeckle,
+		MinRegionSize:      o.MinRegionSize,
+		HighlightStyle:     o.HighlightStyle,
+		BoxWidth:           o.BoxWidth,
+		InMemory:           true,
+	})
+	if err != nil {
+		return js.Undefined(), err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	images := js.Global().Get("Object").New()
+	for _, page := range report.Pages {
+		for _, name := range []string{page.DiffImage, page.CombinedImage} {
+			if name != "" {
+				images.Set(name, uint8Array(report.Images.Bytes(name)))
+			}
+		}
+	}
+	result := js.Global().Get("Object").New()
+	result.Set("report", js.Global().Get("JSON").Call("parse", string(data)))
+	result.Set("images", images)
+	return result, nil
+}
+
+// input reads a document given by JavaScript. The images of its pages are packed as a CBZ archive, in order, named
+// after the document.
+func input(doc js.Value) (pdfdiff.Input, error) {
+	name := doc.Get("name")
+	if name.Type() != js.TypeString || name.String() == "" {
+		return pdfdiff.Input{}, fmt.Errorf("every document needs a name, whose extension tells its format")
+	}
+	if pages := doc.Get("pages"); pages.Truthy() {
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		for i := 0; i < pages.Length(); i++ {
+			// The images are already compressed
+			w, err := archive.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("page_%03d.png", i+1), Method: zip.Store})
+			if err != nil {
+				return pdfdiff.Input{}, err
+			}
+			if _, err := w.Write(goBytes(pages.Index(i))); err != nil {
+				return pdfdiff.Input{}, err
+			}
+		}
+		if err := archive.Close(); err != nil {
+			return pdfdiff.Input{}, err
+		}
+		base := strings.TrimSuffix(name.String(), filepath.Ext(name.String()))
+		return pdfdiff.BytesInput(base+".cbz", buf.Bytes()), nil
+	}
+	return pdfdiff.BytesInput(name.String(), goBytes(doc.Get("data"))), nil
+}
+
+// goBytes copies a Uint8Array
+func goBytes(v js.Value) []byte {
+	if !v.Truthy() {
+		return nil
+	}
+	data := make([]byte, v.Get("length").Int())
+	js.CopyBytesToGo(data, v)
+	return data
+}
+
+// uint8Array copies bytes to a new Uint8Array
+func uint8Array(data []byte) js.Value {
+	v := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(v, data)
+	return v
+}
@@ -0,0 +1,40 @@
+// JavaScript bindings of the WebAssembly build of PdfDiffGo, which compares documents in the browser without sending
+// them anywhere. Load wasm_exec.js from $(go env GOROOT)/lib/wasm (misc/wasm before Go 1.24) first, then:
+//
+//   import { load, renderPDF } from "./pdfdiff.js";
+//   const pdfdiff = await load("pdfdiff.wasm");
+//   const { report, images } = await pdfdiff.compare(
+//     { name: "old.pdf", pages: await renderPDF(pdfjsLib, oldBytes) },
+//     { name: "new.pdf", pages: await renderPDF(pdfjsLib, newBytes) },
+//     { threshold: 8 });
+//
+// report is the JSON report of the comparison and images the PNG difference images by name, as in report.pages.
+
+// load instantiates the WebAssembly build and returns its pdfdiff object
+export async function load(url = "pdfdiff.wasm") {
+  const go = new Go();
+  const { instance } = await WebAssembly.instantiateStreaming(fetch(url), go.importObject);
+  go.run(instance);
+  return globalThis.pdfdiff;
+}
+
+// renderPDF renders every page of a PDF with pdf.js at the given resolution, on a white background, and returns the
+// PNG images of the pages. MuPDF cannot run in the browser, so the pages of PDFs are compared as rendered by pdf.js.
+export async function renderPDF(pdfjsLib, data, dpi = 150) {
+  const pdf = await pdfjsLib.getDocument({ data }).promise;
+  const pages = [];
+  for (let i = 1; i <= pdf.numPages; i++) {
+    const page = await pdf.getPage(i);
+    const viewport = page.getViewport({ scale: dpi / 72 });
+    const canvas = document.createElement("canvas");
+    canvas.width = Math.ceil(viewport.width);
+    canvas.height = Math.ceil(viewport.height);
+    const context = canvas.getContext("2d");
+    context.fillStyle = "#ffffff";
+    context.fillRect(0, 0, canvas.width, canvas.height);
+    await page.render({ canvasContext: context, viewport }).promise;
+    const blob = await new Promise((resolve) => canvas.toBlob(resolve, "image/png"));
+    pages.push(new Uint8Array(await blob.arrayBuffer()));
+  }
+  return pages;
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often the watched inputs are checked for changes
+const watchInterval = 500 * time.Millisecond
+
+// watch compares the documents again every time one of them changes, e.g. while a LaTeX template is being edited,
+// until the tool is interrupted. Every comparison runs the tool again with the same flags and inputs, so each one
+// starts afresh; the watched files are the inputs once fetched, e.g. the directory of a baseline.
+func watch(flags *flag.FlagSet, inputs, files []string) {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	args := []string{"compare"}
+	workdir := false
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "workdir", "outdir", "in-memory":
+			workdir = workdir || f.Name != "in-memory" || f.Value.String() == "true"
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		case "watch", "interactive", "tui":
+		case "ignore-text":
+			// Every pattern is given with a flag of its own
+			for _, pattern := range *f.Value.(*patternList) {
+				args = append(args, "-ignore-text="+pattern)
+			}
+		default:
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	if !workdir {
+		// Every comparison replaces the images of the previous one instead of leaving a new directory behind
+		dir, err := os.MkdirTemp("", "pdfdiff-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitOutput)
+		}
+		args = append(args, "-workdir="+dir)
+	}
+	args = append(args, "--")
+	args = append(args, inputs...)
+
+	fmt.Printf("Watching %s, press Ctrl+C to stop\n", strings.Join(files, " and "))
+	state := inputState(files)
+	for {
+		cmd := exec.Command(exe, args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			fmt.Println("The documents are identical")
+		case errors.As(err, &exitErr) && exitErr.ExitCode() == exitDifferent:
+			fmt.Println("The documents have differences")
+		case errors.As(err, &exitErr):
+			fmt.Printf("The comparison failed (exit code %d)\n", exitErr.ExitCode())
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		fmt.Printf("%s: waiting for %s to change...\n", time.Now().Format("15:04:05"), strings.Join(files, " or "))
+
+		// Wait for a change, then for the inputs to stay the same for an interval, so a document still being
+		// written is not compared
+		for {
+			time.Sleep(watchInterval)
+			if current := inputState(files); current != state {
+				state = current
+				break
+			}
+		}
+		for {
+			time.Sleep(watchInterval)
+			current := inputState(files)
+			if current == state {
+				break
+			}
+			state = current
+		}
+		fmt.Printf("\n%s: the documents changed, comparing again\n", time.Now().Format("15:04:05"))
+	}
+}
+
+// inputState describes the size and modification time of the inputs, and of the files of the input directories
+func inputState(files []string) string {
+	var b strings.Builder
+	for _, file := range files {
+		filepath.Walk(file, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Fprintf(&b, "%s missing\n", path)
+				return nil
+			}
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+	}
+	return b.String()
+}
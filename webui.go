@@ -0,0 +1,193 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed webui.html.tmpl
+var webUITemplate string
+
+var webUI = template.Must(template.New("webui").Parse(webUITemplate))
+
+// uiRescanInterval is how long the web UI lists the comparisons found by a scan of its directory before scanning it
+// again, so the requests, whatever they ask for, walk the tree at most this often
+const uiRescanInterval = 30 * time.Second
+
+// resultsUI serves a web UI listing the comparisons whose artifacts are below a directory, with a viewer to page
+// through their images, for reviewers who would rather not open a folder of PNGs
+type resultsUI struct {
+	root string
+
+	// mu is held during the scans, so the requests arriving meanwhile wait for it rather than scan too
+	mu          sync.Mutex
+	scanned     time.Time
+	list        []uiComparison
+	comparisons map[string]*runManifest // by directory relative to root, with slashes
+}
+
+// uiComparison is a comparison of the list of the web UI
+type uiComparison struct {
+	ID       string
+	Manifest *runManifest
+	Changed  int
+	Modified time.Time
+}
+
+// uiPage is a page of the viewer of the web UI, with the URLs of its difference image and of the pages of the first
+// (A) and second (B) documents, which are only written by comparisons with -html
+type uiPage struct {
+	Number  int
+	Changed bool
+	Status  string
+	Diff    string
+	A, B    string
+}
+
+// newResultsUI returns the handler of the web UI of the comparisons below root
+func newResultsUI(root string) http.Handler {
+	ui := &resultsUI{root: root}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ui.index)
+	mux.HandleFunc("/view/", ui.view)
+	mux.HandleFunc("/image/", ui.image)
+	return mux
+}
+
+// scan finds the manifests of the comparisons below the root directory, the most recent first
+func (ui *resultsUI) scan() ([]uiComparison, map[string]*runManifest) {
+	var list []uiComparison
+	found := make(map[string]*runManifest)
+	filepath.Walk(ui.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != manifestName {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		m, err := readManifest(dir)
+		if err != nil {
+			return nil
+		}
+		// The comparison of the root directory itself has an empty ID
+		id, _ := filepath.Rel(ui.root, dir)
+		if id = filepath.ToSlash(id); id == "." {
+			id = ""
+		}
+		found[id] = m
+		list = append(list, uiComparison{ID: id, Manifest: m, Changed: len(m.ChangedPages()), Modified: info.ModTime()})
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Modified.After(list[j].Modified) })
+	return list, found
+}
+
+// refresh returns the comparisons found by the last scan, scanning again once it is uiRescanInterval old
+func (ui *resultsUI) refresh() ([]uiComparison, map[string]*runManifest) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	if ui.comparisons == nil || time.Since(ui.scanned) >= uiRescanInterval {
+		ui.list, ui.comparisons = ui.scan()
+		ui.scanned = time.Now()
+	}
+	return ui.list, ui.comparisons
+}
+
+// invalidate drops the last scan, so the next request scans again and finds a comparison whose manifest was just
+// written
+func (ui *resultsUI) invalidate() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+	ui.list, ui.comparisons = nil, nil
+	ui.scanned = time.Time{}
+}
+
+// comparison returns the manifest of a comparison, or nil if the last scan did not find it
+func (ui *resultsUI) comparison(id string) *runManifest {
+	_, comparisons := ui.refresh()
+	return comparisons[id]
+}
+
+func (ui *resultsUI) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	data := struct {
+		Root        string
+		Comparisons []uiComparison
+	}{Root: ui.root}
+	data.Comparisons, _ = ui.refresh()
+	if err := webUI.ExecuteTemplate(w, "index", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// view shows the viewer of a comparison, /view/<id>
+func (ui *resultsUI) view(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/view/")
+	m := ui.comparison(id)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := struct {
+		Manifest *runManifest
+		Changed  int
+		Pages    []uiPage
+	}{Manifest: m, Changed: len(m.ChangedPages())}
+	url := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return path.Join("/image", id, name)
+	}
+	for _, page := range m.Pages {
+		if page.DiffImage == "" {
+			continue
+		}
+		status := fmt.Sprintf("%.2f%% changed", page.PercentChanged)
+		switch {
+		case !page.Changed:
+			status = "identical"
+		case page.MissingIn != 0:
+			status = fmt.Sprintf("missing in document %d", page.MissingIn)
+		}
+		data.Pages = append(data.Pages, uiPage{Number: page.Page + 1, Changed: page.Changed, Status: status,
+			Diff: url(page.DiffImage), A: url(page.Image1), B: url(page.Image2)})
+	}
+	if err := webUI.ExecuteTemplate(w, "viewer", data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// image serves an image of a comparison, /image/<id>/<name>. Only the images listed in its manifest are served,
+// so no other file can be read.
+func (ui *resultsUI) image(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/image/")
+	id, name := "", rest
+	if i := strings.LastIndex(rest, "/"); i >= 0 {
+		id, name = rest[:i], rest[i+1:]
+	}
+	m := ui.comparison(id)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, page := range m.Pages {
+		for _, image := range []string{page.DiffImage, page.CombinedImage, page.Image1, page.Image2} {
+			if image != "" && image == name {
+				http.ServeFile(w, r, filepath.Join(m.Dir, image))
+				return
+			}
+		}
+	}
+	http.NotFound(w, r)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"PdfDiff/pdfdiff"
+)
+
+func TestResultsUIRescan(t *testing.T) {
+	root := t.TempDir()
+	addComparison := func(id string) {
+		dir := filepath.Join(root, id)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeManifest(dir, &runManifest{Report: pdfdiff.Report{SchemaVersion: pdfdiff.SchemaVersion}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addComparison("a")
+	ui := &resultsUI{root: root}
+	if ui.comparison("a") == nil {
+		t.Fatal("comparison a not found")
+	}
+	scanned := ui.scanned
+
+	// Neither a new comparison nor an unknown one scans the directory again before uiRescanInterval
+	addComparison("b")
+	for _, path := range []string{"/", "/view/b", "/view/unknown"} {
+		ui.index(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		ui.view(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if !ui.scanned.Equal(scanned) {
+		t.Errorf("the directory was scanned again after %s", ui.scanned.Sub(scanned))
+	}
+	if ui.comparison("b") != nil {
+		t.Error("comparison b found before the next scan")
+	}
+
+	ui.scanned = ui.scanned.Add(-uiRescanInterval)
+	if ui.comparison("b") == nil {
+		t.Error("comparison b not found by the next scan")
+	}
+
+	// A comparison written by the process itself is found at once
+	addComparison("c")
+	ui.invalidate()
+	if ui.comparison("c") == nil {
+		t.Error("comparison c not found once the scan is invalidated")
+	}
+}